@@ -45,6 +45,9 @@ writeShellApplication {
 
       REGIONS=us,tw,kr,eu
 
+      # where to publish the static API, e.g. s3://bucket/api (optional)
+      API_TARGET="''${API_TARGET:-}"
+
       DB_PATH="db/current.db"
       HASH_PATH="db/current.db.sha256"
       LOCK_PATH="db/db.lock"
@@ -109,8 +112,11 @@ writeShellApplication {
         fi
 
         # verify required environment variables
-        if [ -z "''${BLIZZARD_API_TOKEN:-}" ]; then
-          log abort "Environment" "BLIZZARD_API_TOKEN not set"
+        if [ -z "''${BLIZZARD_CLIENT_ID:-}" ]; then
+          log abort "Environment" "BLIZZARD_CLIENT_ID not set"
+        fi
+        if [ -z "''${BLIZZARD_CLIENT_SECRET:-}" ]; then
+          log abort "Environment" "BLIZZARD_CLIENT_SECRET not set"
         fi
 
         if [ "$DRY_RUN" = false ]; then
@@ -351,6 +357,24 @@ writeShellApplication {
         fi
       }
 
+      # === STEP 7: PUBLISH STATIC API ===
+      publishAPI() {
+        if [ -z "$API_TARGET" ]; then
+          log info "Publish" "API_TARGET not set, skipping"
+          return
+        fi
+
+        log info "Publish" "Publishing static API to $API_TARGET"
+
+        publish_args=(--target "$API_TARGET" --out "$API_OUT" --precompress gzip,br --concurrency "$CONCURRENCY")
+        if [ "$DRY_RUN" = true ]; then
+          publish_args+=(--dry-run)
+        fi
+        ookstats publish "''${publish_args[@]}"
+
+        log info "Publish" "Static API published"
+      }
+
       # VERCEL DEPLOYMENT
       vercelDeploy() {
         log info "Vercel" "Deploying to Vercel ($ENVIRONMENT)"
@@ -405,6 +429,7 @@ writeShellApplication {
         buildPipeline
         dbIntegrityCheck
         uploadDB
+        publishAPI
         vercelDeploy
         finish
       }
@@ -24,7 +24,7 @@ in
     version = "0.2.0";
     src = ./src;
 
-    vendorHash = "sha256-DHj4DTsXkFQZUimMrnqYc6RVTfsbukBmUcYt/dRwKUo=";
+    vendorHash = "sha256-ygXkBB5YFUzfmPuqDMZ6QbIff5DhGAf+ZN3wAqH6cu4=";
 
     nativeBuildInputs = [gcc installShellFiles makeWrapper];
 
@@ -16,6 +16,7 @@ import (
 	"github.com/spf13/cobra"
 	"ookstats/internal/blizzard"
 	"ookstats/internal/database"
+	"ookstats/internal/pipeline"
 )
 
 // analyzeCmd summarizes CM fetch coverage to power the status API.
@@ -38,9 +39,16 @@ var analyzeCmd = &cobra.Command{
 		}
 		defer db.Close()
 
-		_, dungeons := blizzard.GetHardcodedPeriodAndDungeons()
+		dbService := database.NewDatabaseService(db)
+		dungeons, err := pipeline.ResolveDungeons(cmd.Context(), dbService, nil, nil)
+		if err != nil {
+			return fmt.Errorf("resolve dungeons: %w", err)
+		}
 
-		realms := blizzard.GetAllRealms()
+		realms, err := pipeline.LoadRealms(dbService)
+		if err != nil {
+			return err
+		}
 		if strings.TrimSpace(regionsCSV) != "" {
 			allowed := map[string]bool{}
 			for _, r := range strings.Split(regionsCSV, ",") {
@@ -98,17 +106,26 @@ type statusDungeonEntry struct {
 	Periods      []int  `json:"periods"`
 	Missing      []int  `json:"missing_periods"`
 	ErrorPeriods []int  `json:"error_periods"`
+	// Truncated lists fetched periods whose leaderboard hit the entry cap
+	Truncated []statusTruncatedPeriod `json:"truncated_periods"`
+}
+
+// statusTruncatedPeriod is a period where runs slower than CutoffDuration may be missing
+type statusTruncatedPeriod struct {
+	Period         int `json:"period"`
+	CutoffDuration int `json:"cutoff_duration"`
 }
 
 type statusRealmEntry struct {
-	Region         string               `json:"region"`
-	RealmSlug      string               `json:"realm_slug"`
-	RealmName      string               `json:"realm_name"`
-	Health         string               `json:"health"`
-	TotalPeriods   int                  `json:"total_periods"`
-	MissingPeriods int                  `json:"missing_periods"`
-	ErrorPeriods   int                  `json:"error_periods"`
-	Dungeons       []statusDungeonEntry `json:"dungeons"`
+	Region           string               `json:"region"`
+	RealmSlug        string               `json:"realm_slug"`
+	RealmName        string               `json:"realm_name"`
+	Health           string               `json:"health"`
+	TotalPeriods     int                  `json:"total_periods"`
+	MissingPeriods   int                  `json:"missing_periods"`
+	ErrorPeriods     int                  `json:"error_periods"`
+	TruncatedPeriods int                  `json:"truncated_periods"`
+	Dungeons         []statusDungeonEntry `json:"dungeons"`
 }
 
 type statusPayload struct {
@@ -144,10 +161,11 @@ func runAnalyze(db *sql.DB, realms map[string]blizzard.RealmInfo, dungeons []bli
 	}
 
 	type dungeonAgg struct {
-		info    blizzard.DungeonInfo
-		periods []int
-		missing []int
-		errors  []int
+		info      blizzard.DungeonInfo
+		periods   []int
+		missing   []int
+		errors    []int
+		truncated []statusTruncatedPeriod
 	}
 	type realmAgg struct {
 		info     blizzard.RealmInfo
@@ -156,7 +174,7 @@ func runAnalyze(db *sql.DB, realms map[string]blizzard.RealmInfo, dungeons []bli
 
 	agg := make(map[string]*realmAgg)
 
-	rows, err := db.Query(`SELECT region, realm_slug, dungeon_id, period_id, status FROM fetch_status`)
+	rows, err := db.Query(`SELECT region, realm_slug, dungeon_id, period_id, status, COALESCE(cutoff_duration, 0) FROM fetch_status`)
 	if err != nil {
 		return fmt.Errorf("query fetch_status: %w", err)
 	}
@@ -166,7 +184,8 @@ func runAnalyze(db *sql.DB, realms map[string]blizzard.RealmInfo, dungeons []bli
 		var region, realmSlug string
 		var dungeonID, periodID int
 		var status string
-		if err := rows.Scan(&region, &realmSlug, &dungeonID, &periodID, &status); err != nil {
+		var cutoff int
+		if err := rows.Scan(&region, &realmSlug, &dungeonID, &periodID, &status, &cutoff); err != nil {
 			return fmt.Errorf("scan fetch_status: %w", err)
 		}
 		if filterPeriods && !allowedPeriods[periodID] {
@@ -196,8 +215,12 @@ func runAnalyze(db *sql.DB, realms map[string]blizzard.RealmInfo, dungeons []bli
 			ra.dungeons[dungeonID] = dagg
 		}
 		switch strings.ToLower(status) {
-		case "ok":
+		case "ok", "unchanged":
 			dagg.periods = append(dagg.periods, periodID)
+		case "truncated":
+			// the data is there, but slower runs past the cap may be missing
+			dagg.periods = append(dagg.periods, periodID)
+			dagg.truncated = append(dagg.truncated, statusTruncatedPeriod{Period: periodID, CutoffDuration: cutoff})
 		case "missing":
 			dagg.missing = append(dagg.missing, periodID)
 		case "error":
@@ -225,6 +248,7 @@ func runAnalyze(db *sql.DB, realms map[string]blizzard.RealmInfo, dungeons []bli
 	})
 
 	realmsOut := make([]statusRealmEntry, 0, len(realmKeys))
+	totalTruncated := 0
 	for _, key := range realmKeys {
 		ra := agg[key]
 		realmEntry := statusRealmEntry{
@@ -232,7 +256,7 @@ func runAnalyze(db *sql.DB, realms map[string]blizzard.RealmInfo, dungeons []bli
 			RealmSlug: ra.info.Slug,
 			RealmName: ra.info.Name,
 		}
-		var realmMissing, realmErrors int
+		var realmMissing, realmErrors, realmTruncated int
 
 		dungeonIDs := make([]int, 0, len(ra.dungeons))
 		for id := range ra.dungeons {
@@ -245,6 +269,7 @@ func runAnalyze(db *sql.DB, realms map[string]blizzard.RealmInfo, dungeons []bli
 			sort.Ints(dagg.periods)
 			sort.Ints(dagg.missing)
 			sort.Ints(dagg.errors)
+			sort.Slice(dagg.truncated, func(i, j int) bool { return dagg.truncated[i].Period < dagg.truncated[j].Period })
 
 			status := coverageStatus(len(dagg.periods), len(dagg.missing), len(dagg.errors))
 			entry := statusDungeonEntry{
@@ -255,15 +280,19 @@ func runAnalyze(db *sql.DB, realms map[string]blizzard.RealmInfo, dungeons []bli
 				Periods:      append([]int(nil), dagg.periods...),
 				Missing:      append([]int(nil), dagg.missing...),
 				ErrorPeriods: append([]int(nil), dagg.errors...),
+				Truncated:    append([]statusTruncatedPeriod(nil), dagg.truncated...),
 			}
 			realmEntry.Dungeons = append(realmEntry.Dungeons, entry)
 			realmEntry.TotalPeriods += len(dagg.periods)
 			realmMissing += len(dagg.missing)
 			realmErrors += len(dagg.errors)
+			realmTruncated += len(dagg.truncated)
 		}
 
 		realmEntry.MissingPeriods = realmMissing
 		realmEntry.ErrorPeriods = realmErrors
+		realmEntry.TruncatedPeriods = realmTruncated
+		totalTruncated += realmTruncated
 		realmEntry.Health = coverageStatus(realmEntry.TotalPeriods, realmMissing, realmErrors)
 		realmsOut = append(realmsOut, realmEntry)
 	}
@@ -312,6 +341,9 @@ func runAnalyze(db *sql.DB, realms map[string]blizzard.RealmInfo, dungeons []bli
 		log.Info("wrote per-realm status files", "dir", statusDir, "count", len(realmsOut))
 	}
 
+	if totalTruncated > 0 {
+		log.Warn("leaderboards at the entry cap, rankings may be incomplete", "cells", totalTruncated, "cap", blizzard.LeaderboardEntryCap)
+	}
 	log.Info("status coverage generated", "realms", len(realmsOut))
 	return nil
 }
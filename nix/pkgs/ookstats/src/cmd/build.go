@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,15 +17,32 @@ import (
 	"ookstats/internal/blizzard"
 	"ookstats/internal/database"
 	"ookstats/internal/generator"
-	"ookstats/internal/generator/indexes"
 	"ookstats/internal/pipeline"
 )
 
-// buildCmd orchestrates a full from-scratch rebuild + static API generation
+// buildCmd orchestrates a full rebuild + static API generation as a graph of stages
 var buildCmd = &cobra.Command{
 	Use:   "build",
 	Short: "Full rebuild of database and static API",
-	Long:  `From-scratch hourly rebuild: init schema, fetch CM runs (period sweep), process players + rankings, fetch profiles, and generate static API.`,
+	Long: `Hourly rebuild: init schema, then run the build stages in dependency order:
+
+  seasons         season metadata, period boundaries and the realm registry
+  fetch           CM leaderboards (period sweep)                  needs seasons
+  assign-seasons  season of each run by timestamp                 needs fetch
+  fingerprints    identity detection + merge                      needs fetch
+  process         run rankings, player aggregations and rankings  needs assign-seasons, fingerprints
+  profiles        detailed player profiles                        needs process
+  rankings        ranking pass over marks written since process   needs process, profiles
+  generate        static API (changed files only)                 needs rankings, profiles
+  status          status API via analyze                          needs fetch
+  manifest        api/manifest.json of sizes and content hashes   needs generate, status
+
+Each stage's completion and input hash are stored in the database. --resume skips
+stages whose inputs are unchanged and that finished after their dependencies, so a
+build that failed at profiles continues there. Stages that read the API (seasons,
+fetch, profiles) are only skipped within an hour of finishing, and seasons and fetch
+run again once a new keystone period has started. --only runs just the listed stages;
+--from runs a stage and everything downstream of it.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		outDir, _ := cmd.Flags().GetString("out")
 		if strings.TrimSpace(outDir) == "" {
@@ -37,18 +56,54 @@ var buildCmd = &cobra.Command{
 		}
 
 		fromScratch, _ := cmd.Flags().GetBool("from-scratch")
-		regionsCSV, _ := cmd.Flags().GetString("regions")
-		pageSize, _ := cmd.Flags().GetInt("page-size")
-		shardSize, _ := cmd.Flags().GetInt("shard-size")
 		wowsimsDB, _ := cmd.Flags().GetString("wowsims-db")
-		skipProfiles, _ := cmd.Flags().GetBool("skip-profiles")
-		periodsCSV, _ := cmd.Flags().GetString("periods")
-		concurrency, _ := cmd.Flags().GetInt("concurrency")
-		workers, _ := cmd.Flags().GetInt("workers")
-		latestPeriodsOnly, _ := cmd.Flags().GetBool("latest-periods")
+		resume, _ := cmd.Flags().GetBool("resume")
+		onlyCSV, _ := cmd.Flags().GetString("only")
+		from, _ := cmd.Flags().GetString("from")
+
+		opts := buildOptions{out: normalizedOut}
+		opts.regionsCSV, _ = cmd.Flags().GetString("regions")
+		opts.pageSize, _ = cmd.Flags().GetInt("page-size")
+		opts.shardSize, _ = cmd.Flags().GetInt("shard-size")
+		opts.skipProfiles, _ = cmd.Flags().GetBool("skip-profiles")
+		opts.periodsCSV, _ = cmd.Flags().GetString("periods")
+		opts.concurrency, _ = cmd.Flags().GetInt("concurrency")
+		opts.workers, _ = cmd.Flags().GetInt("workers")
+		opts.latestPeriodsOnly, _ = cmd.Flags().GetBool("latest-periods")
+		opts.fullRankings, _ = cmd.Flags().GetBool("full-rankings")
 
 		// optional verbose logging propagated to API client
-		verbose, _ := cmd.InheritedFlags().GetBool("verbose")
+		opts.verbose, _ = cmd.InheritedFlags().GetBool("verbose")
+
+		stageOpts := pipeline.StageOptions{Resume: resume, From: strings.TrimSpace(from)}
+		for _, name := range strings.Split(onlyCSV, ",") {
+			if trimmed := strings.TrimSpace(name); trimmed != "" {
+				stageOpts.Only = append(stageOpts.Only, trimmed)
+			}
+		}
+		if fromScratch && (resume || len(stageOpts.Only) > 0 || stageOpts.From != "") {
+			return errors.New("--from-scratch deletes the database; it cannot be combined with --resume, --only or --from")
+		}
+
+		// Parse regions for filter
+		if strings.TrimSpace(opts.regionsCSV) != "" {
+			for _, r := range strings.Split(opts.regionsCSV, ",") {
+				if trimmed := strings.TrimSpace(r); trimmed != "" {
+					opts.regions = append(opts.regions, trimmed)
+				}
+			}
+		}
+
+		// Parse periods (if provided)
+		if strings.TrimSpace(opts.periodsCSV) != "" {
+			var err error
+			opts.periods, err = blizzard.ParsePeriods(opts.periodsCSV)
+			if err != nil {
+				return fmt.Errorf("failed to parse periods: %w", err)
+			}
+		}
+
+		ctx := cmd.Context()
 
 		// Handle from-scratch (file DSN only)
 		if fromScratch {
@@ -66,7 +121,7 @@ var buildCmd = &cobra.Command{
 			}
 		}
 
-		// 1) Schema init
+		// Schema init
 		db, err := database.Connect()
 		if err != nil {
 			return fmt.Errorf("db connect: %w", err)
@@ -77,7 +132,7 @@ var buildCmd = &cobra.Command{
 			return fmt.Errorf("schema init: %w", err)
 		}
 
-		// 2) Populate items (embedded default; file can override)
+		// Populate items (embedded default; file can override)
 		log.Info("populating items", "source", func() string {
 			if wowsimsDB != "" {
 				return "file override"
@@ -88,160 +143,365 @@ var buildCmd = &cobra.Command{
 			return fmt.Errorf("populate items: %w", err)
 		}
 
-		// 3) Initialize Blizzard API client
-		client, err := blizzard.NewClient()
-		if err != nil {
-			return fmt.Errorf("blizzard client: %w", err)
-		}
-		client.Verbose = verbose
-		if concurrency > 0 {
-			client.SetConcurrency(concurrency)
-		}
+		// control database-internal verbosity (hide 404 noise unless verbose)
+		database.SetVerbose(opts.verbose)
 
-		// 4) Sync season metadata from API
-		log.Info("syncing season metadata")
-		if err := syncSeasons(db, client, regionsCSV); err != nil {
-			return fmt.Errorf("sync seasons: %w", err)
+		dbService := database.NewDatabaseService(db)
+		stages, closeClient := buildStages(db, dbService, opts)
+		defer closeClient()
+		result, err := pipeline.RunStages(ctx, dbService, stages, stageOpts)
+		if err != nil {
+			if err == ctx.Err() {
+				// stopped between stages; the finished ones are recorded for --resume
+				return interruptedError(ctx, "build")
+			}
+			return err
 		}
 
-		// 5) Fetch CM runs using pipeline (includes child realm filtering)
-		log.Info("fetching challenge mode leaderboards", "sweep", "global period")
+		// Print summary
+		summarizeBuild(db, normalizedOut)
 
-		dbService := database.NewDatabaseService(db)
+		log.Info("build complete",
+			"api_location", normalizedOut+"/api",
+			"ran", strings.Join(result.Ran, ","),
+			"up_to_date", strings.Join(result.Skipped, ","))
+		return nil
+	},
+}
 
-		// control database-internal verbosity (hide 404 noise unless verbose)
-		database.SetVerbose(verbose)
+// buildOptions are the build flags the stages read
+type buildOptions struct {
+	out               string
+	regionsCSV        string
+	regions           []string
+	periodsCSV        string
+	periods           []string
+	latestPeriodsOnly bool
+	concurrency       int
+	workers           int
+	pageSize          int
+	shardSize         int
+	skipProfiles      bool
+	fullRankings      bool
+	verbose           bool
+}
 
-		// Parse regions for filter
-		var regions []string
-		if strings.TrimSpace(regionsCSV) != "" {
-			for _, r := range strings.Split(regionsCSV, ",") {
-				if trimmed := strings.TrimSpace(r); trimmed != "" {
-					regions = append(regions, trimmed)
-				}
+// apiStageMaxAge is how long --resume trusts a finished stage that read the API,
+// one build interval
+const apiStageMaxAge = time.Hour
+
+// buildStages declares the build graph. Stages that talk to the API share one client,
+// created the first time one of them runs and released by the returned close func.
+func buildStages(db *sql.DB, dbService *database.DatabaseService, opts buildOptions) ([]pipeline.Stage, func()) {
+	var client *blizzard.Client
+	closeClient := func() {
+		if client != nil {
+			if err := client.Close(); err != nil {
+				log.Warn("failed to close API client", "error", err)
 			}
 		}
-
-		// Parse periods (if provided)
-		var periods []string
-		if strings.TrimSpace(periodsCSV) != "" {
-			var err error
-			periods, err = blizzard.ParsePeriods(periodsCSV)
-			if err != nil {
-				return fmt.Errorf("failed to parse periods: %w", err)
-			}
-		}
-
-		// Use pipeline function (handles child realm filtering automatically)
-		fetchOpts := pipeline.FetchCMOptions{
-			Verbose:           verbose,
-			Regions:           regions,
-			Realms:            []string{}, // no realm filter
-			Dungeons:          []string{}, // no dungeon filter
-			Periods:           periods,    // empty means fetch dynamically
-			LatestPeriodsOnly: latestPeriodsOnly,
-			Concurrency:       concurrency,
-			Timeout:           45 * time.Minute,
+	}
+	apiClient := func() (*blizzard.Client, error) {
+		if client != nil {
+			return client, nil
 		}
-
-		result, err := pipeline.FetchChallengeMode(dbService, client, fetchOpts)
+		c, err := blizzard.NewClient()
 		if err != nil {
-			return fmt.Errorf("fetch challenge mode: %w", err)
+			return nil, fmt.Errorf("blizzard client: %w", err)
 		}
-
-		log.Info("fetch complete",
-			"runs", result.TotalRuns,
-			"players", result.TotalPlayers,
-			"duration", result.Duration)
-
-		// 4) Assign seasons to runs based on timestamps
-		log.Info("assigning seasons to runs", "method", "timestamp-based")
-		if err := dbService.AssignRunsToSeasons(); err != nil {
-			return fmt.Errorf("assign seasons: %w", err)
+		c.Verbose = opts.verbose
+		if opts.concurrency > 0 {
+			c.SetConcurrency(opts.concurrency)
 		}
+		client = c
+		return client, nil
+	}
 
-		// 5) Fingerprint players (merge duplicates)
-		log.Info("fingerprinting players", "stage", "identity detection + merge")
-		if err := fingerprintPlayersOnce(db, client); err != nil {
-			return err
+	dirtyRankings := func(context.Context) ([]string, error) {
+		partitions, players, err := dbService.CountDirtyRankings()
+		if err != nil {
+			return nil, err
 		}
+		return []string{fmt.Sprintf("dirty=%d:%d", partitions, players)}, nil
+	}
 
-		// 6) Process players (aggregations + rankings)
-		log.Info("processing players", "stage", "aggregations + rankings")
-		if err := processPlayersOnce(db); err != nil {
-			return err
+	// the keystone period each built region is in; a new week brings new periods,
+	// seasons and leaderboards
+	currentPeriods := func() (string, error) {
+		current, err := dbService.CurrentPeriodIDs(time.Now().UnixMilli())
+		if err != nil {
+			return "", err
 		}
-
-		// 7) Fetch detailed player profiles (optional)
-		if !skipProfiles {
-			log.Info("fetching detailed player profiles", "coverage", "9/9")
-			if err := fetchProfilesOnce(db, client); err != nil {
-				return err
+		regions := opts.regions
+		if len(regions) == 0 {
+			for region := range current {
+				regions = append(regions, region)
 			}
-		} else {
-			log.Info("skipping player profile fetch", "reason", "flag")
+			sort.Strings(regions)
 		}
-
-		// 8) Process run rankings (global/regional)
-		log.Info("processing run rankings", "scopes", "global + regional")
-		if err := processRunRankingsOnce(db); err != nil {
-			return err
+		parts := make([]string, 0, len(regions))
+		for _, region := range regions {
+			parts = append(parts, fmt.Sprintf("%s:%d", region, current[region]))
 		}
+		return "current_periods=" + strings.Join(parts, ","), nil
+	}
 
-		// 9) Generate static API
-		log.Info("generating static API")
-		if err := generateAllAPI(db, normalizedOut, pageSize, shardSize, workers, regionsCSV); err != nil {
-			return err
-		}
+	profilesDisabled := ""
+	if opts.skipProfiles {
+		profilesDisabled = "--skip-profiles"
+	}
 
-		// 10) Generate status API via analyze
-		log.Info("generating status API", "method", "analyze")
-		statusDir := filepath.Join(normalizedOut, "api", "status")
-		outPath := filepath.Join(statusDir, "latest-runs.json")
-		// Get realms and dungeons for analyze
-		_, dungeons := blizzard.GetHardcodedPeriodAndDungeons()
-		allRealms := blizzard.GetAllRealms()
-		if err := runAnalyze(db, allRealms, dungeons, periodsCSV, outPath, statusDir); err != nil {
-			return fmt.Errorf("analyze status: %w", err)
-		}
+	stages := []pipeline.Stage{
+		{
+			Name: "seasons",
+			Inputs: func(context.Context) ([]string, error) {
+				periods, err := currentPeriods()
+				return []string{"regions=" + strings.Join(opts.regions, ","), periods}, err
+			},
+			MaxAge: apiStageMaxAge,
+			Run: func(ctx context.Context) error {
+				client, err := apiClient()
+				if err != nil {
+					return err
+				}
+				log.Info("syncing season metadata")
+				if err := syncSeasons(ctx, db, client, opts.regionsCSV); err != nil {
+					return fmt.Errorf("sync seasons: %w", err)
+				}
+				if ctx.Err() != nil {
+					return interruptedError(ctx, "build (season sync)")
+				}
 
-		// Print summary
-		summarizeBuild(db, normalizedOut)
+				// Sync the realm registry so new and reconnected realms are swept
+				log.Info("syncing realm registry")
+				if err := syncRealms(ctx, dbService, client, opts.regionsCSV); err != nil {
+					return fmt.Errorf("sync realms: %w", err)
+				}
+				if ctx.Err() != nil {
+					return interruptedError(ctx, "build (realm sync)")
+				}
+				return nil
+			},
+		},
+		{
+			Name: "fetch",
+			Deps: []string{"seasons"},
+			Inputs: func(context.Context) ([]string, error) {
+				periods, err := currentPeriods()
+				return []string{
+					"regions=" + strings.Join(opts.regions, ","),
+					"periods=" + strings.Join(opts.periods, ","),
+					"latest_periods=" + strconv.FormatBool(opts.latestPeriodsOnly),
+					periods,
+				}, err
+			},
+			MaxAge: apiStageMaxAge,
+			Run: func(ctx context.Context) error {
+				client, err := apiClient()
+				if err != nil {
+					return err
+				}
+				log.Info("fetching challenge mode leaderboards", "sweep", "global period")
+
+				// Use pipeline function (handles child realm filtering automatically)
+				fetchOpts := pipeline.FetchCMOptions{
+					Verbose:           opts.verbose,
+					Regions:           opts.regions,
+					Realms:            []string{},   // no realm filter
+					Dungeons:          []string{},   // no dungeon filter
+					Periods:           opts.periods, // empty means fetch dynamically
+					LatestPeriodsOnly: opts.latestPeriodsOnly,
+					Concurrency:       opts.concurrency,
+					Timeout:           45 * time.Minute,
+				}
 
-		log.Info("build complete", "api_location", normalizedOut+"/api")
-		return nil
-	},
-}
+				result, err := pipeline.FetchChallengeMode(ctx, dbService, client, fetchOpts)
+				if err != nil {
+					return fmt.Errorf("fetch challenge mode: %w", err)
+				}
+				if result.Interrupted {
+					logFetchCMResume(result, nil, nil)
+					log.Warn("rerun build with --resume once the pending periods are fetched; remaining stages were skipped")
+					return interruptedError(ctx, "build (leaderboard fetch)")
+				}
 
-// processPlayersOnce runs the same steps as `process players`
-func processPlayersOnce(db *sql.DB) error {
-	opts := pipeline.ProcessPlayersOptions{
-		Verbose: false,
+				log.Info("fetch complete",
+					"runs", result.TotalRuns,
+					"players", result.TotalPlayers,
+					"new", result.NewRuns,
+					"resighted", result.Resighted,
+					"duration", result.Duration)
+				return nil
+			},
+		},
+		{
+			// Assign seasons to runs by the keystone period containing them
+			Name: "assign-seasons",
+			Deps: []string{"fetch"},
+			Run: func(ctx context.Context) error {
+				log.Info("assigning seasons to runs", "method", "period-based")
+				if err := dbService.AssignRunsToSeasons(); err != nil {
+					return fmt.Errorf("assign seasons: %w", err)
+				}
+				return nil
+			},
+		},
+		{
+			// Fingerprint players (merge duplicates)
+			Name: "fingerprints",
+			Deps: []string{"fetch"},
+			Inputs: func(context.Context) ([]string, error) {
+				missing, err := dbService.CountPlayersMissingFingerprints()
+				if err != nil {
+					return nil, err
+				}
+				return []string{"missing=" + strconv.Itoa(missing)}, nil
+			},
+			Run: func(ctx context.Context) error {
+				client, err := apiClient()
+				if err != nil {
+					return err
+				}
+				log.Info("fingerprinting players", "stage", "identity detection + merge")
+				return fingerprintPlayersOnce(ctx, db, client)
+			},
+		},
+		{
+			// Process run rankings, player aggregations and player rankings; profiles
+			// picks its players from the coverage this computes
+			Name: "process",
+			Deps: []string{"assign-seasons", "fingerprints"},
+			Inputs: func(ctx context.Context) ([]string, error) {
+				dirty, err := dirtyRankings(ctx)
+				return append(dirty, "full="+strconv.FormatBool(opts.fullRankings)), err
+			},
+			Run: func(ctx context.Context) error {
+				log.Info("processing rankings", "stage", "run rankings + players", "full", opts.fullRankings)
+				return processAllOnce(ctx, dbService, opts.fullRankings)
+			},
+		},
+		{
+			// Fetch detailed player profiles
+			Name:     "profiles",
+			Deps:     []string{"process"},
+			Disabled: profilesDisabled,
+			MaxAge:   apiStageMaxAge,
+			Run: func(ctx context.Context) error {
+				client, err := apiClient()
+				if err != nil {
+					return err
+				}
+				log.Info("fetching detailed player profiles", "coverage", "9/9")
+				return fetchProfilesOnce(ctx, db, client)
+			},
+		},
+		{
+			// Incremental pass over marks written since process (identity updates,
+			// fetches running alongside the build), so generation sees settled rankings;
+			// a no-op when nothing is dirty
+			Name:   "rankings",
+			Deps:   []string{"process", "profiles"},
+			Inputs: dirtyRankings,
+			Run: func(ctx context.Context) error {
+				log.Info("settling rankings", "stage", "incremental pass")
+				return processAllOnce(ctx, dbService, false)
+			},
+		},
+		{
+			// Generate static API
+			Name: "generate",
+			Deps: []string{"rankings", "profiles"},
+			Inputs: func(context.Context) ([]string, error) {
+				data, err := dbService.GenerationInputs()
+				if err != nil {
+					return nil, err
+				}
+				return []string{
+					"out=" + opts.out,
+					"regions=" + strings.Join(opts.regions, ","),
+					"page_size=" + strconv.Itoa(opts.pageSize),
+					"shard_size=" + strconv.Itoa(opts.shardSize),
+					data,
+				}, nil
+			},
+			Run: func(ctx context.Context) error {
+				if ctx.Err() != nil {
+					return interruptedError(ctx, "build (before static API generation)")
+				}
+				log.Info("generating static API")
+				return generateAllAPI(db, opts.out, opts.pageSize, opts.shardSize, opts.workers, opts.regions)
+			},
+		},
+		{
+			// Generate status API via analyze
+			Name: "status",
+			Deps: []string{"fetch"},
+			Inputs: func(context.Context) ([]string, error) {
+				return []string{"out=" + opts.out, "periods=" + opts.periodsCSV}, nil
+			},
+			Run: func(ctx context.Context) error {
+				log.Info("generating status API", "method", "analyze")
+				statusDir := filepath.Join(opts.out, "api", "status")
+				outPath := filepath.Join(statusDir, "latest-runs.json")
+				// Get realms and dungeons for analyze
+				dungeons, err := pipeline.ResolveDungeons(ctx, dbService, nil, nil)
+				if err != nil {
+					return fmt.Errorf("resolve dungeons: %w", err)
+				}
+				allRealms, err := pipeline.LoadRealms(dbService)
+				if err != nil {
+					return err
+				}
+				if err := runAnalyze(db, allRealms, dungeons, opts.periodsCSV, outPath, statusDir); err != nil {
+					return fmt.Errorf("analyze status: %w", err)
+				}
+				return nil
+			},
+		},
+		{
+			// List every file of the api directory for incremental deploys
+			Name: "manifest",
+			Deps: []string{"generate", "status"},
+			Inputs: func(context.Context) ([]string, error) {
+				return []string{"out=" + opts.out}, nil
+			},
+			Run: func(ctx context.Context) error {
+				_, err := generator.WriteManifest(filepath.Join(opts.out, "api"))
+				return err
+			},
+		},
 	}
-	_, _, err := pipeline.ProcessPlayers(db, opts)
-	return err
+	return stages, closeClient
 }
 
-// processRunRankingsOnce runs the same steps as `process rankings`
-func processRunRankingsOnce(db *sql.DB) error {
-	opts := pipeline.ProcessRunRankingsOptions{
-		Verbose: false,
+// processAllOnce runs the same steps as `process all`
+func processAllOnce(ctx context.Context, store database.Store, full bool) error {
+	opts := pipeline.ProcessAllOptions{
+		Full: full,
+	}
+	_, err := pipeline.ProcessAll(ctx, store, opts)
+	if err != nil && ctx.Err() != nil {
+		return interruptedError(ctx, "build (rankings)")
 	}
-	return pipeline.ProcessRunRankings(db, opts)
+	return err
 }
 
 // fingerprintPlayersOnce runs fingerprinting to detect and merge duplicate player identities
-func fingerprintPlayersOnce(db *sql.DB, client *blizzard.Client) error {
+func fingerprintPlayersOnce(ctx context.Context, db *sql.DB, client *blizzard.Client) error {
 	dbService := database.NewDatabaseService(db)
 	opts := pipeline.FingerprintOptions{
 		Verbose:    false,
 		BatchSize:  25,
 		MaxPlayers: 0, // process all players
 	}
-	result, err := pipeline.BuildPlayerFingerprints(dbService, client, opts)
+	result, err := pipeline.BuildPlayerFingerprints(ctx, dbService, client, opts)
 	if err != nil {
 		return fmt.Errorf("fingerprint players: %w", err)
 	}
+	if result.Interrupted {
+		log.Warn("fingerprinting interrupted", "processed", result.Processed, "created", result.Created)
+		return interruptedError(ctx, "build (fingerprinting)")
+	}
 	log.Info("fingerprinting complete",
 		"processed", result.Processed,
 		"created", result.Created,
@@ -251,8 +511,15 @@ func fingerprintPlayersOnce(db *sql.DB, client *blizzard.Client) error {
 }
 
 // fetchProfilesOnce runs the same logic as `fetch profiles`
-func fetchProfilesOnce(db *sql.DB, client *blizzard.Client) error {
+func fetchProfilesOnce(ctx context.Context, db *sql.DB, client *blizzard.Client) error {
 	dbService := database.NewDatabaseService(db)
+	retention := time.Now().AddDate(0, 0, -defaultEquipmentRetentionDays).UnixMilli()
+	pruned, err := dbService.PruneEquipmentSnapshots(retention)
+	if err != nil {
+		return err
+	}
+	log.Info("pruned equipment snapshots", "rows", pruned, "retention_days", defaultEquipmentRetentionDays)
+
 	// refetch profiles older than 72 hours
 	staleThreshold := time.Now().Add(-72 * time.Hour).UnixMilli()
 	players, err := dbService.GetEligiblePlayersForProfileFetch(staleThreshold)
@@ -266,7 +533,7 @@ func fetchProfilesOnce(db *sql.DB, client *blizzard.Client) error {
 
 	// batch in reasonable size
 	batchSize := 20
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
 	defer cancel()
 
 	totalProfiles := 0
@@ -275,6 +542,14 @@ func fetchProfilesOnce(db *sql.DB, client *blizzard.Client) error {
 	start := time.Now()
 
 	for i := 0; i < len(players); i += batchSize {
+		if ctx.Err() != nil {
+			log.Warn("profile fetch interrupted",
+				"processed", processed,
+				"remaining", len(players)-processed,
+				"profiles", totalProfiles)
+			return interruptedError(ctx, "build (profile fetch)")
+		}
+
 		end := i + batchSize
 		if end > len(players) {
 			end = len(players)
@@ -292,6 +567,10 @@ func fetchProfilesOnce(db *sql.DB, client *blizzard.Client) error {
 		batchItems := 0
 		for res := range results {
 			processed++
+			if res.Error != nil && ctx.Err() != nil {
+				// cancelled before the API answered; picked up again next build
+				continue
+			}
 			if res.Error != nil {
 				log.Error("profile fetch failed",
 					"player", res.PlayerName,
@@ -318,7 +597,10 @@ func fetchProfilesOnce(db *sql.DB, client *blizzard.Client) error {
 			"total_processed", processed,
 			"total_players", len(players))
 		if i+batchSize < len(players) {
-			time.Sleep(1 * time.Second)
+			select {
+			case <-time.After(1 * time.Second):
+			case <-ctx.Done():
+			}
 		}
 	}
 
@@ -329,46 +611,26 @@ func fetchProfilesOnce(db *sql.DB, client *blizzard.Client) error {
 	return nil
 }
 
-// generateAllAPI mirrors the behavior of `generate api`
-func generateAllAPI(db *sql.DB, outParent string, pageSize, shardSize, workers int, regionsCSV string) error {
-	base := filepath.Join(outParent, "api")
-	if err := os.MkdirAll(base, 0o755); err != nil {
-		return fmt.Errorf("mkdir: %w", err)
-	}
-
-	// players
-	if err := generator.GeneratePlayers(db, filepath.Join(base, "player"), ""); err != nil {
-		return err
-	}
-
-	// leaderboards (+ players rankings)
-	regions := []string{}
-	if strings.TrimSpace(regionsCSV) != "" {
-		for _, r := range strings.Split(regionsCSV, ",") {
-			rr := strings.TrimSpace(r)
-			if rr != "" {
-				regions = append(regions, rr)
-			}
-		}
-	}
-	if err := generator.GenerateLeaderboards(db, filepath.Join(base, "leaderboard"), pageSize, regions, workers); err != nil {
-		return err
-	}
-	if err := generator.GeneratePlayerLeaderboards(db, filepath.Join(base, "leaderboard"), pageSize, regions, workers); err != nil {
-		return err
-	}
-
-	// search index
-	if err := generator.GenerateSearchIndex(db, filepath.Join(base, "search"), shardSize); err != nil {
-		return err
-	}
-
-	// indexes
-	if err := indexes.GenerateAllIndexes(db, outParent); err != nil {
+// generateAllAPI mirrors the behavior of `generate api`, regenerating only what changed
+// since the output directory was last generated
+func generateAllAPI(db *sql.DB, outParent string, pageSize, shardSize, workers int, regions []string) error {
+	res, err := generator.GenerateAPI(db, outParent, generator.APIOptions{
+		PageSize:  pageSize,
+		ShardSize: shardSize,
+		Workers:   workers,
+		Regions:   regions,
+	})
+	if err != nil {
 		return err
 	}
 
-	log.Info("static API generated")
+	log.Info("static API generated",
+		"full", res.Full,
+		"reason", res.Reason,
+		"leaderboards", res.Leaderboards,
+		"player_leaderboards", res.PlayerLeaderboards,
+		"players", res.Players,
+		"removed_players", res.RemovedPlayers)
 	return nil
 }
 
@@ -388,27 +650,40 @@ func summarizeBuild(db *sql.DB, outParent string) {
 
 	log.Info("per-realm period coverage")
 	rows, err := db.Query(`
-        SELECT r.slug, GROUP_CONCAT(DISTINCT cr.period_id ORDER BY cr.period_id DESC)
+        SELECT DISTINCT r.region, r.slug, cr.period_id
         FROM challenge_runs cr
         JOIN realms r ON cr.realm_id = r.id
-        GROUP BY r.slug
-        ORDER BY r.region, r.slug`)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var slug, periods string
-			if err := rows.Scan(&slug, &periods); err == nil {
-				if periods == "" {
-					periods = "-"
-				}
-				log.Debug("realm coverage", "realm", slug, "periods", periods)
-			}
+        WHERE cr.period_id IS NOT NULL
+        ORDER BY r.region, r.slug, cr.period_id DESC`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	var curRegion, curSlug string
+	var periods []string
+	flush := func() {
+		if curSlug != "" {
+			log.Debug("realm coverage", "realm", curSlug, "periods", strings.Join(periods, ","))
+		}
+	}
+	for rows.Next() {
+		var region, slug string
+		var period int
+		if err := rows.Scan(&region, &slug, &period); err != nil {
+			continue
+		}
+		if region != curRegion || slug != curSlug {
+			flush()
+			curRegion, curSlug, periods = region, slug, nil
 		}
+		periods = append(periods, strconv.Itoa(period))
 	}
+	flush()
 }
 
 // syncSeasons syncs season metadata from Blizzard API for all regions
-func syncSeasons(db *sql.DB, client *blizzard.Client, regionsCSV string) error {
+func syncSeasons(ctx context.Context, db *sql.DB, client *blizzard.Client, regionsCSV string) error {
 	dbService := database.NewDatabaseService(db)
 
 	// Parse regions from CSV (seasons and periods are region-specific)
@@ -433,8 +708,11 @@ func syncSeasons(db *sql.DB, client *blizzard.Client, regionsCSV string) error {
 		log.Info("processing region", "region", strings.ToUpper(region))
 
 		// Fetch season index for this region
-		seasonIndex, err := client.FetchSeasonIndex(region)
+		seasonIndex, err := client.FetchSeasonIndex(ctx, region)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
 			log.Error("failed to fetch season index - skipping region",
 				"region", strings.ToUpper(region),
 				"error", err)
@@ -453,8 +731,11 @@ func syncSeasons(db *sql.DB, client *blizzard.Client, regionsCSV string) error {
 			seasonID := seasonRef.ID
 
 			// Fetch season details
-			seasonDetail, err := client.FetchSeasonDetail(region, seasonID)
+			seasonDetail, err := client.FetchSeasonDetail(ctx, region, seasonID)
 			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
 				log.Error("error fetching season details",
 					"season", seasonID,
 					"error", err)
@@ -499,6 +780,40 @@ func syncSeasons(db *sql.DB, client *blizzard.Client, regionsCSV string) error {
 	}
 
 	log.Info("season metadata synced for all regions")
+
+	// exact period boundaries for season assignment and --latest-periods
+	periodResult, err := pipeline.SyncPeriods(ctx, dbService, client, regions)
+	if err != nil {
+		return err
+	}
+	if !periodResult.Interrupted {
+		log.Info("period boundaries synced", "fetched", periodResult.Fetched, "settled", periodResult.Skipped)
+	}
+	return nil
+}
+
+// syncRealms refreshes the realm registry. A partial failure only warns when the
+// registry already has realms to work with.
+func syncRealms(ctx context.Context, dbService database.Store, client *blizzard.Client, regionsCSV string) error {
+	opts := pipeline.SyncRealmsOptions{}
+	for _, r := range strings.Split(regionsCSV, ",") {
+		if trimmed := strings.TrimSpace(r); trimmed != "" {
+			opts.Regions = append(opts.Regions, trimmed)
+		}
+	}
+
+	result, err := pipeline.SyncRealms(ctx, dbService, client, opts)
+	if err != nil || result.Interrupted {
+		return err
+	}
+	log.Info("realm registry synced", "realms", result.Realms, "added", result.Added, "updated", result.Updated)
+
+	if len(result.FailedRegions) > 0 {
+		if _, err := pipeline.LoadRealms(dbService); err != nil {
+			return err
+		}
+		log.Warn("realm sync incomplete, using stored realms", "regions", strings.Join(result.FailedRegions, ","))
+	}
 	return nil
 }
 
@@ -515,4 +830,8 @@ func init() {
 	buildCmd.Flags().Bool("latest-periods", false, "Only fetch the latest 2 periods from the current season per region (optimized for persistent databases)")
 	buildCmd.Flags().Int("concurrency", 20, "Max concurrent API requests")
 	buildCmd.Flags().Int("workers", 10, "Number of parallel workers for leaderboard generation")
+	buildCmd.Flags().Bool("full-rankings", false, "Rebuild every ranking instead of only the partitions this fetch changed")
+	buildCmd.Flags().Bool("resume", false, "Skip stages whose inputs are unchanged since they last finished")
+	buildCmd.Flags().String("only", "", "Comma-separated stages to run, without their dependencies (e.g. 'generate,status')")
+	buildCmd.Flags().String("from", "", "Run this stage and every stage downstream of it")
 }
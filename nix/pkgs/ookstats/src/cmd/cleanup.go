@@ -37,15 +37,87 @@ This cleanup is safe to run and will significantly reduce database size if dupli
 	},
 }
 
+// defaultEquipmentRetentionDays is how long equipment snapshots are kept before
+// `fetch profiles`, `build` and `cleanup equipment` prune them
+const defaultEquipmentRetentionDays = 180
+
+var cleanupEquipmentCmd = &cobra.Command{
+	Use:   "equipment",
+	Short: "Prune old equipment snapshots",
+	Long: `Deletes equipment snapshots older than --keep-days. For every slot the item worn at
+the cutoff is kept, so current gear and the gear timeline's starting point survive;
+gear changes before the cutoff no longer appear in the timeline.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keepDays, _ := cmd.Flags().GetInt("keep-days")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		vacuum, _ := cmd.Flags().GetBool("vacuum")
+		if keepDays <= 0 {
+			return fmt.Errorf("--keep-days must be positive")
+		}
+
+		db, err := database.Connect()
+		if err != nil {
+			return fmt.Errorf("db connect: %w", err)
+		}
+		defer db.Close()
+
+		return runCleanupEquipment(db, keepDays, dryRun, vacuum)
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(cleanupCmd)
 	cleanupCmd.AddCommand(cleanupPlayerRankingsCmd)
+	cleanupCmd.AddCommand(cleanupEquipmentCmd)
 
 	cleanupPlayerRankingsCmd.Flags().Bool("dry-run", false, "Show what would be deleted without actually deleting")
 	cleanupPlayerRankingsCmd.Flags().Bool("vacuum", true, "Run VACUUM after cleanup to reclaim space")
+
+	cleanupEquipmentCmd.Flags().Int("keep-days", defaultEquipmentRetentionDays, "Keep equipment snapshots from the last N days")
+	cleanupEquipmentCmd.Flags().Bool("dry-run", false, "Show what would be deleted without actually deleting")
+	cleanupEquipmentCmd.Flags().Bool("vacuum", false, "Run VACUUM after cleanup to reclaim space")
+}
+
+func runCleanupEquipment(db *sql.DB, keepDays int, dryRun bool, vacuum bool) error {
+	ds := database.NewDatabaseService(db)
+	cutoff := time.Now().AddDate(0, 0, -keepDays)
+
+	if dryRun {
+		count, err := ds.CountPrunableEquipmentSnapshots(cutoff.UnixMilli())
+		if err != nil {
+			return err
+		}
+		log.Info("[DRY RUN] would delete", "rows", count, "older_than", cutoff.Format(time.DateOnly))
+		return nil
+	}
+
+	startTime := time.Now()
+	pruned, err := ds.PruneEquipmentSnapshots(cutoff.UnixMilli())
+	if err != nil {
+		return err
+	}
+	log.Info("equipment cleanup complete",
+		"rows_deleted", pruned,
+		"older_than", cutoff.Format(time.DateOnly),
+		"duration", time.Since(startTime).Round(time.Millisecond))
+
+	if vacuum && pruned > 0 {
+		log.Info("running VACUUM to reclaim disk space...")
+		if _, err := db.Exec("VACUUM"); err != nil {
+			log.Warn("vacuum failed", "error", err)
+		}
+	}
+	return nil
 }
 
 func runCleanupPlayerRankings(db *sql.DB, dryRun bool, vacuum bool) error {
+	// the postgres schema has always had the composite primary key, so there is
+	// nothing to clean up and the rowid-based delete below would not run there
+	if database.DialectOf(db) == database.Postgres {
+		log.Info("player_rankings is keyed by (player_id, ranking_type, ranking_scope) on postgres - nothing to clean up")
+		return nil
+	}
+
 	log.Info("analyzing player_rankings table")
 
 	// count total rows
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"ookstats/internal/database"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Snapshot, verify and restore the local database",
+	Long: `Take consistent snapshots of the local SQLite database, check them and roll back to them.
+
+Each snapshot is stored with a sha256 checksum file and a manifest recording the row count
+of every table and the schema version.`,
+}
+
+var dbSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Write a consistent snapshot of the database",
+	Long: `Writes a snapshot with VACUUM INTO, which copies the database inside a single read
+transaction. It is safe to run while a fetch is writing to the database.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("dir")
+		keep, _ := cmd.Flags().GetInt("keep")
+
+		db, err := database.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer db.Close()
+
+		path, manifest, err := database.Snapshot(db, dir)
+		if err != nil {
+			return err
+		}
+		log.Info("snapshot written",
+			"path", path,
+			"size_mb", manifest.SizeBytes/1024/1024,
+			"schema_version", manifest.SchemaVersion,
+			"tables", len(manifest.Tables),
+			"sha256", manifest.SHA256)
+
+		if keep > 0 {
+			snapshots, err := database.ListSnapshots(dir)
+			if err != nil {
+				return fmt.Errorf("list snapshots: %w", err)
+			}
+			for len(snapshots) > keep {
+				log.Info("removing old snapshot", "path", snapshots[0])
+				if err := database.RemoveSnapshot(snapshots[0]); err != nil {
+					return fmt.Errorf("remove %s: %w", snapshots[0], err)
+				}
+				snapshots = snapshots[1:]
+			}
+		}
+		return nil
+	},
+}
+
+var dbVerifyCmd = &cobra.Command{
+	Use:   "verify <snapshot>...",
+	Short: "Check snapshots against their checksum and manifest",
+	Long: `Recomputes each snapshot's checksum, runs SQLite's integrity check, and compares
+the row count of every table and the schema version with the manifest.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		failed := 0
+		for _, path := range args {
+			res, err := database.VerifySnapshot(path)
+			if err != nil {
+				return fmt.Errorf("verify %s: %w", path, err)
+			}
+			if res.OK() {
+				log.Info("snapshot ok",
+					"path", path,
+					"created_at", res.Manifest.CreatedAt,
+					"schema_version", res.Manifest.SchemaVersion,
+					"tables", len(res.Manifest.Tables))
+				continue
+			}
+			failed++
+			log.Error("snapshot failed verification", "path", path)
+			for _, p := range res.Problems {
+				fmt.Printf("  %s\n", p)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d snapshot(s) failed verification", failed, len(args))
+		}
+		return nil
+	},
+}
+
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore <snapshot>",
+	Short: "Replace the database with a verified snapshot",
+	Long: `Verifies the snapshot, then atomically replaces the database file (--db-file) with it.
+Stop any running fetch or build first. Take a snapshot before restoring if you may want to
+return to the current state.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if database.DatabaseURL() != "" {
+			return fmt.Errorf("restore only supports the local SQLite database")
+		}
+		target := database.DBFilePath()
+		if abs, err := filepath.Abs(args[0]); err == nil {
+			if t, err := filepath.Abs(target); err == nil && abs == t {
+				return fmt.Errorf("snapshot and database are the same file")
+			}
+		}
+
+		res, err := database.RestoreSnapshot(args[0], target)
+		if err != nil {
+			if res != nil {
+				for _, p := range res.Problems {
+					fmt.Printf("  %s\n", p)
+				}
+			}
+			return err
+		}
+		log.Info("database restored",
+			"path", target,
+			"snapshot", args[0],
+			"created_at", res.Manifest.CreatedAt,
+			"schema_version", res.Manifest.SchemaVersion)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbSnapshotCmd)
+	dbCmd.AddCommand(dbVerifyCmd)
+	dbCmd.AddCommand(dbRestoreCmd)
+
+	dbSnapshotCmd.Flags().String("dir", "snapshots", "Directory to write the snapshot, checksum and manifest to")
+	dbSnapshotCmd.Flags().Int("keep", 0, "Keep only the newest N snapshots in --dir (0 keeps all)")
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"ookstats/internal/database"
+	"ookstats/internal/pipeline"
+	"ookstats/internal/writer"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check database integrity and consistency",
+	Long: `Runs integrity and consistency checks over the database: orphaned run members,
+runs with the wrong number of members, runs without a season, players on unknown
+realms, fingerprints of invalid players, profiles whose dungeon count disagrees with
+their best runs, and duplicate player rankings.
+
+With --repair, checks that have a safe fix apply it. Exits non-zero while issues remain.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repair, _ := cmd.Flags().GetBool("repair")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		outPath, _ := cmd.Flags().GetString("out")
+		samples, _ := cmd.Flags().GetInt("samples")
+
+		// keep stdout clean for the JSON report: connection and repair progress
+		// goes to stderr instead
+		stdout := cmd.OutOrStdout()
+		progress := stdout
+		if asJSON {
+			progress = os.Stderr
+		}
+
+		db, err := database.ConnectWithProgress(progress)
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer db.Close()
+
+		report, err := pipeline.RunDoctor(cmd.Context(), db, pipeline.DoctorOptions{
+			Repair:      repair,
+			SampleLimit: samples,
+			Progress:    progress,
+		})
+		if err != nil {
+			return err
+		}
+
+		if outPath != "" {
+			if err := writer.WriteJSONFile(outPath, report); err != nil {
+				return fmt.Errorf("write report: %w", err)
+			}
+			log.Info("doctor report written", "path", outPath)
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return err
+			}
+		} else {
+			printDoctorReport(stdout, report)
+		}
+
+		if report.Remaining > 0 {
+			return fmt.Errorf("%d issue(s) remain", report.Remaining)
+		}
+		return nil
+	},
+}
+
+func printDoctorReport(w io.Writer, report *pipeline.DoctorReport) {
+	fmt.Fprintf(w, "\n=== ookstats doctor (%s) ===\n\n", report.Backend)
+	for _, c := range report.Checks {
+		state := "OK"
+		switch {
+		case c.Issues == 0:
+		case c.Remaining == 0:
+			state = "FIXED"
+		default:
+			state = "FAIL"
+		}
+		fmt.Fprintf(w, "[%-5s] %-28s %s\n", state, c.Name, c.Description)
+		if c.Issues == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "        issues: %d", c.Issues)
+		if report.Repair && c.Repairable {
+			fmt.Fprintf(w, ", repaired: %d, remaining: %d", c.Repaired, c.Remaining)
+		} else if c.Repairable {
+			fmt.Fprintf(w, " (repairable with --repair)")
+		}
+		fmt.Fprintln(w)
+		if len(c.Samples) > 0 {
+			fmt.Fprintf(w, "        e.g. %s\n", strings.Join(c.Samples, "; "))
+		}
+		if c.Remaining > 0 && c.Hint != "" {
+			fmt.Fprintf(w, "        hint: %s\n", c.Hint)
+		}
+	}
+	fmt.Fprintf(w, "\n%d issue(s) found, %d remaining\n", report.TotalIssues, report.Remaining)
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().Bool("repair", false, "Apply the fix for every check that has one")
+	doctorCmd.Flags().Bool("json", false, "Print the report as JSON instead of text")
+	doctorCmd.Flags().String("out", "", "Also write the JSON report to this path")
+	doctorCmd.Flags().Int("samples", 5, "Example rows to list per failing check")
+}
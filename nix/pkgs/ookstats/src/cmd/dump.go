@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"ookstats/internal/database"
+)
+
+var dumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Write fetched runs, players and profiles as a JSONL dump",
+	Long: `Writes everything fetched from the API to a versioned JSON lines file: realms, dungeons,
+seasons and periods, players and fingerprints, runs with their members and sightings, and
+player summaries with their equipment history, ranking history, and the fetch status and
+HTTP validators of every leaderboard. ` + "`ookstats load`" + ` rebuilds a database from it without
+calling the API.
+
+Paths ending in .gz are gzip-compressed. Stop fetches while dumping.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, _ := cmd.Flags().GetString("out")
+		if strings.TrimSpace(out) == "" {
+			return fmt.Errorf("--out is required")
+		}
+
+		db, err := database.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer db.Close()
+
+		if dir := filepath.Dir(out); dir != "" {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return fmt.Errorf("mkdir %s: %w", dir, err)
+			}
+		}
+		// write under a temporary name so an interrupted dump never looks complete
+		tmp := out + ".tmp"
+		f, err := os.Create(tmp)
+		if err != nil {
+			return fmt.Errorf("create %s: %w", tmp, err)
+		}
+		defer os.Remove(tmp)
+
+		start := time.Now()
+		var w io.Writer = f
+		var zw *gzip.Writer
+		if strings.HasSuffix(out, ".gz") {
+			zw = gzip.NewWriter(f)
+			w = zw
+		}
+		counts, err := database.Dump(db, w)
+		if err == nil && zw != nil {
+			err = zw.Close()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("dump failed: %w", err)
+		}
+		if err := os.Rename(tmp, out); err != nil {
+			return fmt.Errorf("rename %s: %w", out, err)
+		}
+
+		logDumpCounts(counts)
+		log.Info("dump written", "path", out, "duration", time.Since(start).Round(time.Millisecond))
+		return nil
+	},
+}
+
+var loadCmd = &cobra.Command{
+	Use:   "load <dump>",
+	Short: "Rebuild an empty database from a JSONL dump",
+	Long: `Creates the schema if needed, then replays a dump written by ` + "`ookstats dump`" + ` into the
+database: runs go through the same insert path as fetched leaderboards and profiles through
+the same path as fetched profiles, keeping run, realm and season ids. The database must not
+contain realms, players or runs yet.
+
+Afterwards run ` + "`ookstats process all`" + ` to rebuild player profiles and rankings.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := args[0]
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open %s: %w", path, err)
+		}
+		defer f.Close()
+
+		var r io.Reader = f
+		if strings.HasSuffix(path, ".gz") {
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				return fmt.Errorf("open %s: %w", path, err)
+			}
+			defer zr.Close()
+			r = zr
+		}
+
+		db, err := database.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer db.Close()
+
+		if err := database.EnsureCompleteSchema(db); err != nil {
+			return fmt.Errorf("failed to initialize schema: %w", err)
+		}
+
+		start := time.Now()
+		counts, err := database.LoadDump(db, r)
+		if err != nil {
+			return fmt.Errorf("load failed: %w", err)
+		}
+
+		logDumpCounts(counts)
+		log.Info("dump loaded", "path", path, "duration", time.Since(start).Round(time.Millisecond))
+		log.Info("run `ookstats process all` to rebuild player profiles and rankings")
+		return nil
+	},
+}
+
+func logDumpCounts(counts database.DumpCounts) {
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		log.Info("records", "kind", kind, "count", counts[kind])
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(dumpCmd)
+	rootCmd.AddCommand(loadCmd)
+
+	dumpCmd.Flags().String("out", "ookstats-dump.jsonl.gz", "File to write the dump to (.gz compresses)")
+}
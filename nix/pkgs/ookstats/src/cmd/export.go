@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"ookstats/internal/database"
+	"ookstats/internal/export"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export runs, players and rankings as Parquet or CSV for analysis",
+	Long: `Writes a denormalized analytics bundle to --out:
+
+  runs/             one row per run with member, spec and class arrays
+  player_profiles/  per-season player aggregates and rankings
+  best_runs/        each player's best run per dungeon and season
+  equipment/        equipment snapshot rows with enchants and gems
+  run_rankings/     run ranks per ranking type and scope
+  manifest.json     columns, types and files of every dataset
+
+Each dataset is split into season=<id>/ directories, which DuckDB and pandas read
+as a hive partition, e.g. read_parquet('out/runs/*/*.parquet', hive_partitioning=true).
+--seasons and --regions apply to every dataset; --dungeons applies to runs,
+best_runs and run_rankings.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outDir, _ := cmd.Flags().GetString("out")
+		format, _ := cmd.Flags().GetString("format")
+		seasonsCSV, _ := cmd.Flags().GetString("seasons")
+		regionsCSV, _ := cmd.Flags().GetString("regions")
+		dungeonsCSV, _ := cmd.Flags().GetString("dungeons")
+
+		if strings.TrimSpace(outDir) == "" {
+			return fmt.Errorf("--out is required")
+		}
+
+		var seasons []int
+		for _, s := range splitCSV(seasonsCSV) {
+			id, err := strconv.Atoi(s)
+			if err != nil {
+				return fmt.Errorf("invalid season %q", s)
+			}
+			seasons = append(seasons, id)
+		}
+
+		db, err := database.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer db.Close()
+
+		manifest, err := export.Run(cmd.Context(), db, export.Options{
+			Dir:      outDir,
+			Format:   strings.ToLower(format),
+			Seasons:  seasons,
+			Regions:  splitCSV(regionsCSV),
+			Dungeons: splitCSV(dungeonsCSV),
+		})
+		if err != nil {
+			return err
+		}
+
+		var rows int64
+		for _, ds := range manifest.Datasets {
+			rows += ds.Rows
+		}
+		log.Info("export complete", "out", outDir, "format", manifest.Format, "datasets", len(manifest.Datasets), "rows", rows)
+		return nil
+	},
+}
+
+// splitCSV splits a comma-separated flag value, dropping blanks
+func splitCSV(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().String("out", "export", "Directory to write the bundle to")
+	exportCmd.Flags().String("format", export.FormatParquet, "Output format: parquet or csv")
+	exportCmd.Flags().String("seasons", "", "Comma-separated season numbers to include (default: all)")
+	exportCmd.Flags().String("regions", "", "Comma-separated regions to include (us,eu,kr,tw)")
+	exportCmd.Flags().String("dungeons", "", "Comma-separated dungeon IDs or slugs to include")
+}
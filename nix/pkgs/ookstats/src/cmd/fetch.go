@@ -35,6 +35,7 @@ var fetchCMCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("failed to create Blizzard API client: %w", err)
 		}
+		defer client.Close()
 
 		// Enable verbose logging if requested
 		verbose, _ := cmd.InheritedFlags().GetBool("verbose")
@@ -55,6 +56,7 @@ var fetchCMCmd = &cobra.Command{
 		latestPeriodsOnly, _ := cmd.Flags().GetBool("latest-periods")
 		concurrency, _ := cmd.Flags().GetInt("concurrency")
 		timeoutSecs, _ := cmd.Flags().GetInt("api-timeout-seconds")
+		noConditional, _ := cmd.Flags().GetBool("no-conditional")
 
 		// Convert CSV strings to slices
 		var regions []string
@@ -109,6 +111,7 @@ var fetchCMCmd = &cobra.Command{
 			Dungeons:          dungeons,
 			Periods:           periods,
 			LatestPeriodsOnly: latestPeriodsOnly,
+			NoConditional:     noConditional,
 			Concurrency:       concurrency,
 			Timeout:           time.Duration(timeoutSecs) * time.Second,
 		}
@@ -117,14 +120,19 @@ var fetchCMCmd = &cobra.Command{
 		}
 
 		// Fetch challenge mode data
-		result, err := pipeline.FetchChallengeMode(dbService, client, opts)
+		result, err := pipeline.FetchChallengeMode(cmd.Context(), dbService, client, opts)
 		if err != nil {
 			return err
 		}
+		if result.Interrupted {
+			logFetchCMResume(result, realms, dungeons)
+			return interruptedError(cmd.Context(), "leaderboard fetch")
+		}
 
 		log.Info("successfully inserted data into local database",
 			"runs", result.TotalRuns,
 			"players", result.TotalPlayers)
+		log.Info("run sightings", "new", result.NewRuns, "resighted", result.Resighted)
 		log.Info("database saved", "path", database.DBFilePath())
 
 		return nil
@@ -148,6 +156,7 @@ var fetchProfilesCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("failed to create Blizzard API client: %w", err)
 		}
+		defer client.Close()
 
 		// Enable verbose logging if requested
 		verbose, _ := cmd.InheritedFlags().GetBool("verbose")
@@ -163,6 +172,7 @@ var fetchProfilesCmd = &cobra.Command{
 		batchSize, _ := cmd.Flags().GetInt("batch-size")
 		maxPlayers, _ := cmd.Flags().GetInt("max-players")
 		staleHours, _ := cmd.Flags().GetFloat64("stale-hours")
+		retentionDays, _ := cmd.Flags().GetInt("equipment-retention-days")
 
 		var staleDuration time.Duration
 		if staleHours > 0 {
@@ -171,23 +181,33 @@ var fetchProfilesCmd = &cobra.Command{
 
 		// Build options
 		opts := pipeline.FetchProfilesOptions{
-			Verbose:    verbose,
-			BatchSize:  batchSize,
-			MaxPlayers: maxPlayers,
-			StaleAfter: staleDuration,
+			Verbose:            verbose,
+			BatchSize:          batchSize,
+			MaxPlayers:         maxPlayers,
+			StaleAfter:         staleDuration,
+			EquipmentRetention: time.Duration(retentionDays) * 24 * time.Hour,
 		}
 
 		// Fetch player profiles
-		result, err := pipeline.FetchPlayerProfiles(dbService, client, opts)
+		result, err := pipeline.FetchPlayerProfiles(cmd.Context(), dbService, client, opts)
 		if err != nil {
 			return err
 		}
+		if result.Interrupted {
+			log.Warn("profile fetch interrupted",
+				"processed", result.ProcessedCount,
+				"remaining", result.Remaining,
+				"profiles", result.TotalProfiles,
+				"resume", "rerun ookstats fetch profiles; players fetched in this run are no longer stale")
+			return interruptedError(cmd.Context(), "profile fetch")
+		}
 
 		log.Info("player profile fetching complete",
 			"processed", result.ProcessedCount,
 			"duration", result.Duration,
 			"profiles", result.TotalProfiles,
-			"equipment", result.TotalEquipment)
+			"equipment", result.TotalEquipment,
+			"equipment_pruned", result.EquipmentPruned)
 
 		if result.ProcessedCount > 0 {
 			rate := float64(result.ProcessedCount) / result.Duration.Minutes()
@@ -217,6 +237,7 @@ var fetchFingerprintsCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("failed to create Blizzard API client: %w", err)
 		}
+		defer client.Close()
 
 		verbose, _ := cmd.InheritedFlags().GetBool("verbose")
 		client.Verbose = verbose
@@ -241,10 +262,17 @@ var fetchFingerprintsCmd = &cobra.Command{
 			MaxPlayers: maxPlayers,
 		}
 
-		result, err := pipeline.BuildPlayerFingerprints(dbService, client, opts)
+		result, err := pipeline.BuildPlayerFingerprints(cmd.Context(), dbService, client, opts)
 		if err != nil {
 			return err
 		}
+		if result.Interrupted {
+			log.Warn("fingerprint fetch interrupted",
+				"processed", result.Processed,
+				"created", result.Created,
+				"resume", "ookstats fetch fingerprints")
+			return interruptedError(cmd.Context(), "fingerprint fetch")
+		}
 
 		log.Info("fingerprint fetching complete",
 			"processed", result.Processed,
@@ -259,7 +287,7 @@ var fetchFingerprintsCmd = &cobra.Command{
 var fetchSeasonsCmd = &cobra.Command{
 	Use:   "seasons",
 	Short: "Fetch and sync season metadata",
-	Long:  `Fetch season metadata from Blizzard API and populate the seasons and period_seasons tables.`,
+	Long:  `Fetch season and period metadata from Blizzard API and populate the seasons, period_seasons and periods tables.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		log.Info("season metadata sync")
 
@@ -273,6 +301,7 @@ var fetchSeasonsCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("failed to create Blizzard API client: %w", err)
 		}
+		defer client.Close()
 
 		verbose, _ := cmd.InheritedFlags().GetBool("verbose")
 		client.Verbose = verbose
@@ -306,8 +335,11 @@ var fetchSeasonsCmd = &cobra.Command{
 			log.Info("processing region", "region", strings.ToUpper(region))
 
 			// Fetch season index
-			seasonIndex, err := client.FetchSeasonIndex(region)
+			seasonIndex, err := client.FetchSeasonIndex(cmd.Context(), region)
 			if err != nil {
+				if cmd.Context().Err() != nil {
+					return interruptedError(cmd.Context(), "season sync")
+				}
 				log.Error("failed to fetch season index",
 					"region", region,
 					"error", err)
@@ -324,8 +356,11 @@ var fetchSeasonsCmd = &cobra.Command{
 				log.Info("processing season", "season_id", seasonID)
 
 				// Fetch season details
-				seasonDetail, err := client.FetchSeasonDetail(region, seasonID)
+				seasonDetail, err := client.FetchSeasonDetail(cmd.Context(), region, seasonID)
 				if err != nil {
+					if cmd.Context().Err() != nil {
+						return interruptedError(cmd.Context(), "season sync")
+					}
 					log.Error("failed to fetch season details",
 						"season_id", seasonID,
 						"error", err)
@@ -380,10 +415,79 @@ var fetchSeasonsCmd = &cobra.Command{
 			log.Info("synced seasons for region", "region", strings.ToUpper(region))
 		}
 
+		log.Info("syncing period boundaries", "regions", regions)
+		periodResult, err := pipeline.SyncPeriods(cmd.Context(), dbService, client, regions)
+		if err != nil {
+			return err
+		}
+		if periodResult.Interrupted {
+			return interruptedError(cmd.Context(), "period sync")
+		}
+
 		log.Info("sync complete",
 			"total_seasons", totalSeasons,
-			"total_periods", totalPeriods)
+			"total_periods", totalPeriods,
+			"period_details_fetched", periodResult.Fetched,
+			"periods_linked", periodResult.Linked)
+
+		return nil
+	},
+}
+
+var fetchRealmsCmd = &cobra.Command{
+	Use:   "realms",
+	Short: "Sync the realm registry from the connected realm API",
+	Long:  `Walk the connected realm index of each region and populate the realms table with connected realm IDs and parent realms. Every other command reads its realm list from this table.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.Info("realm registry sync")
+
+		db, err := database.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer db.Close()
 
+		client, err := blizzard.NewClient()
+		if err != nil {
+			return fmt.Errorf("failed to create Blizzard API client: %w", err)
+		}
+		defer client.Close()
+
+		verbose, _ := cmd.InheritedFlags().GetBool("verbose")
+		client.Verbose = verbose
+
+		dbService := database.NewDatabaseService(db)
+
+		regionsCSV, _ := cmd.Flags().GetString("regions")
+		excludeCSV, _ := cmd.Flags().GetString("exclude")
+		opts := pipeline.SyncRealmsOptions{}
+		for _, r := range strings.Split(regionsCSV, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				opts.Regions = append(opts.Regions, r)
+			}
+		}
+		for _, s := range strings.Split(excludeCSV, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				opts.Exclude = append(opts.Exclude, s)
+			}
+		}
+
+		result, err := pipeline.SyncRealms(cmd.Context(), dbService, client, opts)
+		if err != nil {
+			return err
+		}
+		if result.Interrupted {
+			return interruptedError(cmd.Context(), "realm sync")
+		}
+
+		log.Info("sync complete",
+			"connected_realms", result.ConnectedRealms,
+			"realms", result.Realms,
+			"added", result.Added,
+			"updated", result.Updated)
+		if len(result.FailedRegions) > 0 {
+			return fmt.Errorf("realm sync incomplete for regions: %s", strings.Join(result.FailedRegions, ","))
+		}
 		return nil
 	},
 }
@@ -405,6 +509,7 @@ var fetchStatusCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("failed to create Blizzard API client: %w", err)
 		}
+		defer client.Close()
 
 		verbose, _ := cmd.InheritedFlags().GetBool("verbose")
 		client.Verbose = verbose
@@ -439,10 +544,16 @@ var fetchStatusCmd = &cobra.Command{
 			MaxRPS:      maxRPS,
 		}
 
-		result, err := pipeline.RefreshPlayerStatuses(dbService, client, opts)
+		result, err := pipeline.RefreshPlayerStatuses(cmd.Context(), dbService, client, opts)
 		if err != nil {
 			return err
 		}
+		if result.Interrupted {
+			log.Warn("status refresh interrupted",
+				"processed", result.Processed,
+				"resume", "ookstats fetch status")
+			return interruptedError(cmd.Context(), "status refresh")
+		}
 
 		log.Info("status refresh complete",
 			"processed", result.Processed,
@@ -459,6 +570,7 @@ func init() {
 	fetchCmd.AddCommand(fetchCMCmd)
 	fetchCmd.AddCommand(fetchProfilesCmd)
 	fetchCmd.AddCommand(fetchSeasonsCmd)
+	fetchCmd.AddCommand(fetchRealmsCmd)
 	fetchCmd.AddCommand(fetchFingerprintsCmd)
 	fetchCmd.AddCommand(fetchStatusCmd)
 
@@ -470,11 +582,13 @@ func init() {
 	fetchCMCmd.Flags().String("dungeons", "", "Comma-separated dungeon IDs or slugs to include")
 	fetchCMCmd.Flags().String("periods", "", "Period specification: comma-separated list or ranges (e.g., '1020-1036' or '1020,1025,1030-1036'). Default: fetch all periods from API")
 	fetchCMCmd.Flags().Bool("latest-periods", false, "Only fetch the latest 2 periods from the current season per region (optimized for persistent databases)")
+	fetchCMCmd.Flags().Bool("no-conditional", false, "Ignore stored ETag/Last-Modified validators and re-download every leaderboard")
 
 	// add player profile fetching flags
 	fetchProfilesCmd.Flags().Int("batch-size", 20, "Number of players to process per batch")
 	fetchProfilesCmd.Flags().Int("max-players", 0, "Maximum number of players to process (0 = no limit)")
 	fetchProfilesCmd.Flags().Float64("stale-hours", 72, "Only fetch profiles older than this many hours (0 = only never-fetched)")
+	fetchProfilesCmd.Flags().Int("equipment-retention-days", defaultEquipmentRetentionDays, "Prune equipment snapshots older than this many days, keeping the gear worn at the cutoff (0 = keep all)")
 
 	// fingerprint fetching flags
 	fetchFingerprintsCmd.Flags().Int("batch-size", 25, "Number of players to process per batch")
@@ -491,4 +605,8 @@ func init() {
 
 	// season syncing flags
 	fetchSeasonsCmd.Flags().String("regions", "us", "Comma-separated regions to query (only one needed since seasons are global)")
+
+	// realms flags
+	fetchRealmsCmd.Flags().String("regions", "us,eu,kr,tw", "Comma-separated regions to sync")
+	fetchRealmsCmd.Flags().String("exclude", "", "Comma-separated realm slugs to leave out (e.g. PTR realms)")
 }
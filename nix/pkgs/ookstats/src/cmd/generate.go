@@ -1,12 +1,14 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
 	_ "github.com/tursodatabase/go-libsql"
 	"ookstats/internal/database"
@@ -23,6 +25,17 @@ var generateCmd = &cobra.Command{
 var generateAPICmd = &cobra.Command{
 	Use:   "api",
 	Short: "Generate static JSON API endpoints",
+	Long: `Generate static JSON API endpoints under <out>/api.
+
+With every section selected, only what changed since the last generation into the
+same directory is regenerated: leaderboards of partitions with new or re-ranked runs,
+and player files and player leaderboards of players that changed. Files of players
+that were merged away or lost complete coverage are deleted. Changed options, realms,
+dungeons or seasons, a full ranking rebuild, or --full regenerate everything.
+Deselecting a section regenerates the selected ones in full.
+
+api/manifest.json lists every file with its size, sha256 and the manifest generation
+its content first appeared in; compare two with 'generate diff'.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		outDir, _ := cmd.Flags().GetString("out")
 		onlyPlayers, _ := cmd.Flags().GetBool("players")
@@ -33,10 +46,16 @@ var generateAPICmd = &cobra.Command{
 		shardSize, _ := cmd.Flags().GetInt("shard-size")
 		regionsCSV, _ := cmd.Flags().GetString("regions")
 		workers, _ := cmd.Flags().GetInt("workers")
+		full, _ := cmd.Flags().GetBool("full")
 
 		if strings.TrimSpace(outDir) == "" {
 			return errors.New("--out is required")
 		}
+		allSections := onlyPlayers && doLeaderboards && doSearch && doIndexes
+		if full && !allSections {
+			return errors.New("--full applies when every section is generated")
+		}
+
 		// Connect to local DB (file:)
 		db, err := database.Connect()
 		if err != nil {
@@ -44,7 +63,38 @@ var generateAPICmd = &cobra.Command{
 		}
 		defer db.Close()
 
+		regions := []string{}
+		if strings.TrimSpace(regionsCSV) != "" {
+			for _, r := range strings.Split(regionsCSV, ",") {
+				rr := strings.TrimSpace(r)
+				if rr != "" {
+					regions = append(regions, rr)
+				}
+			}
+		}
+
 		base := filepath.Join(outDir, "api")
+		if allSections {
+			res, err := generator.GenerateAPI(db, outDir, generator.APIOptions{
+				PageSize:  pageSize,
+				ShardSize: shardSize,
+				Workers:   workers,
+				Regions:   regions,
+				Full:      full,
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := generator.WriteManifest(base); err != nil {
+				return err
+			}
+			mode := "incrementally"
+			if res.Full {
+				mode = "in full (" + res.Reason + ")"
+			}
+			fmt.Printf("\nStatic API generated %s at %s\n", mode, base)
+			return nil
+		}
 		if err := os.MkdirAll(base, 0o755); err != nil {
 			return fmt.Errorf("mkdir base: %w", err)
 		}
@@ -56,15 +106,6 @@ var generateAPICmd = &cobra.Command{
 		}
 
 		if doLeaderboards {
-			regions := []string{}
-			if strings.TrimSpace(regionsCSV) != "" {
-				for _, r := range strings.Split(regionsCSV, ",") {
-					rr := strings.TrimSpace(r)
-					if rr != "" {
-						regions = append(regions, rr)
-					}
-				}
-			}
 			if err := generator.GenerateLeaderboards(db, filepath.Join(base, "leaderboard"), pageSize, regions, workers); err != nil {
 				return err
 			}
@@ -85,14 +126,81 @@ var generateAPICmd = &cobra.Command{
 			}
 		}
 
+		if _, err := generator.WriteManifest(base); err != nil {
+			return err
+		}
+
 		fmt.Printf("\nStatic API generated at %s\n", base)
 		return nil
 	},
 }
 
+var generateDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "List files added, changed or removed since an earlier manifest",
+	Long: `Compares api/manifest.json under --out, or --manifest, with an earlier manifest and
+prints one line per differing file, relative to the api directory:
+
+  A  leaderboard/season/11/global/d2/4.json
+  M  player/us/atiesh/p06.json
+  D  player/us/atiesh/p01.json
+
+Keep a copy of the manifest from the last deploy to upload or invalidate only what
+changed since.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		against, _ := cmd.Flags().GetString("against")
+		manifestPath, _ := cmd.Flags().GetString("manifest")
+		outDir, _ := cmd.Flags().GetString("out")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		if strings.TrimSpace(against) == "" {
+			return errors.New("--against is required")
+		}
+		if strings.TrimSpace(manifestPath) == "" {
+			manifestPath = filepath.Join(outDir, "api", generator.ManifestFileName)
+		}
+
+		old, err := generator.LoadManifest(against)
+		if err != nil {
+			return fmt.Errorf("failed to load old manifest: %w", err)
+		}
+		cur, err := generator.LoadManifest(manifestPath)
+		if err != nil {
+			return fmt.Errorf("failed to load manifest: %w", err)
+		}
+
+		diff := generator.DiffManifests(old, cur)
+		if asJSON {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(diff)
+		}
+		for _, group := range []struct {
+			code  string
+			paths []string
+		}{{"A", diff.Added}, {"M", diff.Changed}, {"D", diff.Removed}} {
+			for _, p := range group.paths {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s  %s\n", group.code, p)
+			}
+		}
+		log.Info("manifest diff",
+			"from_generation", old.Generation,
+			"to_generation", cur.Generation,
+			"added", len(diff.Added),
+			"changed", len(diff.Changed),
+			"removed", len(diff.Removed))
+		return nil
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(generateCmd)
 	generateCmd.AddCommand(generateAPICmd)
+	generateCmd.AddCommand(generateDiffCmd)
+	generateDiffCmd.Flags().String("against", "", "Earlier manifest.json to compare with")
+	generateDiffCmd.Flags().String("manifest", "", "Manifest to compare (default: <out>/api/manifest.json)")
+	generateDiffCmd.Flags().String("out", "public", "Output directory the static API was generated into")
+	generateDiffCmd.Flags().Bool("json", false, "Print the diff as JSON")
 	generateAPICmd.Flags().String("out", "public", "Output directory for static API")
 	generateAPICmd.Flags().Bool("players", true, "Generate player profile JSON endpoints")
 	generateAPICmd.Flags().Bool("leaderboards", true, "Generate leaderboard JSON endpoints")
@@ -102,4 +210,5 @@ func init() {
 	generateAPICmd.Flags().Int("shard-size", 5000, "Search index shard size")
 	generateAPICmd.Flags().String("regions", "us,eu,kr,tw", "Regions to include for regional leaderboards")
 	generateAPICmd.Flags().Int("workers", 10, "Number of parallel workers for leaderboard generation")
+	generateAPICmd.Flags().Bool("full", false, "Regenerate everything instead of only what changed since the last generation")
 }
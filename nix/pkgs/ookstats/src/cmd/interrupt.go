@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/charmbracelet/log"
+	"ookstats/internal/pipeline"
+)
+
+// signalContext returns a context cancelled on the first SIGINT/SIGTERM. Commands
+// then commit finished batches and exit; a second signal kills the process.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-sigCh:
+			log.Warn("signal received, committing finished work and stopping (repeat to force quit)", "signal", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+		// restore default handling so a second signal terminates immediately
+		signal.Stop(sigCh)
+	}()
+
+	return ctx, cancel
+}
+
+// interruptedError is returned by commands that stopped early so the exit code is non-zero
+func interruptedError(ctx context.Context, what string) error {
+	// an interruption isn't a usage mistake, so don't print the flag help
+	rootCmd.SilenceUsage = true
+	cause := ctx.Err()
+	if cause == nil {
+		cause = context.Canceled
+	}
+	return fmt.Errorf("%s interrupted (finished batches were committed): %w", what, cause)
+}
+
+// logFetchCMResume prints what an interrupted leaderboard sweep left undone and
+// the commands that finish it
+func logFetchCMResume(result *pipeline.FetchCMResult, realms, dungeons []string) {
+	regions := make([]string, 0, len(result.Pending))
+	for region := range result.Pending {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	log.Warn("leaderboard sweep interrupted",
+		"runs", result.TotalRuns,
+		"players", result.TotalPlayers,
+		"duration", result.Duration,
+		"pending_regions", len(regions))
+
+	for _, region := range regions {
+		periods := result.Pending[region]
+		args := []string{"ookstats", "fetch", "cm", "--regions", region}
+		described := "all"
+		if len(periods) > 0 {
+			described = strings.Join(periods, ",")
+			args = append(args, "--periods", described)
+		}
+		if len(realms) > 0 {
+			args = append(args, "--realms", strings.Join(realms, ","))
+		}
+		if len(dungeons) > 0 {
+			args = append(args, "--dungeons", strings.Join(dungeons, ","))
+		}
+		log.Warn("pending", "region", strings.ToUpper(region), "periods", described, "resume", strings.Join(args, " "))
+	}
+}
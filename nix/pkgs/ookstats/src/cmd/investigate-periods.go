@@ -6,6 +6,8 @@ import (
 
 	"github.com/spf13/cobra"
 	"ookstats/internal/blizzard"
+	"ookstats/internal/database"
+	"ookstats/internal/pipeline"
 )
 
 // investigatePeriodsCmd analyzes period overlap and leaderboard size limits
@@ -26,6 +28,7 @@ var investigatePeriodsCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("blizzard client: %w", err)
 		}
+		defer client.Close()
 
 		// Parse period range (e.g., "995-1036")
 		var startPeriod, endPeriod int
@@ -34,21 +37,28 @@ var investigatePeriodsCmd = &cobra.Command{
 		}
 
 		// Find realm and dungeon info
-		allRealms := blizzard.GetAllRealms()
-		var realmInfo blizzard.RealmInfo
-		var found bool
-		for _, r := range allRealms {
-			if r.Slug == realmSlug {
-				realmInfo = r
-				found = true
-				break
-			}
+		db, err := database.Connect()
+		if err != nil {
+			return fmt.Errorf("db connect: %w", err)
 		}
+		defer db.Close()
+
+		allRealms, err := pipeline.LoadRealms(database.NewDatabaseService(db))
+		if err != nil {
+			return err
+		}
+		realmInfo, found := pipeline.FindRealm(allRealms, "", realmSlug)
 		if !found {
 			return fmt.Errorf("realm not found: %s", realmSlug)
 		}
 
+		// prefer the realm's own leaderboard index so new dungeons can be investigated
 		_, dungeons := blizzard.GetHardcodedPeriodAndDungeons()
+		if index, err := client.FetchLeaderboardIndex(cmd.Context(), realmInfo); err != nil {
+			fmt.Printf("Leaderboard index unavailable (%v), using built-in dungeon list\n", err)
+		} else if discovered := index.Dungeons(); len(discovered) > 0 {
+			dungeons = discovered
+		}
 		var dungeonInfo blizzard.DungeonInfo
 		found = false
 		for _, d := range dungeons {
@@ -80,8 +90,12 @@ var investigatePeriodsCmd = &cobra.Command{
 		// Fetch each period
 		for period := startPeriod; period <= endPeriod; period++ {
 			periodStr := fmt.Sprintf("%d", period)
-			lb, err := client.FetchLeaderboardData(realmInfo, dungeonInfo, periodStr)
+			lb, err := client.FetchLeaderboardData(cmd.Context(), realmInfo, dungeonInfo, periodStr)
 			if err != nil {
+				if cmd.Context().Err() != nil {
+					fmt.Printf("Interrupted at period %d; analyzing periods fetched so far\n", period)
+					break
+				}
 				fmt.Printf("Period %d: ERROR - %v\n", period, err)
 				continue
 			}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"ookstats/internal/mockapi"
+)
+
+// mockAPICmd serves fixture responses in place of the Blizzard API for offline runs
+var mockAPICmd = &cobra.Command{
+	Use:   "mock-api",
+	Short: "Serve a local stand-in for the Blizzard API from fixtures",
+	Long: `Serves leaderboard, season, and character profile responses from a fixtures directory,
+plus a fake OAuth token endpoint, so the pipeline can run without network access.
+
+Fixtures are laid out as <region>/<api path>.json, for example:
+  us/data/wow/connected-realm/4372/mythic-leaderboard/2/period/1036.json
+  us/data/wow/mythic-keystone/season/index.json
+  us/data/wow/mythic-keystone/season/11.json
+  us/profile/wow/character/atiesh/somename.json             (summary)
+  us/profile/wow/character/atiesh/somename/equipment.json
+  us/profile/wow/character/atiesh/somename/character-media.json
+  us/profile/wow/character/atiesh/somename/status.json
+  us/profile/wow/character/atiesh/somename/achievements.json
+
+Missing fixtures answer 404. Point other commands at the server with
+--api-base-url http://ADDR --oauth-url http://ADDR/token (or the BLIZZARD_API_BASE_URL /
+BLIZZARD_OAUTH_URL env vars); any non-empty client ID and secret are accepted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _ := cmd.Flags().GetString("addr")
+		fixtures, _ := cmd.Flags().GetString("fixtures")
+		faultSpecs, _ := cmd.Flags().GetStringSlice("fault")
+		rate404, _ := cmd.Flags().GetFloat64("rate-404")
+		rate429, _ := cmd.Flags().GetFloat64("rate-429")
+		rate5xx, _ := cmd.Flags().GetFloat64("rate-5xx")
+		retryAfter, _ := cmd.Flags().GetInt("retry-after-seconds")
+		seed, _ := cmd.Flags().GetInt64("seed")
+		verbose, _ := cmd.InheritedFlags().GetBool("verbose")
+
+		if fixtures == "" {
+			return fmt.Errorf("--fixtures is required")
+		}
+		for _, rate := range []float64{rate404, rate429, rate5xx} {
+			if rate < 0 || rate > 1 {
+				return fmt.Errorf("fault rates must be between 0 and 1")
+			}
+		}
+		if rate404+rate429+rate5xx > 1 {
+			return fmt.Errorf("combined fault rates must not exceed 1")
+		}
+
+		var faults []mockapi.Fault
+		for _, spec := range faultSpecs {
+			f, err := mockapi.ParseFault(spec)
+			if err != nil {
+				return err
+			}
+			faults = append(faults, f)
+		}
+
+		handler, err := mockapi.NewServer(mockapi.Options{
+			FixturesDir:         fixtures,
+			Faults:              faults,
+			NotFoundRate:        rate404,
+			TooManyRequestsRate: rate429,
+			ServerErrorRate:     rate5xx,
+			RetryAfter:          time.Duration(retryAfter) * time.Second,
+			Seed:                seed,
+			Verbose:             verbose,
+		})
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+
+		srv := &http.Server{Addr: addr, Handler: handler}
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- srv.ListenAndServe()
+		}()
+
+		log.Info("mock api listening", "addr", addr, "fixtures", fixtures)
+		log.Info("use with", "api-base-url", "http://"+addr, "oauth-url", "http://"+addr+"/token")
+
+		select {
+		case err := <-errCh:
+			if !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("mock api: %w", err)
+			}
+			return nil
+		case <-ctx.Done():
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		log.Info("shutting down mock api")
+		return srv.Shutdown(shutdownCtx)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mockAPICmd)
+	mockAPICmd.Flags().String("addr", "127.0.0.1:8089", "Listen address")
+	mockAPICmd.Flags().String("fixtures", "", "Fixtures directory (required)")
+	mockAPICmd.Flags().StringSlice("fault", nil, "Force a status for matching paths, e.g. --fault mythic-leaderboard/2/=503 (repeatable)")
+	mockAPICmd.Flags().Float64("rate-404", 0, "Probability (0-1) of answering a request with 404")
+	mockAPICmd.Flags().Float64("rate-429", 0, "Probability (0-1) of answering a request with 429")
+	mockAPICmd.Flags().Float64("rate-5xx", 0, "Probability (0-1) of answering a request with 503")
+	mockAPICmd.Flags().Int("retry-after-seconds", 1, "Retry-After header sent with injected 429 responses")
+	mockAPICmd.Flags().Int64("seed", 0, "Random seed for fault injection (0 = time-based)")
+}
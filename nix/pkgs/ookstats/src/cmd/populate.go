@@ -138,8 +138,11 @@ func populateItems(db *sql.DB, wowsimsDBPath string) error {
 		}
 
 		_, err = tx.Exec(`
-			INSERT OR REPLACE INTO items (id, name, icon, quality, type, stats)
+			INSERT INTO items (id, name, icon, quality, type, stats)
 			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET
+				name = excluded.name, icon = excluded.icon, quality = excluded.quality,
+				type = excluded.type, stats = excluded.stats
 		`, item.ID, item.Name, item.Icon, item.Quality, item.Type, "{}")
 
 		if err != nil {
@@ -163,8 +166,11 @@ func populateItems(db *sql.DB, wowsimsDBPath string) error {
 		}
 
 		_, err = tx.Exec(`
-			INSERT OR REPLACE INTO items (id, name, icon, quality, type, stats)
+			INSERT INTO items (id, name, icon, quality, type, stats)
 			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET
+				name = excluded.name, icon = excluded.icon, quality = excluded.quality,
+				type = excluded.type, stats = excluded.stats
 		`, gem.ID, gem.Name, gem.Icon, gem.Quality, 99, string(statsJSON)) // Use type 99 for gems
 
 		if err != nil {
@@ -196,8 +202,11 @@ func populateItems(db *sql.DB, wowsimsDBPath string) error {
 		}
 
 		_, err = tx.Exec(`
-			INSERT OR REPLACE INTO items (id, name, icon, quality, type, stats)
+			INSERT INTO items (id, name, icon, quality, type, stats)
 			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET
+				name = excluded.name, icon = excluded.icon, quality = excluded.quality,
+				type = excluded.type, stats = excluded.stats
 		`, id, enchant.Name, enchant.Icon, enchant.Quality, 98, string(statsJSON)) // Use type 98 for enchants
 
 		if err != nil {
@@ -16,24 +16,45 @@ var processCmd = &cobra.Command{
 
 var processAllCmd = &cobra.Command{
 	Use:   "all",
-	Short: "Process all data (players + rankings)",
-	Long:  `Process all data: player aggregations, player rankings, and run rankings.`,
+	Short: "Process all data (rankings + players)",
+	Long: `Process all data: run rankings, player aggregations, and player rankings.
+
+Only the season/dungeon partitions and players that fetches changed since the last run
+are recomputed. Everything is rebuilt with --full, on the first run, and whenever the
+dungeons, realms or seasons changed. --verify checks an incremental pass against a full
+rebuild and fails without committing if they differ.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		full, _ := cmd.Flags().GetBool("full")
+		verify, _ := cmd.Flags().GetBool("verify")
+		verbose, _ := cmd.InheritedFlags().GetBool("verbose")
+
 		fmt.Println("=== Complete Data Processing ===")
 
-		// step 1: process players (aggregations + rankings)
-		fmt.Println("\n=== Step 1: Processing Players ===")
-		if err := processPlayersCmd.RunE(cmd, args); err != nil {
-			return fmt.Errorf("player processing failed: %w", err)
+		db, err := database.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
 		}
+		defer db.Close()
 
-		// step 2: process run rankings
-		fmt.Println("\n=== Step 2: Processing Run Rankings ===")
-		if err := processRankingsCmd.RunE(cmd, args); err != nil {
-			return fmt.Errorf("run ranking processing failed: %w", err)
+		fmt.Printf("Connected to database: %s\n", database.DBFilePath())
+
+		res, err := pipeline.ProcessAll(cmd.Context(), database.NewDatabaseService(db), pipeline.ProcessAllOptions{
+			Full:    full,
+			Verify:  verify,
+			Verbose: verbose,
+		})
+		if err != nil {
+			return err
 		}
 
-		fmt.Printf("\n[OK] Complete data processing finished!\n")
+		if res.Full {
+			fmt.Printf("\n[OK] Full rebuild finished (%s)\n", res.Reason)
+		} else {
+			fmt.Printf("\n[OK] Incremental pass finished: %d partition(s), %d player(s)\n", res.Partitions, res.Players)
+			if res.Verified {
+				fmt.Printf("[OK] Matches a full rebuild\n")
+			}
+		}
 		fmt.Printf("\nNext steps:\n")
 		fmt.Printf("  1. Run 'ookstats generate api --out web/public' to update JSON files\n")
 		fmt.Printf("  2. Build and deploy the website\n")
@@ -60,7 +81,7 @@ var processRankingsCmd = &cobra.Command{
 			Verbose: verbose,
 		}
 
-		if err := pipeline.ProcessRunRankings(db, opts); err != nil {
+		if err := pipeline.ProcessRunRankings(cmd.Context(), database.NewDatabaseService(db), opts); err != nil {
 			return err
 		}
 
@@ -90,7 +111,7 @@ var processPlayersCmd = &cobra.Command{
 			Verbose: verbose,
 		}
 
-		profilesCreated, qualifiedPlayers, err := pipeline.ProcessPlayers(db, opts)
+		profilesCreated, qualifiedPlayers, err := pipeline.ProcessPlayers(cmd.Context(), database.NewDatabaseService(db), opts)
 		if err != nil {
 			return err
 		}
@@ -152,4 +173,7 @@ func init() {
 	processCmd.AddCommand(processRankingsCmd)
 	processCmd.AddCommand(processPlayersCmd)
 	processCmd.AddCommand(processProfilesCmd)
+
+	processAllCmd.Flags().Bool("full", false, "Rebuild every ranking instead of only what changed")
+	processAllCmd.Flags().Bool("verify", false, "Compare an incremental pass with a full rebuild and fail if they differ")
 }
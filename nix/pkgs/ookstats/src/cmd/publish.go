@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"ookstats/internal/publish"
+)
+
+var publishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Upload the generated static API to S3-compatible storage",
+	Long: `Uploads <out>/api to --target (s3://bucket/prefix) on any S3-compatible endpoint
+(AWS, R2, MinIO, ...), using AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and optionally
+AWS_SESSION_TOKEN. --endpoint defaults to AWS_ENDPOINT_URL, --region to AWS_REGION.
+
+Only files whose sha256 differs from the manifest.json published under the prefix
+are uploaded; keys published without a manifest are compared by ETag. Files get a
+Content-Type from their extension and --cache-control. With --precompress, each
+changed file is also uploaded as <key>.gz and/or <key>.br with Content-Encoding set.
+
+Files keep the <prefix><path> keys the site fetches. manifest.json is the pointer
+to the published generation: it is uploaded last, with Cache-Control: no-cache, so
+it only names a generation once all of its files are in place. Keys the previous
+manifest listed that are gone from the new one are deleted afterwards; anything else
+under the bucket, such as database backups, is left alone.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, _ := cmd.Flags().GetString("target")
+		outDir, _ := cmd.Flags().GetString("out")
+		endpoint, _ := cmd.Flags().GetString("endpoint")
+		region, _ := cmd.Flags().GetString("region")
+		cacheControl, _ := cmd.Flags().GetString("cache-control")
+		precompress, _ := cmd.Flags().GetString("precompress")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		if strings.TrimSpace(target) == "" {
+			return errors.New("--target is required")
+		}
+		if endpoint == "" {
+			endpoint = os.Getenv("AWS_ENDPOINT_URL")
+		}
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+
+		res, err := publish.Run(cmd.Context(), publish.Options{
+			Dir:          filepath.Join(outDir, "api"),
+			Target:       target,
+			Endpoint:     endpoint,
+			Region:       region,
+			CacheControl: cacheControl,
+			Precompress:  splitCSV(strings.ToLower(precompress)),
+			Concurrency:  concurrency,
+			DryRun:       dryRun,
+		})
+		if err != nil {
+			return err
+		}
+		log.Info("publish complete",
+			"target", target,
+			"generation", res.Generation,
+			"uploaded", res.Uploaded,
+			"unchanged", res.Unchanged,
+			"variants", res.Variants,
+			"deleted", res.Deleted,
+			"dry_run", dryRun)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(publishCmd)
+	publishCmd.Flags().String("target", "", "Destination as s3://bucket/prefix")
+	publishCmd.Flags().String("out", "public", "Output directory the static API was generated into")
+	publishCmd.Flags().String("endpoint", "", "S3 endpoint URL (default: $AWS_ENDPOINT_URL, else AWS)")
+	publishCmd.Flags().String("region", "", "Signing region (default: $AWS_REGION, else us-east-1)")
+	publishCmd.Flags().String("cache-control", "public, max-age=300", "Cache-Control for uploaded files")
+	publishCmd.Flags().String("precompress", "", "Also upload precompressed variants: gzip, br or gzip,br")
+	publishCmd.Flags().Int("concurrency", 16, "Number of parallel uploads")
+	publishCmd.Flags().Bool("dry-run", false, "List what would be uploaded and deleted without changing the bucket")
+}
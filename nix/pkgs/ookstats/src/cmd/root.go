@@ -6,6 +6,7 @@ import (
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
+	"ookstats/internal/blizzard"
 	"ookstats/internal/database"
 )
 
@@ -50,7 +51,10 @@ func initLogger(verbose bool) {
 // execute adds all child commands to the root command and sets flags appropriately.
 // this is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, cancel := signalContext()
+	err := rootCmd.ExecuteContext(ctx)
+	cancel()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -62,6 +66,15 @@ func init() {
 
 	// global flag for local db path
 	rootCmd.PersistentFlags().String("db-file", "", "Path to local SQLite database file (default: local.db). Also reads OOKSTATS_DB or ASTRO_DATABASE_FILE.")
+	rootCmd.PersistentFlags().String("database-url", "", "PostgreSQL URL (postgres://...) to use instead of the local SQLite file. Also reads OOKSTATS_DATABASE_URL.")
+	// global Blizzard endpoint overrides (e.g. point at `ookstats mock-api`)
+	rootCmd.PersistentFlags().String("api-base-url", "", "Blizzard API base URL, or region=url pairs (default: https://{region}.api.blizzard.com). Also reads BLIZZARD_API_BASE_URL.")
+	rootCmd.PersistentFlags().String("oauth-url", "", "OAuth token endpoint, or region=url pairs (default: https://oauth.battle.net/token). Also reads BLIZZARD_OAUTH_URL.")
+	// global record/replay of raw Blizzard API responses
+	rootCmd.PersistentFlags().String("record", "", "Record every Blizzard API response to a compressed archive in this directory")
+	rootCmd.PersistentFlags().String("replay", "", "Answer Blizzard API requests only from the archive in this directory (no network)")
+	// global per-region request budgets
+	rootCmd.PersistentFlags().String("rate-quota", "", "Blizzard API request budget as PER_SECOND/PER_HOUR[/BURST], or region=... pairs (default: 90/36000/20)")
 	// global verbose flag
 	rootCmd.PersistentFlags().Bool("verbose", false, "Enable verbose logging for debugging and benchmarking")
 
@@ -75,5 +88,25 @@ func init() {
 		if v, _ := cmd.Flags().GetString("db-file"); v != "" {
 			database.SetDBPath(v)
 		}
+		if v, _ := cmd.Flags().GetString("database-url"); v != "" {
+			database.SetDatabaseURL(v)
+		}
+
+		// Set Blizzard endpoint overrides
+		if v, _ := cmd.Flags().GetString("api-base-url"); v != "" {
+			blizzard.SetAPIBaseURL(v)
+		}
+		if v, _ := cmd.Flags().GetString("oauth-url"); v != "" {
+			blizzard.SetOAuthTokenURL(v)
+		}
+		if v, _ := cmd.Flags().GetString("record"); v != "" {
+			blizzard.SetRecordDir(v)
+		}
+		if v, _ := cmd.Flags().GetString("replay"); v != "" {
+			blizzard.SetReplayDir(v)
+		}
+		if v, _ := cmd.Flags().GetString("rate-quota"); v != "" {
+			blizzard.SetRateQuotas(v)
+		}
 	}
 }
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"ookstats/internal/database"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -10,13 +11,13 @@ import (
 var schemaCmd = &cobra.Command{
 	Use:   "schema",
 	Short: "Database schema management",
-	Long:  `Manage database schema creation and initialization.`,
+	Long:  `Manage database schema creation, versioned migrations and rollbacks.`,
 }
 
 var schemaInitCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize database schema",
-	Long:  `Create all required tables and indexes for the ookstats database.`,
+	Long:  `Create all required tables and indexes for the ookstats database by applying every pending migration.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("=== Database Schema Initialization ===")
 
@@ -41,12 +42,54 @@ var schemaInitCmd = &cobra.Command{
 	},
 }
 
+var schemaStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show applied and pending schema migrations",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer db.Close()
+
+		statuses, err := database.GetMigrationStatus(db)
+		if err != nil {
+			return fmt.Errorf("failed to read migration status: %w", err)
+		}
+		current, err := database.SchemaVersion(db)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\nBackend: %s\n", database.DialectOf(db))
+		fmt.Printf("Schema version: %d (latest %d)\n\n", current, database.LatestSchemaVersion())
+		fmt.Printf("%-8s %-32s %-9s %s\n", "VERSION", "NAME", "STATE", "APPLIED AT")
+		modified := 0
+		for _, st := range statuses {
+			state, appliedAt := "pending", "-"
+			if st.Applied {
+				state = "applied"
+				appliedAt = time.UnixMilli(st.AppliedAt).UTC().Format(time.RFC3339)
+			}
+			if st.Modified {
+				state = "MODIFIED"
+				modified++
+			}
+			fmt.Printf("%-8d %-32s %-9s %s\n", st.Version, st.Name, state, appliedAt)
+		}
+		if modified > 0 {
+			return fmt.Errorf("%d applied migration(s) no longer match their recorded checksum", modified)
+		}
+		return nil
+	},
+}
+
 var schemaMigrateCmd = &cobra.Command{
 	Use:   "migrate",
-	Short: "Migrate database schema to add season_id column",
-	Long:  `Adds the season_id column to challenge_runs table if it doesn't exist.`,
+	Short: "Apply pending schema migrations",
+	Long:  `Apply pending schema migrations in order, up to --to (default: latest).`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		fmt.Println("=== Database Schema Migration ===")
+		to, _ := cmd.Flags().GetInt("to")
 
 		db, err := database.Connect()
 		if err != nil {
@@ -54,37 +97,68 @@ var schemaMigrateCmd = &cobra.Command{
 		}
 		defer db.Close()
 
-		fmt.Printf("Connected to database: %s\n", database.DBFilePath())
-
-		// check if season_id column already exists
-		var columnExists int
-		err = db.QueryRow(`
-			SELECT COUNT(*)
-			FROM pragma_table_info('challenge_runs')
-			WHERE name = 'season_id'
-		`).Scan(&columnExists)
+		current, err := database.SchemaVersion(db)
 		if err != nil {
-			return fmt.Errorf("failed to check column existence: %w", err)
+			return err
+		}
+		if to > 0 && to < current {
+			return fmt.Errorf("database is at version %d; use 'schema rollback --to %d' to go back", current, to)
 		}
 
-		if columnExists > 0 {
-			fmt.Println("[SKIP] season_id column already exists")
+		applied, err := database.Migrate(db, to)
+		if err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		version, err := database.SchemaVersion(db)
+		if err != nil {
+			return err
+		}
+		if applied == 0 {
+			fmt.Printf("[SKIP] Schema already at version %d\n", version)
 			return nil
 		}
+		fmt.Printf("\n[OK] Applied %d migration(s); schema at version %d\n", applied, version)
+		return nil
+	},
+}
 
-		fmt.Println("Adding season_id column to challenge_runs table...")
-		_, err = db.Exec("ALTER TABLE challenge_runs ADD COLUMN season_id INTEGER")
+var schemaRollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Revert schema migrations",
+	Long:  `Revert the most recent schema migration, or every migration newer than --to.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Connect()
 		if err != nil {
-			return fmt.Errorf("failed to add season_id column: %w", err)
+			return fmt.Errorf("failed to connect to database: %w", err)
 		}
+		defer db.Close()
 
-		fmt.Println("Creating index on season_id...")
-		_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_challenge_runs_season ON challenge_runs(season_id, dungeon_id, completed_timestamp)")
+		current, err := database.SchemaVersion(db)
 		if err != nil {
-			return fmt.Errorf("failed to create index: %w", err)
+			return err
+		}
+		if current == 0 {
+			fmt.Println("[SKIP] No migrations applied")
+			return nil
+		}
+
+		to := current - 1
+		if cmd.Flags().Changed("to") {
+			to, _ = cmd.Flags().GetInt("to")
+		}
+		if to >= current {
+			return fmt.Errorf("database is at version %d; nothing to roll back to %d", current, to)
 		}
 
-		fmt.Printf("\n[OK] Schema migration complete!\n")
+		reverted, err := database.Rollback(db, to)
+		if err != nil {
+			return fmt.Errorf("rollback failed: %w", err)
+		}
+		version, err := database.SchemaVersion(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n[OK] Reverted %d migration(s); schema at version %d\n", reverted, version)
 		return nil
 	},
 }
@@ -92,5 +166,10 @@ var schemaMigrateCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(schemaCmd)
 	schemaCmd.AddCommand(schemaInitCmd)
+	schemaCmd.AddCommand(schemaStatusCmd)
 	schemaCmd.AddCommand(schemaMigrateCmd)
+	schemaCmd.AddCommand(schemaRollbackCmd)
+
+	schemaMigrateCmd.Flags().Int("to", 0, "target schema version (default: latest)")
+	schemaRollbackCmd.Flags().Int("to", 0, "roll back every migration newer than this version (default: one step)")
 }
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"ookstats/internal/database"
+)
+
+var sightingsCmd = &cobra.Command{
+	Use:   "sightings",
+	Short: "Query which leaderboards and periods listed each run",
+	Long: `Every fetch records the (realm, dungeon, period) leaderboards a run appeared on,
+with the first and last time it was seen there. Use these commands to trace a run
+across periods, spot runs that dropped off a leaderboard, and check coverage.`,
+}
+
+var sightingsRunCmd = &cobra.Command{
+	Use:   "run <run-id>",
+	Short: "Show every leaderboard a run was seen on",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runID, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid run id %q", args[0])
+		}
+
+		db, err := database.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer db.Close()
+
+		sightings, err := database.NewDatabaseService(db).GetRunSightings(runID)
+		if err != nil {
+			return err
+		}
+		if len(sightings) == 0 {
+			return fmt.Errorf("no sightings recorded for run %d", runID)
+		}
+
+		fmt.Printf("%-8s %-6s %-24s %-32s %-20s %s\n", "PERIOD", "REGION", "REALM", "DUNGEON", "FIRST SEEN", "LAST SEEN")
+		for _, s := range sightings {
+			fmt.Printf("%-8d %-6s %-24s %-32s %-20s %s\n",
+				s.PeriodID, s.Region, s.RealmSlug, s.DungeonSlug, formatSeenAt(s.FirstSeenAt), formatSeenAt(s.LastSeenAt))
+		}
+		return nil
+	},
+}
+
+var sightingsLeaderboardCmd = &cobra.Command{
+	Use:   "leaderboard",
+	Short: "List the runs seen on one realm/dungeon/period leaderboard",
+	Long: `Lists the runs seen on a leaderboard with their first and last sighting. Runs
+missing from the most recent fetch of that leaderboard are marked as dropped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		region, _ := cmd.Flags().GetString("region")
+		realmSlug, _ := cmd.Flags().GetString("realm")
+		dungeonSlug, _ := cmd.Flags().GetString("dungeon")
+		periodID, _ := cmd.Flags().GetInt("period")
+		droppedOnly, _ := cmd.Flags().GetBool("dropped")
+
+		if realmSlug == "" || dungeonSlug == "" || periodID <= 0 {
+			return fmt.Errorf("--realm, --dungeon, and --period are required")
+		}
+
+		db, err := database.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer db.Close()
+
+		sightings, err := database.NewDatabaseService(db).GetLeaderboardSightings(strings.ToLower(region), realmSlug, dungeonSlug, periodID)
+		if err != nil {
+			return err
+		}
+
+		dropped := 0
+		fmt.Printf("%-10s %-10s %-20s %-20s %-20s %s\n", "RUN", "DURATION", "COMPLETED", "FIRST SEEN", "LAST SEEN", "STATE")
+		for _, s := range sightings {
+			state := "listed"
+			if s.Dropped {
+				state = "dropped"
+				dropped++
+			} else if droppedOnly {
+				continue
+			}
+			fmt.Printf("%-10d %-10s %-20s %-20s %-20s %s\n",
+				s.RunID,
+				(time.Duration(s.Duration) * time.Millisecond).String(),
+				time.UnixMilli(s.CompletedTimestamp).UTC().Format("2006-01-02 15:04:05"),
+				formatSeenAt(s.FirstSeenAt), formatSeenAt(s.LastSeenAt), state)
+		}
+		fmt.Printf("\n%d run(s) seen, %d dropped since an earlier fetch\n", len(sightings), dropped)
+		return nil
+	},
+}
+
+var sightingsSummaryCmd = &cobra.Command{
+	Use:   "summary",
+	Short: "Summarize sighting coverage per period and recent new vs re-sighted runs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		region, _ := cmd.Flags().GetString("region")
+		since, _ := cmd.Flags().GetDuration("since")
+
+		db, err := database.Connect()
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer db.Close()
+		dbService := database.NewDatabaseService(db)
+
+		coverage, err := dbService.GetSightingCoverage(strings.ToLower(region))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%-6s %-8s %-13s %-8s %-20s %s\n", "REGION", "PERIOD", "LEADERBOARDS", "RUNS", "FIRST SEEN", "LAST SEEN")
+		for _, c := range coverage {
+			fmt.Printf("%-6s %-8d %-13d %-8d %-20s %s\n",
+				c.Region, c.PeriodID, c.Leaderboards, c.Runs, formatSeenAt(c.FirstSeenAt), formatSeenAt(c.LastSeenAt))
+		}
+
+		if since > 0 {
+			newRuns, resighted, err := dbService.CountSightingsSince(time.Now().Add(-since).UnixMilli())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("\nSeen in the last %s: %d new run(s), %d re-sighted\n", since, newRuns, resighted)
+		}
+		return nil
+	},
+}
+
+// formatSeenAt renders a sighting timestamp; runs merged from before sightings were
+// recorded have none
+func formatSeenAt(ts sql.NullInt64) string {
+	if !ts.Valid {
+		return "-"
+	}
+	return time.UnixMilli(ts.Int64).UTC().Format("2006-01-02 15:04:05")
+}
+
+func init() {
+	rootCmd.AddCommand(sightingsCmd)
+	sightingsCmd.AddCommand(sightingsRunCmd)
+	sightingsCmd.AddCommand(sightingsLeaderboardCmd)
+	sightingsCmd.AddCommand(sightingsSummaryCmd)
+
+	sightingsLeaderboardCmd.Flags().String("region", "us", "Region of the realm")
+	sightingsLeaderboardCmd.Flags().String("realm", "", "Realm slug")
+	sightingsLeaderboardCmd.Flags().String("dungeon", "", "Dungeon slug")
+	sightingsLeaderboardCmd.Flags().Int("period", 0, "Keystone period ID")
+	sightingsLeaderboardCmd.Flags().Bool("dropped", false, "Only list runs that dropped off the leaderboard")
+
+	sightingsSummaryCmd.Flags().String("region", "", "Limit to one region (us,eu,kr,tw)")
+	sightingsSummaryCmd.Flags().Duration("since", 24*time.Hour, "Also count new vs re-sighted runs seen within this window (0 to skip)")
+}
@@ -0,0 +1,347 @@
+package blizzard
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Archive layout (shared by record and replay):
+//
+//	<dir>/journal.jsonl             one archiveEntry per response, in the order received
+//	<dir>/objects/ab/abcdef....gz   gzip-compressed response bodies keyed by sha256 of the raw body
+//
+// Identical bodies are stored once, so hourly recordings into the same directory
+// stay small. Token requests are never recorded since they carry credentials.
+const (
+	archiveJournalFile = "journal.jsonl"
+	archiveObjectsDir  = "objects"
+)
+
+var (
+	recordDirOverride string
+	replayDirOverride string
+)
+
+// SetRecordDir makes new clients save every API response to an archive in dir
+func SetRecordDir(dir string) {
+	recordDirOverride = dir
+}
+
+// SetReplayDir makes new clients answer every API request from the archive in dir
+func SetReplayDir(dir string) {
+	replayDirOverride = dir
+}
+
+// archiveEntry is a single recorded request/response pair
+type archiveEntry struct {
+	Key    string `json:"key"`
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// RequestHeader holds the request's conditional headers, if it sent any
+	RequestHeader http.Header `json:"request_header,omitempty"`
+	Status        int         `json:"status"`
+	Header        http.Header `json:"header"`
+	BodySHA256    string      `json:"body_sha256"`
+	BodySize      int         `json:"body_size"`
+	RecordedAt    int64       `json:"recorded_at"`
+}
+
+// archiveConditionalHeaders are the request headers that change which response a
+// request gets, so they are recorded and keyed on
+var archiveConditionalHeaders = []string{"If-None-Match", "If-Modified-Since"}
+
+// archiveKey canonicalizes a request so equivalent URLs map to the same entry. A
+// conditional request's validators are part of its key, so a recorded 304 only
+// answers a request carrying the same validators.
+func archiveKey(method string, u *url.URL, header http.Header) string {
+	canon := *u
+	canon.RawQuery = u.Query().Encode() // sorted by key
+	canon.Fragment = ""
+	key := method + " " + canon.String()
+	for _, name := range archiveConditionalHeaders {
+		if v := header.Get(name); v != "" {
+			key += " " + name + "=" + v
+		}
+	}
+	return key
+}
+
+// conditionalHeaders returns the conditional headers of a request, or nil if none are set
+func conditionalHeaders(header http.Header) http.Header {
+	var out http.Header
+	for _, name := range archiveConditionalHeaders {
+		if v := header.Get(name); v != "" {
+			if out == nil {
+				out = http.Header{}
+			}
+			out.Set(name, v)
+		}
+	}
+	return out
+}
+
+func archiveObjectPath(dir, sum string) string {
+	return filepath.Join(dir, archiveObjectsDir, sum[:2], sum+".gz")
+}
+
+// recordingTransport passes requests through and archives each response
+type recordingTransport struct {
+	dir  string
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	journal *os.File
+}
+
+func newRecordingTransport(dir string, next http.RoundTripper) (*recordingTransport, error) {
+	if err := os.MkdirAll(filepath.Join(dir, archiveObjectsDir), 0o755); err != nil {
+		return nil, fmt.Errorf("create archive dir: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, archiveJournalFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open archive journal: %w", err)
+	}
+	return &recordingTransport{dir: dir, next: next, journal: f}, nil
+}
+
+// Close flushes the journal to disk and closes it
+func (t *recordingTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.journal == nil {
+		return nil
+	}
+	syncErr := t.journal.Sync()
+	closeErr := t.journal.Close()
+	t.journal = nil
+	if syncErr != nil {
+		return fmt.Errorf("sync archive journal: %w", syncErr)
+	}
+	return closeErr
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := t.record(req, resp, body); err != nil {
+		return nil, fmt.Errorf("record response: %w", err)
+	}
+	return resp, nil
+}
+
+func (t *recordingTransport) record(req *http.Request, resp *http.Response, body []byte) error {
+	sum := sha256.Sum256(body)
+	hexSum := hex.EncodeToString(sum[:])
+
+	if err := writeArchiveObject(archiveObjectPath(t.dir, hexSum), body); err != nil {
+		return err
+	}
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+
+	entry := archiveEntry{
+		Key:           archiveKey(req.Method, req.URL, req.Header),
+		Method:        req.Method,
+		URL:           req.URL.String(),
+		RequestHeader: conditionalHeaders(req.Header),
+		Status:        resp.StatusCode,
+		Header:        header,
+		BodySHA256:    hexSum,
+		BodySize:      len(body),
+		RecordedAt:    time.Now().UnixMilli(),
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.journal == nil {
+		return fmt.Errorf("archive journal is closed")
+	}
+	_, err = t.journal.Write(line)
+	return err
+}
+
+// writeArchiveObject stores a gzip-compressed body unless it already exists
+func writeArchiveObject(path string, body []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), ".tmp-*.gz")
+	if err != nil {
+		return err
+	}
+	tmp := tmpFile.Name()
+
+	zw := gzip.NewWriter(tmpFile)
+	if _, err := zw.Write(body); err != nil {
+		zw.Close()
+		tmpFile.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// concurrent writers of the same object produce identical content, so last rename wins
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// replayTransport answers requests only from an archive. Repeated requests for the
+// same URL are answered in recorded order (e.g. a 429 followed by a 200); once the
+// recorded responses are exhausted the last one is repeated. A conditional request
+// with no recording for its validators gets the unconditional recording, since a
+// full response is a valid answer to any conditional request.
+type replayTransport struct {
+	dir string
+
+	mu      sync.Mutex
+	entries map[string][]archiveEntry
+	served  map[string]int
+}
+
+func newReplayTransport(dir string) (*replayTransport, error) {
+	f, err := os.Open(filepath.Join(dir, archiveJournalFile))
+	if err != nil {
+		return nil, fmt.Errorf("open archive journal: %w", err)
+	}
+	defer f.Close()
+
+	entries := make(map[string][]archiveEntry)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e archiveEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("archive journal line %d: %w", lineNo, err)
+		}
+		entries[e.Key] = append(entries[e.Key], e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read archive journal: %w", err)
+	}
+
+	return &replayTransport{dir: dir, entries: entries, served: make(map[string]int)}, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+
+	// the client only POSTs for OAuth tokens; hand out a placeholder
+	if req.Method == http.MethodPost {
+		body := []byte(`{"access_token":"replay","token_type":"bearer","expires_in":86399}`)
+		return replayResponse(req, http.StatusOK, http.Header{"Content-Type": {"application/json"}}, body), nil
+	}
+
+	key := archiveKey(req.Method, req.URL, req.Header)
+
+	t.mu.Lock()
+	recorded := t.entries[key]
+	if len(recorded) == 0 {
+		key = archiveKey(req.Method, req.URL, nil)
+		recorded = t.entries[key]
+	}
+	if len(recorded) == 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("replay: no archived response for %s", key)
+	}
+	idx := t.served[key]
+	if idx >= len(recorded) {
+		idx = len(recorded) - 1
+	} else {
+		t.served[key] = idx + 1
+	}
+	entry := recorded[idx]
+	t.mu.Unlock()
+
+	body, err := readArchiveObject(archiveObjectPath(t.dir, entry.BodySHA256))
+	if err != nil {
+		return nil, fmt.Errorf("replay: read body for %s: %w", key, err)
+	}
+
+	return replayResponse(req, entry.Status, entry.Header.Clone(), body), nil
+}
+
+func readArchiveObject(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+func replayResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, strings.TrimSpace(http.StatusText(status))),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,114 @@
+package blizzard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultOAuthTokenURL is the Battle.net OAuth endpoint used for the client-credentials grant
+	DefaultOAuthTokenURL = "https://oauth.battle.net/token"
+	// tokenRefreshMargin refreshes tokens this long before they actually expire
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// tokenSource mints and caches OAuth access tokens via the client-credentials grant.
+// Tokens are shared by all requests and refreshed shortly before they expire.
+type tokenSource struct {
+	clientID     string
+	clientSecret string
+	tokenURL     string
+	httpClient   *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// tokenResponse is the OAuth token endpoint payload
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func newTokenSource(clientID, clientSecret, tokenURL string, httpClient *http.Client) *tokenSource {
+	return &tokenSource{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		tokenURL:     tokenURL,
+		httpClient:   httpClient,
+	}
+}
+
+// Token returns a valid access token, fetching a new one if the cached token
+// is missing or about to expire.
+func (ts *tokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != "" && time.Now().Before(ts.expiresAt.Add(-tokenRefreshMargin)) {
+		return ts.token, nil
+	}
+
+	token, expiresAt, err := ts.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	ts.token = token
+	ts.expiresAt = expiresAt
+	return token, nil
+}
+
+// invalidate drops the cached token if it is still the one that was rejected.
+// Other goroutines may already have refreshed it, in which case nothing happens.
+func (ts *tokenSource) invalidate(rejected string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token == rejected {
+		ts.token = ""
+		ts.expiresAt = time.Time{}
+	}
+}
+
+func (ts *tokenSource) fetch(ctx context.Context) (string, time.Time, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", ts.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.SetBasicAuth(ts.clientID, ts.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := ts.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", time.Time{}, fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(bodyBytes)))
+	}
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("token response did not include an access token")
+	}
+
+	return tr.AccessToken, time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second), nil
+}
@@ -1,7 +1,11 @@
 package blizzard
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
@@ -13,13 +17,21 @@ import (
 
 type Client struct {
 	HTTPClient  *http.Client
-	Token       string
 	concurrency chan struct{}
-	rateTicker  *time.Ticker
-	rateMu      sync.Mutex
-	ratePrimed  bool
+	limiter     *rateLimiter
 	// Verbose controls extra per-request logging
 	Verbose bool
+	// oauth credentials and per-endpoint token caches
+	clientID     string
+	clientSecret string
+	authMu       sync.Mutex
+	auth         map[string]*tokenSource
+	// api/oauth endpoints (default + per-region overrides)
+	endpointsMu      sync.RWMutex
+	defaultEndpoints Endpoints
+	regionEndpoints  map[string]Endpoints
+	// optional validators for conditional leaderboard requests
+	validators ValidatorCache
 	// metrics
 	reqCount       int64
 	notFoundCount  int64
@@ -30,11 +42,51 @@ const (
 	defaultConcurrency          = 20
 	DefaultRequestRatePerSecond = 90
 	minRatePerSecond            = 1
+	userAgent                   = "WoWStatsDB/1.0"
+	// defaultRetryDelay is the backoff for a 429 without a Retry-After header
+	defaultRetryDelay = 2 * time.Second
 )
 
-// NewClient creates a new Blizzard API client
+// NewClient creates a new Blizzard API client using the BLIZZARD_CLIENT_ID and
+// BLIZZARD_CLIENT_SECRET environment variables
 func NewClient() (*Client, error) {
-	token := getEnvOrFail("BLIZZARD_API_TOKEN")
+	if replayDirOverride != "" {
+		// replayed runs never reach the OAuth endpoint, so credentials are optional
+		return NewClientWithCredentials(
+			firstNonEmpty(os.Getenv("BLIZZARD_CLIENT_ID"), "replay"),
+			firstNonEmpty(os.Getenv("BLIZZARD_CLIENT_SECRET"), "replay"),
+		)
+	}
+
+	clientID, err := requireEnv("BLIZZARD_CLIENT_ID")
+	if err != nil {
+		return nil, err
+	}
+	clientSecret, err := requireEnv("BLIZZARD_CLIENT_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithCredentials(clientID, clientSecret)
+}
+
+// NewClientWithCredentials creates a new Blizzard API client that obtains access
+// tokens through the OAuth client-credentials flow. Endpoints default to Blizzard's
+// and can be overridden with BLIZZARD_API_BASE_URL / BLIZZARD_OAUTH_URL. Responses
+// are recorded to or replayed from an archive when SetRecordDir/SetReplayDir is set.
+func NewClientWithCredentials(clientID, clientSecret string) (*Client, error) {
+	if clientID == "" || clientSecret == "" {
+		return nil, fmt.Errorf("blizzard client ID and secret are required")
+	}
+
+	defaultEndpoints, regionEndpoints, err := configuredEndpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultQuota, regionQuotas, err := ParseRateQuotas(rateQuotaOverride, DefaultRateQuota)
+	if err != nil {
+		return nil, err
+	}
 
 	// configure hhtp client with connection pooling
 	transport := &http.Transport{
@@ -43,22 +95,57 @@ func NewClient() (*Client, error) {
 		MaxIdleConnsPerHost: 10,
 	}
 
+	// optionally wrap the transport to record or replay raw responses
+	var roundTripper http.RoundTripper = transport
+	switch {
+	case recordDirOverride != "" && replayDirOverride != "":
+		return nil, fmt.Errorf("record and replay modes are mutually exclusive")
+	case replayDirOverride != "":
+		rt, err := newReplayTransport(replayDirOverride)
+		if err != nil {
+			return nil, fmt.Errorf("replay archive: %w", err)
+		}
+		roundTripper = rt
+	case recordDirOverride != "":
+		rt, err := newRecordingTransport(recordDirOverride, transport)
+		if err != nil {
+			return nil, fmt.Errorf("record archive: %w", err)
+		}
+		roundTripper = rt
+	}
+
 	// create concurrency limiter with default slots
 	concurrency := make(chan struct{}, defaultConcurrency)
 
+	httpClient := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: roundTripper,
+	}
+
 	client := &Client{
-		HTTPClient: &http.Client{
-			Timeout:   15 * time.Second,
-			Transport: transport,
-		},
-		Token:       token,
-		concurrency: concurrency,
+		HTTPClient:       httpClient,
+		concurrency:      concurrency,
+		clientID:         clientID,
+		clientSecret:     clientSecret,
+		auth:             make(map[string]*tokenSource),
+		defaultEndpoints: defaultEndpoints,
+		regionEndpoints:  regionEndpoints,
+		limiter:          newRateLimiter(defaultQuota, regionQuotas),
 	}
-	client.setRequestRate(DefaultRequestRatePerSecond)
 
 	return client, nil
 }
 
+// Close releases the client's connections and, when recording, flushes and closes
+// the archive journal. The client must not be used afterwards.
+func (c *Client) Close() error {
+	c.HTTPClient.CloseIdleConnections()
+	if closer, ok := c.HTTPClient.Transport.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
 // SetConcurrency adjusts the maximum concurrent API requests.
 func (c *Client) SetConcurrency(n int) {
 	if n <= 0 {
@@ -67,9 +154,20 @@ func (c *Client) SetConcurrency(n int) {
 	c.concurrency = make(chan struct{}, n)
 }
 
-// SetRequestRate updates the max requests per second.
+// SetRequestRate updates the default max requests per second (< 1 = no per-second limit).
+// The hourly quota, burst, and any per-region quotas are left unchanged.
 func (c *Client) SetRequestRate(rps int) {
-	c.setRequestRate(rps)
+	q := c.limiter.quota("")
+	q.PerSecond = float64(rps)
+	if rps < minRatePerSecond {
+		q.PerSecond = 0
+	}
+	c.limiter.setQuota("", q)
+}
+
+// SetRegionQuota sets the request budget for a single region.
+func (c *Client) SetRegionQuota(region string, q RateQuota) {
+	c.limiter.setQuota(region, q)
 }
 
 // SetTimeout updates the HTTP client timeout.
@@ -82,57 +180,180 @@ func (c *Client) SetTimeout(d time.Duration) {
 	}
 }
 
-func (c *Client) setRequestRate(rps int) {
-	c.rateMu.Lock()
-	defer c.rateMu.Unlock()
+// waitForRateSlot blocks until the region's budget allows another request or ctx is done
+func (c *Client) waitForRateSlot(ctx context.Context, region string) error {
+	return c.limiter.wait(ctx, region)
+}
 
-	if c.rateTicker != nil {
-		c.rateTicker.Stop()
-		c.rateTicker = nil
-		c.ratePrimed = false
-	}
+// SetValidatorCache enables conditional leaderboard requests using the given validators.
+func (c *Client) SetValidatorCache(vc ValidatorCache) {
+	c.validators = vc
+}
 
-	if rps < minRatePerSecond {
-		return
+// doGet performs an authenticated GET request. If the API rejects the access
+// token with a 401, the token is refreshed and the request is retried once.
+// Callers are responsible for closing the response body.
+func (c *Client) doGet(ctx context.Context, region, url string) (*http.Response, error) {
+	return c.doGetWithHeaders(ctx, region, url, nil)
+}
+
+// doGetWithHeaders is doGet with additional request headers (e.g. conditional validators).
+func (c *Client) doGetWithHeaders(ctx context.Context, region, url string, header http.Header) (*http.Response, error) {
+	auth := c.tokenSourceFor(region)
+	for attempt := 0; ; attempt++ {
+		token, err := auth.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain access token: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		for k, vs := range header {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Set("User-Agent", userAgent)
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("HTTP request failed: %w", err)
+		}
+
+		// any 429 or Retry-After pauses every worker, not just this one
+		if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > 0 {
+			c.limiter.backoff(retryAfter)
+		} else if resp.StatusCode == http.StatusTooManyRequests {
+			c.limiter.backoff(defaultRetryDelay)
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			auth.invalidate(token)
+			continue
+		}
+
+		return resp, nil
 	}
+}
+
+// fetchJSON GETs and decodes a JSON API resource, retrying transient failures
+func fetchJSON[T any](ctx context.Context, c *Client, region, url string) (*T, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*T, error) {
+		return fetchJSONOnce[T](ctx, c, region, url)
+	})
+}
+
+// withRetries calls fetch until it succeeds, retrying failures with exponential backoff.
+// A 429 doesn't count against the attempts and a 404 or cancellation isn't retried.
+func withRetries[T any](ctx context.Context, c *Client, fetch func(context.Context) (*T, error)) (*T, error) {
+	const maxRetries = 3
+	const baseDelay = 1 * time.Second
+
+	var lastErr error
+	attempt := 0
+	for {
+		if attempt > 0 {
+			delay := time.Duration(1<<uint(attempt-1)) * baseDelay
+			if err := sleepCtx(ctx, delay); err != nil {
+				return nil, err
+			}
+			if c.Verbose {
+				fmt.Printf("    [RETRY %d/%d] Retrying after %v delay...\n", attempt, maxRetries, delay)
+			}
+		}
+
+		result, err := fetch(ctx)
+		if err == nil {
+			return result, nil
+		}
+
+		lastErr = err
+		if ctx.Err() != nil {
+			// cancelled mid-request; don't retry
+			return nil, ctx.Err()
+		}
 
-	interval := time.Second / time.Duration(rps)
-	if interval <= 0 {
-		interval = time.Second
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			switch apiErr.Status {
+			case http.StatusTooManyRequests:
+				// the limiter holds every worker until the backoff expires
+				if c.Verbose {
+					fmt.Printf("    [WARN] 429 received, all workers backing off for %v\n", apiErr.retryDelay())
+				}
+				continue
+			case http.StatusNotFound:
+				return nil, err
+			}
+		}
+
+		attempt++
+		if attempt >= maxRetries {
+			break
+		}
 	}
 
-	c.rateTicker = time.NewTicker(interval)
-	c.ratePrimed = false
+	return nil, fmt.Errorf("failed after %d attempts: %w", maxRetries, lastErr)
 }
 
-func (c *Client) waitForRateSlot() {
-	c.rateMu.Lock()
-	ticker := c.rateTicker
-	primed := c.ratePrimed
-	if !primed {
-		c.ratePrimed = true
-		c.rateMu.Unlock()
-		return
+// fetchJSONOnce performs a single fetch attempt
+func fetchJSONOnce[T any](ctx context.Context, c *Client, region, url string) (*T, error) {
+	if err := c.waitForRateSlot(ctx, region); err != nil {
+		return nil, err
 	}
-	c.rateMu.Unlock()
 
-	if ticker == nil {
-		return
+	resp, err := c.doGet(ctx, region, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError(resp.StatusCode, bodyBytes, resp.Header.Get("Retry-After"))
+	}
+
+	var result T
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	<-ticker.C
+	return &result, nil
 }
 
-// Stats returns simple client-side metrics for diagnostics
-func (c *Client) Stats() (requests int64, notFound int64, avgLatencyMs float64) {
+// ClientStats is a snapshot of client-side metrics and rate limiter state
+type ClientStats struct {
+	Requests     int64
+	NotFound     int64
+	AvgLatencyMs float64
+	// InFlight is how many of the Concurrency slots are currently held
+	InFlight    int
+	Concurrency int
+	Limiter     LimiterStats
+}
+
+// Stats returns client-side metrics for diagnostics
+func (c *Client) Stats() ClientStats {
 	req := atomic.LoadInt64(&c.reqCount)
-	nf := atomic.LoadInt64(&c.notFoundCount)
 	tot := atomic.LoadInt64(&c.totalLatencyMs)
 	var avg float64
 	if req > 0 {
 		avg = float64(tot) / float64(req)
 	}
-	return req, nf, avg
+	return ClientStats{
+		Requests:     req,
+		NotFound:     atomic.LoadInt64(&c.notFoundCount),
+		AvgLatencyMs: avg,
+		InFlight:     len(c.concurrency),
+		Concurrency:  cap(c.concurrency),
+		Limiter:      c.limiter.stats(),
+	}
 }
 
 type APIError struct {
@@ -157,16 +378,30 @@ func (e *APIError) retryDelay() time.Duration {
 	if e.retryAfter > 0 {
 		return e.retryAfter
 	}
-	return 2 * time.Second
+	return defaultRetryDelay
+}
+
+// sleepCtx waits for d, returning early with ctx.Err() if ctx is done first
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func getEnvOrFail(key string) string {
+func requireEnv(key string) (string, error) {
 	value := os.Getenv(key)
 	if value == "" {
-		fmt.Fprintf(os.Stderr, "Error: %s environment variable is required\n", key)
-		os.Exit(1)
+		return "", fmt.Errorf("%s environment variable is required", key)
 	}
-	return value
+	return value, nil
 }
 
 func parseRetryAfter(v string) time.Duration {
@@ -6,8 +6,13 @@ import (
 	"strings"
 )
 
-// GetHardcodedPeriodAndDungeons returns the primary period ID and dungeon list
-// hardcoded values since the index endpoint is broken - most records are in period 1026
+// LeaderboardEntryCap is the most runs a leaderboard response ever lists. A full
+// leaderboard may have dropped slower runs, so rankings built from it are incomplete.
+const LeaderboardEntryCap = 500
+
+// GetHardcodedPeriodAndDungeons returns the primary period ID and dungeon list.
+// Dungeons are normally discovered from the leaderboard index and read from the
+// dungeons table; this list only seeds it when discovery fails on an empty database.
 func GetHardcodedPeriodAndDungeons() (string, []DungeonInfo) {
 	periodID := "1036"
 
@@ -26,95 +31,6 @@ func GetHardcodedPeriodAndDungeons() (string, []DungeonInfo) {
 	return periodID, dungeons
 }
 
-// GetAllRealms returns the complete realm configuration
-// this data comes from nix/api/realm.nix
-func GetAllRealms() map[string]RealmInfo {
-	return map[string]RealmInfo{
-		// us realms
-		"atiesh":               {ID: 4372, Region: "us", Name: "Atiesh", Slug: "atiesh"},
-		"myzrael":              {ID: 4373, Region: "us", Name: "Myzrael", Slug: "myzrael"},
-		"old-blanchy":          {ID: 4374, Region: "us", Name: "Old Blanchy", Slug: "old-blanchy"},
-		"azuresong":            {ID: 4376, Region: "us", Name: "Azuresong", Slug: "azuresong"},
-		"mankrik":              {ID: 4384, Region: "us", Name: "Mankrik", Slug: "mankrik"},
-		"pagle":                {ID: 4385, Region: "us", Name: "Pagle", Slug: "pagle"},
-		"ashkandi":             {ID: 4387, Region: "us", Name: "Ashkandi", Slug: "ashkandi"},
-		"westfall":             {ID: 4388, Region: "us", Name: "Westfall", Slug: "westfall"},
-		"whitemane":            {ID: 4395, Region: "us", Name: "Whitemane", Slug: "whitemane"},
-		"faerlina":             {ID: 4408, Region: "us", Name: "Faerlina", Slug: "faerlina"},
-		"grobbulus":            {ID: 4647, Region: "us", Name: "Grobbulus", Slug: "grobbulus"},
-		"bloodsail-buccaneers": {ID: 4648, Region: "us", Name: "Bloodsail Buccaneers", Slug: "bloodsail-buccaneers"},
-		// OCE realms are served under US region with -au slug suffix
-		"remulos-au":  {ID: 4667, Region: "us", Name: "Remulos (AU)", Slug: "remulos-au"},
-		"arugal-au":   {ID: 4669, Region: "us", Name: "Arugal (AU)", Slug: "arugal-au"},
-		"yojamba-au":  {ID: 4670, Region: "us", Name: "Yojamba (AU)", Slug: "yojamba-au"},
-		"skyfury":     {ID: 4725, Region: "us", Name: "Skyfury", Slug: "skyfury"},
-		"sulfuras":    {ID: 4726, Region: "us", Name: "Sulfuras", Slug: "sulfuras"},
-		"windseeker":  {ID: 4727, Region: "us", Name: "Windseeker", Slug: "windseeker"},
-		"benediction": {ID: 4728, Region: "us", Name: "Benediction", Slug: "benediction"},
-		"earthfury":   {ID: 4731, Region: "us", Name: "Earthfury", Slug: "earthfury"},
-		"maladath":    {ID: 4738, Region: "us", Name: "Maladath", Slug: "maladath"},
-		"angerforge":  {ID: 4795, Region: "us", Name: "Angerforge", Slug: "angerforge"},
-		"eranikus":    {ID: 4800, Region: "us", Name: "Eranikus", Slug: "eranikus"},
-		// New US realms (MoP Classic launch wave) - child realms connecting to Pagle
-		"nazgrim":   {ID: 6359, Region: "us", Name: "Nazgrim", Slug: "nazgrim", ParentRealmSlug: "pagle"},
-		"galakras":  {ID: 6360, Region: "us", Name: "Galakras", Slug: "galakras", ParentRealmSlug: "pagle"},
-		"raden":     {ID: 6361, Region: "us", Name: "Ra-den", Slug: "raden", ParentRealmSlug: "pagle"},
-		"lei-shen":  {ID: 6362, Region: "us", Name: "Lei Shen", Slug: "lei-shen", ParentRealmSlug: "pagle"},
-		"immerseus": {ID: 6363, Region: "us", Name: "Immerseus", Slug: "immerseus", ParentRealmSlug: "pagle"},
-
-		// eu realms
-		"everlook":             {ID: 4440, Region: "eu", Name: "Everlook", Slug: "everlook"},
-		"auberdine":            {ID: 4441, Region: "eu", Name: "Auberdine", Slug: "auberdine"},
-		"lakeshire":            {ID: 4442, Region: "eu", Name: "Lakeshire", Slug: "lakeshire"},
-		"chromie":              {ID: 4452, Region: "eu", Name: "Chromie", Slug: "chromie"},
-		"pyrewood-village":     {ID: 4453, Region: "eu", Name: "Pyrewood Village", Slug: "pyrewood-village"},
-		"mirage-raceway":       {ID: 4454, Region: "eu", Name: "Mirage Raceway", Slug: "mirage-raceway"},
-		"razorfen":             {ID: 4455, Region: "eu", Name: "Razorfen", Slug: "razorfen"},
-		"nethergarde-keep":     {ID: 4456, Region: "eu", Name: "Nethergarde Keep", Slug: "nethergarde-keep"},
-		"sulfuron":             {ID: 4464, Region: "eu", Name: "Sulfuron", Slug: "sulfuron"},
-		"golemagg":             {ID: 4465, Region: "eu", Name: "Golemagg", Slug: "golemagg"},
-		"patchwerk":            {ID: 4466, Region: "eu", Name: "Patchwerk", Slug: "patchwerk"},
-		"firemaw":              {ID: 4467, Region: "eu", Name: "Firemaw", Slug: "firemaw"},
-		"flamegor":             {ID: 4474, Region: "eu", Name: "Flamegor", Slug: "flamegor"},
-		"gehennas":             {ID: 4476, Region: "eu", Name: "Gehennas", Slug: "gehennas"},
-		"venoxis":              {ID: 4477, Region: "eu", Name: "Venoxis", Slug: "venoxis"},
-		"hydraxian-waterlords": {ID: 4678, Region: "eu", Name: "Hydraxian Waterlords", Slug: "hydraxian-waterlords"},
-		"mograine":             {ID: 4701, Region: "eu", Name: "Mograine", Slug: "mograine"},
-		"amnennar":             {ID: 4703, Region: "eu", Name: "Amnennar", Slug: "amnennar"},
-		"ashbringer":           {ID: 4742, Region: "eu", Name: "Ashbringer", Slug: "ashbringer"},
-		"transcendence":        {ID: 4745, Region: "eu", Name: "Transcendence", Slug: "transcendence"},
-		"earthshaker":          {ID: 4749, Region: "eu", Name: "Earthshaker", Slug: "earthshaker"},
-		"giantstalker":         {ID: 4811, Region: "eu", Name: "Giantstalker", Slug: "giantstalker"},
-		"mandokir":             {ID: 4813, Region: "eu", Name: "Mandokir", Slug: "mandokir"},
-		"thekal":               {ID: 4815, Region: "eu", Name: "Thekal", Slug: "thekal"},
-		"jindo":                {ID: 4816, Region: "eu", Name: "Jin'do", Slug: "jindo"},
-		// New EU realms (MoP Classic launch wave) - child realms connecting to Mirage Raceway and Everlook
-		"shekzeer":  {ID: 6364, Region: "eu", Name: "Shek'zeer", Slug: "shekzeer", ParentRealmSlug: "mirage-raceway"},
-		"garalon":   {ID: 6365, Region: "eu", Name: "Garalon", Slug: "garalon", ParentRealmSlug: "mirage-raceway"},
-		"norushen":  {ID: 6366, Region: "eu", Name: "Norushen", Slug: "norushen", ParentRealmSlug: "mirage-raceway"},
-		"hoptallus": {ID: 6367, Region: "eu", Name: "Hoptallus", Slug: "hoptallus", ParentRealmSlug: "mirage-raceway"},
-		"ook-ook":   {ID: 6368, Region: "eu", Name: "Ook Ook", Slug: "ook-ook", ParentRealmSlug: "everlook"},
-
-		// kr realms
-		"shimmering-flats": {ID: 4417, Region: "kr", Name: "Shimmering Flats", Slug: "shimmering-flats"},
-		"lokholar":         {ID: 4419, Region: "kr", Name: "Lokholar", Slug: "lokholar"},
-		"iceblood":         {ID: 4420, Region: "kr", Name: "Iceblood", Slug: "iceblood"},
-		"ragnaros":         {ID: 4421, Region: "kr", Name: "Ragnaros", Slug: "ragnaros"},
-		"frostmourne":      {ID: 4840, Region: "kr", Name: "Frostmourne", Slug: "frostmourne"},
-
-		// tw realms (exclude PTR realms TW4 CWOW GMSS 1/2)
-		"maraudon":     {ID: 4485, Region: "tw", Name: "Maraudon", Slug: "maraudon"},
-		"ivus":         {ID: 4487, Region: "tw", Name: "Ivus", Slug: "ivus"},
-		"wushoolay":    {ID: 4488, Region: "tw", Name: "Wushoolay", Slug: "wushoolay"},
-		"zeliek":       {ID: 4489, Region: "tw", Name: "Zeliek", Slug: "zeliek"},
-		"arathi-basin": {ID: 5740, Region: "tw", Name: "Arathi Basin", Slug: "arathi-basin"},
-		"murloc":       {ID: 5741, Region: "tw", Name: "Murloc", Slug: "murloc"},
-		// Use disambiguated map keys for slugs colliding across regions; Slug field remains the Blizzard slug.
-		"golemagg-tw":   {ID: 5742, Region: "tw", Name: "Golemagg", Slug: "golemagg"},
-		"windseeker-tw": {ID: 5743, Region: "tw", Name: "Windseeker", Slug: "windseeker"},
-	}
-}
-
 // NormalizeRealmSlug applies known region-specific realm slug renames.
 // Example: US OCE realms moved to -au slugs (e.g., arugal -> arugal-au).
 func NormalizeRealmSlug(region, slug string) string {
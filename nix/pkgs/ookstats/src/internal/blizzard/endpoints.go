@@ -0,0 +1,186 @@
+package blizzard
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// DefaultAPIBaseURL is the Blizzard API root; {region} is replaced with the request region
+	DefaultAPIBaseURL = "https://{region}.api.blizzard.com"
+	regionPlaceholder = "{region}"
+)
+
+// Endpoints describes where API and OAuth token requests for a region are sent.
+// Both fields may contain a {region} placeholder.
+type Endpoints struct {
+	APIBaseURL string
+	TokenURL   string
+}
+
+var (
+	apiBaseURLOverride string
+	tokenURLOverride   string
+)
+
+// SetAPIBaseURL allows callers (CLI) to override the API base URL used by new clients.
+// The spec is either a single URL or a comma-separated list of region=url pairs,
+// e.g. "http://127.0.0.1:8089" or "us=http://localhost:8089,eu=http://localhost:8090".
+func SetAPIBaseURL(spec string) {
+	apiBaseURLOverride = spec
+}
+
+// SetOAuthTokenURL allows callers (CLI) to override the OAuth token endpoint used by new clients.
+// Accepts the same format as SetAPIBaseURL.
+func SetOAuthTokenURL(spec string) {
+	tokenURLOverride = spec
+}
+
+// configuredEndpoints resolves the default and per-region endpoints.
+// Priority: explicit override -> env vars -> Blizzard defaults
+func configuredEndpoints() (Endpoints, map[string]Endpoints, error) {
+	def := Endpoints{APIBaseURL: DefaultAPIBaseURL, TokenURL: DefaultOAuthTokenURL}
+	perRegion := make(map[string]Endpoints)
+
+	apiSpec := firstNonEmpty(apiBaseURLOverride, os.Getenv("BLIZZARD_API_BASE_URL"))
+	tokenSpec := firstNonEmpty(tokenURLOverride, os.Getenv("BLIZZARD_OAUTH_URL"))
+
+	apiDefault, apiRegions, err := parseEndpointSpec(apiSpec)
+	if err != nil {
+		return def, nil, fmt.Errorf("invalid API base URL: %w", err)
+	}
+	tokenDefault, tokenRegions, err := parseEndpointSpec(tokenSpec)
+	if err != nil {
+		return def, nil, fmt.Errorf("invalid OAuth token URL: %w", err)
+	}
+
+	if apiDefault != "" {
+		def.APIBaseURL = apiDefault
+	}
+	if tokenDefault != "" {
+		def.TokenURL = tokenDefault
+	}
+	for region, u := range apiRegions {
+		ep := perRegion[region]
+		ep.APIBaseURL = u
+		perRegion[region] = ep
+	}
+	for region, u := range tokenRegions {
+		ep := perRegion[region]
+		ep.TokenURL = u
+		perRegion[region] = ep
+	}
+
+	return def, perRegion, nil
+}
+
+// parseEndpointSpec splits "url" or "region=url,region=url" into a default and per-region URLs
+func parseEndpointSpec(spec string) (string, map[string]string, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return "", nil, nil
+	}
+
+	var def string
+	perRegion := make(map[string]string)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		region, u, ok := strings.Cut(part, "=")
+		if !ok || strings.Contains(region, "/") {
+			if def != "" {
+				return "", nil, fmt.Errorf("multiple default URLs in %q", spec)
+			}
+			def = strings.TrimRight(part, "/")
+			continue
+		}
+		region = strings.ToLower(strings.TrimSpace(region))
+		u = strings.TrimRight(strings.TrimSpace(u), "/")
+		if region == "" || u == "" {
+			return "", nil, fmt.Errorf("invalid region endpoint %q", part)
+		}
+		perRegion[region] = u
+	}
+
+	return def, perRegion, nil
+}
+
+// SetEndpoints overrides the endpoints for a region. An empty region sets the default
+// used by regions without an explicit override; empty fields keep the current value.
+func (c *Client) SetEndpoints(region string, ep Endpoints) {
+	c.endpointsMu.Lock()
+	defer c.endpointsMu.Unlock()
+
+	ep.APIBaseURL = strings.TrimRight(ep.APIBaseURL, "/")
+	if region == "" {
+		if ep.APIBaseURL != "" {
+			c.defaultEndpoints.APIBaseURL = ep.APIBaseURL
+		}
+		if ep.TokenURL != "" {
+			c.defaultEndpoints.TokenURL = ep.TokenURL
+		}
+		return
+	}
+
+	region = strings.ToLower(region)
+	cur := c.regionEndpoints[region]
+	if ep.APIBaseURL != "" {
+		cur.APIBaseURL = ep.APIBaseURL
+	}
+	if ep.TokenURL != "" {
+		cur.TokenURL = ep.TokenURL
+	}
+	c.regionEndpoints[region] = cur
+}
+
+// endpointsFor returns the resolved endpoints for a region with placeholders expanded
+func (c *Client) endpointsFor(region string) Endpoints {
+	c.endpointsMu.RLock()
+	ep := c.defaultEndpoints
+	if override, ok := c.regionEndpoints[strings.ToLower(region)]; ok {
+		if override.APIBaseURL != "" {
+			ep.APIBaseURL = override.APIBaseURL
+		}
+		if override.TokenURL != "" {
+			ep.TokenURL = override.TokenURL
+		}
+	}
+	c.endpointsMu.RUnlock()
+
+	ep.APIBaseURL = strings.ReplaceAll(ep.APIBaseURL, regionPlaceholder, region)
+	ep.TokenURL = strings.ReplaceAll(ep.TokenURL, regionPlaceholder, region)
+	return ep
+}
+
+// apiURL joins the region's API base URL with a path (including query string)
+func (c *Client) apiURL(region, path string) string {
+	return c.endpointsFor(region).APIBaseURL + path
+}
+
+// tokenSourceFor returns the token source for the region's OAuth endpoint.
+// Regions sharing an endpoint share a cached token.
+func (c *Client) tokenSourceFor(region string) *tokenSource {
+	tokenURL := c.endpointsFor(region).TokenURL
+
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	ts, ok := c.auth[tokenURL]
+	if !ok {
+		ts = newTokenSource(c.clientID, c.clientSecret, tokenURL, c.HTTPClient)
+		c.auth[tokenURL] = ts
+	}
+	return ts
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
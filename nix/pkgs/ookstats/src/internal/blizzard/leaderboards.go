@@ -3,10 +3,11 @@ package blizzard
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"ookstats/internal/utils"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -22,56 +23,17 @@ type FetchResult struct {
 }
 
 // FetchLeaderboardData fetches leaderboard data for a specific realm and dungeon with retries
-func (c *Client) FetchLeaderboardData(realmInfo RealmInfo, dungeon DungeonInfo, periodID string) (*LeaderboardResponse, error) {
-	const maxRetries = 3
-	const baseDelay = 1 * time.Second
-
-	var lastErr error
-	attempt := 0
-	for {
-		if attempt > 0 {
-			// exponential backoff between full retries (non-429)
-			delay := time.Duration(1<<uint(attempt-1)) * baseDelay
-			time.Sleep(delay)
-			if c.Verbose {
-				fmt.Printf("    [RETRY %d/%d] Retrying after %v delay...\n", attempt, maxRetries, delay)
-			}
-		}
-
-		result, err := c.fetchLeaderboardDataOnce(realmInfo, dungeon, periodID)
-		if err == nil {
-			return result, nil
-		}
-
-		lastErr = err
-
-		var apiErr *APIError
-		if errors.As(err, &apiErr) {
-			switch apiErr.Status {
-			case http.StatusTooManyRequests:
-				delay := apiErr.retryDelay()
-				if c.Verbose {
-					fmt.Printf("    [WARN] 429 received, backing off for %v\n", delay)
-				}
-				time.Sleep(delay)
-				continue
-			case http.StatusNotFound:
-				return nil, err
-			}
-		}
-
-		attempt++
-		if attempt >= maxRetries {
-			break
-		}
-	}
-
-	return nil, fmt.Errorf("failed after %d attempts: %w", maxRetries, lastErr)
+func (c *Client) FetchLeaderboardData(ctx context.Context, realmInfo RealmInfo, dungeon DungeonInfo, periodID string) (*LeaderboardResponse, error) {
+	return withRetries(ctx, c, func(ctx context.Context) (*LeaderboardResponse, error) {
+		return c.fetchLeaderboardDataOnce(ctx, realmInfo, dungeon, periodID)
+	})
 }
 
 // fetchLeaderboardDataOnce performs a single fetch attempt
-func (c *Client) fetchLeaderboardDataOnce(realmInfo RealmInfo, dungeon DungeonInfo, periodID string) (*LeaderboardResponse, error) {
-	c.waitForRateSlot()
+func (c *Client) fetchLeaderboardDataOnce(ctx context.Context, realmInfo RealmInfo, dungeon DungeonInfo, periodID string) (*LeaderboardResponse, error) {
+	if err := c.waitForRateSlot(ctx, realmInfo.Region); err != nil {
+		return nil, err
+	}
 
 	start := time.Now()
 
@@ -80,25 +42,43 @@ func (c *Client) fetchLeaderboardDataOnce(realmInfo RealmInfo, dungeon DungeonIn
 	dungeonID := dungeon.ID
 
 	namespace := fmt.Sprintf("dynamic-classic-%s", region)
-	url := fmt.Sprintf(
-		"https://%s.api.blizzard.com/data/wow/connected-realm/%d/mythic-leaderboard/%d/period/%s?namespace=%s",
-		region, realmID, dungeonID, periodID, namespace,
-	)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	url := c.apiURL(region, fmt.Sprintf(
+		"/data/wow/connected-realm/%d/mythic-leaderboard/%d/period/%s?namespace=%s",
+		realmID, dungeonID, periodID, namespace,
+	))
+
+	// send stored validators so unchanged leaderboards come back as 304
+	var header http.Header
+	var cached Validators
+	if c.validators != nil {
+		if v, ok := c.validators.LeaderboardValidators(region, realmInfo.Slug, dungeonID, periodID); ok && !v.IsZero() {
+			cached = v
+			header = http.Header{}
+			if v.ETag != "" {
+				header.Set("If-None-Match", v.ETag)
+			}
+			if v.LastModified != "" {
+				header.Set("If-Modified-Since", v.LastModified)
+			}
+		}
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.Token))
-	req.Header.Set("User-Agent", "WoWStatsDB/1.0")
-
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.doGetWithHeaders(ctx, region, url, header)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		io.Copy(io.Discard, resp.Body)
+		atomic.AddInt64(&c.reqCount, 1)
+		atomic.AddInt64(&c.totalLatencyMs, time.Since(start).Milliseconds())
+		if c.Verbose {
+			fmt.Printf("HTTP 304 %-3s %-20s %-26s in %dms\n", realmInfo.Region, realmInfo.Name, dungeon.Name, time.Since(start).Milliseconds())
+		}
+		return &LeaderboardResponse{NotModified: true, Validators: cached}, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		// metrics
@@ -118,6 +98,11 @@ func (c *Client) fetchLeaderboardDataOnce(realmInfo RealmInfo, dungeon DungeonIn
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	leaderboard.Validators = Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
 	// successfully decoded
 	atomic.AddInt64(&c.reqCount, 1)
 	atomic.AddInt64(&c.totalLatencyMs, time.Since(start).Milliseconds())
@@ -127,6 +112,51 @@ func (c *Client) fetchLeaderboardDataOnce(realmInfo RealmInfo, dungeon DungeonIn
 	return &leaderboard, nil
 }
 
+// FetchLeaderboardIndex fetches the list of current dungeon leaderboards for a connected realm
+func (c *Client) FetchLeaderboardIndex(ctx context.Context, realmInfo RealmInfo) (*LeaderboardIndexResponse, error) {
+	region := realmInfo.Region
+	namespace := fmt.Sprintf("dynamic-classic-%s", region)
+	url := c.apiURL(region, fmt.Sprintf(
+		"/data/wow/connected-realm/%d/mythic-leaderboard/index?namespace=%s&locale=en_US",
+		realmInfo.ID, namespace,
+	))
+
+	return fetchJSON[LeaderboardIndexResponse](ctx, c, region, url)
+}
+
+// Dungeons converts the index entries to dungeon info, skipping malformed entries
+func (r *LeaderboardIndexResponse) Dungeons() []DungeonInfo {
+	dungeons := make([]DungeonInfo, 0, len(r.CurrentLeaderboards))
+	for _, lb := range r.CurrentLeaderboards {
+		if lb.ID <= 0 || strings.TrimSpace(lb.Name) == "" {
+			continue
+		}
+		dungeons = append(dungeons, DungeonInfo{
+			ID:   lb.ID,
+			Name: lb.Name,
+			Slug: utils.Slugify(lb.Name),
+		})
+	}
+	return dungeons
+}
+
+// IsTruncated reports whether the leaderboard hit LeaderboardEntryCap
+func (lb *LeaderboardResponse) IsTruncated() bool {
+	return len(lb.LeadingGroups) >= LeaderboardEntryCap
+}
+
+// SlowestDuration returns the longest run duration on the leaderboard (the cutoff
+// when it's truncated), or 0 when it has no runs
+func (lb *LeaderboardResponse) SlowestDuration() int {
+	slowest := 0
+	for _, run := range lb.LeadingGroups {
+		if run.Duration > slowest {
+			slowest = run.Duration
+		}
+	}
+	return slowest
+}
+
 // FetchLeaderboardsConcurrent fetches multiple leaderboards concurrently
 func (c *Client) FetchLeaderboardsConcurrent(ctx context.Context, realmInfo RealmInfo, dungeons []DungeonInfo, periodID string) <-chan FetchResult {
 	results := make(chan FetchResult, len(dungeons))
@@ -151,10 +181,7 @@ func (c *Client) FetchLeaderboardsConcurrent(ctx context.Context, realmInfo Real
 				return
 			}
 
-			// minimal delay to respect API rate limits
-			time.Sleep(20 * time.Millisecond)
-
-			leaderboard, err := c.FetchLeaderboardData(realmInfo, d, periodID)
+			leaderboard, err := c.FetchLeaderboardData(ctx, realmInfo, d, periodID)
 			results <- FetchResult{
 				RealmInfo:   realmInfo,
 				Dungeon:     d,
@@ -0,0 +1,377 @@
+package blizzard
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRequestsPerHour matches Blizzard's per-client hourly quota
+	DefaultRequestsPerHour = 36000
+	// DefaultBurst is how many requests may go out back-to-back after an idle period
+	DefaultBurst = 20
+
+	// after a 429 the per-second rate is multiplied by throttleDecrease (down to
+	// minThrottle) and then recovers by throttleStep every throttleRampInterval
+	throttleDecrease     = 0.5
+	minThrottle          = 0.1
+	throttleStep         = 0.1
+	throttleRampInterval = 10 * time.Second
+)
+
+// RateQuota is the request budget for a region. Zero PerSecond or PerHour disables that limit.
+type RateQuota struct {
+	PerSecond float64
+	PerHour   float64
+	Burst     int
+}
+
+// DefaultRateQuota is applied to regions without an explicit quota
+var DefaultRateQuota = RateQuota{
+	PerSecond: DefaultRequestRatePerSecond,
+	PerHour:   DefaultRequestsPerHour,
+	Burst:     DefaultBurst,
+}
+
+var rateQuotaOverride string
+
+// SetRateQuotas allows callers (CLI) to override rate quotas for new clients.
+// Spec format: comma-separated [region=]PER_SECOND/PER_HOUR[/BURST], e.g.
+// "90/36000" or "us=50/18000/10,eu=50/18000/10". Entries without a region set the default.
+func SetRateQuotas(spec string) {
+	rateQuotaOverride = spec
+}
+
+// ParseRateQuotas parses a rate quota spec into a default and per-region quotas
+func ParseRateQuotas(spec string, def RateQuota) (RateQuota, map[string]RateQuota, error) {
+	perRegion := make(map[string]RateQuota)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		region := ""
+		if r, q, ok := strings.Cut(part, "="); ok {
+			region = strings.ToLower(strings.TrimSpace(r))
+			part = strings.TrimSpace(q)
+		}
+
+		fields := strings.Split(part, "/")
+		if len(fields) < 2 || len(fields) > 3 {
+			return def, nil, fmt.Errorf("invalid rate quota %q (expected PER_SECOND/PER_HOUR[/BURST])", part)
+		}
+		perSecond, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		if err != nil || perSecond < 0 {
+			return def, nil, fmt.Errorf("invalid per-second rate in %q", part)
+		}
+		perHour, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil || perHour < 0 {
+			return def, nil, fmt.Errorf("invalid per-hour rate in %q", part)
+		}
+		q := RateQuota{PerSecond: perSecond, PerHour: perHour, Burst: def.Burst}
+		if len(fields) == 3 {
+			burst, err := strconv.Atoi(strings.TrimSpace(fields[2]))
+			if err != nil || burst < 1 {
+				return def, nil, fmt.Errorf("invalid burst in %q", part)
+			}
+			q.Burst = burst
+		}
+
+		if region == "" {
+			def = q
+		} else {
+			perRegion[region] = q
+		}
+	}
+	return def, perRegion, nil
+}
+
+// tokenBucket holds up to capacity tokens; callers refill it at the current rate
+type tokenBucket struct {
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(capacity float64, now time.Time) tokenBucket {
+	return tokenBucket{capacity: capacity, tokens: capacity, last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time, rate float64) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*rate)
+	}
+	b.last = now
+}
+
+// timeUntil returns how long until one token is available at the given rate
+func (b *tokenBucket) timeUntil(rate float64) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	if rate <= 0 {
+		return time.Second
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// regionBudget tracks the per-second and per-hour buckets for one region
+type regionBudget struct {
+	quota    RateQuota
+	second   tokenBucket
+	hour     tokenBucket
+	requests int64
+	waited   time.Duration
+}
+
+// rateLimiter is a per-region token-bucket limiter with shared 429 backoff.
+// A 429 (or any Retry-After) pauses every worker until the backoff expires, then
+// the per-second rate is throttled and ramps back up gradually.
+type rateLimiter struct {
+	mu           sync.Mutex
+	defaultQuota RateQuota
+	quotas       map[string]RateQuota
+	regions      map[string]*regionBudget
+
+	blockedUntil time.Time
+	throttle     float64 // multiplier applied to per-second rates after the last 429
+	lastThrottle time.Time
+	rateLimited  int64
+}
+
+func newRateLimiter(def RateQuota, quotas map[string]RateQuota) *rateLimiter {
+	if quotas == nil {
+		quotas = make(map[string]RateQuota)
+	}
+	return &rateLimiter{
+		defaultQuota: def,
+		quotas:       quotas,
+		regions:      make(map[string]*regionBudget),
+		throttle:     1,
+	}
+}
+
+// setQuota replaces the quota for a region ("" = default) and resets its buckets
+func (l *rateLimiter) setQuota(region string, q RateQuota) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if region == "" {
+		l.defaultQuota = q
+		for r := range l.regions {
+			if _, explicit := l.quotas[r]; !explicit {
+				delete(l.regions, r)
+			}
+		}
+		return
+	}
+	region = strings.ToLower(region)
+	l.quotas[region] = q
+	delete(l.regions, region)
+}
+
+// quota returns the configured quota for a region ("" = default)
+func (l *rateLimiter) quota(region string) RateQuota {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if q, ok := l.quotas[strings.ToLower(region)]; ok && region != "" {
+		return q
+	}
+	return l.defaultQuota
+}
+
+func (l *rateLimiter) budget(region string, now time.Time) *regionBudget {
+	region = strings.ToLower(region)
+	b, ok := l.regions[region]
+	if ok {
+		return b
+	}
+	q, ok := l.quotas[region]
+	if !ok {
+		q = l.defaultQuota
+	}
+	burst := float64(q.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	b = &regionBudget{
+		quota:  q,
+		second: newTokenBucket(burst, now),
+		hour:   newTokenBucket(q.PerHour, now),
+	}
+	l.regions[region] = b
+	return b
+}
+
+// currentThrottle returns the throttle multiplier after ramp-up since the last 429
+func (l *rateLimiter) currentThrottle(now time.Time) float64 {
+	if l.throttle >= 1 {
+		return 1
+	}
+	steps := math.Floor(now.Sub(l.lastThrottle).Seconds() / throttleRampInterval.Seconds())
+	if steps < 0 {
+		// still inside the backoff window
+		steps = 0
+	}
+	t := l.throttle + steps*throttleStep
+	if t >= 1 {
+		l.throttle = 1
+		return 1
+	}
+	return t
+}
+
+// reserve takes a token for the region if one is available, otherwise it
+// returns how long the caller should wait before trying again
+func (l *rateLimiter) reserve(region string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.blockedUntil) {
+		return l.blockedUntil.Sub(now)
+	}
+
+	b := l.budget(region, now)
+	secondRate := b.quota.PerSecond * l.currentThrottle(now)
+	hourRate := b.quota.PerHour / 3600
+
+	limitSecond := b.quota.PerSecond > 0
+	limitHour := b.quota.PerHour > 0
+	if limitSecond {
+		b.second.refill(now, secondRate)
+	}
+	if limitHour {
+		b.hour.refill(now, hourRate)
+	}
+
+	var wait time.Duration
+	if limitSecond {
+		wait = b.second.timeUntil(secondRate)
+	}
+	if limitHour {
+		if w := b.hour.timeUntil(hourRate); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	if limitSecond {
+		b.second.tokens--
+	}
+	if limitHour {
+		b.hour.tokens--
+	}
+	b.requests++
+	return 0
+}
+
+// wait blocks until the region has budget for one request or ctx is done
+func (l *rateLimiter) wait(ctx context.Context, region string) error {
+	var waited time.Duration
+	defer func() { l.recordWait(region, waited) }()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		d := l.reserve(region)
+		if d <= 0 {
+			return nil
+		}
+		if err := sleepCtx(ctx, d); err != nil {
+			return err
+		}
+		waited += d
+	}
+}
+
+func (l *rateLimiter) recordWait(region string, waited time.Duration) {
+	if waited <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.regions[strings.ToLower(region)]; ok {
+		b.waited += waited
+	}
+}
+
+// backoff pauses all workers for d and throttles the per-second rate
+func (l *rateLimiter) backoff(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.rateLimited++
+	if until := now.Add(d); until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+	// only throttle once per backoff window so a burst of 429s doesn't collapse the rate
+	if now.Sub(l.lastThrottle) >= d || l.throttle >= 1 {
+		l.throttle = math.Max(minThrottle, l.currentThrottle(now)*throttleDecrease)
+		l.lastThrottle = l.blockedUntil
+	}
+	// drain burst capacity so workers resume gradually rather than all at once
+	for _, b := range l.regions {
+		b.second.tokens = math.Min(b.second.tokens, 1)
+	}
+}
+
+// RegionLimiterStats is a snapshot of one region's rate budget
+type RegionLimiterStats struct {
+	Quota         RateQuota
+	SecondTokens  float64
+	HourTokens    float64
+	Requests      int64
+	RateWait      time.Duration
+	EffectiveRate float64
+}
+
+// LimiterStats is a snapshot of the rate limiter
+type LimiterStats struct {
+	RateLimited      int64
+	Throttle         float64
+	BackoffRemaining time.Duration
+	Regions          map[string]RegionLimiterStats
+}
+
+func (l *rateLimiter) stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	throttle := l.currentThrottle(now)
+	out := LimiterStats{
+		RateLimited: l.rateLimited,
+		Throttle:    throttle,
+		Regions:     make(map[string]RegionLimiterStats, len(l.regions)),
+	}
+	if now.Before(l.blockedUntil) {
+		out.BackoffRemaining = l.blockedUntil.Sub(now)
+	}
+	for region, b := range l.regions {
+		if b.quota.PerSecond > 0 {
+			b.second.refill(now, b.quota.PerSecond*throttle)
+		}
+		if b.quota.PerHour > 0 {
+			b.hour.refill(now, b.quota.PerHour/3600)
+		}
+		out.Regions[region] = RegionLimiterStats{
+			Quota:         b.quota,
+			SecondTokens:  b.second.tokens,
+			HourTokens:    b.hour.tokens,
+			Requests:      b.requests,
+			RateWait:      b.waited,
+			EffectiveRate: b.quota.PerSecond * throttle,
+		}
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package blizzard
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+)
+
+// FetchConnectedRealmIDs fetches the connected realm index for a region and returns
+// the connected realm IDs parsed from its links, sorted
+func (c *Client) FetchConnectedRealmIDs(ctx context.Context, region string) ([]int, error) {
+	namespace := fmt.Sprintf("dynamic-classic-%s", region)
+	url := c.apiURL(region, fmt.Sprintf(
+		"/data/wow/connected-realm/index?namespace=%s&locale=en_US",
+		namespace,
+	))
+
+	index, err := fetchJSON[ConnectedRealmIndexResponse](ctx, c, region, url)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(index.ConnectedRealms))
+	for _, ref := range index.ConnectedRealms {
+		id, err := connectedRealmIDFromHref(ref.Href)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// FetchConnectedRealm fetches a connected realm with its member realms
+func (c *Client) FetchConnectedRealm(ctx context.Context, region string, id int) (*ConnectedRealmResponse, error) {
+	namespace := fmt.Sprintf("dynamic-classic-%s", region)
+	url := c.apiURL(region, fmt.Sprintf(
+		"/data/wow/connected-realm/%d?namespace=%s&locale=en_US",
+		id, namespace,
+	))
+
+	return fetchJSON[ConnectedRealmResponse](ctx, c, region, url)
+}
+
+// connectedRealmIDFromHref extracts the ID from a link like
+// https://us.api.blizzard.com/data/wow/connected-realm/4372?namespace=dynamic-classic-us
+func connectedRealmIDFromHref(href string) (int, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return 0, fmt.Errorf("invalid connected realm link %q: %w", href, err)
+	}
+	id, err := strconv.Atoi(path.Base(u.Path))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid connected realm link %q", href)
+	}
+	return id, nil
+}
+
+// RealmInfos converts a connected realm into realm entries. The realm sharing the
+// connected realm's ID (or the lowest ID when none does) leads the pool and carries
+// the connected realm ID used in leaderboard URLs; the others become its children and
+// keep their own realm ID. Tournament realms are skipped.
+func (cr *ConnectedRealmResponse) RealmInfos(region string) []RealmInfo {
+	members := cr.Realms[:0:0]
+	for _, r := range cr.Realms {
+		if r.IsTournament || r.Slug == "" {
+			continue
+		}
+		members = append(members, r)
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+
+	leader := 0
+	for i, r := range members {
+		if r.ID == cr.ID {
+			leader = i
+			break
+		}
+	}
+	leaderSlug := NormalizeRealmSlug(region, members[leader].Slug)
+
+	infos := make([]RealmInfo, 0, len(members))
+	for i, r := range members {
+		info := RealmInfo{
+			ID:     r.ID,
+			Name:   r.Name,
+			Region: region,
+			Slug:   NormalizeRealmSlug(region, r.Slug),
+		}
+		if i == leader {
+			info.ID = cr.ID
+		} else {
+			info.ParentRealmSlug = leaderSlug
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
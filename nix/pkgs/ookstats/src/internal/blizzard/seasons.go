@@ -1,162 +1,62 @@
 package blizzard
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"time"
 )
 
 // FetchSeasonIndex fetches the list of available seasons for a region
-func (c *Client) FetchSeasonIndex(region string) (*SeasonIndexResponse, error) {
+func (c *Client) FetchSeasonIndex(ctx context.Context, region string) (*SeasonIndexResponse, error) {
 	namespace := fmt.Sprintf("dynamic-classic-%s", region)
-	url := fmt.Sprintf(
-		"https://%s.api.blizzard.com/data/wow/mythic-keystone/season/index?namespace=%s&locale=en_US",
-		region, namespace,
-	)
+	url := c.apiURL(region, fmt.Sprintf(
+		"/data/wow/mythic-keystone/season/index?namespace=%s&locale=en_US",
+		namespace,
+	))
 
-	const maxRetries = 3
-	const baseDelay = 1 * time.Second
-
-	var lastErr error
-	attempt := 0
-	for {
-		if attempt > 0 {
-			delay := time.Duration(1<<uint(attempt-1)) * baseDelay
-			time.Sleep(delay)
-		}
-
-		result, err := c.fetchSeasonIndexOnce(url)
-		if err == nil {
-			return result, nil
-		}
-
-		lastErr = err
-
-		var apiErr *APIError
-		if errors.As(err, &apiErr) {
-			switch apiErr.Status {
-			case http.StatusTooManyRequests:
-				time.Sleep(apiErr.retryDelay())
-				continue
-			case http.StatusNotFound:
-				return nil, err
-			}
-		}
-
-		attempt++
-		if attempt >= maxRetries {
-			break
-		}
-	}
-
-	return nil, fmt.Errorf("failed to fetch season index after %d attempts: %w", maxRetries, lastErr)
-}
-
-func (c *Client) fetchSeasonIndexOnce(url string) (*SeasonIndexResponse, error) {
-	c.waitForRateSlot()
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.Token))
-	req.Header.Set("User-Agent", "WoWStatsDB/1.0")
-
-	resp, err := c.HTTPClient.Do(req)
+	result, err := fetchJSON[SeasonIndexResponse](ctx, c, region, url)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, newAPIError(resp.StatusCode, bodyBytes, resp.Header.Get("Retry-After"))
-	}
-
-	var result SeasonIndexResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("season index: %w", err)
 	}
-
-	return &result, nil
+	return result, nil
 }
 
 // FetchSeasonDetail fetches details for a specific season
-func (c *Client) FetchSeasonDetail(region string, seasonID int) (*SeasonDetailResponse, error) {
+func (c *Client) FetchSeasonDetail(ctx context.Context, region string, seasonID int) (*SeasonDetailResponse, error) {
 	namespace := fmt.Sprintf("dynamic-classic-%s", region)
-	url := fmt.Sprintf(
-		"https://%s.api.blizzard.com/data/wow/mythic-keystone/season/%d?namespace=%s&locale=en_US",
-		region, seasonID, namespace,
-	)
-
-	const maxRetries = 3
-	const baseDelay = 1 * time.Second
-
-	var lastErr error
-	attempt := 0
-	for {
-		if attempt > 0 {
-			delay := time.Duration(1<<uint(attempt-1)) * baseDelay
-			time.Sleep(delay)
-		}
-
-		result, err := c.fetchSeasonDetailOnce(url)
-		if err == nil {
-			return result, nil
-		}
-
-		lastErr = err
+	url := c.apiURL(region, fmt.Sprintf(
+		"/data/wow/mythic-keystone/season/%d?namespace=%s&locale=en_US",
+		seasonID, namespace,
+	))
 
-		var apiErr *APIError
-		if errors.As(err, &apiErr) {
-			switch apiErr.Status {
-			case http.StatusTooManyRequests:
-				time.Sleep(apiErr.retryDelay())
-				continue
-			case http.StatusNotFound:
-				return nil, err
-			}
-		}
-
-		attempt++
-		if attempt >= maxRetries {
-			break
-		}
+	result, err := fetchJSON[SeasonDetailResponse](ctx, c, region, url)
+	if err != nil {
+		return nil, fmt.Errorf("season %d: %w", seasonID, err)
 	}
-
-	return nil, fmt.Errorf("failed to fetch season %d after %d attempts: %w", seasonID, maxRetries, lastErr)
+	return result, nil
 }
 
-func (c *Client) fetchSeasonDetailOnce(url string) (*SeasonDetailResponse, error) {
-	c.waitForRateSlot()
+// FetchPeriodIndex fetches the list of keystone periods for a region
+func (c *Client) FetchPeriodIndex(ctx context.Context, region string) (*PeriodIndexResponse, error) {
+	namespace := fmt.Sprintf("dynamic-classic-%s", region)
+	url := c.apiURL(region, fmt.Sprintf(
+		"/data/wow/mythic-keystone/period/index?namespace=%s&locale=en_US",
+		namespace,
+	))
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+	return fetchJSON[PeriodIndexResponse](ctx, c, region, url)
+}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.Token))
-	req.Header.Set("User-Agent", "WoWStatsDB/1.0")
+// FetchPeriodDetail fetches the start and end timestamps of a keystone period
+func (c *Client) FetchPeriodDetail(ctx context.Context, region string, periodID int) (*PeriodDetailResponse, error) {
+	namespace := fmt.Sprintf("dynamic-classic-%s", region)
+	url := c.apiURL(region, fmt.Sprintf(
+		"/data/wow/mythic-keystone/period/%d?namespace=%s&locale=en_US",
+		periodID, namespace,
+	))
 
-	resp, err := c.HTTPClient.Do(req)
+	result, err := fetchJSON[PeriodDetailResponse](ctx, c, region, url)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		return nil, fmt.Errorf("period %d: %w", periodID, err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, newAPIError(resp.StatusCode, bodyBytes, resp.Header.Get("Retry-After"))
-	}
-
-	var result SeasonDetailResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	return &result, nil
+	return result, nil
 }
@@ -1,5 +1,7 @@
 package blizzard
 
+import "encoding/json"
+
 // RealmInfo represents a realm
 type RealmInfo struct {
 	ID              int    `json:"id"`
@@ -9,11 +11,34 @@ type RealmInfo struct {
 	ParentRealmSlug string `json:"parent_realm_slug,omitempty"`
 }
 
-// DungeonInfo represents a challenge mode dungeon
+// DungeonInfo represents a challenge mode dungeon. ID is the map challenge mode ID
+// used in leaderboard URLs; MapID is the underlying map (0 when unknown).
 type DungeonInfo struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	MapID int    `json:"map_id,omitempty"`
+}
+
+// LeaderboardIndexResponse lists the current leaderboards of a connected realm
+type LeaderboardIndexResponse struct {
+	CurrentLeaderboards []LeaderboardIndexEntry `json:"current_leaderboards"`
+}
+
+// LeaderboardIndexEntry is one dungeon leaderboard in the index. ID is the map
+// challenge mode ID; Key.Href points at the leaderboard for the current period.
+type LeaderboardIndexEntry struct {
+	Key struct {
+		Href string `json:"href"`
+	} `json:"key"`
+	Name string `json:"name"`
+	ID   int    `json:"id"`
+}
+
+// MapRef identifies the map a leaderboard belongs to
+type MapRef struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
-	Slug string `json:"slug"`
 }
 
 // LeaderboardResponse is the top-level response from the mythic leaderboard API
@@ -22,6 +47,30 @@ type LeaderboardResponse struct {
 	Period               int            `json:"period"`
 	PeriodStartTimestamp int64          `json:"period_start_timestamp"`
 	PeriodEndTimestamp   int64          `json:"period_end_timestamp"`
+	Map                  MapRef         `json:"map"`
+	MapChallengeModeID   int            `json:"map_challenge_mode_id"`
+
+	// NotModified is set when the API answered 304 to a conditional request;
+	// the response then carries no data
+	NotModified bool `json:"-"`
+	// Validators are the cache validators returned with this response
+	Validators Validators `json:"-"`
+}
+
+// Validators are HTTP cache validators from a previous response
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// IsZero reports whether no validators are set
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// ValidatorCache supplies stored validators for conditional leaderboard requests
+type ValidatorCache interface {
+	LeaderboardValidators(region, realmSlug string, dungeonID int, periodID string) (Validators, bool)
 }
 
 // ChallengeRun represents a single challenge mode run
@@ -40,12 +89,12 @@ type Member struct {
 	Name      *string `json:"name,omitempty"`
 	RealmSlug *string `json:"realm_slug,omitempty"`
 	SpecID    *int    `json:"spec_id,omitempty"`
-	Faction   *string `json:"faction,omitempty"`
+	// faction is a plain string in the new format and {"type": ...} in the old one
+	Faction *FactionType `json:"faction,omitempty"`
 
 	// old format with nested profile
 	Profile        *Profile        `json:"profile,omitempty"`
 	Specialization *Specialization `json:"specialization,omitempty"`
-	FactionType    *FactionType    `json:"faction,omitempty"`
 }
 
 // Profile represents nested profile data in the old API format
@@ -70,6 +119,22 @@ type FactionType struct {
 	Type string `json:"type"`
 }
 
+// UnmarshalJSON accepts both "ALLIANCE" and {"type": "ALLIANCE"}
+func (f *FactionType) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		f.Type = s
+		return nil
+	}
+	type plain FactionType
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*f = FactionType(p)
+	return nil
+}
+
 // GetPlayerID extracts player ID from either format
 func (m *Member) GetPlayerID() (int, bool) {
 	if m.ID != nil {
@@ -117,10 +182,7 @@ func (m *Member) GetSpecID() (int, bool) {
 // GetFaction extracts faction from either format
 func (m *Member) GetFaction() (string, bool) {
 	if m.Faction != nil {
-		return *m.Faction, true
-	}
-	if m.FactionType != nil {
-		return m.FactionType.Type, true
+		return m.Faction.Type, true
 	}
 	return "", false
 }
@@ -182,9 +244,15 @@ type EquippedItem struct {
 	Name         string            `json:"name"`
 	Quality      ItemQuality       `json:"quality"`
 	UpgradeID    *int              `json:"upgrade_id,omitempty"`
+	Level        *ItemLevel        `json:"level,omitempty"`
 	Enchantments []ItemEnchantment `json:"enchantments,omitempty"`
 }
 
+// ItemLevel represents the item level of an equipped item
+type ItemLevel struct {
+	Value int `json:"value"`
+}
+
 // ItemInfo represents basic item information
 type ItemInfo struct {
 	ID int `json:"id"`
@@ -282,6 +350,22 @@ type MediaAsset struct {
 	Value string `json:"value"`
 }
 
+// PeriodIndexResponse represents the response from the mythic keystone period index API
+type PeriodIndexResponse struct {
+	Periods []struct {
+		Key struct {
+			Href string `json:"href"`
+		} `json:"key"`
+		ID int `json:"id"`
+	} `json:"periods"`
+	CurrentPeriod struct {
+		Key struct {
+			Href string `json:"href"`
+		} `json:"key"`
+		ID int `json:"id"`
+	} `json:"current_period"`
+}
+
 // PeriodDetailResponse represents the response from a specific period detail API
 type PeriodDetailResponse struct {
 	ID             int   `json:"id"`
@@ -317,3 +401,21 @@ type SeasonDetailResponse struct {
 		ID int `json:"id"`
 	} `json:"periods"`
 }
+
+// ConnectedRealmIndexResponse represents the response from the connected realm index API
+type ConnectedRealmIndexResponse struct {
+	ConnectedRealms []struct {
+		Href string `json:"href"`
+	} `json:"connected_realms"`
+}
+
+// ConnectedRealmResponse represents a connected realm and the realms it groups
+type ConnectedRealmResponse struct {
+	ID     int `json:"id"`
+	Realms []struct {
+		ID           int    `json:"id"`
+		Name         string `json:"name"`
+		Slug         string `json:"slug"`
+		IsTournament bool   `json:"is_tournament"`
+	} `json:"realms"`
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// BuildStage is the recorded state of one `build` stage
+type BuildStage struct {
+	Name      string
+	InputHash string
+	StartedAt int64
+	// CompletedAt is zero while the stage runs or when its last run failed
+	CompletedAt int64
+	DurationMs  int64
+}
+
+// BuildStageStore covers the checkpoints `build --resume` skips finished stages by
+type BuildStageStore interface {
+	GetBuildStages() (map[string]BuildStage, error)
+	StartBuildStage(name, inputHash string, startedAt int64) error
+	CompleteBuildStage(name, inputHash string, completedAt int64) error
+	// CountDirtyRankings counts the ranking dirty marks the next ranking pass consumes
+	CountDirtyRankings() (partitions, players int, err error)
+	// GenerationInputs summarizes the tables the static API is generated from
+	GenerationInputs() (string, error)
+}
+
+// GetBuildStages returns every recorded build stage by name
+func (ds *DatabaseService) GetBuildStages() (map[string]BuildStage, error) {
+	rows, err := ds.db.Query(`SELECT stage, input_hash, started_at, completed_at, duration_ms FROM build_stages`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build stages: %w", err)
+	}
+	defer rows.Close()
+
+	stages := make(map[string]BuildStage)
+	for rows.Next() {
+		var st BuildStage
+		var completedAt, duration sql.NullInt64
+		if err := rows.Scan(&st.Name, &st.InputHash, &st.StartedAt, &completedAt, &duration); err != nil {
+			return nil, fmt.Errorf("failed to scan build stage: %w", err)
+		}
+		st.CompletedAt, st.DurationMs = completedAt.Int64, duration.Int64
+		stages[st.Name] = st
+	}
+	return stages, rows.Err()
+}
+
+// StartBuildStage records that a stage started against inputHash, clearing its last
+// completion so a failed run is never mistaken for a finished one
+func (ds *DatabaseService) StartBuildStage(name, inputHash string, startedAt int64) error {
+	_, err := ds.db.Exec(`
+		INSERT INTO build_stages (stage, input_hash, started_at, completed_at, duration_ms)
+		VALUES (?, ?, ?, NULL, NULL)
+		ON CONFLICT(stage) DO UPDATE SET
+			input_hash = excluded.input_hash,
+			started_at = excluded.started_at,
+			completed_at = NULL,
+			duration_ms = NULL
+	`, name, inputHash, startedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record start of build stage %s: %w", name, err)
+	}
+	return nil
+}
+
+// CompleteBuildStage marks a started stage as finished against inputHash, the inputs
+// as the stage left them
+func (ds *DatabaseService) CompleteBuildStage(name, inputHash string, completedAt int64) error {
+	_, err := ds.db.Exec(`
+		UPDATE build_stages
+		SET input_hash = ?, completed_at = ?, duration_ms = ? - started_at
+		WHERE stage = ?
+	`, inputHash, completedAt, completedAt, name)
+	if err != nil {
+		return fmt.Errorf("failed to record completion of build stage %s: %w", name, err)
+	}
+	return nil
+}
+
+// CountDirtyRankings counts the ranking partitions and players marked dirty
+func (ds *DatabaseService) CountDirtyRankings() (int, int, error) {
+	var partitions, players int
+	if err := ds.db.QueryRow(`SELECT COUNT(*) FROM ranking_dirty_partitions`).Scan(&partitions); err != nil {
+		return 0, 0, fmt.Errorf("failed to count dirty partitions: %w", err)
+	}
+	if err := ds.db.QueryRow(`SELECT COUNT(*) FROM ranking_dirty_players`).Scan(&players); err != nil {
+		return 0, 0, fmt.Errorf("failed to count dirty players: %w", err)
+	}
+	return partitions, players, nil
+}
+
+// GenerationInputs returns row counts and high-water marks of the runs, players,
+// profiles, details and ranking state the static API is generated from. It changes
+// whenever a fetch, profile fetch or ranking pass wrote something.
+func (ds *DatabaseService) GenerationInputs() (string, error) {
+	var runs, maxRunID, players, profiles, details, detailsUpdated, equipment, rankedAt int64
+	err := ds.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM challenge_runs),
+			(SELECT COALESCE(MAX(id), 0) FROM challenge_runs),
+			(SELECT COUNT(*) FROM players),
+			(SELECT COUNT(*) FROM player_profiles),
+			(SELECT COUNT(*) FROM player_details),
+			(SELECT COALESCE(MAX(last_updated), 0) FROM player_details),
+			(SELECT COALESCE(MAX(id), 0) FROM player_equipment),
+			(SELECT COALESCE(MAX(CASE WHEN COALESCE(full_at, 0) > COALESCE(incremental_at, 0) THEN full_at ELSE incremental_at END), 0) FROM ranking_state)
+	`).Scan(&runs, &maxRunID, &players, &profiles, &details, &detailsUpdated, &equipment, &rankedAt)
+	if err != nil {
+		return "", fmt.Errorf("failed to read generation inputs: %w", err)
+	}
+	return fmt.Sprintf("runs=%d:%d players=%d profiles=%d details=%d:%d equipment=%d ranked=%d",
+		runs, maxRunID, players, profiles, details, detailsUpdated, equipment, rankedAt), nil
+}
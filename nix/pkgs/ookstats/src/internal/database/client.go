@@ -3,6 +3,8 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"strings"
 
@@ -17,8 +19,30 @@ func SetDBPath(path string) {
 	dbPathOverride = path
 }
 
-// DBConnString returns the libsql connection string for the local SQLite file
+var databaseURLOverride string
+
+// SetDatabaseURL points the CLI at a PostgreSQL server instead of the local SQLite file
+func SetDatabaseURL(url string) {
+	databaseURLOverride = url
+}
+
+// DatabaseURL returns the configured PostgreSQL connection URL, or "" when the
+// local SQLite file is in use.
+func DatabaseURL() string {
+	if databaseURLOverride != "" {
+		return databaseURLOverride
+	}
+	if v := os.Getenv("OOKSTATS_DATABASE_URL"); v != "" {
+		return v
+	}
+	return ""
+}
+
+// DBConnString returns the connection string for the configured database
 func DBConnString() string {
+	if u := DatabaseURL(); u != "" {
+		return u
+	}
 	// Priority: explicit override -> env vars -> default
 	if dbPathOverride != "" {
 		if strings.HasPrefix(dbPathOverride, "file:") {
@@ -55,40 +79,86 @@ func ensureDSNParams(base string) string {
 		"_pragma=cache_size=-64000"
 }
 
-// DBFilePath returns the plain filesystem path for the local DB (without file: prefix)
+// DBFilePath returns the plain filesystem path for the local DB (without the file:
+// prefix or DSN parameters). For PostgreSQL it returns the server URL with the
+// password redacted.
 func DBFilePath() string {
 	conn := DBConnString()
+	if isPostgresURL(conn) {
+		return redactURL(conn)
+	}
 	if strings.HasPrefix(conn, "file:") {
-		return strings.TrimPrefix(conn, "file:")
+		path, _, _ := strings.Cut(strings.TrimPrefix(conn, "file:"), "?")
+		return path
 	}
 	return conn
 }
 
+// Connect opens the configured database, printing connection progress to stdout
 func Connect() (*sql.DB, error) {
+	return ConnectWithProgress(os.Stdout)
+}
+
+// ConnectWithProgress opens the configured database, printing connection progress
+// to w, for commands whose stdout carries their output
+func ConnectWithProgress(w io.Writer) (*sql.DB, error) {
+	if u := DatabaseURL(); u != "" {
+		return connectPostgres(w, u)
+	}
+
 	dsn := DBConnString()
-	fmt.Printf("Using local SQLite database: %s\n", dsn)
-	fmt.Printf("Opening database connection...\n")
+	fmt.Fprintf(w, "Using local SQLite database: %s\n", dsn)
+	fmt.Fprintf(w, "Opening database connection...\n")
 
 	db, err := sql.Open("libsql", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
-	fmt.Printf("Testing database connection...\n")
+	fmt.Fprintf(w, "Testing database connection...\n")
 	if err := db.Ping(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	// configure database for optimal performance
-	if err := configureDatabaseSettings(db, dsn); err != nil {
+	if err := configureDatabaseSettings(w, db, dsn); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to configure database: %w", err)
 	}
 
-	fmt.Printf("[OK] Local SQLite database connected\n")
+	fmt.Fprintf(w, "[OK] Local SQLite database connected\n")
+	return db, nil
+}
+
+// connectPostgres opens a PostgreSQL database through the placeholder-rebinding pgx driver
+func connectPostgres(w io.Writer, dsn string) (*sql.DB, error) {
+	if !isPostgresURL(dsn) {
+		return nil, fmt.Errorf("unsupported database URL %q (expected postgres:// or postgresql://)", redactURL(dsn))
+	}
+	fmt.Fprintf(w, "Using PostgreSQL database: %s\n", redactURL(dsn))
+
+	db, err := sql.Open(postgresDriverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	fmt.Fprintf(w, "[OK] PostgreSQL database connected\n")
 	return db, nil
 }
 
+// redactURL hides the password in a connection URL for logging
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
 func getEnvOrFail(key string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -117,7 +187,7 @@ func QuerySQL(db *sql.DB, query string, args ...any) (*sql.Rows, error) {
 }
 
 // configureDatabaseSettings optimizes database for performance
-func configureDatabaseSettings(db *sql.DB, dsn string) error {
-	fmt.Printf("[OK] Database configured\n")
+func configureDatabaseSettings(w io.Writer, db *sql.DB, dsn string) error {
+	fmt.Fprintf(w, "[OK] Database configured\n")
 	return nil
 }
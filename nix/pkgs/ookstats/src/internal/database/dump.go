@@ -0,0 +1,757 @@
+package database
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"ookstats/internal/blizzard"
+)
+
+// A dump is JSON lines: a header, one {"kind": ..., "data": ...} record per line in
+// the order they are loaded, and an end record with the count of every kind so a
+// truncated file is caught on load. Runs and profiles keep the shape of the API
+// responses they were stored from, so loading replays them through the fetch insert
+// paths. Derived tables (profiles, best runs, rankings) are not dumped; `process all`
+// rebuilds them after a load. Ranking history, fetch statuses and leaderboard
+// validators can't be rebuilt, so they are copied as stored.
+//
+// Version 2 added the ranking_history, fetch_status and leaderboard_validators kinds.
+const (
+	DumpFormat        = "ookstats-dump"
+	DumpFormatVersion = 2
+)
+
+// Dump record kinds, in the order they are written and loaded
+const (
+	dumpKindHeader             = "header"
+	dumpKindRealm              = "realm"
+	dumpKindDungeon            = "dungeon"
+	dumpKindSeason             = "season"
+	dumpKindPeriod             = "period"
+	dumpKindPeriodSeason       = "period_season"
+	dumpKindPlayer             = "player"
+	dumpKindFingerprint        = "fingerprint"
+	dumpKindRun                = "run"
+	dumpKindProfile            = "profile"
+	dumpKindEquipmentRetention = "equipment_retention"
+	dumpKindRankingHistory     = "ranking_history"
+	dumpKindFetchStatus        = "fetch_status"
+	dumpKindValidators         = "leaderboard_validators"
+	dumpKindEnd                = "end"
+)
+
+var dumpKindOrder = []string{
+	dumpKindRealm,
+	dumpKindDungeon,
+	dumpKindSeason,
+	dumpKindPeriod,
+	dumpKindPeriodSeason,
+	dumpKindPlayer,
+	dumpKindFingerprint,
+	dumpKindRun,
+	dumpKindProfile,
+	dumpKindEquipmentRetention,
+	dumpKindRankingHistory,
+	dumpKindFetchStatus,
+	dumpKindValidators,
+}
+
+// dumpRunPageSize is how many runs are read per page while dumping
+const dumpRunPageSize = 1000
+
+// DumpCounts is the number of records of each kind in a dump
+type DumpCounts map[string]int64
+
+// DumpHeader is the first line of a dump
+type DumpHeader struct {
+	Format        string `json:"format"`
+	Version       int    `json:"version"`
+	SchemaVersion int    `json:"schema_version"`
+	CreatedAt     string `json:"created_at"`
+}
+
+type dumpRecord struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+type dumpEnd struct {
+	Counts DumpCounts `json:"counts"`
+}
+
+type dumpRealm struct {
+	ID               int64   `json:"id"`
+	Slug             string  `json:"slug"`
+	Name             *string `json:"name"`
+	Region           string  `json:"region"`
+	ConnectedRealmID *int64  `json:"connected_realm_id"`
+	ParentRealmSlug  *string `json:"parent_realm_slug"`
+}
+
+type dumpSeason struct {
+	ID             int64   `json:"id"`
+	SeasonNumber   int     `json:"season_number"`
+	Region         string  `json:"region"`
+	StartTimestamp *int64  `json:"start_timestamp"`
+	EndTimestamp   *int64  `json:"end_timestamp"`
+	SeasonName     *string `json:"season_name"`
+	FirstPeriodID  *int64  `json:"first_period_id"`
+	LastPeriodID   *int64  `json:"last_period_id"`
+}
+
+type dumpPeriod struct {
+	ID             int64  `json:"id"`
+	Region         string `json:"region"`
+	StartTimestamp *int64 `json:"start_timestamp"`
+	EndTimestamp   *int64 `json:"end_timestamp"`
+	SeasonID       *int64 `json:"season_id"`
+}
+
+type dumpPeriodSeason struct {
+	PeriodID int64 `json:"period_id"`
+	SeasonID int64 `json:"season_id"`
+}
+
+type dumpPlayer struct {
+	ID                  int64   `json:"id"`
+	BlizzardCharacterID *int64  `json:"blizzard_character_id"`
+	Name                *string `json:"name"`
+	NameLower           *string `json:"name_lower"`
+	RealmID             *int64  `json:"realm_id"`
+	IsValid             *int64  `json:"is_valid"`
+	StatusCheckedAt     *int64  `json:"status_checked_at"`
+}
+
+// dumpRun is a stored run as a leaderboard entry, with the leaderboard it was first
+// stored from and every realm that listed it
+type dumpRun struct {
+	ID                   int64  `json:"id"`
+	RealmID              int64  `json:"realm_id"`
+	DungeonID            int    `json:"dungeon_id"`
+	PeriodID             int    `json:"period_id"`
+	PeriodStartTimestamp int64  `json:"period_start_timestamp"`
+	PeriodEndTimestamp   int64  `json:"period_end_timestamp"`
+	SeasonID             *int64 `json:"season_id"`
+	// TeamSignature is kept as stored; it no longer matches the members after a
+	// faction transfer moved their runs to a new player id
+	TeamSignature string `json:"team_signature"`
+	blizzard.ChallengeRun
+	Sightings       []dumpRunSighting    `json:"sightings"`
+	PeriodSightings []dumpPeriodSighting `json:"period_sightings"`
+}
+
+type dumpRunSighting struct {
+	RealmID     int64  `json:"realm_id"`
+	FirstSeenAt *int64 `json:"first_seen_at"`
+}
+
+type dumpPeriodSighting struct {
+	RealmID     int64  `json:"realm_id"`
+	DungeonID   int64  `json:"dungeon_id"`
+	PeriodID    int64  `json:"period_id"`
+	FirstSeenAt *int64 `json:"first_seen_at"`
+	LastSeenAt  *int64 `json:"last_seen_at"`
+}
+
+// dumpProfile is one profile fetch of a player: the character summary, or the
+// equipment slots that changed at Timestamp
+type dumpProfile struct {
+	PlayerID  int                                `json:"player_id"`
+	Timestamp int64                              `json:"timestamp"`
+	Summary   *blizzard.CharacterSummaryResponse `json:"summary,omitempty"`
+	Media     *blizzard.CharacterMediaResponse   `json:"media,omitempty"`
+	Equipment []dumpEquippedItem                 `json:"equipment,omitempty"`
+}
+
+type dumpEquippedItem struct {
+	blizzard.EquippedItem
+	// Emptied marks a slot that no longer held an item
+	Emptied bool `json:"emptied,omitempty"`
+}
+
+type dumpEquipmentRetention struct {
+	PrunedBefore int64  `json:"pruned_before"`
+	PrunedAt     *int64 `json:"pruned_at"`
+}
+
+type dumpRankingHistory struct {
+	PlayerID         int64  `json:"player_id"`
+	SeasonID         int64  `json:"season_id"`
+	RecordedAt       int64  `json:"recorded_at"`
+	CombinedBestTime *int64 `json:"combined_best_time"`
+	GlobalRanking    *int64 `json:"global_ranking"`
+	RegionalRanking  *int64 `json:"regional_ranking"`
+	RealmRanking     *int64 `json:"realm_ranking"`
+	GlobalClassRank  *int64 `json:"global_class_rank"`
+	RegionClassRank  *int64 `json:"region_class_rank"`
+	RealmClassRank   *int64 `json:"realm_class_rank"`
+}
+
+type dumpFetchStatus struct {
+	Region         string  `json:"region"`
+	RealmSlug      string  `json:"realm_slug"`
+	DungeonID      int64   `json:"dungeon_id"`
+	PeriodID       int64   `json:"period_id"`
+	Status         string  `json:"status"`
+	HTTPStatus     *int64  `json:"http_status"`
+	CheckedAt      int64   `json:"checked_at"`
+	Message        *string `json:"message"`
+	CutoffDuration *int64  `json:"cutoff_duration"`
+}
+
+type dumpValidators struct {
+	Region       string  `json:"region"`
+	RealmSlug    string  `json:"realm_slug"`
+	DungeonID    int64   `json:"dungeon_id"`
+	PeriodID     int64   `json:"period_id"`
+	ETag         *string `json:"etag"`
+	LastModified *string `json:"last_modified"`
+	UpdatedAt    int64   `json:"updated_at"`
+}
+
+// dumpWriter encodes records and counts them by kind
+type dumpWriter struct {
+	enc    *json.Encoder
+	counts DumpCounts
+}
+
+func (w *dumpWriter) write(kind string, data any) error {
+	if err := w.enc.Encode(struct {
+		Kind string `json:"kind"`
+		Data any    `json:"data"`
+	}{kind, data}); err != nil {
+		return fmt.Errorf("write %s: %w", kind, err)
+	}
+	if kind != dumpKindHeader && kind != dumpKindEnd {
+		w.counts[kind]++
+	}
+	return nil
+}
+
+// Dump writes the fetched data of the database to w: realms, dungeons, seasons and
+// periods, players and fingerprints, runs with their members and sightings, profile
+// summaries and equipment history, ranking history, and the fetch status and
+// validators of every leaderboard. Stop fetches while dumping; the tables
+// are read one after another, not in a single transaction.
+func Dump(db *sql.DB, w io.Writer) (DumpCounts, error) {
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if latest := LatestSchemaVersion(); version != latest {
+		return nil, fmt.Errorf("database is at schema version %d, expected %d; run `ookstats schema migrate` first", version, latest)
+	}
+
+	bw := bufio.NewWriter(w)
+	dw := &dumpWriter{enc: json.NewEncoder(bw), counts: DumpCounts{}}
+	if err := dw.write(dumpKindHeader, DumpHeader{
+		Format:        DumpFormat,
+		Version:       DumpFormatVersion,
+		SchemaVersion: version,
+		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
+	}); err != nil {
+		return nil, err
+	}
+
+	steps := []func(*sql.DB, *dumpWriter) error{
+		dumpRealms,
+		dumpDungeons,
+		dumpSeasons,
+		dumpPeriods,
+		dumpPeriodSeasons,
+		dumpPlayers,
+		dumpFingerprints,
+		dumpRuns,
+		dumpEquipment,
+		dumpPlayerDetails,
+		dumpEquipmentRetentionRow,
+		dumpRankingHistoryRows,
+		dumpFetchStatuses,
+		dumpValidatorRows,
+	}
+	for _, step := range steps {
+		if err := step(db, dw); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := dw.write(dumpKindEnd, dumpEnd{Counts: dw.counts}); err != nil {
+		return nil, err
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, fmt.Errorf("flush dump: %w", err)
+	}
+	return dw.counts, nil
+}
+
+// dumpQuery writes one record per row of query
+func dumpQuery(db *sql.DB, dw *dumpWriter, kind, query string, scan func(*sql.Rows) (any, error)) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return fmt.Errorf("query %s: %w", kind, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rec, err := scan(rows)
+		if err != nil {
+			return fmt.Errorf("scan %s: %w", kind, err)
+		}
+		if err := dw.write(kind, rec); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate %s: %w", kind, err)
+	}
+	return nil
+}
+
+func dumpRealms(db *sql.DB, dw *dumpWriter) error {
+	return dumpQuery(db, dw, dumpKindRealm, `
+		SELECT id, COALESCE(slug, ''), name, COALESCE(region, ''), connected_realm_id, parent_realm_slug
+		FROM realms ORDER BY id
+	`, func(rows *sql.Rows) (any, error) {
+		var r dumpRealm
+		err := rows.Scan(&r.ID, &r.Slug, &r.Name, &r.Region, &r.ConnectedRealmID, &r.ParentRealmSlug)
+		return r, err
+	})
+}
+
+func dumpDungeons(db *sql.DB, dw *dumpWriter) error {
+	return dumpQuery(db, dw, dumpKindDungeon, `
+		SELECT id, COALESCE(name, ''), COALESCE(slug, ''), COALESCE(map_id, 0)
+		FROM dungeons ORDER BY id
+	`, func(rows *sql.Rows) (any, error) {
+		var d blizzard.DungeonInfo
+		err := rows.Scan(&d.ID, &d.Name, &d.Slug, &d.MapID)
+		return d, err
+	})
+}
+
+func dumpSeasons(db *sql.DB, dw *dumpWriter) error {
+	return dumpQuery(db, dw, dumpKindSeason, `
+		SELECT id, season_number, region, start_timestamp, end_timestamp, season_name, first_period_id, last_period_id
+		FROM seasons ORDER BY id
+	`, func(rows *sql.Rows) (any, error) {
+		var s dumpSeason
+		err := rows.Scan(&s.ID, &s.SeasonNumber, &s.Region, &s.StartTimestamp, &s.EndTimestamp, &s.SeasonName, &s.FirstPeriodID, &s.LastPeriodID)
+		return s, err
+	})
+}
+
+func dumpPeriods(db *sql.DB, dw *dumpWriter) error {
+	return dumpQuery(db, dw, dumpKindPeriod, `
+		SELECT id, region, start_timestamp, end_timestamp, season_id
+		FROM periods ORDER BY region, id
+	`, func(rows *sql.Rows) (any, error) {
+		var p dumpPeriod
+		err := rows.Scan(&p.ID, &p.Region, &p.StartTimestamp, &p.EndTimestamp, &p.SeasonID)
+		return p, err
+	})
+}
+
+func dumpPeriodSeasons(db *sql.DB, dw *dumpWriter) error {
+	return dumpQuery(db, dw, dumpKindPeriodSeason, `
+		SELECT period_id, season_id FROM period_seasons ORDER BY season_id, period_id
+	`, func(rows *sql.Rows) (any, error) {
+		var ps dumpPeriodSeason
+		err := rows.Scan(&ps.PeriodID, &ps.SeasonID)
+		return ps, err
+	})
+}
+
+func dumpPlayers(db *sql.DB, dw *dumpWriter) error {
+	return dumpQuery(db, dw, dumpKindPlayer, `
+		SELECT id, blizzard_character_id, name, name_lower, realm_id, is_valid, status_checked_at
+		FROM players ORDER BY id
+	`, func(rows *sql.Rows) (any, error) {
+		var p dumpPlayer
+		err := rows.Scan(&p.ID, &p.BlizzardCharacterID, &p.Name, &p.NameLower, &p.RealmID, &p.IsValid, &p.StatusCheckedAt)
+		return p, err
+	})
+}
+
+func dumpFingerprints(db *sql.DB, dw *dumpWriter) error {
+	return dumpQuery(db, dw, dumpKindFingerprint, `
+		SELECT player_id, COALESCE(fingerprint_hash, ''), class_id, level85_timestamp, level90_timestamp,
+		       earliest_heroic_timestamp, COALESCE(last_seen_name, ''), COALESCE(last_seen_realm_slug, ''),
+		       COALESCE(last_seen_timestamp, 0), COALESCE(first_run_timestamp, 0), COALESCE(created_at, 0)
+		FROM player_fingerprints ORDER BY player_id
+	`, func(rows *sql.Rows) (any, error) {
+		var fp PlayerFingerprint
+		err := rows.Scan(&fp.PlayerID, &fp.FingerprintHash, &fp.ClassID, &fp.Level85Timestamp, &fp.Level90Timestamp,
+			&fp.EarliestHeroicTimestamp, &fp.LastSeenName, &fp.LastSeenRealmSlug,
+			&fp.LastSeenTimestamp, &fp.FirstRunTimestamp, &fp.CreatedAt)
+		return fp, err
+	})
+}
+
+// dumpRuns writes runs in id order, a page at a time with their members and sightings
+func dumpRuns(db *sql.DB, dw *dumpWriter) error {
+	afterID := int64(0)
+	for {
+		runs, err := loadDumpRunPage(db, afterID)
+		if err != nil {
+			return err
+		}
+		if len(runs) == 0 {
+			return nil
+		}
+		for _, run := range runs {
+			if err := dw.write(dumpKindRun, run); err != nil {
+				return err
+			}
+		}
+		afterID = runs[len(runs)-1].ID
+	}
+}
+
+func loadDumpRunPage(db *sql.DB, afterID int64) ([]*dumpRun, error) {
+	rows, err := db.Query(`
+		SELECT id, COALESCE(realm_id, 0), COALESCE(dungeon_id, 0), COALESCE(period_id, 0),
+		       COALESCE(period_start_timestamp, 0), COALESCE(period_end_timestamp, 0), season_id,
+		       COALESCE(team_signature, ''), COALESCE(duration, 0), COALESCE(completed_timestamp, 0),
+		       COALESCE(keystone_level, 0)
+		FROM challenge_runs
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`, afterID, dumpRunPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("query runs: %w", err)
+	}
+	var runs []*dumpRun
+	byID := make(map[int64]*dumpRun)
+	for rows.Next() {
+		run := &dumpRun{}
+		if err := rows.Scan(&run.ID, &run.RealmID, &run.DungeonID, &run.PeriodID,
+			&run.PeriodStartTimestamp, &run.PeriodEndTimestamp, &run.SeasonID,
+			&run.TeamSignature, &run.Duration, &run.CompletedTimestamp, &run.KeystoneLevel); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan run: %w", err)
+		}
+		run.Members = []blizzard.Member{}
+		run.Sightings = []dumpRunSighting{}
+		run.PeriodSightings = []dumpPeriodSighting{}
+		runs = append(runs, run)
+		byID[run.ID] = run
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate runs: %w", err)
+	}
+	if len(runs) == 0 {
+		return nil, nil
+	}
+	first, last := runs[0].ID, runs[len(runs)-1].ID
+
+	rows, err = db.Query(`
+		SELECT rm.run_id, rm.player_id, p.name, r.slug, rm.spec_id, rm.faction
+		FROM run_members rm
+		LEFT JOIN players p ON p.id = rm.player_id
+		LEFT JOIN realms r ON r.id = p.realm_id
+		WHERE rm.run_id BETWEEN ? AND ? AND rm.player_id IS NOT NULL
+		ORDER BY rm.run_id, rm.player_id
+	`, first, last)
+	if err != nil {
+		return nil, fmt.Errorf("query run members: %w", err)
+	}
+	for rows.Next() {
+		var runID int64
+		var playerID int
+		var name, realmSlug, faction sql.NullString
+		var specID sql.NullInt64
+		if err := rows.Scan(&runID, &playerID, &name, &realmSlug, &specID, &faction); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan run member: %w", err)
+		}
+		run := byID[runID]
+		if run == nil {
+			continue
+		}
+		m := blizzard.Member{ID: &playerID}
+		if name.Valid {
+			m.Name = &name.String
+		}
+		if realmSlug.Valid {
+			m.RealmSlug = &realmSlug.String
+		}
+		if specID.Valid {
+			spec := int(specID.Int64)
+			m.SpecID = &spec
+		}
+		if faction.Valid {
+			m.Faction = &blizzard.FactionType{Type: faction.String}
+		}
+		run.Members = append(run.Members, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate run members: %w", err)
+	}
+
+	rows, err = db.Query(`
+		SELECT run_id, realm_id, first_seen_at
+		FROM run_sightings
+		WHERE run_id BETWEEN ? AND ?
+		ORDER BY run_id, realm_id
+	`, first, last)
+	if err != nil {
+		return nil, fmt.Errorf("query run sightings: %w", err)
+	}
+	for rows.Next() {
+		var runID int64
+		var s dumpRunSighting
+		if err := rows.Scan(&runID, &s.RealmID, &s.FirstSeenAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan run sighting: %w", err)
+		}
+		if run := byID[runID]; run != nil {
+			run.Sightings = append(run.Sightings, s)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate run sightings: %w", err)
+	}
+
+	rows, err = db.Query(`
+		SELECT run_id, realm_id, dungeon_id, period_id, first_seen_at, last_seen_at
+		FROM run_period_sightings
+		WHERE run_id BETWEEN ? AND ?
+		ORDER BY run_id, realm_id, period_id
+	`, first, last)
+	if err != nil {
+		return nil, fmt.Errorf("query run period sightings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var runID int64
+		var s dumpPeriodSighting
+		if err := rows.Scan(&runID, &s.RealmID, &s.DungeonID, &s.PeriodID, &s.FirstSeenAt, &s.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("scan run period sighting: %w", err)
+		}
+		if run := byID[runID]; run != nil {
+			run.PeriodSightings = append(run.PeriodSightings, s)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate run period sightings: %w", err)
+	}
+	return runs, nil
+}
+
+// equipmentSnapshotOrder groups equipment rows into the fetch that wrote them and
+// orders the fetches as they were stored, so a load assigns the same row ids
+const equipmentSnapshotOrder = `
+	JOIN (
+		SELECT player_id, snapshot_timestamp, MIN(id) AS first_id
+		FROM player_equipment
+		GROUP BY player_id, snapshot_timestamp
+	) g ON g.player_id = e.player_id AND g.snapshot_timestamp = e.snapshot_timestamp`
+
+// dumpEquipment writes one profile record per equipment snapshot with the slots that
+// changed in it. Enchantments are merged in from a second cursor in the same order.
+func dumpEquipment(db *sql.DB, dw *dumpWriter) error {
+	items, err := db.Query(`
+		SELECT e.id, e.player_id, e.snapshot_timestamp, COALESCE(e.slot_type, ''), e.item_id,
+		       e.upgrade_id, e.item_level, COALESCE(e.quality, ''), COALESCE(e.item_name, '')
+		FROM player_equipment e` + equipmentSnapshotOrder + `
+		WHERE e.player_id IS NOT NULL AND e.snapshot_timestamp IS NOT NULL
+		ORDER BY g.first_id, e.id
+	`)
+	if err != nil {
+		return fmt.Errorf("query equipment: %w", err)
+	}
+	defer items.Close()
+
+	enchants, err := db.Query(`
+		SELECT pee.equipment_id, pee.enchantment_id, pee.slot_id, pee.slot_type, pee.display_string,
+		       pee.source_item_id, pee.source_item_name, pee.spell_id
+		FROM player_equipment_enchantments pee
+		JOIN player_equipment e ON e.id = pee.equipment_id` + equipmentSnapshotOrder + `
+		ORDER BY g.first_id, e.id, pee.id
+	`)
+	if err != nil {
+		return fmt.Errorf("query equipment enchantments: %w", err)
+	}
+	defer enchants.Close()
+
+	type enchantRow struct {
+		equipmentID int64
+		enchant     blizzard.ItemEnchantment
+	}
+	var pending *enchantRow
+	nextEnchant := func() error {
+		pending = nil
+		if !enchants.Next() {
+			return enchants.Err()
+		}
+		var row enchantRow
+		var enchantmentID, slotID, sourceItemID, spellID sql.NullInt64
+		var slotType, display, sourceItemName sql.NullString
+		if err := enchants.Scan(&row.equipmentID, &enchantmentID, &slotID, &slotType, &display,
+			&sourceItemID, &sourceItemName, &spellID); err != nil {
+			return fmt.Errorf("scan equipment enchantment: %w", err)
+		}
+		e := &row.enchant
+		if enchantmentID.Valid {
+			id := int(enchantmentID.Int64)
+			e.EnchantmentID = &id
+		}
+		if slotID.Valid || slotType.Valid {
+			e.EnchantmentSlot = &blizzard.EnchantSlot{ID: int(slotID.Int64), Type: slotType.String}
+		}
+		if display.Valid {
+			e.DisplayString = &display.String
+		}
+		if sourceItemID.Valid {
+			e.SourceItem = &blizzard.SourceItem{ID: int(sourceItemID.Int64), Name: sourceItemName.String}
+		}
+		if spellID.Valid {
+			e.Spell = &blizzard.SpellInfo{Spell: blizzard.SpellDetail{ID: int(spellID.Int64)}}
+		}
+		pending = &row
+		return nil
+	}
+	if err := nextEnchant(); err != nil {
+		return err
+	}
+
+	var snapshot *dumpProfile
+	flush := func() error {
+		if snapshot == nil {
+			return nil
+		}
+		err := dw.write(dumpKindProfile, snapshot)
+		snapshot = nil
+		return err
+	}
+
+	for items.Next() {
+		var id int64
+		var playerID int
+		var timestamp int64
+		var itemID, upgradeID, itemLevel sql.NullInt64
+		var item dumpEquippedItem
+		if err := items.Scan(&id, &playerID, &timestamp, &item.Slot.Type, &itemID,
+			&upgradeID, &itemLevel, &item.Quality.Type, &item.Name); err != nil {
+			return fmt.Errorf("scan equipment: %w", err)
+		}
+		if snapshot == nil || snapshot.PlayerID != playerID || snapshot.Timestamp != timestamp {
+			if err := flush(); err != nil {
+				return err
+			}
+			snapshot = &dumpProfile{PlayerID: playerID, Timestamp: timestamp}
+		}
+
+		item.Emptied = !itemID.Valid
+		item.Item.ID = int(itemID.Int64)
+		if upgradeID.Valid {
+			upgrade := int(upgradeID.Int64)
+			item.UpgradeID = &upgrade
+		}
+		if itemLevel.Valid {
+			item.Level = &blizzard.ItemLevel{Value: int(itemLevel.Int64)}
+		}
+		for pending != nil && pending.equipmentID == id {
+			item.Enchantments = append(item.Enchantments, pending.enchant)
+			if err := nextEnchant(); err != nil {
+				return err
+			}
+		}
+		snapshot.Equipment = append(snapshot.Equipment, item)
+	}
+	if err := items.Err(); err != nil {
+		return fmt.Errorf("iterate equipment: %w", err)
+	}
+	return flush()
+}
+
+// dumpPlayerDetails writes the stored character summary of each player as a profile
+// record at the time it was last updated
+func dumpPlayerDetails(db *sql.DB, dw *dumpWriter) error {
+	return dumpQuery(db, dw, dumpKindProfile, `
+		SELECT pd.player_id, COALESCE(p.name, ''), COALESCE(pd.last_updated, 0),
+		       COALESCE(pd.race_id, 0), COALESCE(pd.race_name, ''), COALESCE(pd.gender, ''),
+		       COALESCE(pd.class_id, 0), COALESCE(pd.class_name, ''),
+		       COALESCE(pd.active_spec_id, 0), COALESCE(pd.active_spec_name, ''),
+		       pd.guild_name, COALESCE(pd.level, 0),
+		       COALESCE(pd.average_item_level, 0), COALESCE(pd.equipped_item_level, 0),
+		       pd.avatar_url
+		FROM player_details pd
+		LEFT JOIN players p ON p.id = pd.player_id
+		ORDER BY pd.player_id
+	`, func(rows *sql.Rows) (any, error) {
+		var rec dumpProfile
+		var summary blizzard.CharacterSummaryResponse
+		var guild, avatar sql.NullString
+		if err := rows.Scan(&rec.PlayerID, &summary.Name, &rec.Timestamp,
+			&summary.Race.ID, &summary.Race.Name, &summary.Gender.Type,
+			&summary.CharacterClass.ID, &summary.CharacterClass.Name,
+			&summary.ActiveSpec.ID, &summary.ActiveSpec.Name,
+			&guild, &summary.Level,
+			&summary.AverageItemLevel, &summary.EquippedItemLevel,
+			&avatar); err != nil {
+			return nil, err
+		}
+		summary.ID = rec.PlayerID
+		if guild.Valid {
+			summary.Guild = &blizzard.CharacterGuild{Name: guild.String}
+		}
+		rec.Summary = &summary
+		if avatar.Valid {
+			rec.Media = &blizzard.CharacterMediaResponse{Assets: []blizzard.MediaAsset{{Key: "avatar", Value: avatar.String}}}
+		}
+		return rec, nil
+	})
+}
+
+func dumpEquipmentRetentionRow(db *sql.DB, dw *dumpWriter) error {
+	return dumpQuery(db, dw, dumpKindEquipmentRetention, `
+		SELECT pruned_before, pruned_at FROM equipment_retention ORDER BY id
+	`, func(rows *sql.Rows) (any, error) {
+		var r dumpEquipmentRetention
+		err := rows.Scan(&r.PrunedBefore, &r.PrunedAt)
+		return r, err
+	})
+}
+
+func dumpRankingHistoryRows(db *sql.DB, dw *dumpWriter) error {
+	return dumpQuery(db, dw, dumpKindRankingHistory, `
+		SELECT player_id, season_id, recorded_at, combined_best_time, global_ranking, regional_ranking,
+		       realm_ranking, global_class_rank, region_class_rank, realm_class_rank
+		FROM player_ranking_history ORDER BY player_id, season_id, recorded_at
+	`, func(rows *sql.Rows) (any, error) {
+		var h dumpRankingHistory
+		err := rows.Scan(&h.PlayerID, &h.SeasonID, &h.RecordedAt, &h.CombinedBestTime, &h.GlobalRanking, &h.RegionalRanking,
+			&h.RealmRanking, &h.GlobalClassRank, &h.RegionClassRank, &h.RealmClassRank)
+		return h, err
+	})
+}
+
+func dumpFetchStatuses(db *sql.DB, dw *dumpWriter) error {
+	return dumpQuery(db, dw, dumpKindFetchStatus, `
+		SELECT region, realm_slug, dungeon_id, period_id, status, http_status, checked_at, message, cutoff_duration
+		FROM fetch_status ORDER BY region, realm_slug, dungeon_id, period_id
+	`, func(rows *sql.Rows) (any, error) {
+		var f dumpFetchStatus
+		err := rows.Scan(&f.Region, &f.RealmSlug, &f.DungeonID, &f.PeriodID, &f.Status, &f.HTTPStatus, &f.CheckedAt, &f.Message, &f.CutoffDuration)
+		return f, err
+	})
+}
+
+func dumpValidatorRows(db *sql.DB, dw *dumpWriter) error {
+	return dumpQuery(db, dw, dumpKindValidators, `
+		SELECT region, realm_slug, dungeon_id, period_id, etag, last_modified, updated_at
+		FROM leaderboard_validators ORDER BY region, realm_slug, dungeon_id, period_id
+	`, func(rows *sql.Rows) (any, error) {
+		var v dumpValidators
+		err := rows.Scan(&v.Region, &v.RealmSlug, &v.DungeonID, &v.PeriodID, &v.ETag, &v.LastModified, &v.UpdatedAt)
+		return v, err
+	})
+}
@@ -0,0 +1,515 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"ookstats/internal/blizzard"
+)
+
+// dumpLoadBatchSize is how many records are written per transaction while loading
+const dumpLoadBatchSize = 500
+
+// identityTables have ids generated by the database. A load writes their ids
+// explicitly, which PostgreSQL identity sequences don't notice.
+var identityTables = []string{
+	"realms",
+	"challenge_runs",
+	"seasons",
+	"player_equipment",
+	"player_equipment_enchantments",
+	"api_fetch_metadata",
+}
+
+// LoadDump replays a dump written by Dump into an empty database. Runs are inserted
+// through the leaderboard insert path and profiles through the profile insert path,
+// so a load writes what a fetch of the same responses would have; run ids, realm ids
+// and season ids keep their dumped values. Run `process all` afterwards to rebuild
+// player profiles and rankings.
+func LoadDump(db *sql.DB, r io.Reader) (DumpCounts, error) {
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if latest := LatestSchemaVersion(); version != latest {
+		return nil, fmt.Errorf("database is at schema version %d, expected %d; run `ookstats schema migrate` first", version, latest)
+	}
+	var existing int64
+	if err := db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM realms) + (SELECT COUNT(*) FROM players) + (SELECT COUNT(*) FROM challenge_runs)
+	`).Scan(&existing); err != nil {
+		return nil, fmt.Errorf("check database is empty: %w", err)
+	}
+	if existing > 0 {
+		return nil, fmt.Errorf("database already has realms, players or runs; load into an empty database")
+	}
+
+	br := bufio.NewReaderSize(r, 1<<20)
+	line, err := readDumpLine(br)
+	if err != nil {
+		return nil, fmt.Errorf("read dump header: %w", err)
+	}
+	var first dumpRecord
+	var header DumpHeader
+	if err := json.Unmarshal(line, &first); err != nil || first.Kind != dumpKindHeader {
+		return nil, fmt.Errorf("not an %s file: missing header", DumpFormat)
+	}
+	if err := json.Unmarshal(first.Data, &header); err != nil {
+		return nil, fmt.Errorf("decode dump header: %w", err)
+	}
+	if header.Format != DumpFormat {
+		return nil, fmt.Errorf("not an %s file: format %q", DumpFormat, header.Format)
+	}
+	if header.Version < 1 || header.Version > DumpFormatVersion {
+		return nil, fmt.Errorf("dump format version %d is not supported (this build reads up to %d)", header.Version, DumpFormatVersion)
+	}
+
+	l := &dumpLoader{
+		ds:       NewDatabaseService(db),
+		realms:   make(map[int64]blizzard.RealmInfo),
+		dungeons: make(map[int]blizzard.DungeonInfo),
+		loadouts: make(map[int]map[string]blizzard.EquippedItem),
+		counts:   DumpCounts{},
+	}
+	defer l.rollback()
+
+	rank := make(map[string]int, len(dumpKindOrder))
+	for i, kind := range dumpKindOrder {
+		rank[kind] = i
+	}
+	current := 0
+	var end *dumpEnd
+	for lineNo := 2; ; lineNo++ {
+		line, err := readDumpLine(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read dump line %d: %w", lineNo, err)
+		}
+		if end != nil {
+			return nil, fmt.Errorf("dump line %d: data after the end record", lineNo)
+		}
+
+		var rec dumpRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("dump line %d: %w", lineNo, err)
+		}
+		if rec.Kind == dumpKindEnd {
+			end = &dumpEnd{}
+			if err := json.Unmarshal(rec.Data, end); err != nil {
+				return nil, fmt.Errorf("dump line %d: %w", lineNo, err)
+			}
+			continue
+		}
+		r, ok := rank[rec.Kind]
+		if !ok {
+			return nil, fmt.Errorf("dump line %d: unknown record kind %q", lineNo, rec.Kind)
+		}
+		if r < current {
+			return nil, fmt.Errorf("dump line %d: %s record after %s records", lineNo, rec.Kind, dumpKindOrder[current])
+		}
+		current = r
+
+		if err := l.load(rec); err != nil {
+			return nil, fmt.Errorf("dump line %d (%s): %w", lineNo, rec.Kind, err)
+		}
+		l.counts[rec.Kind]++
+	}
+	if err := l.commit(); err != nil {
+		return nil, err
+	}
+
+	if end == nil {
+		return nil, fmt.Errorf("dump is truncated: no end record")
+	}
+	for kind, want := range end.Counts {
+		if got := l.counts[kind]; got != want {
+			return nil, fmt.Errorf("dump is incomplete: %d %s records, end record lists %d", got, kind, want)
+		}
+	}
+
+	if DialectOf(db) == Postgres {
+		if err := resetIdentitySequences(db); err != nil {
+			return nil, err
+		}
+	}
+	return l.counts, nil
+}
+
+// readDumpLine returns the next non-empty line without its newline
+func readDumpLine(br *bufio.Reader) ([]byte, error) {
+	for {
+		line, err := br.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// resetIdentitySequences moves each identity sequence past the largest loaded id
+func resetIdentitySequences(db *sql.DB) error {
+	for _, table := range identityTables {
+		if _, err := db.Exec(`SELECT setval(pg_get_serial_sequence('` + table + `', 'id'), COALESCE((SELECT MAX(id) FROM ` + table + `), 0) + 1, false)`); err != nil {
+			return fmt.Errorf("reset %s id sequence: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// dumpLoader writes dump records in batched transactions
+type dumpLoader struct {
+	ds      *DatabaseService
+	tx      *sql.Tx
+	pending int
+
+	realms   map[int64]blizzard.RealmInfo
+	dungeons map[int]blizzard.DungeonInfo
+	// loadouts is the gear of each player as of the last replayed snapshot
+	loadouts map[int]map[string]blizzard.EquippedItem
+	counts   DumpCounts
+}
+
+func (l *dumpLoader) begin() (*sql.Tx, error) {
+	if l.tx == nil {
+		tx, err := l.ds.db.Begin()
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		l.tx = tx
+	}
+	return l.tx, nil
+}
+
+// step counts a written record and commits once the batch is full
+func (l *dumpLoader) step() error {
+	l.pending++
+	if l.pending >= dumpLoadBatchSize {
+		return l.commit()
+	}
+	return nil
+}
+
+func (l *dumpLoader) commit() error {
+	if l.tx == nil {
+		return nil
+	}
+	err := l.tx.Commit()
+	l.tx = nil
+	l.pending = 0
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (l *dumpLoader) rollback() {
+	if l.tx != nil {
+		l.tx.Rollback()
+		l.tx = nil
+	}
+}
+
+// exec runs one statement in the current batch
+func (l *dumpLoader) exec(query string, args ...any) error {
+	tx, err := l.begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+	return l.step()
+}
+
+func (l *dumpLoader) load(rec dumpRecord) error {
+	switch rec.Kind {
+	case dumpKindRealm:
+		var realm dumpRealm
+		if err := json.Unmarshal(rec.Data, &realm); err != nil {
+			return err
+		}
+		info := blizzard.RealmInfo{Slug: realm.Slug, Region: realm.Region}
+		if realm.Name != nil {
+			info.Name = *realm.Name
+		}
+		l.realms[realm.ID] = info
+		return l.exec(`
+			INSERT INTO realms (id, slug, name, region, connected_realm_id, parent_realm_slug)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, realm.ID, realm.Slug, realm.Name, realm.Region, realm.ConnectedRealmID, realm.ParentRealmSlug)
+
+	case dumpKindDungeon:
+		var dungeon blizzard.DungeonInfo
+		if err := json.Unmarshal(rec.Data, &dungeon); err != nil {
+			return err
+		}
+		l.dungeons[dungeon.ID] = dungeon
+		if err := l.commit(); err != nil {
+			return err
+		}
+		_, err := l.ds.MergeDungeons([]blizzard.DungeonInfo{dungeon})
+		return err
+
+	case dumpKindSeason:
+		var season dumpSeason
+		if err := json.Unmarshal(rec.Data, &season); err != nil {
+			return err
+		}
+		return l.exec(`
+			INSERT INTO seasons (id, season_number, region, start_timestamp, end_timestamp, season_name, first_period_id, last_period_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, season.ID, season.SeasonNumber, season.Region, season.StartTimestamp, season.EndTimestamp,
+			season.SeasonName, season.FirstPeriodID, season.LastPeriodID)
+
+	case dumpKindPeriod:
+		var period dumpPeriod
+		if err := json.Unmarshal(rec.Data, &period); err != nil {
+			return err
+		}
+		return l.exec(`
+			INSERT INTO periods (id, region, start_timestamp, end_timestamp, season_id)
+			VALUES (?, ?, ?, ?, ?)
+		`, period.ID, period.Region, period.StartTimestamp, period.EndTimestamp, period.SeasonID)
+
+	case dumpKindPeriodSeason:
+		var ps dumpPeriodSeason
+		if err := json.Unmarshal(rec.Data, &ps); err != nil {
+			return err
+		}
+		return l.exec(`INSERT INTO period_seasons (period_id, season_id) VALUES (?, ?)`, ps.PeriodID, ps.SeasonID)
+
+	case dumpKindPlayer:
+		var p dumpPlayer
+		if err := json.Unmarshal(rec.Data, &p); err != nil {
+			return err
+		}
+		return l.exec(`
+			INSERT INTO players (id, blizzard_character_id, name, name_lower, realm_id, is_valid, status_checked_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, p.ID, p.BlizzardCharacterID, p.Name, p.NameLower, p.RealmID, p.IsValid, p.StatusCheckedAt)
+
+	case dumpKindFingerprint:
+		var fp PlayerFingerprint
+		if err := json.Unmarshal(rec.Data, &fp); err != nil {
+			return err
+		}
+		if err := l.commit(); err != nil {
+			return err
+		}
+		return l.ds.UpsertPlayerFingerprint(fp)
+
+	case dumpKindRun:
+		var run dumpRun
+		if err := json.Unmarshal(rec.Data, &run); err != nil {
+			return err
+		}
+		return l.loadRun(run)
+
+	case dumpKindProfile:
+		var profile dumpProfile
+		if err := json.Unmarshal(rec.Data, &profile); err != nil {
+			return err
+		}
+		return l.loadProfile(profile)
+
+	case dumpKindEquipmentRetention:
+		var ret dumpEquipmentRetention
+		if err := json.Unmarshal(rec.Data, &ret); err != nil {
+			return err
+		}
+		return l.exec(`INSERT INTO equipment_retention (id, pruned_before, pruned_at) VALUES (1, ?, ?)`, ret.PrunedBefore, ret.PrunedAt)
+
+	case dumpKindRankingHistory:
+		var h dumpRankingHistory
+		if err := json.Unmarshal(rec.Data, &h); err != nil {
+			return err
+		}
+		return l.exec(`
+			INSERT INTO player_ranking_history (
+				player_id, season_id, recorded_at, combined_best_time, global_ranking, regional_ranking,
+				realm_ranking, global_class_rank, region_class_rank, realm_class_rank
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, h.PlayerID, h.SeasonID, h.RecordedAt, h.CombinedBestTime, h.GlobalRanking, h.RegionalRanking,
+			h.RealmRanking, h.GlobalClassRank, h.RegionClassRank, h.RealmClassRank)
+
+	case dumpKindFetchStatus:
+		var f dumpFetchStatus
+		if err := json.Unmarshal(rec.Data, &f); err != nil {
+			return err
+		}
+		return l.exec(`
+			INSERT INTO fetch_status (region, realm_slug, dungeon_id, period_id, status, http_status, checked_at, message, cutoff_duration)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, f.Region, f.RealmSlug, f.DungeonID, f.PeriodID, f.Status, f.HTTPStatus, f.CheckedAt, f.Message, f.CutoffDuration)
+
+	case dumpKindValidators:
+		var v dumpValidators
+		if err := json.Unmarshal(rec.Data, &v); err != nil {
+			return err
+		}
+		return l.exec(`
+			INSERT INTO leaderboard_validators (region, realm_slug, dungeon_id, period_id, etag, last_modified, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, v.Region, v.RealmSlug, v.DungeonID, v.PeriodID, v.ETag, v.LastModified, v.UpdatedAt)
+	}
+	return fmt.Errorf("unhandled record kind %q", rec.Kind)
+}
+
+// loadRun replays a run as a one-run leaderboard of the realm and period it was
+// first stored from, then restores what later passes changed: the season it was
+// assigned to, its team signature and the realms that listed it
+func (l *dumpLoader) loadRun(run dumpRun) error {
+	realm, ok := l.realms[run.RealmID]
+	if !ok {
+		return fmt.Errorf("run %d: unknown realm %d", run.ID, run.RealmID)
+	}
+	dungeon, ok := l.dungeons[run.DungeonID]
+	if !ok {
+		return fmt.Errorf("run %d: unknown dungeon %d", run.ID, run.DungeonID)
+	}
+
+	var seenAt int64
+	for _, s := range run.Sightings {
+		if s.RealmID == run.RealmID && s.FirstSeenAt != nil {
+			seenAt = *s.FirstSeenAt
+		}
+	}
+
+	tx, err := l.begin()
+	if err != nil {
+		return err
+	}
+	board := &blizzard.LeaderboardResponse{
+		LeadingGroups:        []blizzard.ChallengeRun{run.ChallengeRun},
+		Period:               run.PeriodID,
+		PeriodStartTimestamp: run.PeriodStartTimestamp,
+		PeriodEndTimestamp:   run.PeriodEndTimestamp,
+	}
+	inserted, _, err := l.ds.insertLeaderboardTx(tx, board, realm, dungeon, leaderboardInsert{
+		runIDs:  []int64{run.ID},
+		seenAt:  seenAt,
+		restore: true,
+	})
+	if err != nil {
+		return fmt.Errorf("run %d: %w", run.ID, err)
+	}
+	if inserted != 1 {
+		return fmt.Errorf("run %d was not inserted: it has no members or duplicates a loaded run", run.ID)
+	}
+
+	// the other leaderboards that listed the run only add sightings and move their
+	// fetch markers
+	for _, s := range run.PeriodSightings {
+		if s.RealmID == run.RealmID && int(s.PeriodID) == run.PeriodID {
+			continue
+		}
+		other, ok := l.realms[s.RealmID]
+		if !ok {
+			return fmt.Errorf("run %d: unknown sighting realm %d", run.ID, s.RealmID)
+		}
+		board := &blizzard.LeaderboardResponse{
+			LeadingGroups: []blizzard.ChallengeRun{run.ChallengeRun},
+			Period:        int(s.PeriodID),
+		}
+		opts := leaderboardInsert{restore: true}
+		if s.FirstSeenAt != nil {
+			opts.seenAt = *s.FirstSeenAt
+		}
+		if _, _, err := l.ds.insertLeaderboardTx(tx, board, other, dungeon, opts); err != nil {
+			return fmt.Errorf("run %d: %w", run.ID, err)
+		}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE challenge_runs SET season_id = ?, team_signature = ?
+		WHERE id = ? AND (season_id IS DISTINCT FROM ? OR team_signature IS DISTINCT FROM ?)
+	`, run.SeasonID, run.TeamSignature, run.ID, run.SeasonID, run.TeamSignature); err != nil {
+		return fmt.Errorf("run %d: restore season: %w", run.ID, err)
+	}
+
+	// sightings record when we saw the run rather than what the API returned, so
+	// they are restored as stored instead of replayed
+	if _, err := tx.Exec(`DELETE FROM run_sightings WHERE run_id = ?`, run.ID); err != nil {
+		return fmt.Errorf("run %d: restore sightings: %w", run.ID, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM run_period_sightings WHERE run_id = ?`, run.ID); err != nil {
+		return fmt.Errorf("run %d: restore sightings: %w", run.ID, err)
+	}
+	for _, s := range run.Sightings {
+		if _, err := tx.Exec(`INSERT INTO run_sightings (run_id, realm_id, first_seen_at) VALUES (?, ?, ?)`,
+			run.ID, s.RealmID, s.FirstSeenAt); err != nil {
+			return fmt.Errorf("run %d: restore sightings: %w", run.ID, err)
+		}
+	}
+	for _, s := range run.PeriodSightings {
+		if _, err := tx.Exec(`
+			INSERT INTO run_period_sightings (run_id, realm_id, dungeon_id, period_id, first_seen_at, last_seen_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, run.ID, s.RealmID, s.DungeonID, s.PeriodID, s.FirstSeenAt, s.LastSeenAt); err != nil {
+			return fmt.Errorf("run %d: restore sightings: %w", run.ID, err)
+		}
+	}
+	return l.step()
+}
+
+// loadProfile replays one profile fetch. Equipment records hold only the slots that
+// changed, so the full equipment list is rebuilt from the player's previous
+// snapshots; the insert path then stores exactly the changed slots again.
+func (l *dumpLoader) loadProfile(profile dumpProfile) error {
+	result := blizzard.PlayerProfileResult{
+		PlayerID: profile.PlayerID,
+		Summary:  profile.Summary,
+		Media:    profile.Media,
+	}
+
+	if len(profile.Equipment) > 0 {
+		loadout := l.loadouts[profile.PlayerID]
+		if loadout == nil {
+			loadout = make(map[string]blizzard.EquippedItem)
+			l.loadouts[profile.PlayerID] = loadout
+		}
+		// changed slots go first, in stored order, so their rows get the dumped ids
+		var items []blizzard.EquippedItem
+		changed := make(map[string]bool, len(profile.Equipment))
+		for _, item := range profile.Equipment {
+			changed[item.Slot.Type] = true
+			if item.Emptied {
+				delete(loadout, item.Slot.Type)
+				continue
+			}
+			loadout[item.Slot.Type] = item.EquippedItem
+			items = append(items, item.EquippedItem)
+		}
+		for slot, item := range loadout {
+			if !changed[slot] {
+				items = append(items, item)
+			}
+		}
+		result.Equipment = &blizzard.CharacterEquipmentResponse{EquippedItems: items}
+	}
+
+	tx, err := l.begin()
+	if err != nil {
+		return err
+	}
+	_, written, err := l.ds.insertPlayerProfileDataTx(tx, result, profile.Timestamp)
+	if err != nil {
+		return fmt.Errorf("player %d: %w", profile.PlayerID, err)
+	}
+	if written != len(profile.Equipment) {
+		// the replay would leave the loaded database with other equipment rows, and
+		// every later row with another id, than the dumped one
+		return fmt.Errorf("player %d snapshot %d: replay stored %d equipment rows, dump has %d",
+			profile.PlayerID, profile.Timestamp, written, len(profile.Equipment))
+	}
+	return l.step()
+}
@@ -0,0 +1,228 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"ookstats/internal/blizzard"
+	"ookstats/internal/database"
+	"ookstats/internal/dbtest"
+	"ookstats/internal/pipeline"
+)
+
+// dumpedTables are the tables a dump carries, compared row for row after a load
+var dumpedTables = []string{
+	"realms",
+	"dungeons",
+	"seasons",
+	"periods",
+	"period_seasons",
+	"players",
+	"player_fingerprints",
+	"challenge_runs",
+	"run_members",
+	"run_sightings",
+	"run_period_sightings",
+	"player_details",
+	"player_equipment",
+	"player_equipment_enchantments",
+	"player_ranking_history",
+	"fetch_status",
+	"leaderboard_validators",
+}
+
+func TestDumpLoadRoundTrip(t *testing.T) {
+	src := dbtest.OpenSQLite(t)
+	ds := database.NewDatabaseService(src)
+	f := dbtest.Seed(t, ds)
+	dbtest.Ingest(t, ds, f, 8)
+	if _, err := pipeline.ProcessAll(context.Background(), ds, pipeline.ProcessAllOptions{}); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	// a later pass with more runs moves ranks, so players get a second history row
+	dbtest.Ingest(t, ds, f, 10)
+	if _, err := pipeline.ProcessAll(context.Background(), ds, pipeline.ProcessAllOptions{}); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	insertProfiles(t, ds, src)
+	if err := ds.RecordFetchStatus("us", "atiesh", 2, 1035, "ok", 200, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.RecordFetchStatus("us", "pagle", 60, 1036, "missing", 404, "not found"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Exec(`
+		INSERT INTO leaderboard_validators (region, realm_slug, dungeon_id, period_id, etag, last_modified, updated_at)
+		VALUES ('us', 'atiesh', 2, 1035, '"abc"', NULL, 1790000000), ('us', 'pagle', 2, 1036, NULL, 'Tue, 08 Sep 2026 15:00:00 GMT', 1790000001)
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 items, then a raised item level and an emptied slot, then a swapped gem, for
+	// each of the two players; enchanted heads carry an enchant and a gem
+	for table, want := range map[string]int{"player_details": 2, "player_equipment": 12, "player_equipment_enchantments": 8} {
+		var n int
+		if err := src.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Fatalf("%s has %d rows before the dump, want %d", table, n, want)
+		}
+	}
+
+	var dump bytes.Buffer
+	dumped, err := database.Dump(src, &dump)
+	if err != nil {
+		t.Fatalf("dump: %v", err)
+	}
+	for _, kind := range []string{"run", "profile", "ranking_history", "fetch_status", "leaderboard_validators"} {
+		if dumped[kind] == 0 {
+			t.Errorf("dump has no %s records", kind)
+		}
+	}
+
+	dst := dbtest.OpenSQLite(t)
+	loaded, err := database.LoadDump(dst, &dump)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for kind, n := range dumped {
+		if loaded[kind] != n {
+			t.Errorf("%s: loaded %d records, dumped %d", kind, loaded[kind], n)
+		}
+	}
+
+	for _, table := range dumpedTables {
+		want, got := tableDigest(t, src, table), tableDigest(t, dst, table)
+		if got != want {
+			t.Errorf("%s differs after load: got %s, want %s", table, got, want)
+		}
+	}
+}
+
+// insertProfiles stores three interleaved profile fetches for two players: the second
+// raises an item level and empties a slot, the third swaps a gem
+func insertProfiles(t *testing.T, ds *database.DatabaseService, db *sql.DB) {
+	t.Helper()
+	rows, err := db.Query(`SELECT id FROM players ORDER BY id LIMIT 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var players []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		players = append(players, id)
+	}
+	rows.Close()
+	if len(players) != 2 {
+		t.Fatalf("want 2 players to attach profiles to, have %d", len(players))
+	}
+
+	intp := func(v int) *int { return &v }
+	strp := func(v string) *string { return &v }
+	gem := func(id int) blizzard.ItemEnchantment {
+		return blizzard.ItemEnchantment{
+			EnchantmentID:   intp(4000 + id),
+			EnchantmentSlot: &blizzard.EnchantSlot{ID: 2, Type: "GEM_0"},
+			DisplayString:   strp(fmt.Sprintf("+%d Mastery", id)),
+			SourceItem:      &blizzard.SourceItem{ID: 76600 + id, Name: fmt.Sprintf("Gem %d", id)},
+		}
+	}
+	item := func(slot string, id, level int, enchants ...blizzard.ItemEnchantment) blizzard.EquippedItem {
+		return blizzard.EquippedItem{
+			Item:         blizzard.ItemInfo{ID: id},
+			Slot:         blizzard.ItemSlot{Type: slot},
+			Name:         fmt.Sprintf("Item %d", id),
+			Quality:      blizzard.ItemQuality{Type: "EPIC"},
+			Level:        &blizzard.ItemLevel{Value: level},
+			Enchantments: enchants,
+		}
+	}
+	enchant := blizzard.ItemEnchantment{
+		EnchantmentID:   intp(4804),
+		EnchantmentSlot: &blizzard.EnchantSlot{ID: 0, Type: "PERMANENT"},
+		DisplayString:   strp("Enchanted: +200 Intellect"),
+		Spell:           &blizzard.SpellInfo{Spell: blizzard.SpellDetail{ID: 121193}},
+	}
+	fetches := [][]blizzard.EquippedItem{
+		{item("HEAD", 86000, 496, enchant, gem(1)), item("CHEST", 86001, 483), item("TRINKET_1", 86002, 489)},
+		{item("HEAD", 86000, 496, enchant, gem(1)), item("CHEST", 86001, 496)},
+		{item("HEAD", 86000, 496, enchant, gem(2)), item("CHEST", 86001, 496)},
+	}
+
+	at := int64(1789000000000)
+	for i, equipped := range fetches {
+		for _, id := range players {
+			at += 1000
+			result := blizzard.PlayerProfileResult{
+				PlayerID: id,
+				Summary: &blizzard.CharacterSummaryResponse{
+					ID:                id,
+					Level:             90,
+					Race:              blizzard.CharacterRace{ID: 24, Name: "Pandaren"},
+					CharacterClass:    blizzard.CharacterClass{ID: 10, Name: "Monk"},
+					ActiveSpec:        blizzard.CharacterSpec{ID: 270, Name: "Mistweaver"},
+					Gender:            blizzard.CharacterGender{Type: "FEMALE"},
+					Guild:             &blizzard.CharacterGuild{Name: "Ook"},
+					AverageItemLevel:  480 + i,
+					EquippedItemLevel: 480 + i,
+				},
+				Media:     &blizzard.CharacterMediaResponse{Assets: []blizzard.MediaAsset{{Key: "avatar", Value: fmt.Sprintf("https://render/%d.jpg", id)}}},
+				Equipment: &blizzard.CharacterEquipmentResponse{EquippedItems: equipped},
+			}
+			if _, _, err := ds.InsertPlayerProfileData(result, at); err != nil {
+				t.Fatalf("profile of player %d: %v", id, err)
+			}
+		}
+	}
+}
+
+// tableDigest returns the row count and a checksum of every row of table,
+// independent of row order
+func tableDigest(t *testing.T, db *sql.DB, table string) string {
+	t.Helper()
+	rows, err := db.Query("SELECT * FROM " + table)
+	if err != nil {
+		t.Fatalf("read %s: %v", table, err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	var lines []string
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			t.Fatalf("scan %s: %v", table, err)
+		}
+		var b strings.Builder
+		for i, v := range values {
+			if raw, ok := v.([]byte); ok {
+				v = string(raw)
+			}
+			fmt.Fprintf(&b, "%s=%v\x1f", cols[i], v)
+		}
+		lines = append(lines, b.String())
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return fmt.Sprintf("%d rows %s", len(lines), hex.EncodeToString(sum[:8]))
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// prunableEquipmentFilter matches snapshot rows superseded in their slot by a newer row
+// taken at or before the cutoff. The newest row per slot at the cutoff survives, so
+// the gear a player wore at the retention horizon stays known.
+const prunableEquipmentFilter = `
+	e.snapshot_timestamp < ?
+	AND EXISTS (
+		SELECT 1 FROM player_equipment newer
+		WHERE newer.player_id = e.player_id
+		  AND newer.slot_type = e.slot_type
+		  AND newer.snapshot_timestamp > e.snapshot_timestamp
+		  AND newer.snapshot_timestamp <= ?
+	)`
+
+// CountPrunableEquipmentSnapshots returns how many equipment rows PruneEquipmentSnapshots
+// would delete for the same cutoff
+func (ds *DatabaseService) CountPrunableEquipmentSnapshots(before int64) (int64, error) {
+	var count int64
+	err := ds.db.QueryRow(`SELECT COUNT(*) FROM player_equipment e WHERE `+prunableEquipmentFilter, before, before).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count prunable equipment: %w", err)
+	}
+	return count, nil
+}
+
+// PruneEquipmentSnapshots deletes equipment snapshots older than before (unix millis)
+// together with their enchantments, and records the cutoff so gear diffs start there
+func (ds *DatabaseService) PruneEquipmentSnapshots(before int64) (int64, error) {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		DELETE FROM player_equipment_enchantments
+		WHERE equipment_id IN (
+			SELECT e.id FROM player_equipment e WHERE `+prunableEquipmentFilter+`
+		)
+	`, before, before); err != nil {
+		return 0, fmt.Errorf("failed to prune equipment enchantments: %w", err)
+	}
+
+	res, err := tx.Exec(`
+		DELETE FROM player_equipment
+		WHERE id IN (
+			SELECT e.id FROM player_equipment e WHERE `+prunableEquipmentFilter+`
+		)
+	`, before, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune equipment snapshots: %w", err)
+	}
+	pruned, _ := res.RowsAffected()
+
+	if _, err := tx.Exec(`
+		INSERT INTO equipment_retention (id, pruned_before, pruned_at)
+		VALUES (1, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			pruned_before = CASE
+				WHEN excluded.pruned_before > equipment_retention.pruned_before THEN excluded.pruned_before
+				ELSE equipment_retention.pruned_before
+			END,
+			pruned_at = excluded.pruned_at
+	`, before, time.Now().UnixMilli()); err != nil {
+		return 0, fmt.Errorf("failed to record equipment retention: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit equipment pruning: %w", err)
+	}
+	return pruned, nil
+}
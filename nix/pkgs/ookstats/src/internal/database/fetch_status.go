@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -13,10 +14,21 @@ const (
 	fetchStatusOK      = "ok"
 	fetchStatusMissing = "missing"
 	fetchStatusError   = "error"
+	// fetchStatusUnchanged marks a leaderboard answered with 304 Not Modified
+	fetchStatusUnchanged = "unchanged"
+	// fetchStatusTruncated marks a leaderboard at blizzard.LeaderboardEntryCap; runs
+	// slower than cutoff_duration may be missing
+	fetchStatusTruncated = "truncated"
 )
 
 // RecordFetchStatus records the status of an API fetch attempt
 func (ds *DatabaseService) RecordFetchStatus(region, realmSlug string, dungeonID, periodID int, status string, httpStatus int, message string) error {
+	return ds.recordFetchStatus(region, realmSlug, dungeonID, periodID, status, httpStatus, message, 0)
+}
+
+// recordFetchStatus upserts a fetch_status row. A 304 keeps an earlier truncated
+// status and cutoff since the leaderboard behind it hasn't changed.
+func (ds *DatabaseService) recordFetchStatus(region, realmSlug string, dungeonID, periodID int, status string, httpStatus int, message string, cutoffDuration int) error {
 	if periodID == 0 {
 		return nil
 	}
@@ -27,15 +39,22 @@ func (ds *DatabaseService) RecordFetchStatus(region, realmSlug string, dungeonID
 
 	err := retryOnBusy(func() error {
 		_, execErr := ds.db.Exec(`
-			INSERT INTO fetch_status (region, realm_slug, dungeon_id, period_id, status, http_status, checked_at, message)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			INSERT INTO fetch_status (region, realm_slug, dungeon_id, period_id, status, http_status, checked_at, message, cutoff_duration)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 			ON CONFLICT(region, realm_slug, dungeon_id, period_id)
 			DO UPDATE SET
-				status = excluded.status,
+				status = CASE
+					WHEN excluded.status = 'unchanged' AND fetch_status.status = 'truncated' THEN fetch_status.status
+					ELSE excluded.status
+				END,
 				http_status = excluded.http_status,
 				checked_at = excluded.checked_at,
-				message = excluded.message
-		`, region, realmSlug, dungeonID, periodID, status, httpStatus, time.Now().Unix(), message)
+				message = excluded.message,
+				cutoff_duration = CASE
+					WHEN excluded.status = 'unchanged' THEN fetch_status.cutoff_duration
+					ELSE excluded.cutoff_duration
+				END
+		`, region, realmSlug, dungeonID, periodID, status, httpStatus, time.Now().Unix(), message, nullableInt(cutoffDuration))
 		return execErr
 	})
 	return err
@@ -46,10 +65,15 @@ func (ds *DatabaseService) recordFetchStatusResult(res blizzard.FetchResult) {
 	if periodID == 0 {
 		return
 	}
+	// cancelled fetches never reached the API; leave their previous status alone
+	if errors.Is(res.Error, context.Canceled) || errors.Is(res.Error, context.DeadlineExceeded) {
+		return
+	}
 
 	status := fetchStatusOK
 	httpStatus := http.StatusOK
 	message := ""
+	cutoff := 0
 
 	if res.Error != nil {
 		status = fetchStatusError
@@ -66,11 +90,18 @@ func (ds *DatabaseService) recordFetchStatusResult(res blizzard.FetchResult) {
 		} else {
 			httpStatus = 0
 		}
+	} else if res.Leaderboard != nil && res.Leaderboard.NotModified {
+		status = fetchStatusUnchanged
+		httpStatus = http.StatusNotModified
 	} else if res.Leaderboard == nil || len(res.Leaderboard.LeadingGroups) == 0 {
 		message = "no runs returned"
+	} else if res.Leaderboard.IsTruncated() {
+		status = fetchStatusTruncated
+		cutoff = res.Leaderboard.SlowestDuration()
+		message = fmt.Sprintf("leaderboard capped at %d runs", len(res.Leaderboard.LeadingGroups))
 	}
 
-	if err := ds.RecordFetchStatus(res.RealmInfo.Region, res.RealmInfo.Slug, res.Dungeon.ID, periodID, status, httpStatus, message); err != nil {
+	if err := ds.recordFetchStatus(res.RealmInfo.Region, res.RealmInfo.Slug, res.Dungeon.ID, periodID, status, httpStatus, message, cutoff); err != nil {
 		fmt.Printf("[WARN] failed to record fetch status for %s/%s period %d: %v\n",
 			res.RealmInfo.Slug, res.Dungeon.Slug, periodID, err)
 	}
@@ -94,3 +125,11 @@ func truncateStatusMessage(msg string) string {
 	}
 	return msg[:512]
 }
+
+// nullableInt stores 0 as NULL
+func nullableInt(v int) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,29 @@
+package database
+
+import "fmt"
+
+// Generated leaderboard pages and player files go stale when rankings or identities
+// change. The ranking pass marks what it recomputed in generation_dirty_partitions and
+// generation_dirty_players; identity changes mark the partitions of the player's runs
+// here, since leaderboard pages show member names. `generate api` regenerates what is
+// marked and clears the marks it consumed.
+
+// markPlayerRunsForGeneration marks every leaderboard partition listing a run of the
+// given players
+func markPlayerRunsForGeneration(ex execer, at int64, playerIDs ...int64) error {
+	for _, id := range playerIDs {
+		if _, err := ex.Exec(`
+			INSERT INTO generation_dirty_partitions (season_id, dungeon_id, region, realm_id, marked_at)
+			SELECT DISTINCT cr.season_id, cr.dungeon_id, r.region, rs.realm_id, ?
+			FROM run_members rm
+			JOIN challenge_runs cr ON cr.id = rm.run_id
+			JOIN run_sightings rs ON rs.run_id = cr.id
+			JOIN realms r ON r.id = rs.realm_id
+			WHERE rm.player_id = ? AND cr.season_id > 0
+			ON CONFLICT(season_id, dungeon_id, region, realm_id) DO UPDATE SET marked_at = excluded.marked_at
+		`, at, id); err != nil {
+			return fmt.Errorf("failed to mark runs of player %d for generation: %w", id, err)
+		}
+	}
+	return nil
+}
@@ -39,6 +39,8 @@ func (ds *DatabaseService) BatchProcessFetchResults(ctx context.Context, results
 	totalPlayers := 0
 	processedCount := 0
 	errorCount := 0
+	unchangedCount := 0
+	truncatedCount := 0
 
 	batch := make([]blizzard.FetchResult, 0, 10)
 	batchNumber := 0
@@ -58,6 +60,24 @@ func (ds *DatabaseService) BatchProcessFetchResults(ctx context.Context, results
 			continue
 		}
 
+		// 304: nothing to decode or insert, but the listed runs were seen again
+		if result.Leaderboard != nil && result.Leaderboard.NotModified {
+			unchangedCount++
+			if err := ds.touchUnchangedLeaderboard(result, time.Now().UnixMilli()); err != nil {
+				fmt.Printf("[WARN] Failed to refresh sightings for %s/%s: %v\n", result.RealmInfo.Name, result.Dungeon.Name, err)
+			}
+			continue
+		}
+
+		// a capped leaderboard may have dropped slower runs; fetch_status keeps the cutoff
+		if result.Leaderboard != nil && result.Leaderboard.IsTruncated() {
+			truncatedCount++
+			if verbose {
+				fmt.Printf("[WARN] Leaderboard truncated %s/%s: %d runs, slowest %dms\n",
+					result.RealmInfo.Name, result.Dungeon.Name, len(result.Leaderboard.LeadingGroups), result.Leaderboard.SlowestDuration())
+			}
+		}
+
 		batch = append(batch, result)
 
 		if len(batch) >= 10 {
@@ -80,11 +100,10 @@ func (ds *DatabaseService) BatchProcessFetchResults(ctx context.Context, results
 			fmt.Printf("[INFO] Progress: %d requests processed, %d errors\n", processedCount, errorCount)
 		}
 
-		select {
-		case <-ctx.Done():
-			fmt.Printf("[WARN] Context cancelled, stopping processing\n")
-			return totalRuns, totalPlayers, ctx.Err()
-		default:
+		if ctx.Err() != nil {
+			// keep results that already arrived; the final batch below commits them
+			fmt.Printf("[WARN] Context cancelled, committing %d finished results and stopping\n", len(batch))
+			break
 		}
 	}
 
@@ -102,10 +121,10 @@ func (ds *DatabaseService) BatchProcessFetchResults(ctx context.Context, results
 		}
 	}
 
-	fmt.Printf("\n[INFO] Final stats: %d requests processed, %d errors, %d runs, %d players\n",
-		processedCount, errorCount, totalRuns, totalPlayers)
+	fmt.Printf("\n[INFO] Final stats: %d requests processed, %d unchanged, %d truncated, %d errors, %d runs, %d players\n",
+		processedCount, unchangedCount, truncatedCount, errorCount, totalRuns, totalPlayers)
 
-	return totalRuns, totalPlayers, nil
+	return totalRuns, totalPlayers, ctx.Err()
 }
 
 // processBatch processes a batch of fetch results in a single transaction
@@ -122,8 +141,13 @@ func (ds *DatabaseService) processBatch(batch []blizzard.FetchResult) (int, int,
 	}
 
 	items := make([]batchItem, 0, len(batch))
+	validated := make([]blizzard.FetchResult, 0, len(batch))
 	for i, res := range batch {
-		if res.Leaderboard == nil || len(res.Leaderboard.LeadingGroups) == 0 {
+		if res.Leaderboard == nil {
+			continue
+		}
+		validated = append(validated, res)
+		if len(res.Leaderboard.LeadingGroups) == 0 {
 			continue
 		}
 		items = append(items, batchItem{
@@ -134,7 +158,7 @@ func (ds *DatabaseService) processBatch(batch []blizzard.FetchResult) (int, int,
 		})
 	}
 
-	if len(items) == 0 {
+	if len(validated) == 0 {
 		return 0, 0, nil
 	}
 
@@ -162,6 +186,13 @@ func (ds *DatabaseService) processBatch(batch []blizzard.FetchResult) (int, int,
 		}
 	}
 
+	// store validators alongside the data so the next sweep can send conditional requests
+	for _, res := range validated {
+		if err := upsertLeaderboardValidatorsTx(tx, res.RealmInfo.Region, res.RealmInfo.Slug, res.Dungeon.ID, parsePeriodID(res), res.Leaderboard.Validators); err != nil {
+			return 0, 0, fmt.Errorf("failed to store leaderboard validators: %w", err)
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return 0, 0, fmt.Errorf("failed to commit batch transaction: %w", err)
 	}
@@ -172,6 +203,22 @@ func (ds *DatabaseService) processBatch(batch []blizzard.FetchResult) (int, int,
 
 // insertLeaderboardDataTx inserts leaderboard data within a transaction
 func (ds *DatabaseService) insertLeaderboardDataTx(tx *sql.Tx, leaderboard *blizzard.LeaderboardResponse, realmInfo blizzard.RealmInfo, dungeon blizzard.DungeonInfo) (int, int, error) {
+	return ds.insertLeaderboardTx(tx, leaderboard, realmInfo, dungeon, leaderboardInsert{})
+}
+
+// leaderboardInsert adjusts insertLeaderboardTx for replaying runs from a dump
+type leaderboardInsert struct {
+	// runIDs are the ids to store LeadingGroups under, by index; nil lets the
+	// database assign them
+	runIDs []int64
+	// seenAt is when the leaderboard was seen; zero means now
+	seenAt int64
+	// restore writes members exactly as given: players are loaded separately and
+	// identity merges recorded in the members already happened
+	restore bool
+}
+
+func (ds *DatabaseService) insertLeaderboardTx(tx *sql.Tx, leaderboard *blizzard.LeaderboardResponse, realmInfo blizzard.RealmInfo, dungeon blizzard.DungeonInfo, opts leaderboardInsert) (int, int, error) {
 	realmID, err := ds.getRealmIDTx(tx, realmInfo.Slug, realmInfo.Region)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to get realm ID: %w", err)
@@ -181,6 +228,17 @@ func (ds *DatabaseService) insertLeaderboardDataTx(tx *sql.Tx, leaderboard *bliz
 		return 0, 0, fmt.Errorf("failed to get dungeon ID: %w", err)
 	}
 
+	// the leaderboard index doesn't carry map IDs, so learn them from the leaderboards
+	if leaderboard.Map.ID > 0 {
+		if _, err := tx.Exec(`UPDATE dungeons SET map_id = ? WHERE id = ? AND map_id IS NULL`, leaderboard.Map.ID, dungeonID); err != nil {
+			return 0, 0, fmt.Errorf("failed to record dungeon map ID: %w", err)
+		}
+	}
+
+	if err := ds.recordPeriodTx(tx, realmInfo.Region, leaderboard.Period, leaderboard.PeriodStartTimestamp, leaderboard.PeriodEndTimestamp); err != nil {
+		return 0, 0, fmt.Errorf("failed to record period boundaries: %w", err)
+	}
+
 	// compute newest completed_timestamp in this leaderboard (for marker update)
 	maxCT := int64(0)
 	for _, run := range leaderboard.LeadingGroups {
@@ -191,8 +249,12 @@ func (ds *DatabaseService) insertLeaderboardDataTx(tx *sql.Tx, leaderboard *bliz
 
 	runsInserted := 0
 	playersInserted := 0
+	seenAt := opts.seenAt
+	if seenAt == 0 {
+		seenAt = time.Now().UnixMilli()
+	}
 
-	for _, run := range leaderboard.LeadingGroups {
+	for i, run := range leaderboard.LeadingGroups {
 		var playerIDs []int
 		for _, member := range run.Members {
 			if id, ok := member.GetPlayerID(); ok {
@@ -217,13 +279,8 @@ func (ds *DatabaseService) insertLeaderboardDataTx(tx *sql.Tx, leaderboard *bliz
 			}
 		}
 
-		runQuery := `
-			INSERT OR IGNORE INTO challenge_runs
-			(duration, completed_timestamp, keystone_level, dungeon_id, realm_id, period_id, period_start_timestamp, period_end_timestamp, team_signature, season_id)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`
-
-		result, err := tx.Exec(runQuery,
+		runColumns := "duration, completed_timestamp, keystone_level, dungeon_id, realm_id, period_id, period_start_timestamp, period_end_timestamp, team_signature, season_id"
+		runArgs := []any{
 			run.Duration,
 			run.CompletedTimestamp,
 			run.KeystoneLevel,
@@ -234,23 +291,44 @@ func (ds *DatabaseService) insertLeaderboardDataTx(tx *sql.Tx, leaderboard *bliz
 			leaderboard.PeriodEndTimestamp,
 			teamSignature,
 			runSeasonID,
-		)
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to insert run: %w", err)
 		}
-
-		rowsAffected, err := result.RowsAffected()
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to check rows affected: %w", err)
+		if opts.runIDs != nil {
+			runColumns = "id, " + runColumns
+			runArgs = append([]any{opts.runIDs[i]}, runArgs...)
 		}
+		runQuery := `
+			INSERT INTO challenge_runs (` + runColumns + `)
+			VALUES (` + strings.TrimSuffix(strings.Repeat("?, ", len(runArgs)), ", ") + `)
+			ON CONFLICT DO NOTHING
+			RETURNING id
+		`
 
-		if rowsAffected == 0 {
+		var runID int64
+		err := tx.QueryRow(runQuery, runArgs...).Scan(&runID)
+		if err == sql.ErrNoRows {
+			// run already stored, possibly from another realm's leaderboard; only
+			// note that this realm has seen it too
+			existingID, err := getCanonicalRunIDTx(tx, run.CompletedTimestamp, dungeonID, run.Duration, teamSignature)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to resolve existing run: %w", err)
+			}
+			newRealm, err := recordRunSightingTx(tx, existingID, realmID, dungeonID, leaderboard.Period, seenAt)
+			if err != nil {
+				return 0, 0, err
+			}
+			if newRealm {
+				// the run now also counts towards this realm's pool rankings
+				if err := markRunPartitionsDirtyTx(tx, existingID, realmID, seenAt); err != nil {
+					return 0, 0, err
+				}
+			}
 			continue
 		}
-
-		runID, err := result.LastInsertId()
 		if err != nil {
-			return 0, 0, fmt.Errorf("failed to get run ID: %w", err)
+			return 0, 0, fmt.Errorf("failed to insert run: %w", err)
+		}
+		if _, err := recordRunSightingTx(tx, runID, realmID, dungeonID, leaderboard.Period, seenAt); err != nil {
+			return 0, 0, err
 		}
 
 		runsInserted++
@@ -264,6 +342,13 @@ func (ds *DatabaseService) insertLeaderboardDataTx(tx *sql.Tx, leaderboard *bliz
 				continue
 			}
 
+			if opts.restore {
+				if err := insertRunMemberTx(tx, runID, playerID, member); err != nil {
+					return 0, 0, err
+				}
+				continue
+			}
+
 			var playerRealmID int
 			if hasRealmSlug {
 				playerRealmID, err = ds.getRealmIDTx(tx, playerRealmSlug, realmInfo.Region)
@@ -271,7 +356,7 @@ func (ds *DatabaseService) insertLeaderboardDataTx(tx *sql.Tx, leaderboard *bliz
 					return 0, 0, fmt.Errorf("failed to resolve player realm: %w", err)
 				}
 				if playerRealmID == 0 {
-					if _, err := tx.Exec(`INSERT OR IGNORE INTO realms (slug, name, region, connected_realm_id, parent_realm_slug) VALUES (?, ?, ?, NULL, ?)`,
+					if _, err := tx.Exec(`INSERT INTO realms (slug, name, region, connected_realm_id, parent_realm_slug) VALUES (?, ?, ?, NULL, ?) ON CONFLICT DO NOTHING`,
 						playerRealmSlug, playerRealmSlug, realmInfo.Region, ""); err != nil {
 						return 0, 0, fmt.Errorf("failed to create placeholder realm: %w", err)
 					}
@@ -304,6 +389,9 @@ func (ds *DatabaseService) insertLeaderboardDataTx(tx *sql.Tx, leaderboard *bliz
 				if err != nil {
 					return 0, 0, fmt.Errorf("failed to invalidate old player: %w", err)
 				}
+				if err := markPlayersDirty(tx, seenAt, existingPlayerID, int64(playerID)); err != nil {
+					return 0, 0, err
+				}
 			}
 
 			playerQuery := `
@@ -313,7 +401,7 @@ func (ds *DatabaseService) insertLeaderboardDataTx(tx *sql.Tx, leaderboard *bliz
 				  name = excluded.name,
 				  name_lower = lower(excluded.name),
 				  realm_id = excluded.realm_id
-				WHERE excluded.name != name OR excluded.realm_id != realm_id
+				WHERE excluded.name != players.name OR excluded.realm_id != players.realm_id
 			`
 			playerResult, err := tx.Exec(playerQuery, playerID, playerName, playerName, playerRealmID)
 			if err != nil {
@@ -326,26 +414,22 @@ func (ds *DatabaseService) insertLeaderboardDataTx(tx *sql.Tx, leaderboard *bliz
 			}
 			if rowsAffected > 0 {
 				playersInserted++
+				// new or renamed/moved player; profiles copy name and realm
+				if err := markPlayersDirty(tx, seenAt, int64(playerID)); err != nil {
+					return 0, 0, err
+				}
 			}
 
-			specID, _ := member.GetSpecID()
-			faction, _ := member.GetFaction()
-
-			memberQuery := `INSERT INTO run_members (run_id, player_id, spec_id, faction) VALUES (?, ?, ?, ?)`
-
-			var specPtr *int
-			var factionPtr *string
-			if specID > 0 {
-				specPtr = &specID
-			}
-			if faction != "" {
-				factionPtr = &faction
+			if err := insertRunMemberTx(tx, runID, effectivePlayerID, member); err != nil {
+				return 0, 0, err
 			}
+		}
 
-			_, err = tx.Exec(memberQuery, runID, effectivePlayerID, specPtr, factionPtr)
-			if err != nil {
-				return 0, 0, fmt.Errorf("failed to insert run member: %w", err)
-			}
+		if err := markRunPartitionsDirtyTx(tx, runID, realmID, seenAt); err != nil {
+			return 0, 0, err
+		}
+		if err := markRunPlayersDirtyTx(tx, runID, seenAt); err != nil {
+			return 0, 0, err
 		}
 	}
 
@@ -354,7 +438,10 @@ func (ds *DatabaseService) insertLeaderboardDataTx(tx *sql.Tx, leaderboard *bliz
 		if _, err := tx.Exec(`INSERT INTO api_fetch_markers (realm_slug, dungeon_id, period_id, last_completed_ts)
                                VALUES (?, ?, ?, ?)
                                ON CONFLICT(realm_slug, dungeon_id, period_id)
-                               DO UPDATE SET last_completed_ts = MAX(last_completed_ts, excluded.last_completed_ts)`,
+                               DO UPDATE SET last_completed_ts = CASE
+                                   WHEN excluded.last_completed_ts > api_fetch_markers.last_completed_ts THEN excluded.last_completed_ts
+                                   ELSE api_fetch_markers.last_completed_ts
+                               END`,
 			realmInfo.Slug, dungeonID, leaderboard.Period, maxCT); err != nil {
 			return 0, 0, fmt.Errorf("failed to update fetch marker: %w", err)
 		}
@@ -362,3 +449,63 @@ func (ds *DatabaseService) insertLeaderboardDataTx(tx *sql.Tx, leaderboard *bliz
 
 	return runsInserted, playersInserted, nil
 }
+
+// insertRunMemberTx stores one member of a run under playerID
+func insertRunMemberTx(tx *sql.Tx, runID int64, playerID int, member blizzard.Member) error {
+	specID, _ := member.GetSpecID()
+	faction, _ := member.GetFaction()
+
+	var specPtr *int
+	var factionPtr *string
+	if specID > 0 {
+		specPtr = &specID
+	}
+	if faction != "" {
+		factionPtr = &faction
+	}
+
+	if _, err := tx.Exec(`INSERT INTO run_members (run_id, player_id, spec_id, faction) VALUES (?, ?, ?, ?)`,
+		runID, playerID, specPtr, factionPtr); err != nil {
+		return fmt.Errorf("failed to insert run member: %w", err)
+	}
+	return nil
+}
+
+// getCanonicalRunIDTx looks up a stored run by its realm-independent identity
+func getCanonicalRunIDTx(tx *sql.Tx, completedTimestamp int64, dungeonID, duration int, teamSignature string) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`
+		SELECT id FROM challenge_runs
+		WHERE completed_timestamp = ? AND dungeon_id = ? AND duration = ? AND team_signature = ?
+	`, completedTimestamp, dungeonID, duration, teamSignature).Scan(&id)
+	return id, err
+}
+
+// recordRunSightingTx links a run to a realm leaderboard it appeared on and extends
+// its sighting history for the leaderboard's period. It reports whether the realm
+// had not listed the run before.
+func recordRunSightingTx(tx *sql.Tx, runID int64, realmID, dungeonID, periodID int, seenAt int64) (bool, error) {
+	res, err := tx.Exec(`
+		INSERT INTO run_sightings (run_id, realm_id, first_seen_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`, runID, realmID, seenAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to record run sighting: %w", err)
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record run sighting: %w", err)
+	}
+	if periodID <= 0 {
+		return inserted > 0, nil
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO run_period_sightings (run_id, realm_id, dungeon_id, period_id, first_seen_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(run_id, realm_id, period_id) DO UPDATE SET last_seen_at = excluded.last_seen_at
+	`, runID, realmID, dungeonID, periodID, seenAt, seenAt); err != nil {
+		return false, fmt.Errorf("failed to record run period sighting: %w", err)
+	}
+	return inserted > 0, nil
+}
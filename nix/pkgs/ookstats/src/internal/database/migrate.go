@@ -0,0 +1,298 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Migration is one numbered schema change. Up statements run in order inside a
+// single transaction together with the schema_migrations bookkeeping; Apply, when
+// set, runs after them for changes that must inspect the existing schema first.
+// A migration without Down statements cannot be rolled back.
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Apply   func(tx *sql.Tx) error
+	Down    []string
+}
+
+// Checksum fingerprints the migration's definition so edits to an already-applied
+// migration are detected instead of silently diverging from deployed databases.
+func (m Migration) Checksum() string {
+	h := sha256.New()
+	h.Write([]byte(strconv.Itoa(m.Version)))
+	h.Write([]byte{0})
+	h.Write([]byte(m.Name))
+	for _, s := range m.Up {
+		h.Write([]byte{0, 'u'})
+		h.Write([]byte(s))
+	}
+	for _, s := range m.Down {
+		h.Write([]byte{0, 'd'})
+		h.Write([]byte(s))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Reversible reports whether the migration can be rolled back.
+func (m Migration) Reversible() bool {
+	return len(m.Down) > 0
+}
+
+// MigrationStatus describes a known migration and whether it has been applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt int64
+	// Modified is set when the stored checksum no longer matches the definition
+	Modified bool
+}
+
+// appliedMigration is a schema_migrations row
+type appliedMigration struct {
+	version   int
+	name      string
+	checksum  string
+	appliedAt int64
+}
+
+// Migrations returns the ordered list of schema migrations for a backend.
+// Both lists carry the same versions and names; only the DDL differs.
+func Migrations(d Dialect) []Migration {
+	if d == Postgres {
+		return postgresMigrations
+	}
+	return schemaMigrations
+}
+
+// LatestSchemaVersion returns the highest migration version known to this build.
+func LatestSchemaVersion() int {
+	if len(schemaMigrations) == 0 {
+		return 0
+	}
+	return schemaMigrations[len(schemaMigrations)-1].Version
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func loadAppliedMigrations(db *sql.DB) (map[int]appliedMigration, error) {
+	rows, err := db.Query(`SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[a.version] = a
+	}
+	return applied, rows.Err()
+}
+
+// verifyApplied fails when the database records a migration this build does not
+// know about or one whose definition has changed since it was applied.
+func verifyApplied(migrations []Migration, applied map[int]appliedMigration) error {
+	known := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+	for v, a := range applied {
+		m, ok := known[v]
+		if !ok {
+			return fmt.Errorf("database has migration %d (%s) which this build does not know; upgrade ookstats", v, a.name)
+		}
+		if a.checksum != m.Checksum() {
+			return fmt.Errorf("checksum mismatch for applied migration %d (%s): the migration was edited after it was applied", v, m.Name)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 for an
+// unmanaged database.
+func SchemaVersion(db *sql.DB) (int, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return 0, err
+	}
+	var v sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&v); err != nil {
+		return 0, fmt.Errorf("query schema version: %w", err)
+	}
+	return int(v.Int64), nil
+}
+
+// GetMigrationStatus lists every known migration with its applied state.
+func GetMigrationStatus(db *sql.DB) ([]MigrationStatus, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+	applied, err := loadAppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := Migrations(DialectOf(db))
+	out := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = a.appliedAt
+			st.Modified = a.checksum != m.Checksum()
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// Migrate applies pending migrations up to and including target. A target of 0
+// or less means the latest known version. Each migration commits on its own, so
+// a failure leaves the database at the last successful version.
+func Migrate(db *sql.DB, target int) (int, error) {
+	if target <= 0 {
+		target = LatestSchemaVersion()
+	}
+	if target > LatestSchemaVersion() {
+		return 0, fmt.Errorf("unknown schema version %d (latest is %d)", target, LatestSchemaVersion())
+	}
+	if err := ensureMigrationsTable(db); err != nil {
+		return 0, err
+	}
+	applied, err := loadAppliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	migrations := Migrations(DialectOf(db))
+	if err := verifyApplied(migrations, applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if m.Version > target {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		fmt.Printf("[MIGRATE] Applying %03d %s...\n", m.Version, m.Name)
+		if err := applyMigration(db, m); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func applyMigration(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin migration %d: %w", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range m.Up {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("migration %d (%s): %w\nQuery: %s", m.Version, m.Name, err, stmt)
+		}
+	}
+	if m.Apply != nil {
+		if err := m.Apply(tx); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+		m.Version, m.Name, m.Checksum(), time.Now().UnixMilli()); err != nil {
+		return fmt.Errorf("record migration %d: %w", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit migration %d: %w", m.Version, err)
+	}
+	return nil
+}
+
+// Rollback reverts applied migrations newer than target, newest first. It stops
+// before touching anything if one of them is irreversible.
+func Rollback(db *sql.DB, target int) (int, error) {
+	if target < 0 {
+		return 0, fmt.Errorf("invalid rollback target %d", target)
+	}
+	if err := ensureMigrationsTable(db); err != nil {
+		return 0, err
+	}
+	applied, err := loadAppliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	migrations := Migrations(DialectOf(db))
+	if err := verifyApplied(migrations, applied); err != nil {
+		return 0, err
+	}
+
+	var pending []Migration
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= target {
+			break
+		}
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if !m.Reversible() {
+			return 0, fmt.Errorf("migration %d (%s) is irreversible; cannot roll back below version %d", m.Version, m.Name, m.Version)
+		}
+		pending = append(pending, m)
+	}
+
+	count := 0
+	for _, m := range pending {
+		fmt.Printf("[MIGRATE] Reverting %03d %s...\n", m.Version, m.Name)
+		if err := revertMigration(db, m); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func revertMigration(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin rollback %d: %w", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range m.Down {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("rollback %d (%s): %w\nQuery: %s", m.Version, m.Name, err, stmt)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version); err != nil {
+		return fmt.Errorf("unrecord migration %d: %w", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit rollback %d: %w", m.Version, err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package database_test
+
+import (
+	"strings"
+	"testing"
+
+	"ookstats/internal/database"
+	"ookstats/internal/dbtest"
+)
+
+func TestRollbackStopsAtRunSightings(t *testing.T) {
+	db := dbtest.OpenSQLite(t)
+
+	if _, err := database.Rollback(db, 6); err != nil {
+		t.Fatalf("rollback to 6: %v", err)
+	}
+	_, err := database.Rollback(db, 5)
+	if err == nil || !strings.Contains(err.Error(), "irreversible") {
+		t.Fatalf("rollback below run_sightings: err = %v, want irreversible", err)
+	}
+	if v, _ := database.SchemaVersion(db); v != 6 {
+		t.Errorf("schema version = %d after refused rollback, want 6", v)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM run_sightings`).Scan(&n); err != nil {
+		t.Errorf("run_sightings gone after refused rollback: %v", err)
+	}
+}
@@ -6,22 +6,43 @@ import (
 	"strings"
 )
 
+// upgradeLegacyTables reshapes tables created by pre-migration builds. Every step
+// inspects the live schema through PRAGMAs, so it is a no-op on fresh databases.
+func upgradeLegacyTables(tx *sql.Tx) error {
+	steps := []func(*sql.Tx) error{
+		migrateRealmsCompositeSlug,
+		migrateSeasonsAddRegion,
+		migratePlayersIdentityColumns,
+		migrateChallengeRunsSeason,
+		migratePlayerRankingsPrimaryKey,
+	}
+	for _, step := range steps {
+		if err := step(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // migrateRealmsCompositeSlug upgrades the realms table from slug-unique to (region,slug)-unique if necessary.
-func migrateRealmsCompositeSlug(db *sql.DB) error {
-	// Inspect table SQL
-	var createSQL string
-	_ = db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='realms'`).Scan(&createSQL)
-	// If table already created without UNIQUE on slug, nothing to do
-	if !strings.Contains(strings.ToLower(createSQL), "slug text unique") {
+func migrateRealmsCompositeSlug(tx *sql.Tx) error {
+	slugUnique, err := hasUniqueIndexOn(tx, "realms", "slug")
+	if err != nil {
+		return err
+	}
+	if !slugUnique {
 		return nil
 	}
-
-	fmt.Printf("[MIGRATE] Upgrading realms table to composite (region,slug) uniqueness...\n")
-	tx, err := db.Begin()
+	hasParent, err := columnExists(tx, "realms", "parent_realm_slug")
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
+	parentExpr := "NULL"
+	if hasParent {
+		parentExpr = "parent_realm_slug"
+	}
+
+	fmt.Printf("[MIGRATE] Upgrading realms table to composite (region,slug) uniqueness...\n")
 
 	// Create new table without UNIQUE on slug
 	if _, err := tx.Exec(`
@@ -39,9 +60,7 @@ func migrateRealmsCompositeSlug(db *sql.DB) error {
 
 	// Copy data
 	if _, err := tx.Exec(`INSERT INTO realms_new (id, slug, name, region, connected_realm_id, parent_realm_slug)
-                          SELECT id, slug, name, region, connected_realm_id,
-                                 CASE WHEN EXISTS(SELECT 1 FROM sqlite_master WHERE type='table' AND name='realms' AND sql LIKE '%parent_realm_slug%')
-                                      THEN parent_realm_slug ELSE NULL END
+                          SELECT id, slug, name, region, connected_realm_id, ` + parentExpr + `
                           FROM realms`); err != nil {
 		return fmt.Errorf("copy realms: %w", err)
 	}
@@ -64,30 +83,21 @@ func migrateRealmsCompositeSlug(db *sql.DB) error {
 		return fmt.Errorf("create composite unique index: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return err
-	}
 	fmt.Printf("[OK] Realms table migrated\n")
 	return nil
 }
 
 // migrateSeasonsAddRegion adds region column to seasons table if it doesn't exist
-func migrateSeasonsAddRegion(db *sql.DB) error {
-	// Check if region column already exists
-	var createSQL string
-	_ = db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='seasons'`).Scan(&createSQL)
-
-	// If table already has region column, nothing to do
-	if strings.Contains(strings.ToLower(createSQL), "region text") {
+func migrateSeasonsAddRegion(tx *sql.Tx) error {
+	hasRegion, err := columnExists(tx, "seasons", "region")
+	if err != nil {
+		return err
+	}
+	if hasRegion {
 		return nil
 	}
 
 	fmt.Printf("[MIGRATE] Adding region column to seasons table...\n")
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
 
 	// Create new table with region column
 	if _, err := tx.Exec(`
@@ -128,60 +138,97 @@ func migrateSeasonsAddRegion(db *sql.DB) error {
 		return fmt.Errorf("drop old: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return err
-	}
 	fmt.Printf("[OK] Seasons table migrated - added region column\n")
 	return nil
 }
 
 // migratePlayersIdentityColumns ensures identity + status metadata columns exist on players.
-func migratePlayersIdentityColumns(db *sql.DB) error {
-	hasBlizzardID, err := columnExists(db, "players", "blizzard_character_id")
+func migratePlayersIdentityColumns(tx *sql.Tx) error {
+	hasBlizzardID, err := columnExists(tx, "players", "blizzard_character_id")
 	if err != nil {
 		return err
 	}
 	if !hasBlizzardID {
-		if _, err := db.Exec(`ALTER TABLE players ADD COLUMN blizzard_character_id INTEGER`); err != nil {
+		if _, err := tx.Exec(`ALTER TABLE players ADD COLUMN blizzard_character_id INTEGER`); err != nil {
 			return fmt.Errorf("add blizzard_character_id: %w", err)
 		}
-		if _, err := db.Exec(`UPDATE players SET blizzard_character_id = id WHERE blizzard_character_id IS NULL`); err != nil {
+		if _, err := tx.Exec(`UPDATE players SET blizzard_character_id = id WHERE blizzard_character_id IS NULL`); err != nil {
 			return fmt.Errorf("backfill blizzard_character_id: %w", err)
 		}
 	}
 
-	hasIsValid, err := columnExists(db, "players", "is_valid")
+	hasIsValid, err := columnExists(tx, "players", "is_valid")
 	if err != nil {
 		return err
 	}
 	if !hasIsValid {
-		if _, err := db.Exec(`ALTER TABLE players ADD COLUMN is_valid INTEGER DEFAULT 1`); err != nil {
+		if _, err := tx.Exec(`ALTER TABLE players ADD COLUMN is_valid INTEGER DEFAULT 1`); err != nil {
 			return fmt.Errorf("add is_valid: %w", err)
 		}
-		if _, err := db.Exec(`UPDATE players SET is_valid = 1 WHERE is_valid IS NULL`); err != nil {
+		if _, err := tx.Exec(`UPDATE players SET is_valid = 1 WHERE is_valid IS NULL`); err != nil {
 			return fmt.Errorf("backfill is_valid: %w", err)
 		}
 	}
 
-	hasStatusChecked, err := columnExists(db, "players", "status_checked_at")
+	hasStatusChecked, err := columnExists(tx, "players", "status_checked_at")
 	if err != nil {
 		return err
 	}
 	if !hasStatusChecked {
-		if _, err := db.Exec(`ALTER TABLE players ADD COLUMN status_checked_at INTEGER`); err != nil {
+		if _, err := tx.Exec(`ALTER TABLE players ADD COLUMN status_checked_at INTEGER`); err != nil {
 			return fmt.Errorf("add status_checked_at: %w", err)
 		}
 	}
 	return nil
 }
 
-func columnExists(db *sql.DB, table, column string) (bool, error) {
-	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+// migrateChallengeRunsSeason adds season_id to challenge_runs tables that predate seasons.
+func migrateChallengeRunsSeason(tx *sql.Tx) error {
+	hasSeason, err := columnExists(tx, "challenge_runs", "season_id")
+	if err != nil {
+		return err
+	}
+	if hasSeason {
+		return nil
+	}
+	if _, err := tx.Exec(`ALTER TABLE challenge_runs ADD COLUMN season_id INTEGER`); err != nil {
+		return fmt.Errorf("add challenge_runs.season_id: %w", err)
+	}
+	return nil
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func columnExists(q queryer, table, column string) (bool, error) {
+	cols, err := tableColumns(q, table)
 	if err != nil {
 		return false, err
 	}
+	for _, c := range cols {
+		if strings.EqualFold(c.name, column) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type columnInfo struct {
+	name string
+	// pk is the 1-based position within the primary key, 0 when not part of it
+	pk int
+}
+
+func tableColumns(q queryer, table string) ([]columnInfo, error) {
+	rows, err := q.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
+	var cols []columnInfo
 	for rows.Next() {
 		var (
 			cid        int
@@ -192,38 +239,100 @@ func columnExists(db *sql.DB, table, column string) (bool, error) {
 			primaryKey int
 		)
 		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &primaryKey); err != nil {
+			return nil, err
+		}
+		cols = append(cols, columnInfo{name: name, pk: primaryKey})
+	}
+	return cols, rows.Err()
+}
+
+// hasUniqueIndexOn reports whether table has a unique index (including inline
+// UNIQUE constraints) covering exactly the given columns.
+func hasUniqueIndexOn(q queryer, table string, columns ...string) (bool, error) {
+	rows, err := q.Query(fmt.Sprintf(`PRAGMA index_list(%s)`, table))
+	if err != nil {
+		return false, err
+	}
+	var uniqueIndexes []string
+	for rows.Next() {
+		var (
+			seq     int
+			name    string
+			unique  int
+			origin  string
+			partial int
+		)
+		if err := rows.Scan(&seq, &name, &unique, &origin, &partial); err != nil {
+			rows.Close()
+			return false, err
+		}
+		if unique == 1 && partial == 0 {
+			uniqueIndexes = append(uniqueIndexes, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return false, err
+	}
+	rows.Close()
+
+	for _, idx := range uniqueIndexes {
+		cols, err := indexColumns(q, idx)
+		if err != nil {
 			return false, err
 		}
-		if strings.EqualFold(name, column) {
+		if len(cols) != len(columns) {
+			continue
+		}
+		match := true
+		for i := range cols {
+			if !strings.EqualFold(cols[i], columns[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
 			return true, nil
 		}
 	}
-	return false, rows.Err()
+	return false, nil
 }
 
-// migratePlayerRankingsPrimaryKey adds PRIMARY KEY constraint to player_rankings table if missing
-func migratePlayerRankingsPrimaryKey(db *sql.DB) error {
-	// Check if table already has PRIMARY KEY by inspecting schema
-	var createSQL string
-	err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='player_rankings'`).Scan(&createSQL)
+func indexColumns(q queryer, index string) ([]string, error) {
+	rows, err := q.Query(fmt.Sprintf(`PRAGMA index_info(%q)`, index))
 	if err != nil {
-		// Table doesn't exist yet, skip migration
-		return nil
+		return nil, err
 	}
+	defer rows.Close()
 
-	// If PRIMARY KEY already exists, skip migration
-	if strings.Contains(strings.ToUpper(createSQL), "PRIMARY KEY") {
-		return nil
+	var cols []string
+	for rows.Next() {
+		var (
+			seqno int
+			cid   int
+			name  sql.NullString
+		)
+		if err := rows.Scan(&seqno, &cid, &name); err != nil {
+			return nil, err
+		}
+		cols = append(cols, name.String)
 	}
+	return cols, rows.Err()
+}
 
-	fmt.Printf("Migrating player_rankings table to add PRIMARY KEY constraint...\n")
-
-	// Begin transaction for safe migration
-	tx, err := db.Begin()
+// migratePlayerRankingsPrimaryKey adds PRIMARY KEY constraint to player_rankings table if missing
+func migratePlayerRankingsPrimaryKey(tx *sql.Tx) error {
+	cols, err := tableColumns(tx, "player_rankings")
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	for _, c := range cols {
+		if c.pk > 0 {
+			return nil
+		}
+	}
+
+	fmt.Printf("[MIGRATE] Adding PRIMARY KEY constraint to player_rankings table...\n")
 
 	// Create new table with PRIMARY KEY
 	if _, err := tx.Exec(`
@@ -270,10 +379,37 @@ func migratePlayerRankingsPrimaryKey(db *sql.DB) error {
 		return fmt.Errorf("rename new table: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit transaction: %w", err)
+	fmt.Printf("[OK] player_rankings table migrated - added PRIMARY KEY and removed duplicates\n")
+	return nil
+}
+
+// addFetchStatusCutoff adds the cutoff_duration column used for truncated leaderboards.
+// Databases initialised just before versioned migrations may already have it.
+func addFetchStatusCutoff(tx *sql.Tx) error {
+	hasCutoff, err := columnExists(tx, "fetch_status", "cutoff_duration")
+	if err != nil {
+		return err
 	}
+	if hasCutoff {
+		return nil
+	}
+	if _, err := tx.Exec(`ALTER TABLE fetch_status ADD COLUMN cutoff_duration INTEGER`); err != nil {
+		return fmt.Errorf("add fetch_status.cutoff_duration: %w", err)
+	}
+	return nil
+}
 
-	fmt.Printf("[OK] player_rankings table migrated - added PRIMARY KEY and removed duplicates\n")
+// addEquipmentItemLevel adds the per-item level reported by the equipment endpoint.
+func addEquipmentItemLevel(tx *sql.Tx) error {
+	hasLevel, err := columnExists(tx, "player_equipment", "item_level")
+	if err != nil {
+		return err
+	}
+	if hasLevel {
+		return nil
+	}
+	if _, err := tx.Exec(`ALTER TABLE player_equipment ADD COLUMN item_level INTEGER`); err != nil {
+		return fmt.Errorf("add player_equipment.item_level: %w", err)
+	}
 	return nil
 }
@@ -32,17 +32,17 @@ type Player struct {
 
 // PlayerFingerprint represents a fingerprint record
 type PlayerFingerprint struct {
-	PlayerID                int64
-	FingerprintHash         string
-	ClassID                 int
-	Level85Timestamp        int64
-	Level90Timestamp        int64
-	EarliestHeroicTimestamp int64
-	LastSeenName            string
-	LastSeenRealmSlug       string
-	LastSeenTimestamp       int64
-	FirstRunTimestamp       int64
-	CreatedAt               int64
+	PlayerID                int64  `json:"player_id"`
+	FingerprintHash         string `json:"fingerprint_hash"`
+	ClassID                 int    `json:"class_id"`
+	Level85Timestamp        int64  `json:"level85_timestamp"`
+	Level90Timestamp        int64  `json:"level90_timestamp"`
+	EarliestHeroicTimestamp int64  `json:"earliest_heroic_timestamp"`
+	LastSeenName            string `json:"last_seen_name"`
+	LastSeenRealmSlug       string `json:"last_seen_realm_slug"`
+	LastSeenTimestamp       int64  `json:"last_seen_timestamp"`
+	FirstRunTimestamp       int64  `json:"first_run_timestamp"`
+	CreatedAt               int64  `json:"created_at"`
 }
 
 // PlayerFingerprintCandidate holds upstream data required to compute a fingerprint
@@ -106,7 +106,8 @@ type APIFetchMetadata struct {
 
 // DatabaseService handles database operations for challenge mode data
 type DatabaseService struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
 }
 
 // Verbose logging toggle for database batch processing
@@ -117,7 +118,7 @@ func SetVerbose(v bool) { verbose = v }
 
 // NewDatabaseService creates a new database service instance
 func NewDatabaseService(db *sql.DB) *DatabaseService {
-	return &DatabaseService{db: db}
+	return &DatabaseService{db: db, dialect: DialectOf(db)}
 }
 
 // GetLastFetchInfo retrieves information about the last successful fetch
@@ -137,7 +138,7 @@ func (ds *DatabaseService) GetLastFetchInfo(fetchType string) (*int64, int, int,
 	}
 	if err != nil {
 		// If using embedded replica and schema isn't warmed yet, try to warm schema and retry once
-		if strings.Contains(err.Error(), "no such table") {
+		if ds.dialect == SQLite && strings.Contains(err.Error(), "no such table") {
 			var _tmp int
 			_ = ds.db.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&_tmp)
 			err2 := ds.db.QueryRow(query, fetchType).Scan(&lastFetch, &runsFetched, &playersFetched)
@@ -160,9 +161,14 @@ func (ds *DatabaseService) UpdateFetchMetadata(fetchType string, runsFetched, pl
 	currentTimestamp := time.Now().UnixMilli()
 
 	query := `
-		INSERT OR REPLACE INTO api_fetch_metadata
+		INSERT INTO api_fetch_metadata
 		(fetch_type, last_fetch_timestamp, last_successful_fetch, runs_fetched, players_fetched)
 		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(fetch_type) DO UPDATE SET
+			last_fetch_timestamp = excluded.last_fetch_timestamp,
+			last_successful_fetch = excluded.last_successful_fetch,
+			runs_fetched = excluded.runs_fetched,
+			players_fetched = excluded.players_fetched
 	`
 
 	_, err := ds.db.Exec(query, fetchType, currentTimestamp, currentTimestamp, runsFetched, playersFetched)
@@ -307,6 +313,13 @@ func (ds *DatabaseService) UpdatePlayerIdentity(playerID int, name, region, real
 	if realmID == 0 {
 		return sql.ErrNoRows
 	}
-	_, err = ds.db.Exec(`UPDATE players SET name = ?, name_lower = lower(?), realm_id = ? WHERE id = ?`, name, name, realmID, playerID)
-	return err
+	res, err := ds.db.Exec(`UPDATE players SET name = ?, name_lower = lower(?), realm_id = ? WHERE id = ? AND (name IS DISTINCT FROM ? OR realm_id IS DISTINCT FROM ?)`,
+		name, name, realmID, playerID, name, realmID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	return markPlayersDirty(ds.db, time.Now().UnixMilli(), int64(playerID))
 }
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// UpsertPeriod records the exact boundaries of a keystone period in a region
+func (ds *DatabaseService) UpsertPeriod(region string, periodID int, startTimestamp, endTimestamp int64) error {
+	query := `
+		INSERT INTO periods (id, region, start_timestamp, end_timestamp)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(id, region) DO UPDATE SET
+			start_timestamp = excluded.start_timestamp,
+			end_timestamp = excluded.end_timestamp
+	`
+	_, err := ds.db.Exec(query, periodID, region, startTimestamp, endTimestamp)
+	return err
+}
+
+// recordPeriodTx stores period boundaries seen in a leaderboard response. Synced
+// values from the period API take precedence, so existing rows are left alone.
+func (ds *DatabaseService) recordPeriodTx(tx *sql.Tx, region string, periodID int, startTimestamp, endTimestamp int64) error {
+	if periodID <= 0 || startTimestamp <= 0 || endTimestamp <= 0 {
+		return nil
+	}
+	_, err := tx.Exec(`
+		INSERT INTO periods (id, region, start_timestamp, end_timestamp)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(id, region) DO NOTHING
+	`, periodID, region, startTimestamp, endTimestamp)
+	return err
+}
+
+// LinkPeriodsToSeasons sets periods.season_id from the period lists of synced seasons
+// and returns how many periods of the region now belong to a season
+func (ds *DatabaseService) LinkPeriodsToSeasons(region string) (int64, error) {
+	_, err := ds.db.Exec(`
+		UPDATE periods
+		SET season_id = (
+			SELECT s.id
+			FROM period_seasons ps
+			JOIN seasons s ON s.id = ps.season_id
+			WHERE ps.period_id = periods.id AND s.region = periods.region
+			ORDER BY s.season_number DESC
+			LIMIT 1
+		)
+		WHERE region = ?
+	`, region)
+	if err != nil {
+		return 0, fmt.Errorf("failed to link periods to seasons for %s: %w", region, err)
+	}
+	var linked int64
+	err = ds.db.QueryRow(`SELECT COUNT(*) FROM periods WHERE region = ? AND season_id IS NOT NULL`, region).Scan(&linked)
+	return linked, err
+}
+
+// CurrentPeriodIDs returns the stored keystone period containing nowMillis in each
+// region that has one, by region
+func (ds *DatabaseService) CurrentPeriodIDs(nowMillis int64) (map[string]int, error) {
+	rows, err := ds.db.Query(`
+		SELECT region, MAX(id) FROM periods
+		WHERE start_timestamp <= ? AND end_timestamp > ?
+		GROUP BY region
+	`, nowMillis, nowMillis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current periods: %w", err)
+	}
+	defer rows.Close()
+
+	current := make(map[string]int)
+	for rows.Next() {
+		var region string
+		var id int
+		if err := rows.Scan(&region, &id); err != nil {
+			return nil, err
+		}
+		current[region] = id
+	}
+	return current, rows.Err()
+}
+
+// GetSettledPeriodIDs returns periods in a region whose boundaries are stored and whose
+// end lies before nowMillis; their details never change so they needn't be refetched
+func (ds *DatabaseService) GetSettledPeriodIDs(region string, nowMillis int64) (map[int]bool, error) {
+	rows, err := ds.db.Query(`
+		SELECT id FROM periods
+		WHERE region = ? AND start_timestamp IS NOT NULL AND end_timestamp IS NOT NULL AND end_timestamp <= ?
+	`, region, nowMillis)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settled := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		settled[id] = true
+	}
+	return settled, rows.Err()
+}
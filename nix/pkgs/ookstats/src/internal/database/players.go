@@ -6,6 +6,7 @@ import (
 	"ookstats/internal/blizzard"
 	"sort"
 	"strings"
+	"time"
 )
 
 // GetEligiblePlayersForProfileFetch returns players with complete coverage (9/9 dungeons)
@@ -69,15 +70,29 @@ func (ds *DatabaseService) InsertPlayerProfileData(result blizzard.PlayerProfile
 		return 0, 0, result.Error
 	}
 
-	profilesUpdated := 0
-	equipmentUpdated := 0
-
 	tx, err := ds.db.Begin()
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	profilesUpdated, equipmentUpdated, err := ds.insertPlayerProfileDataTx(tx, result, timestamp)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return profilesUpdated, equipmentUpdated, nil
+}
+
+// insertPlayerProfileDataTx inserts player profile data within a transaction
+func (ds *DatabaseService) insertPlayerProfileDataTx(tx *sql.Tx, result blizzard.PlayerProfileResult, timestamp int64) (int, int, error) {
+	profilesUpdated := 0
+	equipmentUpdated := 0
+
 	if result.Summary != nil {
 		err := ds.insertPlayerDetailsTx(tx, result.PlayerID, result.Summary, result.Media, timestamp)
 		if err != nil {
@@ -94,10 +109,6 @@ func (ds *DatabaseService) InsertPlayerProfileData(result blizzard.PlayerProfile
 		equipmentUpdated += itemCount
 	}
 
-	if err := tx.Commit(); err != nil {
-		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	return profilesUpdated, equipmentUpdated, nil
 }
 
@@ -139,18 +150,18 @@ func (ds *DatabaseService) insertPlayerDetailsTx(tx *sql.Tx, playerID int, summa
             avatar_url = excluded.avatar_url,
             last_updated = excluded.last_updated
         WHERE
-            player_details.race_id               IS NOT excluded.race_id OR
-            player_details.race_name            IS NOT excluded.race_name OR
-            player_details.gender               IS NOT excluded.gender OR
-            player_details.class_id             IS NOT excluded.class_id OR
-            player_details.class_name           IS NOT excluded.class_name OR
-            player_details.active_spec_id       IS NOT excluded.active_spec_id OR
-            player_details.active_spec_name     IS NOT excluded.active_spec_name OR
-            player_details.guild_name           IS NOT excluded.guild_name OR
-            player_details.level                IS NOT excluded.level OR
-            player_details.average_item_level   IS NOT excluded.average_item_level OR
-            player_details.equipped_item_level  IS NOT excluded.equipped_item_level OR
-            player_details.avatar_url           IS NOT excluded.avatar_url
+            player_details.race_id               IS DISTINCT FROM excluded.race_id OR
+            player_details.race_name            IS DISTINCT FROM excluded.race_name OR
+            player_details.gender               IS DISTINCT FROM excluded.gender OR
+            player_details.class_id             IS DISTINCT FROM excluded.class_id OR
+            player_details.class_name           IS DISTINCT FROM excluded.class_name OR
+            player_details.active_spec_id       IS DISTINCT FROM excluded.active_spec_id OR
+            player_details.active_spec_name     IS DISTINCT FROM excluded.active_spec_name OR
+            player_details.guild_name           IS DISTINCT FROM excluded.guild_name OR
+            player_details.level                IS DISTINCT FROM excluded.level OR
+            player_details.average_item_level   IS DISTINCT FROM excluded.average_item_level OR
+            player_details.equipped_item_level  IS DISTINCT FROM excluded.equipped_item_level OR
+            player_details.avatar_url           IS DISTINCT FROM excluded.avatar_url
     `,
 		playerID,
 		summary.Race.ID,
@@ -178,21 +189,30 @@ func (ds *DatabaseService) insertPlayerEquipmentTx(tx *sql.Tx, playerID int, equ
 	}
 
 	equipmentCount := 0
+	equippedSlots := make(map[string]bool, len(equipment.EquippedItems))
 
 	for _, item := range equipment.EquippedItems {
+		equippedSlots[item.Slot.Type] = true
+
+		var curLevel *int
+		if item.Level != nil {
+			curLevel = &item.Level.Value
+		}
+
 		// check latest snapshot for this slot; skip writing if unchanged
 		var prevID sql.NullInt64
 		var prevItemID sql.NullInt64
 		var prevUpgradeID sql.NullInt64
+		var prevLevel sql.NullInt64
 		var prevQuality, prevName sql.NullString
 		if err := tx.QueryRow(
-			`SELECT id, item_id, upgrade_id, quality, item_name
+			`SELECT id, item_id, upgrade_id, item_level, quality, item_name
              FROM player_equipment
              WHERE player_id = ? AND slot_type = ?
              ORDER BY snapshot_timestamp DESC
              LIMIT 1`,
 			playerID, item.Slot.Type,
-		).Scan(&prevID, &prevItemID, &prevUpgradeID, &prevQuality, &prevName); err != nil && err != sql.ErrNoRows {
+		).Scan(&prevID, &prevItemID, &prevUpgradeID, &prevLevel, &prevQuality, &prevName); err != nil && err != sql.ErrNoRows {
 			return 0, fmt.Errorf("failed to query latest equipment: %w", err)
 		}
 
@@ -206,7 +226,10 @@ func (ds *DatabaseService) insertPlayerEquipmentTx(tx *sql.Tx, playerID int, equ
 			if item.UpgradeID != nil {
 				curUpg = *item.UpgradeID
 			}
-			sameBasics := prevItemID.Valid && int(prevItemID.Int64) == item.Item.ID && prevQuality.Valid && prevQuality.String == item.Quality.Type && prevName.Valid && prevName.String == item.Name && prevUpg == curUpg
+			// snapshots stored before item levels were recorded only count as changed
+			// when something else differs
+			sameLevel := curLevel == nil || !prevLevel.Valid || int(prevLevel.Int64) == *curLevel
+			sameBasics := prevItemID.Valid && int(prevItemID.Int64) == item.Item.ID && prevQuality.Valid && prevQuality.String == item.Quality.Type && prevName.Valid && prevName.String == item.Name && prevUpg == curUpg && sameLevel
 
 			if sameBasics {
 				// compare enchantments as a canonical sorted signature
@@ -256,7 +279,10 @@ func (ds *DatabaseService) insertPlayerEquipmentTx(tx *sql.Tx, playerID int, equ
 					if ench.Spell != nil {
 						spellId = ench.Spell.Spell.ID
 					}
-					disp := ench.DisplayString
+					disp := ""
+					if ench.DisplayString != nil {
+						disp = *ench.DisplayString
+					}
 					curSigs = append(curSigs, fmt.Sprintf("%d|%d|%d|%s|%d|%s", eid, sid, slotId, slotType, spellId, disp))
 				}
 				sort.Strings(curSigs)
@@ -268,32 +294,35 @@ func (ds *DatabaseService) insertPlayerEquipmentTx(tx *sql.Tx, playerID int, equ
 		}
 
 		if unchanged {
+			if curLevel != nil && !prevLevel.Valid {
+				if _, err := tx.Exec(`UPDATE player_equipment SET item_level = ? WHERE id = ?`, *curLevel, prevID.Int64); err != nil {
+					return 0, fmt.Errorf("failed to backfill item level: %w", err)
+				}
+			}
 			continue
 		}
 
-		result, err := tx.Exec(`
+		var equipmentID int64
+		err := tx.QueryRow(`
             INSERT INTO player_equipment (
-                player_id, slot_type, item_id, upgrade_id, quality, item_name, snapshot_timestamp
-            ) VALUES (?, ?, ?, ?, ?, ?, ?)
+                player_id, slot_type, item_id, upgrade_id, item_level, quality, item_name, snapshot_timestamp
+            ) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+            RETURNING id
         `,
 			playerID,
 			item.Slot.Type,
 			item.Item.ID,
 			item.UpgradeID,
+			curLevel,
 			item.Quality.Type,
 			item.Name,
 			timestamp,
-		)
+		).Scan(&equipmentID)
 
 		if err != nil {
 			return 0, fmt.Errorf("failed to insert equipment item: %w", err)
 		}
 
-		equipmentID, err := result.LastInsertId()
-		if err != nil {
-			return 0, fmt.Errorf("failed to get equipment ID: %w", err)
-		}
-
 		equipmentCount++
 
 		for _, enchant := range item.Enchantments {
@@ -338,7 +367,57 @@ func (ds *DatabaseService) insertPlayerEquipmentTx(tx *sql.Tx, playerID int, equ
 		}
 	}
 
-	return equipmentCount, nil
+	emptied, err := ds.insertEmptiedSlotsTx(tx, playerID, equippedSlots, timestamp)
+	if err != nil {
+		return 0, err
+	}
+
+	return equipmentCount + emptied, nil
+}
+
+// insertEmptiedSlotsTx records slots that held an item in the latest snapshot but are
+// missing from the current equipment as a row without an item, so diffs can tell a
+// removed item from an unchanged one
+func (ds *DatabaseService) insertEmptiedSlotsTx(tx *sql.Tx, playerID int, equippedSlots map[string]bool, timestamp int64) (int, error) {
+	rows, err := tx.Query(`
+		SELECT e.slot_type
+		FROM player_equipment e
+		WHERE e.player_id = ?
+		  AND e.item_id IS NOT NULL
+		  AND e.snapshot_timestamp = (
+			SELECT MAX(e2.snapshot_timestamp)
+			FROM player_equipment e2
+			WHERE e2.player_id = e.player_id AND e2.slot_type = e.slot_type
+		  )
+	`, playerID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query equipped slots: %w", err)
+	}
+	var emptied []string
+	for rows.Next() {
+		var slot string
+		if err := rows.Scan(&slot); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan equipped slot: %w", err)
+		}
+		if !equippedSlots[slot] {
+			emptied = append(emptied, slot)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating equipped slots: %w", err)
+	}
+
+	for _, slot := range emptied {
+		if _, err := tx.Exec(`
+			INSERT INTO player_equipment (player_id, slot_type, snapshot_timestamp)
+			VALUES (?, ?, ?)
+		`, playerID, slot, timestamp); err != nil {
+			return 0, fmt.Errorf("failed to insert emptied slot: %w", err)
+		}
+	}
+	return len(emptied), nil
 }
 
 // CountPlayersMissingFingerprints returns how many valid players still lack a fingerprint
@@ -619,7 +698,7 @@ func (ds *DatabaseService) MigratePlayerRuns(fromPlayerID, toPlayerID int64) (in
 
 		rows, _ := result.RowsAffected()
 		rowsAffected = int(rows)
-		return nil
+		return markPlayersDirty(ds.db, time.Now().UnixMilli(), fromPlayerID, toPlayerID)
 	})
 	return rowsAffected, err
 }
@@ -631,7 +710,10 @@ func (ds *DatabaseService) InvalidatePlayerProfile(playerID int64) error {
 			DELETE FROM player_profiles
 			WHERE player_id = ?
 		`, playerID)
-		return err
+		if err != nil {
+			return err
+		}
+		return markPlayersDirty(ds.db, time.Now().UnixMilli(), playerID)
 	})
 }
 
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// postgresDriverName is registered with database/sql. It wraps pgx so the rest of
+// the codebase can keep writing `?` placeholders for both backends.
+const postgresDriverName = "ookstats-postgres"
+
+func init() {
+	sql.Register(postgresDriverName, &pgDriver{})
+}
+
+// Dialect identifies the SQL backend behind a *sql.DB
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	Postgres
+)
+
+func (d Dialect) String() string {
+	if d == Postgres {
+		return "postgres"
+	}
+	return "sqlite"
+}
+
+// DialectOf reports which backend db was opened with
+func DialectOf(db *sql.DB) Dialect {
+	if _, ok := db.Driver().(*pgDriver); ok {
+		return Postgres
+	}
+	return SQLite
+}
+
+// isPostgresURL reports whether a connection string targets PostgreSQL
+func isPostgresURL(s string) bool {
+	return strings.HasPrefix(s, "postgres://") || strings.HasPrefix(s, "postgresql://")
+}
+
+type pgDriver struct{}
+
+func (d *pgDriver) Open(dsn string) (driver.Conn, error) {
+	c, err := stdlib.GetDefaultDriver().Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &pgConn{inner: c.(*stdlib.Conn)}, nil
+}
+
+// pgConn forwards to the pgx connection after rewriting placeholders
+type pgConn struct {
+	inner *stdlib.Conn
+}
+
+func (c *pgConn) Prepare(query string) (driver.Stmt, error) {
+	return c.inner.Prepare(rebindPostgres(query))
+}
+
+func (c *pgConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.inner.PrepareContext(ctx, rebindPostgres(query))
+}
+
+func (c *pgConn) Close() error { return c.inner.Close() }
+
+func (c *pgConn) Begin() (driver.Tx, error) {
+	return c.inner.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *pgConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.inner.BeginTx(ctx, opts)
+}
+
+func (c *pgConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.inner.ExecContext(ctx, rebindPostgres(query), args)
+}
+
+func (c *pgConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.inner.QueryContext(ctx, rebindPostgres(query), args)
+}
+
+func (c *pgConn) Ping(ctx context.Context) error { return c.inner.Ping(ctx) }
+
+func (c *pgConn) CheckNamedValue(v *driver.NamedValue) error { return c.inner.CheckNamedValue(v) }
+
+func (c *pgConn) ResetSession(ctx context.Context) error { return c.inner.ResetSession(ctx) }
+
+// rebindPostgres rewrites `?` placeholders to `$1..$n`, leaving string literals,
+// quoted identifiers, dollar-quoted strings and comments untouched.
+func rebindPostgres(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			j := i + 1
+			for j < len(query) {
+				if query[j] == ch {
+					if j+1 < len(query) && query[j+1] == ch {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			b.WriteString(query[i:min(j+1, len(query))])
+			i = j
+		case ch == '-' && i+1 < len(query) && query[i+1] == '-':
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+j+1])
+			i += j
+		case ch == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := blockCommentEnd(query, i)
+			b.WriteString(query[i:end])
+			i = end - 1
+		case ch == '$' && (i == 0 || !isIdentByte(query[i-1])):
+			tag, ok := dollarQuoteTag(query[i:])
+			if !ok {
+				b.WriteByte(ch)
+				continue
+			}
+			end := len(query)
+			if j := strings.Index(query[i+len(tag):], tag); j >= 0 {
+				end = i + len(tag) + j + len(tag)
+			}
+			b.WriteString(query[i:end])
+			i = end - 1
+		case ch == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// blockCommentEnd returns the index just past the /* comment starting at start.
+// PostgreSQL block comments nest.
+func blockCommentEnd(query string, start int) int {
+	depth := 0
+	for i := start; i+1 < len(query); i++ {
+		switch {
+		case query[i] == '/' && query[i+1] == '*':
+			depth++
+			i++
+		case query[i] == '*' && query[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(query)
+}
+
+// dollarQuoteTag returns the opening $tag$ or $$ at the start of s. `$1` style
+// parameters aren't tags, since a tag can't start with a digit.
+func dollarQuoteTag(s string) (string, bool) {
+	for i := 1; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '$':
+			return s[:i+1], true
+		case ch >= '0' && ch <= '9':
+			if i == 1 {
+				return "", false
+			}
+		case !isIdentByte(ch):
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isIdentByte(ch byte) bool {
+	return ch == '_' || ch == '$' || ch >= 0x80 ||
+		(ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
@@ -0,0 +1,29 @@
+package database
+
+import "testing"
+
+func TestRebindPostgres(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"placeholders", "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = $1 AND b = $2"},
+		{"string literal", "SELECT 'a?''b?' , ?", "SELECT 'a?''b?' , $1"},
+		{"quoted identifier", `SELECT "we?ird" FROM t WHERE x = ?`, `SELECT "we?ird" FROM t WHERE x = $1`},
+		{"line comment", "SELECT ? -- why?\n, ?", "SELECT $1 -- why?\n, $2"},
+		{"block comment", "SELECT /* a? */ ?", "SELECT /* a? */ $1"},
+		{"nested block comment", "SELECT /* a /* b? */ c? */ ?", "SELECT /* a /* b? */ c? */ $1"},
+		{"unterminated block comment", "SELECT ? /* a?", "SELECT $1 /* a?"},
+		{"dollar quote", "SELECT $$ a? $$, ?", "SELECT $$ a? $$, $1"},
+		{"tagged dollar quote", "SELECT $fn$ a? $$ b? $fn$, ?", "SELECT $fn$ a? $$ b? $fn$, $1"},
+		{"numbered parameter", "SELECT $1, ?", "SELECT $1, $1"},
+		{"dollar in identifier", "SELECT a$b$, ?", "SELECT a$b$, $1"},
+		{"no placeholders", "SELECT /* ? */ 1", "SELECT /* ? */ 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rebindPostgres(tt.in); got != tt.want {
+				t.Errorf("rebindPostgres(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Writes that change which runs a ranking partition holds, or whose runs a player
+// has, record it here so `process all` can recompute just those partitions and
+// players. Anything not tracked this way (realm pools, the dungeon list) is caught by
+// the reference signature the ranking pass stores in ranking_state.
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// markRunPartitionsDirtyTx records the (season, dungeon, region, realm) partition a
+// run was listed in on one realm's leaderboard
+func markRunPartitionsDirtyTx(tx *sql.Tx, runID int64, realmID int, at int64) error {
+	if _, err := tx.Exec(`
+		INSERT INTO ranking_dirty_partitions (season_id, dungeon_id, region, realm_id, marked_at)
+		SELECT COALESCE(cr.season_id, 0), cr.dungeon_id, r.region, r.id, ?
+		FROM challenge_runs cr
+		JOIN realms r ON r.id = ?
+		WHERE cr.id = ?
+		ON CONFLICT(season_id, dungeon_id, region, realm_id) DO UPDATE SET marked_at = excluded.marked_at
+	`, at, realmID, runID); err != nil {
+		return fmt.Errorf("failed to mark ranking partition: %w", err)
+	}
+	return nil
+}
+
+// markRunPlayersDirtyTx records every member of a run as needing its profile rebuilt
+func markRunPlayersDirtyTx(tx *sql.Tx, runID int64, at int64) error {
+	if _, err := tx.Exec(`
+		INSERT INTO ranking_dirty_players (player_id, marked_at)
+		SELECT DISTINCT player_id, ? FROM run_members WHERE run_id = ?
+		ON CONFLICT(player_id) DO UPDATE SET marked_at = excluded.marked_at
+	`, at, runID); err != nil {
+		return fmt.Errorf("failed to mark run members for ranking: %w", err)
+	}
+	return nil
+}
+
+// markPlayersDirty records players whose runs or identity changed, and the leaderboard
+// pages their runs are listed on
+func markPlayersDirty(ex execer, at int64, playerIDs ...int64) error {
+	for _, id := range playerIDs {
+		if _, err := ex.Exec(`
+			INSERT INTO ranking_dirty_players (player_id, marked_at)
+			VALUES (?, ?)
+			ON CONFLICT(player_id) DO UPDATE SET marked_at = excluded.marked_at
+		`, id, at); err != nil {
+			return fmt.Errorf("failed to mark player %d for ranking: %w", id, err)
+		}
+	}
+	return markPlayerRunsForGeneration(ex, at, playerIDs...)
+}
+
+// RequireFullRankingRebuild forgets what the last ranking pass was computed against,
+// so the next `process all` rebuilds every partition. Use it after writes to runs or
+// run members that don't mark what they changed.
+func (ds *DatabaseService) RequireFullRankingRebuild() error {
+	return retryOnBusy(func() error {
+		_, err := ds.db.Exec(`DELETE FROM ranking_state`)
+		return err
+	})
+}
+
+// LoadRankingSignature returns the reference signature the last ranking pass stored,
+// and false if no pass was recorded since the state was last reset
+func (ds *DatabaseService) LoadRankingSignature(tx *sql.Tx) (string, bool, error) {
+	var signature string
+	err := tx.QueryRow(`SELECT reference_signature FROM ranking_state WHERE id = 1`).Scan(&signature)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read ranking state: %w", err)
+	}
+	return signature, true, nil
+}
+
+// SaveRankingState records the signature a ranking pass was computed against and when
+// it ran, as full_at or incremental_at
+func (ds *DatabaseService) SaveRankingState(tx *sql.Tx, signature string, full bool, at int64) error {
+	var fullAt, incrementalAt any
+	if full {
+		fullAt = at
+	} else {
+		incrementalAt = at
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO ranking_state (id, reference_signature, full_at, incremental_at)
+		VALUES (1, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			reference_signature = excluded.reference_signature,
+			full_at = COALESCE(excluded.full_at, ranking_state.full_at),
+			incremental_at = COALESCE(excluded.incremental_at, ranking_state.incremental_at)
+	`, signature, fullAt, incrementalAt); err != nil {
+		return fmt.Errorf("failed to record ranking state: %w", err)
+	}
+	return nil
+}
+
+// ResetRankingState is RequireFullRankingRebuild inside a transaction that rewrote
+// rankings without keeping the dirty marks in step
+func (ds *DatabaseService) ResetRankingState(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DELETE FROM ranking_state`); err != nil {
+		return fmt.Errorf("failed to reset ranking state: %w", err)
+	}
+	return nil
+}
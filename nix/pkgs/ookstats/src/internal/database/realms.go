@@ -3,6 +3,8 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"ookstats/internal/blizzard"
+	"strings"
 )
 
 // getRealmIDTx gets a realm ID within a transaction
@@ -71,3 +73,105 @@ func (ds *DatabaseService) GetRealmPoolIDs(region, slug string) ([]int, error) {
 	}
 	return poolIDs, rows.Err()
 }
+
+// UpsertRealms writes realms discovered from the connected realm API, keyed by
+// (region, slug). Placeholder rows created for unknown player realms are filled in.
+// Returns how many realms were added and how many existing rows changed.
+func (ds *DatabaseService) UpsertRealms(realms []blizzard.RealmInfo) (added, updated int, err error) {
+	if len(realms) == 0 {
+		return 0, 0, nil
+	}
+
+	err = retryOnBusy(func() error {
+		added, updated = 0, 0
+		tx, err := ds.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		for _, ri := range realms {
+			var (
+				name        sql.NullString
+				connectedID sql.NullInt64
+				parentSlug  sql.NullString
+			)
+			err := tx.QueryRow(`SELECT name, connected_realm_id, parent_realm_slug FROM realms WHERE region = ? AND slug = ?`,
+				ri.Region, ri.Slug).Scan(&name, &connectedID, &parentSlug)
+			exists := err == nil
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("realm %s-%s: %w", ri.Region, ri.Slug, err)
+			}
+			if exists && name.String == ri.Name && connectedID.Int64 == int64(ri.ID) && parentSlug.String == ri.ParentRealmSlug {
+				continue
+			}
+
+			// connected_realm_id is unique; release it from a row whose slug has since changed
+			if _, err := tx.Exec(`UPDATE realms SET connected_realm_id = NULL WHERE connected_realm_id = ? AND NOT (region = ? AND slug = ?)`,
+				ri.ID, ri.Region, ri.Slug); err != nil {
+				return fmt.Errorf("realm %s-%s: %w", ri.Region, ri.Slug, err)
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO realms (slug, name, region, connected_realm_id, parent_realm_slug)
+				VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT(region, slug) DO UPDATE SET
+					name = excluded.name,
+					connected_realm_id = excluded.connected_realm_id,
+					parent_realm_slug = excluded.parent_realm_slug
+			`, ri.Slug, ri.Name, ri.Region, ri.ID, ri.ParentRealmSlug); err != nil {
+				return fmt.Errorf("realm %s-%s: %w", ri.Region, ri.Slug, err)
+			}
+			if exists {
+				updated++
+			} else {
+				added++
+			}
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to upsert realms: %w", err)
+	}
+	return added, updated, nil
+}
+
+// GetRealms returns the realm registry: every realm with a connected realm ID, i.e.
+// synced from the API rather than a placeholder seen only on a leaderboard. Keys are
+// slugs; a slug used in several regions is keyed as slug-region in each of them.
+func (ds *DatabaseService) GetRealms() (map[string]blizzard.RealmInfo, error) {
+	rows, err := ds.db.Query(`
+		SELECT connected_realm_id, COALESCE(name, ''), region, slug, COALESCE(parent_realm_slug, '')
+		FROM realms
+		WHERE connected_realm_id IS NOT NULL
+		ORDER BY region, slug
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query realms: %w", err)
+	}
+	defer rows.Close()
+
+	var list []blizzard.RealmInfo
+	slugCount := make(map[string]int)
+	for rows.Next() {
+		var ri blizzard.RealmInfo
+		if err := rows.Scan(&ri.ID, &ri.Name, &ri.Region, &ri.Slug, &ri.ParentRealmSlug); err != nil {
+			return nil, fmt.Errorf("scan realm: %w", err)
+		}
+		ri.Region = strings.ToLower(ri.Region)
+		list = append(list, ri)
+		slugCount[ri.Slug]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	realms := make(map[string]blizzard.RealmInfo, len(list))
+	for _, ri := range list {
+		key := ri.Slug
+		if slugCount[ri.Slug] > 1 {
+			key = ri.Slug + "-" + ri.Region
+		}
+		realms[key] = ri
+	}
+	return realms, nil
+}
@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"fmt"
 	"ookstats/internal/blizzard"
-	"sort"
 	"strings"
 )
 
@@ -12,8 +11,9 @@ import (
 func (ds *DatabaseService) EnsureReferenceData(realmInfo blizzard.RealmInfo, dungeons []blizzard.DungeonInfo) error {
 	// insert realm data
 	realmQuery := `
-		INSERT OR IGNORE INTO realms (slug, name, region, connected_realm_id, parent_realm_slug)
+		INSERT INTO realms (slug, name, region, connected_realm_id, parent_realm_slug)
 		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
 	`
 	_, err := ds.db.Exec(realmQuery, realmInfo.Slug, realmInfo.Name, realmInfo.Region, realmInfo.ID, realmInfo.ParentRealmSlug)
 	if err != nil {
@@ -22,8 +22,9 @@ func (ds *DatabaseService) EnsureReferenceData(realmInfo blizzard.RealmInfo, dun
 
 	// insert dungeon data
 	dungeonQuery := `
-		INSERT OR IGNORE INTO dungeons (id, slug, name, map_challenge_mode_id)
+		INSERT INTO dungeons (id, slug, name, map_challenge_mode_id)
 		VALUES (?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
 	`
 
 	for _, dungeon := range dungeons {
@@ -41,10 +42,10 @@ func (ds *DatabaseService) EnsureDungeonsOnce(dungeons []blizzard.DungeonInfo) e
 	if len(dungeons) == 0 {
 		return nil
 	}
-	// Build a single INSERT OR IGNORE with multi-row VALUES to reduce round trips
+	// Build a single multi-row INSERT that skips existing rows to reduce round trips
 	var b strings.Builder
 	args := make([]any, 0, len(dungeons)*4)
-	b.WriteString("INSERT OR IGNORE INTO dungeons (id, slug, name, map_challenge_mode_id) VALUES ")
+	b.WriteString("INSERT INTO dungeons (id, slug, name, map_challenge_mode_id) VALUES ")
 	for i, d := range dungeons {
 		if i > 0 {
 			b.WriteString(",")
@@ -52,6 +53,7 @@ func (ds *DatabaseService) EnsureDungeonsOnce(dungeons []blizzard.DungeonInfo) e
 		b.WriteString("(?, ?, ?, ?)")
 		args = append(args, d.ID, d.Slug, d.Name, d.ID)
 	}
+	b.WriteString(" ON CONFLICT DO NOTHING")
 	_, err := ds.db.Exec(b.String(), args...)
 	if err != nil {
 		return fmt.Errorf("failed to ensure dungeons: %w", err)
@@ -59,59 +61,92 @@ func (ds *DatabaseService) EnsureDungeonsOnce(dungeons []blizzard.DungeonInfo) e
 	return nil
 }
 
-// EnsureRealmsBatch inserts/updates all known realms in a single transaction with a prepared statement
-func (ds *DatabaseService) EnsureRealmsBatch(realms map[string]blizzard.RealmInfo) error {
-	if len(realms) == 0 {
-		return nil
+// MergeDungeons upserts discovered dungeons, keyed by map challenge mode ID. Names follow
+// the API; slugs stay as first stored since they appear in generated API paths, and a
+// known map_id is kept when the new entry doesn't have one.
+// Returns how many dungeons were not in the table before.
+func (ds *DatabaseService) MergeDungeons(dungeons []blizzard.DungeonInfo) (int, error) {
+	if len(dungeons) == 0 {
+		return 0, nil
 	}
-	tx, err := ds.db.Begin()
-	if err != nil {
-		return err
+
+	var before int
+	if err := ds.db.QueryRow("SELECT COUNT(*) FROM dungeons").Scan(&before); err != nil {
+		return 0, fmt.Errorf("count dungeons: %w", err)
 	}
-	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`
-        INSERT OR IGNORE INTO realms (slug, name, region, connected_realm_id, parent_realm_slug)
-        VALUES (?, ?, ?, ?, ?)
-    `)
+	err := retryOnBusy(func() error {
+		tx, err := ds.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		stmt, err := tx.Prepare(`
+			INSERT INTO dungeons (id, slug, name, map_id, map_challenge_mode_id)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET
+				name = excluded.name,
+				map_id = COALESCE(excluded.map_id, dungeons.map_id),
+				map_challenge_mode_id = excluded.map_challenge_mode_id
+		`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, d := range dungeons {
+			var mapID any
+			if d.MapID > 0 {
+				mapID = d.MapID
+			}
+			if _, err := stmt.Exec(d.ID, d.Slug, d.Name, mapID, d.ID); err != nil {
+				return fmt.Errorf("dungeon %d (%s): %w", d.ID, d.Name, err)
+			}
+		}
+		return tx.Commit()
+	})
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("failed to merge dungeons: %w", err)
 	}
-	defer stmt.Close()
 
-	keys := make([]string, 0, len(realms))
-	for k := range realms {
-		keys = append(keys, k)
+	var after int
+	if err := ds.db.QueryRow("SELECT COUNT(*) FROM dungeons").Scan(&after); err != nil {
+		return 0, fmt.Errorf("count dungeons: %w", err)
 	}
-	sort.Strings(keys)
-	total := len(keys)
-	for i, slug := range keys {
-		ri := realms[slug]
-		if _, err := stmt.Exec(ri.Slug, ri.Name, ri.Region, ri.ID, ri.ParentRealmSlug); err != nil {
-			return fmt.Errorf("failed to insert realm %s: %w", ri.Slug, err)
-		}
-		if (i+1)%10 == 0 || i+1 == total {
-			fmt.Printf("    - Ensured %d/%d realms\n", i+1, total)
-		}
+	return after - before, nil
+}
+
+// GetDungeons returns all dungeons in the dungeons table ordered by ID
+func (ds *DatabaseService) GetDungeons() ([]blizzard.DungeonInfo, error) {
+	rows, err := ds.db.Query(`SELECT id, COALESCE(name, ''), COALESCE(slug, ''), COALESCE(map_id, 0) FROM dungeons ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("query dungeons: %w", err)
 	}
+	defer rows.Close()
 
-	if err := tx.Commit(); err != nil {
-		return err
+	var dungeons []blizzard.DungeonInfo
+	for rows.Next() {
+		var d blizzard.DungeonInfo
+		if err := rows.Scan(&d.ID, &d.Name, &d.Slug, &d.MapID); err != nil {
+			return nil, fmt.Errorf("scan dungeon: %w", err)
+		}
+		dungeons = append(dungeons, d)
 	}
-	return nil
+	return dungeons, rows.Err()
 }
 
 // ensureReferenceDataTx ensures reference data within a transaction
 func (ds *DatabaseService) ensureReferenceDataTx(tx *sql.Tx, realmInfo blizzard.RealmInfo, dungeons []blizzard.DungeonInfo) error {
 	// insert realm data
-	realmQuery := `INSERT OR IGNORE INTO realms (slug, name, region, connected_realm_id, parent_realm_slug) VALUES (?, ?, ?, ?, ?)`
+	realmQuery := `INSERT INTO realms (slug, name, region, connected_realm_id, parent_realm_slug) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`
 	_, err := tx.Exec(realmQuery, realmInfo.Slug, realmInfo.Name, realmInfo.Region, realmInfo.ID, realmInfo.ParentRealmSlug)
 	if err != nil {
 		return fmt.Errorf("failed to insert realm data: %w", err)
 	}
 
 	// insert dungeon data
-	dungeonQuery := `INSERT OR IGNORE INTO dungeons (id, slug, name, map_challenge_mode_id) VALUES (?, ?, ?, ?)`
+	dungeonQuery := `INSERT INTO dungeons (id, slug, name, map_challenge_mode_id) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`
 	for _, dungeon := range dungeons {
 		_, err := tx.Exec(dungeonQuery, dungeon.ID, dungeon.Slug, dungeon.Name, dungeon.ID)
 		if err != nil {
@@ -3,24 +3,315 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
-// EnsureCompleteSchema creates all required tables and indexes for the ookstats database
+// schemaMigrations is the ordered schema history. Never edit a migration once it
+// has shipped: applied migrations are checksummed, so append a new one instead.
+var schemaMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline_tables",
+		Up:      baselineTables,
+	},
+	{
+		// Databases created before versioned migrations may carry older table shapes
+		Version: 2,
+		Name:    "upgrade_legacy_tables",
+		Apply:   upgradeLegacyTables,
+	},
+	{
+		Version: 3,
+		Name:    "recommended_indexes",
+		Up:      recommendedIndexes,
+		Down:    dropIndexStatements(recommendedIndexes),
+	},
+	{
+		Version: 4,
+		Name:    "periods",
+		Up: []string{
+			// Exact period boundaries per region; season_id references seasons.id
+			`CREATE TABLE IF NOT EXISTS periods (
+				id INTEGER NOT NULL,
+				region TEXT NOT NULL,
+				start_timestamp INTEGER,
+				end_timestamp INTEGER,
+				season_id INTEGER,
+				PRIMARY KEY (id, region)
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_periods_region_start ON periods(region, start_timestamp)",
+		},
+		Down: []string{
+			"DROP INDEX IF EXISTS idx_periods_region_start",
+			"DROP TABLE IF EXISTS periods",
+		},
+	},
+	{
+		Version: 5,
+		Name:    "fetch_status_cutoff_duration",
+		Apply:   addFetchStatusCutoff,
+		Down:    []string{"ALTER TABLE fetch_status DROP COLUMN cutoff_duration"},
+	},
+	{
+		Version: 6,
+		Name:    "run_sightings",
+		Up: append([]string{
+			`CREATE TABLE IF NOT EXISTS run_sightings (
+				run_id INTEGER NOT NULL,
+				realm_id INTEGER NOT NULL,
+				first_seen_at INTEGER,
+				PRIMARY KEY (run_id, realm_id)
+			)`,
+		}, mergeRealmDuplicateRuns...),
+		// irreversible: merged runs keep one row, so dropping run_sightings would
+		// lose every realm they were seen on but the canonical one
+	},
+	{
+		Version: 7,
+		Name:    "run_period_sightings",
+		Up: append([]string{
+			`CREATE TABLE IF NOT EXISTS run_period_sightings (
+				run_id INTEGER NOT NULL,
+				realm_id INTEGER NOT NULL,
+				dungeon_id INTEGER NOT NULL,
+				period_id INTEGER NOT NULL,
+				first_seen_at INTEGER,
+				last_seen_at INTEGER,
+				PRIMARY KEY (run_id, realm_id, period_id)
+			)`,
+		}, runPeriodSightingsIndexes...),
+		Down: runPeriodSightingsDown,
+	},
+	{
+		Version: 8,
+		Name:    "ranking_dirty_partitions",
+		Up: []string{
+			// (season, dungeon, region, realm) partitions whose runs changed since the
+			// last ranking pass; season_id 0 stands for runs without a season
+			`CREATE TABLE IF NOT EXISTS ranking_dirty_partitions (
+				season_id INTEGER NOT NULL,
+				dungeon_id INTEGER NOT NULL,
+				region TEXT NOT NULL,
+				realm_id INTEGER NOT NULL,
+				marked_at INTEGER NOT NULL,
+				PRIMARY KEY (season_id, dungeon_id, region, realm_id)
+			)`,
+			// Players whose runs or identity changed since the last ranking pass
+			`CREATE TABLE IF NOT EXISTS ranking_dirty_players (
+				player_id INTEGER NOT NULL PRIMARY KEY,
+				marked_at INTEGER NOT NULL
+			)`,
+			// What the last ranking pass was computed against; no row forces a full rebuild
+			`CREATE TABLE IF NOT EXISTS ranking_state (
+				id INTEGER NOT NULL PRIMARY KEY,
+				reference_signature TEXT NOT NULL,
+				full_at INTEGER,
+				incremental_at INTEGER
+			)`,
+		},
+		Down: rankingDirtyPartitionsDown,
+	},
+	{
+		Version: 9,
+		Name:    "player_ranking_history",
+		Up: append([]string{
+			// One row per player, season and ranking pass whose ranks differ from the
+			// player's previous row
+			`CREATE TABLE IF NOT EXISTS player_ranking_history (
+				player_id INTEGER NOT NULL,
+				season_id INTEGER NOT NULL,
+				recorded_at INTEGER NOT NULL,
+				combined_best_time INTEGER,
+				global_ranking INTEGER,
+				regional_ranking INTEGER,
+				realm_ranking INTEGER,
+				global_class_rank INTEGER,
+				region_class_rank INTEGER,
+				realm_class_rank INTEGER,
+				PRIMARY KEY (player_id, season_id, recorded_at)
+			)`,
+		}, playerRankingHistorySeed...),
+		Down: []string{"DROP TABLE IF EXISTS player_ranking_history"},
+	},
+	{
+		Version: 10,
+		Name:    "equipment_history",
+		Up: []string{
+			"CREATE INDEX IF NOT EXISTS idx_player_equipment_slot_ts ON player_equipment(player_id, slot_type, snapshot_timestamp)",
+			// Snapshots older than pruned_before were collapsed into the gear each slot
+			// had at that time, so diffs only start there
+			`CREATE TABLE IF NOT EXISTS equipment_retention (
+				id INTEGER NOT NULL PRIMARY KEY,
+				pruned_before INTEGER NOT NULL,
+				pruned_at INTEGER
+			)`,
+		},
+		Apply: addEquipmentItemLevel,
+		Down: []string{
+			"DROP TABLE IF EXISTS equipment_retention",
+			"DROP INDEX IF EXISTS idx_player_equipment_slot_ts",
+			"ALTER TABLE player_equipment DROP COLUMN item_level",
+		},
+	},
+	{
+		Version: 11,
+		Name:    "build_stages",
+		Up: []string{
+			// Last start and completion of each `build` stage, with the hash of the
+			// inputs it ran against; completed_at is NULL while a stage runs or after
+			// it failed
+			`CREATE TABLE IF NOT EXISTS build_stages (
+				stage TEXT NOT NULL PRIMARY KEY,
+				input_hash TEXT NOT NULL,
+				started_at INTEGER NOT NULL,
+				completed_at INTEGER,
+				duration_ms INTEGER
+			)`,
+		},
+		Down: []string{"DROP TABLE IF EXISTS build_stages"},
+	},
+	{
+		Version: 12,
+		Name:    "generation_tracking",
+		Up: []string{
+			// Leaderboard partitions and players whose generated files are stale; the
+			// ranking pass and identity changes write them, `generate api` consumes them
+			`CREATE TABLE IF NOT EXISTS generation_dirty_partitions (
+				season_id INTEGER NOT NULL,
+				dungeon_id INTEGER NOT NULL,
+				region TEXT NOT NULL,
+				realm_id INTEGER NOT NULL,
+				marked_at INTEGER NOT NULL,
+				PRIMARY KEY (season_id, dungeon_id, region, realm_id)
+			)`,
+			`CREATE TABLE IF NOT EXISTS generation_dirty_players (
+				player_id INTEGER NOT NULL PRIMARY KEY,
+				marked_at INTEGER NOT NULL
+			)`,
+			// What the last generation was produced from; watermark is when it started
+			`CREATE TABLE IF NOT EXISTS generation_state (
+				id INTEGER NOT NULL PRIMARY KEY,
+				signature TEXT NOT NULL,
+				watermark INTEGER NOT NULL,
+				full_at INTEGER,
+				incremental_at INTEGER
+			)`,
+			// Where each player's file was last written, relative to api/player
+			`CREATE TABLE IF NOT EXISTS generated_player_files (
+				player_id INTEGER NOT NULL PRIMARY KEY,
+				path TEXT NOT NULL
+			)`,
+		},
+		Down: generationTrackingDown,
+	},
+}
+
+var generationTrackingDown = []string{
+	"DROP TABLE IF EXISTS generated_player_files",
+	"DROP TABLE IF EXISTS generation_state",
+	"DROP TABLE IF EXISTS generation_dirty_players",
+	"DROP TABLE IF EXISTS generation_dirty_partitions",
+}
+
+// playerRankingHistorySeed starts the history with the ranks currently stored
+var playerRankingHistorySeed = []string{
+	`INSERT INTO player_ranking_history (
+		player_id, season_id, recorded_at, combined_best_time,
+		global_ranking, regional_ranking, realm_ranking,
+		global_class_rank, region_class_rank, realm_class_rank
+	)
+	SELECT
+		player_id, season_id, COALESCE(last_updated, 0), combined_best_time,
+		global_ranking, regional_ranking, realm_ranking,
+		global_class_rank, region_class_rank, realm_class_rank
+	FROM player_profiles
+	WHERE global_ranking IS NOT NULL
+	ON CONFLICT DO NOTHING`,
+}
+
+var rankingDirtyPartitionsDown = []string{
+	"DROP TABLE IF EXISTS ranking_state",
+	"DROP TABLE IF EXISTS ranking_dirty_players",
+	"DROP TABLE IF EXISTS ranking_dirty_partitions",
+}
+
+// mergeRealmDuplicateRuns collapses runs that were stored once per realm leaderboard
+// into the lowest id of each (timestamp, dungeon, duration, team) group, records every
+// original realm as a sighting, and moves the uniqueness key off realm_id. Rankings
+// for the dropped ids are deleted; the next ranking pass recomputes them.
+var mergeRealmDuplicateRuns = []string{
+	"CREATE INDEX IF NOT EXISTS idx_run_sightings_realm ON run_sightings(realm_id, run_id)",
+	`CREATE TEMP TABLE run_merge AS
+		SELECT cr.id AS run_id,
+		       CASE WHEN cr.team_signature IS NULL THEN cr.id
+		            ELSE MIN(cr.id) OVER (PARTITION BY cr.completed_timestamp, cr.dungeon_id, cr.duration, cr.team_signature)
+		       END AS canonical_id
+		FROM challenge_runs cr`,
+	`INSERT INTO run_sightings (run_id, realm_id)
+		SELECT DISTINCT m.canonical_id, cr.realm_id
+		FROM challenge_runs cr
+		JOIN run_merge m ON m.run_id = cr.id`,
+	`INSERT INTO run_members (run_id, player_id, spec_id, faction)
+		SELECT m.canonical_id, rm.player_id, rm.spec_id, rm.faction
+		FROM run_members rm
+		JOIN run_merge m ON m.run_id = rm.run_id
+		WHERE m.run_id <> m.canonical_id
+		ON CONFLICT DO NOTHING`,
+	"DELETE FROM run_members WHERE run_id IN (SELECT run_id FROM run_merge WHERE run_id <> canonical_id)",
+	`UPDATE player_best_runs
+		SET run_id = (SELECT m.canonical_id FROM run_merge m WHERE m.run_id = player_best_runs.run_id)
+		WHERE run_id IN (SELECT run_id FROM run_merge WHERE run_id <> canonical_id)`,
+	"DELETE FROM run_rankings WHERE run_id IN (SELECT run_id FROM run_merge WHERE run_id <> canonical_id)",
+	"DELETE FROM challenge_runs WHERE id IN (SELECT run_id FROM run_merge WHERE run_id <> canonical_id)",
+	"DROP TABLE run_merge",
+	"DROP INDEX IF EXISTS idx_runs_unique",
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_runs_canonical ON challenge_runs(completed_timestamp, dungeon_id, duration, team_signature)",
+}
+
+// runPeriodSightingsIndexes index sighting history by leaderboard and by fetch time,
+// and seed it with the one period each stored run is known to have appeared in
+var runPeriodSightingsIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_run_period_sightings_board ON run_period_sightings(realm_id, dungeon_id, period_id)",
+	"CREATE INDEX IF NOT EXISTS idx_run_period_sightings_last_seen ON run_period_sightings(last_seen_at)",
+	`INSERT INTO run_period_sightings (run_id, realm_id, dungeon_id, period_id, first_seen_at, last_seen_at)
+		SELECT rs.run_id, rs.realm_id, cr.dungeon_id, cr.period_id, rs.first_seen_at, rs.first_seen_at
+		FROM run_sightings rs
+		JOIN challenge_runs cr ON cr.id = rs.run_id
+		WHERE cr.period_id IS NOT NULL
+		ON CONFLICT DO NOTHING`,
+}
+
+var runPeriodSightingsDown = []string{
+	"DROP INDEX IF EXISTS idx_run_period_sightings_last_seen",
+	"DROP INDEX IF EXISTS idx_run_period_sightings_board",
+	"DROP TABLE IF EXISTS run_period_sightings",
+}
+
+// EnsureCompleteSchema brings the ookstats database up to the latest schema version
 func EnsureCompleteSchema(db *sql.DB) error {
 	fmt.Printf("Ensuring complete database schema...\n")
 
-	// Create all tables first
-	tables := []string{
-		// Reference tables
-		`CREATE TABLE IF NOT EXISTS dungeons (
-			id INTEGER PRIMARY KEY,
-			slug TEXT UNIQUE,
-			name TEXT,
-			map_id INTEGER,
-			map_challenge_mode_id INTEGER UNIQUE
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS realms (
+	applied, err := Migrate(db, 0)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[OK] Schema at version %d (%d migrations applied)\n", LatestSchemaVersion(), applied)
+	return nil
+}
+
+// baselineTables is the table layout every managed database starts from
+var baselineTables = []string{
+	// Reference tables
+	`CREATE TABLE IF NOT EXISTS dungeons (
+		id INTEGER PRIMARY KEY,
+		slug TEXT UNIQUE,
+		name TEXT,
+		map_id INTEGER,
+		map_challenge_mode_id INTEGER UNIQUE
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS realms (
             id INTEGER PRIMARY KEY,
             slug TEXT,
             name TEXT,
@@ -29,312 +320,296 @@ func EnsureCompleteSchema(db *sql.DB) error {
             parent_realm_slug TEXT
         )`,
 
-		// Core leaderboard data
-		`CREATE TABLE IF NOT EXISTS challenge_runs (
-			id INTEGER PRIMARY KEY,
-			duration INTEGER,
-			completed_timestamp INTEGER,
-			keystone_level INTEGER DEFAULT 1,
-			dungeon_id INTEGER,
-			realm_id INTEGER,
-			period_id INTEGER,
-			period_start_timestamp INTEGER,
-			period_end_timestamp INTEGER,
-			team_signature TEXT,
-			season_id INTEGER
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS players (
-			id INTEGER PRIMARY KEY,
-			blizzard_character_id INTEGER,
-			name TEXT,
-			name_lower TEXT,
-			realm_id INTEGER,
-			is_valid INTEGER DEFAULT 1,
-			status_checked_at INTEGER
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS run_members (
-			run_id INTEGER,
-			player_id INTEGER,
-			spec_id INTEGER,
-			faction TEXT
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS player_fingerprints (
-			player_id INTEGER PRIMARY KEY REFERENCES players(id),
-			fingerprint_hash TEXT UNIQUE,
-			class_id INTEGER NOT NULL,
-			level85_timestamp INTEGER NOT NULL,
-			level90_timestamp INTEGER NOT NULL,
-			earliest_heroic_timestamp INTEGER NOT NULL,
-			last_seen_name TEXT,
-			last_seen_realm_slug TEXT,
-			last_seen_timestamp INTEGER,
-			first_run_timestamp INTEGER,
-			created_at INTEGER
-		)`,
-
-		// Player aggregation and rankings (season-scoped)
-		`CREATE TABLE IF NOT EXISTS player_profiles (
-			player_id INTEGER,
-			season_id INTEGER NOT NULL,
-			name TEXT,
-			realm_id INTEGER,
-			main_spec_id INTEGER,
-			class_name TEXT,
-			dungeons_completed INTEGER DEFAULT 0,
-			total_runs INTEGER DEFAULT 0,
-			combined_best_time INTEGER,
-			average_best_time INTEGER,
-			global_ranking INTEGER,
-			regional_ranking INTEGER,
-			realm_ranking INTEGER,
-			global_ranking_bracket TEXT,
-			regional_ranking_bracket TEXT,
-			realm_ranking_bracket TEXT,
-			global_class_rank INTEGER,
-			region_class_rank INTEGER,
-			realm_class_rank INTEGER,
-			global_class_bracket TEXT,
-			region_class_bracket TEXT,
-			realm_class_bracket TEXT,
-			has_complete_coverage INTEGER DEFAULT 0,
-			last_updated INTEGER,
-			PRIMARY KEY (player_id, season_id)
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS player_best_runs (
-			player_id INTEGER,
-			dungeon_id INTEGER,
-			run_id INTEGER,
-			duration INTEGER,
-			season_id INTEGER NOT NULL,
-			global_ranking INTEGER,
-			global_ranking_filtered INTEGER,
-			regional_ranking INTEGER,
-			realm_ranking INTEGER,
-			regional_ranking_filtered INTEGER,
-			realm_ranking_filtered INTEGER,
-			percentile_bracket TEXT,
-			global_percentile_bracket TEXT,
-			regional_percentile_bracket TEXT,
-			realm_percentile_bracket TEXT,
-			completed_timestamp INTEGER,
-			PRIMARY KEY (player_id, dungeon_id, season_id)
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS player_rankings (
-			player_id INTEGER NOT NULL,
-			ranking_type TEXT NOT NULL,
-			ranking_scope TEXT NOT NULL,
-			ranking INTEGER,
-			combined_best_time INTEGER,
-			last_updated INTEGER,
-			PRIMARY KEY (player_id, ranking_type, ranking_scope)
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS player_seasonal_rankings (
-			player_id INTEGER,
-			season_id INTEGER NOT NULL,
-			dungeons_completed INTEGER DEFAULT 0,
-			combined_best_time INTEGER,
-			global_ranking INTEGER,
-			regional_ranking INTEGER,
-			realm_ranking INTEGER,
-			global_ranking_bracket TEXT,
-			regional_ranking_bracket TEXT,
-			realm_ranking_bracket TEXT,
-			last_updated INTEGER,
-			PRIMARY KEY (player_id, season_id)
-		)`,
-
-		// Extended player information
-		`CREATE TABLE IF NOT EXISTS player_details (
-			player_id INTEGER PRIMARY KEY,
-			race_id INTEGER,
-			race_name TEXT,
-			gender TEXT,
-			class_id INTEGER,
-			class_name TEXT,
-			active_spec_id INTEGER,
-			active_spec_name TEXT,
-			guild_name TEXT,
-			level INTEGER,
-			average_item_level INTEGER,
-			equipped_item_level INTEGER,
-			avatar_url TEXT,
-			last_login_timestamp INTEGER,
-			last_updated INTEGER
-		)`,
-
-		// Equipment system
-		`CREATE TABLE IF NOT EXISTS player_equipment (
-			id INTEGER PRIMARY KEY,
-			player_id INTEGER,
-			slot_type TEXT,
-			item_id INTEGER,
-			upgrade_id INTEGER,
-			quality TEXT,
-			item_name TEXT,
-			snapshot_timestamp INTEGER
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS player_equipment_enchantments (
-			id INTEGER PRIMARY KEY,
-			equipment_id INTEGER,
-			enchantment_id INTEGER,
-			slot_id INTEGER,
-			slot_type TEXT,
-			display_string TEXT,
-			source_item_id INTEGER,
-			source_item_name TEXT,
-			spell_id INTEGER
-		)`,
-
-		// Computed rankings
-		`CREATE TABLE IF NOT EXISTS run_rankings (
-			run_id INTEGER,
-			dungeon_id INTEGER,
-			ranking_type TEXT,
-			ranking_scope TEXT,
-			ranking INTEGER,
-			percentile_bracket TEXT,
-			season_id INTEGER NOT NULL,
-			computed_at INTEGER,
-			PRIMARY KEY (run_id, ranking_type, ranking_scope, season_id)
-		)`,
-
-		// Metadata and items
-		`CREATE TABLE IF NOT EXISTS api_fetch_metadata (
-			id INTEGER PRIMARY KEY,
-			fetch_type TEXT UNIQUE,
-			last_fetch_timestamp INTEGER,
-			last_successful_fetch INTEGER,
-			runs_fetched INTEGER DEFAULT 0,
-			players_fetched INTEGER DEFAULT 0
-		)`,
-		// Incremental markers used by fetchers/batch processing
-		`CREATE TABLE IF NOT EXISTS api_fetch_markers (
-			realm_slug TEXT NOT NULL,
-			dungeon_id INTEGER NOT NULL,
-			period_id INTEGER NOT NULL,
-			last_completed_ts INTEGER NOT NULL DEFAULT 0,
-			PRIMARY KEY (realm_slug, dungeon_id, period_id)
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS fetch_status (
-			region TEXT NOT NULL,
-			realm_slug TEXT NOT NULL,
-			dungeon_id INTEGER NOT NULL,
-			period_id INTEGER NOT NULL,
-			status TEXT NOT NULL,
-			http_status INTEGER,
-			checked_at INTEGER NOT NULL,
-			message TEXT,
-			PRIMARY KEY (region, realm_slug, dungeon_id, period_id)
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS items (
-			id INTEGER PRIMARY KEY,
-			name TEXT,
-			icon TEXT,
-			quality INTEGER,
-			type INTEGER,
-			stats TEXT
-		)`,
-
-		// Season tables
-		`CREATE TABLE IF NOT EXISTS seasons (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			season_number INTEGER NOT NULL,
-			region TEXT NOT NULL,
-			start_timestamp INTEGER,
-			end_timestamp INTEGER,
-			season_name TEXT,
-			first_period_id INTEGER,
-			last_period_id INTEGER,
-			UNIQUE(season_number, region)
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS period_seasons (
-			period_id INTEGER,
-			season_id INTEGER,
-			PRIMARY KEY (period_id, season_id)
-		)`,
-	}
+	// Core leaderboard data
+	`CREATE TABLE IF NOT EXISTS challenge_runs (
+		id INTEGER PRIMARY KEY,
+		duration INTEGER,
+		completed_timestamp INTEGER,
+		keystone_level INTEGER DEFAULT 1,
+		dungeon_id INTEGER,
+		realm_id INTEGER,
+		period_id INTEGER,
+		period_start_timestamp INTEGER,
+		period_end_timestamp INTEGER,
+		team_signature TEXT,
+		season_id INTEGER
+	)`,
 
-	for _, table := range tables {
-		if _, err := db.Exec(table); err != nil {
-			return fmt.Errorf("failed to create table: %w", err)
-		}
-	}
+	`CREATE TABLE IF NOT EXISTS players (
+		id INTEGER PRIMARY KEY,
+		blizzard_character_id INTEGER,
+		name TEXT,
+		name_lower TEXT,
+		realm_id INTEGER,
+		is_valid INTEGER DEFAULT 1,
+		status_checked_at INTEGER
+	)`,
 
-	fmt.Printf("[OK] All tables created\n")
+	`CREATE TABLE IF NOT EXISTS run_members (
+		run_id INTEGER,
+		player_id INTEGER,
+		spec_id INTEGER,
+		faction TEXT
+	)`,
 
-	// Migrate realms schema if needed (ensure (region, slug) composite uniqueness)
-	if err := migrateRealmsCompositeSlug(db); err != nil {
-		return err
-	}
+	`CREATE TABLE IF NOT EXISTS player_fingerprints (
+		player_id INTEGER PRIMARY KEY REFERENCES players(id),
+		fingerprint_hash TEXT UNIQUE,
+		class_id INTEGER NOT NULL,
+		level85_timestamp INTEGER NOT NULL,
+		level90_timestamp INTEGER NOT NULL,
+		earliest_heroic_timestamp INTEGER NOT NULL,
+		last_seen_name TEXT,
+		last_seen_realm_slug TEXT,
+		last_seen_timestamp INTEGER,
+		first_run_timestamp INTEGER,
+		created_at INTEGER
+	)`,
 
-	// Migrate seasons schema if needed (add region column)
-	if err := migrateSeasonsAddRegion(db); err != nil {
-		return err
-	}
+	// Player aggregation and rankings (season-scoped)
+	`CREATE TABLE IF NOT EXISTS player_profiles (
+		player_id INTEGER,
+		season_id INTEGER NOT NULL,
+		name TEXT,
+		realm_id INTEGER,
+		main_spec_id INTEGER,
+		class_name TEXT,
+		dungeons_completed INTEGER DEFAULT 0,
+		total_runs INTEGER DEFAULT 0,
+		combined_best_time INTEGER,
+		average_best_time INTEGER,
+		global_ranking INTEGER,
+		regional_ranking INTEGER,
+		realm_ranking INTEGER,
+		global_ranking_bracket TEXT,
+		regional_ranking_bracket TEXT,
+		realm_ranking_bracket TEXT,
+		global_class_rank INTEGER,
+		region_class_rank INTEGER,
+		realm_class_rank INTEGER,
+		global_class_bracket TEXT,
+		region_class_bracket TEXT,
+		realm_class_bracket TEXT,
+		has_complete_coverage INTEGER DEFAULT 0,
+		last_updated INTEGER,
+		PRIMARY KEY (player_id, season_id)
+	)`,
 
-	// Ensure players table has identity/status columns
-	if err := migratePlayersIdentityColumns(db); err != nil {
-		return err
-	}
+	`CREATE TABLE IF NOT EXISTS player_best_runs (
+		player_id INTEGER,
+		dungeon_id INTEGER,
+		run_id INTEGER,
+		duration INTEGER,
+		season_id INTEGER NOT NULL,
+		global_ranking INTEGER,
+		global_ranking_filtered INTEGER,
+		regional_ranking INTEGER,
+		realm_ranking INTEGER,
+		regional_ranking_filtered INTEGER,
+		realm_ranking_filtered INTEGER,
+		percentile_bracket TEXT,
+		global_percentile_bracket TEXT,
+		regional_percentile_bracket TEXT,
+		realm_percentile_bracket TEXT,
+		completed_timestamp INTEGER,
+		PRIMARY KEY (player_id, dungeon_id, season_id)
+	)`,
 
-	// Migrate player_rankings to add PRIMARY KEY constraint
-	if err := migratePlayerRankingsPrimaryKey(db); err != nil {
-		return err
-	}
+	`CREATE TABLE IF NOT EXISTS player_rankings (
+		player_id INTEGER NOT NULL,
+		ranking_type TEXT NOT NULL,
+		ranking_scope TEXT NOT NULL,
+		ranking INTEGER,
+		combined_best_time INTEGER,
+		last_updated INTEGER,
+		PRIMARY KEY (player_id, ranking_type, ranking_scope)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS player_seasonal_rankings (
+		player_id INTEGER,
+		season_id INTEGER NOT NULL,
+		dungeons_completed INTEGER DEFAULT 0,
+		combined_best_time INTEGER,
+		global_ranking INTEGER,
+		regional_ranking INTEGER,
+		realm_ranking INTEGER,
+		global_ranking_bracket TEXT,
+		regional_ranking_bracket TEXT,
+		realm_ranking_bracket TEXT,
+		last_updated INTEGER,
+		PRIMARY KEY (player_id, season_id)
+	)`,
+
+	// Extended player information
+	`CREATE TABLE IF NOT EXISTS player_details (
+		player_id INTEGER PRIMARY KEY,
+		race_id INTEGER,
+		race_name TEXT,
+		gender TEXT,
+		class_id INTEGER,
+		class_name TEXT,
+		active_spec_id INTEGER,
+		active_spec_name TEXT,
+		guild_name TEXT,
+		level INTEGER,
+		average_item_level INTEGER,
+		equipped_item_level INTEGER,
+		avatar_url TEXT,
+		last_login_timestamp INTEGER,
+		last_updated INTEGER
+	)`,
+
+	// Equipment system
+	`CREATE TABLE IF NOT EXISTS player_equipment (
+		id INTEGER PRIMARY KEY,
+		player_id INTEGER,
+		slot_type TEXT,
+		item_id INTEGER,
+		upgrade_id INTEGER,
+		quality TEXT,
+		item_name TEXT,
+		snapshot_timestamp INTEGER
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS player_equipment_enchantments (
+		id INTEGER PRIMARY KEY,
+		equipment_id INTEGER,
+		enchantment_id INTEGER,
+		slot_id INTEGER,
+		slot_type TEXT,
+		display_string TEXT,
+		source_item_id INTEGER,
+		source_item_name TEXT,
+		spell_id INTEGER
+	)`,
 
-	// Create indexes
-	return ensureRecommendedIndexes(db)
+	// Computed rankings
+	`CREATE TABLE IF NOT EXISTS run_rankings (
+		run_id INTEGER,
+		dungeon_id INTEGER,
+		ranking_type TEXT,
+		ranking_scope TEXT,
+		ranking INTEGER,
+		percentile_bracket TEXT,
+		season_id INTEGER NOT NULL,
+		computed_at INTEGER,
+		PRIMARY KEY (run_id, ranking_type, ranking_scope, season_id)
+	)`,
+
+	// Metadata and items
+	`CREATE TABLE IF NOT EXISTS api_fetch_metadata (
+		id INTEGER PRIMARY KEY,
+		fetch_type TEXT UNIQUE,
+		last_fetch_timestamp INTEGER,
+		last_successful_fetch INTEGER,
+		runs_fetched INTEGER DEFAULT 0,
+		players_fetched INTEGER DEFAULT 0
+	)`,
+	// Incremental markers used by fetchers/batch processing
+	`CREATE TABLE IF NOT EXISTS api_fetch_markers (
+		realm_slug TEXT NOT NULL,
+		dungeon_id INTEGER NOT NULL,
+		period_id INTEGER NOT NULL,
+		last_completed_ts INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (realm_slug, dungeon_id, period_id)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS fetch_status (
+		region TEXT NOT NULL,
+		realm_slug TEXT NOT NULL,
+		dungeon_id INTEGER NOT NULL,
+		period_id INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		http_status INTEGER,
+		checked_at INTEGER NOT NULL,
+		message TEXT,
+		PRIMARY KEY (region, realm_slug, dungeon_id, period_id)
+	)`,
+
+	// HTTP validators (ETag/Last-Modified) for conditional leaderboard requests
+	`CREATE TABLE IF NOT EXISTS leaderboard_validators (
+		region TEXT NOT NULL,
+		realm_slug TEXT NOT NULL,
+		dungeon_id INTEGER NOT NULL,
+		period_id INTEGER NOT NULL,
+		etag TEXT,
+		last_modified TEXT,
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (region, realm_slug, dungeon_id, period_id)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS items (
+		id INTEGER PRIMARY KEY,
+		name TEXT,
+		icon TEXT,
+		quality INTEGER,
+		type INTEGER,
+		stats TEXT
+	)`,
+
+	// Season tables
+	`CREATE TABLE IF NOT EXISTS seasons (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		season_number INTEGER NOT NULL,
+		region TEXT NOT NULL,
+		start_timestamp INTEGER,
+		end_timestamp INTEGER,
+		season_name TEXT,
+		first_period_id INTEGER,
+		last_period_id INTEGER,
+		UNIQUE(season_number, region)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS period_seasons (
+		period_id INTEGER,
+		season_id INTEGER,
+		PRIMARY KEY (period_id, season_id)
+	)`,
 }
 
-// ensureRecommendedIndexes creates indexes used by hot paths if missing
-func ensureRecommendedIndexes(db *sql.DB) error {
-	stmts := []string{
-		// Ensure composite uniqueness for realms
-		"CREATE UNIQUE INDEX IF NOT EXISTS idx_realms_region_slug ON realms(region, slug)",
-		// Fast path for high-water checks
-		"CREATE INDEX IF NOT EXISTS idx_runs_realm_dungeon_ct ON challenge_runs(realm_id, dungeon_id, completed_timestamp)",
-		// Uniqueness key to avoid duplicates
-		"CREATE UNIQUE INDEX IF NOT EXISTS idx_runs_unique ON challenge_runs(completed_timestamp, dungeon_id, duration, realm_id, team_signature)",
-		// Lookups used elsewhere
-		"CREATE INDEX IF NOT EXISTS idx_players_name_lower ON players(name_lower)",
-		"CREATE INDEX IF NOT EXISTS idx_players_blizzard_id ON players(blizzard_character_id)",
-		"CREATE INDEX IF NOT EXISTS idx_players_status_checked ON players(status_checked_at)",
-		"CREATE UNIQUE INDEX IF NOT EXISTS idx_run_members_pair ON run_members(run_id, player_id)",
-		"CREATE INDEX IF NOT EXISTS idx_fetch_status_realm ON fetch_status(region, realm_slug)",
-		"CREATE INDEX IF NOT EXISTS idx_fetch_status_dungeon ON fetch_status(dungeon_id)",
-		"CREATE UNIQUE INDEX IF NOT EXISTS idx_player_fingerprints_hash ON player_fingerprints(fingerprint_hash)",
-		// Speed up canonical run selection: partition/order by team per dungeon
-		"CREATE INDEX IF NOT EXISTS idx_runs_dungeon_team_duration ON challenge_runs(dungeon_id, team_signature, duration, completed_timestamp, id)",
-		// Additional indexes for performance
-		"CREATE INDEX IF NOT EXISTS idx_run_members_player_id ON run_members(player_id)",
-		"CREATE INDEX IF NOT EXISTS idx_challenge_runs_dungeon_duration ON challenge_runs(dungeon_id, duration)",
-		// Season-related indexes
-		"CREATE INDEX IF NOT EXISTS idx_challenge_runs_season ON challenge_runs(season_id, dungeon_id, completed_timestamp)",
-		"CREATE INDEX IF NOT EXISTS idx_run_rankings_season ON run_rankings(season_id, ranking_type, ranking_scope, dungeon_id)",
-		"CREATE INDEX IF NOT EXISTS idx_player_best_runs_season ON player_best_runs(season_id, player_id)",
-		"CREATE INDEX IF NOT EXISTS idx_player_profiles_season ON player_profiles(season_id, global_ranking)",
-		"CREATE INDEX IF NOT EXISTS idx_player_profiles_season_coverage ON player_profiles(season_id, has_complete_coverage, combined_best_time)",
-		// Player rankings indexes
-		"CREATE INDEX IF NOT EXISTS idx_player_rankings_scope ON player_rankings(ranking_type, ranking_scope)",
-		"CREATE INDEX IF NOT EXISTS idx_player_rankings_player ON player_rankings(player_id)",
-	}
-	for _, s := range stmts {
-		if _, err := db.Exec(s); err != nil {
-			// don't fail the connection on index creation errors
-			fmt.Printf("Warning: index creation failed: %v\n", err)
+// recommendedIndexes are indexes used by hot paths
+var recommendedIndexes = []string{
+	// Ensure composite uniqueness for realms
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_realms_region_slug ON realms(region, slug)",
+	// Fast path for high-water checks
+	"CREATE INDEX IF NOT EXISTS idx_runs_realm_dungeon_ct ON challenge_runs(realm_id, dungeon_id, completed_timestamp)",
+	// Uniqueness key to avoid duplicates
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_runs_unique ON challenge_runs(completed_timestamp, dungeon_id, duration, realm_id, team_signature)",
+	// Lookups used elsewhere
+	"CREATE INDEX IF NOT EXISTS idx_players_name_lower ON players(name_lower)",
+	"CREATE INDEX IF NOT EXISTS idx_players_blizzard_id ON players(blizzard_character_id)",
+	"CREATE INDEX IF NOT EXISTS idx_players_status_checked ON players(status_checked_at)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_run_members_pair ON run_members(run_id, player_id)",
+	"CREATE INDEX IF NOT EXISTS idx_fetch_status_realm ON fetch_status(region, realm_slug)",
+	"CREATE INDEX IF NOT EXISTS idx_fetch_status_dungeon ON fetch_status(dungeon_id)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_player_fingerprints_hash ON player_fingerprints(fingerprint_hash)",
+	// Speed up canonical run selection: partition/order by team per dungeon
+	"CREATE INDEX IF NOT EXISTS idx_runs_dungeon_team_duration ON challenge_runs(dungeon_id, team_signature, duration, completed_timestamp, id)",
+	// Additional indexes for performance
+	"CREATE INDEX IF NOT EXISTS idx_run_members_player_id ON run_members(player_id)",
+	"CREATE INDEX IF NOT EXISTS idx_challenge_runs_dungeon_duration ON challenge_runs(dungeon_id, duration)",
+	// Season-related indexes
+	"CREATE INDEX IF NOT EXISTS idx_challenge_runs_season ON challenge_runs(season_id, dungeon_id, completed_timestamp)",
+	"CREATE INDEX IF NOT EXISTS idx_run_rankings_season ON run_rankings(season_id, ranking_type, ranking_scope, dungeon_id)",
+	"CREATE INDEX IF NOT EXISTS idx_player_best_runs_season ON player_best_runs(season_id, player_id)",
+	"CREATE INDEX IF NOT EXISTS idx_player_profiles_season ON player_profiles(season_id, global_ranking)",
+	"CREATE INDEX IF NOT EXISTS idx_player_profiles_season_coverage ON player_profiles(season_id, has_complete_coverage, combined_best_time)",
+	// Player rankings indexes
+	"CREATE INDEX IF NOT EXISTS idx_player_rankings_scope ON player_rankings(ranking_type, ranking_scope)",
+	"CREATE INDEX IF NOT EXISTS idx_player_rankings_player ON player_rankings(player_id)",
+}
+
+// dropIndexStatements derives DROP INDEX statements for a list of CREATE INDEX statements
+func dropIndexStatements(creates []string) []string {
+	drops := make([]string, 0, len(creates))
+	for i := len(creates) - 1; i >= 0; i-- {
+		fields := strings.Fields(creates[i])
+		for j, f := range fields {
+			if strings.EqualFold(f, "ON") && j > 0 {
+				drops = append(drops, "DROP INDEX IF EXISTS "+fields[j-1])
+				break
+			}
 		}
 	}
-
-	fmt.Printf("[OK] All indexes ensured\n")
-	return nil
+	return drops
 }
@@ -0,0 +1,451 @@
+package database
+
+// postgresMigrations mirrors schemaMigrations version for version. Integer
+// columns are BIGINT because ids and millisecond timestamps overflow INTEGER, and
+// ids SQLite assigned through rowid aliasing become identity columns.
+var postgresMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline_tables",
+		Up:      postgresBaselineTables,
+	},
+	{
+		// Legacy table shapes only ever existed in SQLite
+		Version: 2,
+		Name:    "upgrade_legacy_tables",
+	},
+	{
+		Version: 3,
+		Name:    "recommended_indexes",
+		Up:      recommendedIndexes,
+		Down:    dropIndexStatements(recommendedIndexes),
+	},
+	{
+		Version: 4,
+		Name:    "periods",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS periods (
+				id BIGINT NOT NULL,
+				region TEXT NOT NULL,
+				start_timestamp BIGINT,
+				end_timestamp BIGINT,
+				season_id BIGINT,
+				PRIMARY KEY (id, region)
+			)`,
+			"CREATE INDEX IF NOT EXISTS idx_periods_region_start ON periods(region, start_timestamp)",
+		},
+		Down: []string{
+			"DROP INDEX IF EXISTS idx_periods_region_start",
+			"DROP TABLE IF EXISTS periods",
+		},
+	},
+	{
+		Version: 5,
+		Name:    "fetch_status_cutoff_duration",
+		Up:      []string{"ALTER TABLE fetch_status ADD COLUMN IF NOT EXISTS cutoff_duration BIGINT"},
+		Down:    []string{"ALTER TABLE fetch_status DROP COLUMN IF EXISTS cutoff_duration"},
+	},
+	{
+		Version: 6,
+		Name:    "run_sightings",
+		Up: append([]string{
+			`CREATE TABLE IF NOT EXISTS run_sightings (
+				run_id BIGINT NOT NULL,
+				realm_id BIGINT NOT NULL,
+				first_seen_at BIGINT,
+				PRIMARY KEY (run_id, realm_id)
+			)`,
+		}, mergeRealmDuplicateRuns...),
+		// irreversible: merged runs keep one row, so dropping run_sightings would
+		// lose every realm they were seen on but the canonical one
+	},
+	{
+		Version: 7,
+		Name:    "run_period_sightings",
+		Up: append([]string{
+			`CREATE TABLE IF NOT EXISTS run_period_sightings (
+				run_id BIGINT NOT NULL,
+				realm_id BIGINT NOT NULL,
+				dungeon_id BIGINT NOT NULL,
+				period_id BIGINT NOT NULL,
+				first_seen_at BIGINT,
+				last_seen_at BIGINT,
+				PRIMARY KEY (run_id, realm_id, period_id)
+			)`,
+		}, runPeriodSightingsIndexes...),
+		Down: runPeriodSightingsDown,
+	},
+	{
+		Version: 8,
+		Name:    "ranking_dirty_partitions",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS ranking_dirty_partitions (
+				season_id BIGINT NOT NULL,
+				dungeon_id BIGINT NOT NULL,
+				region TEXT NOT NULL,
+				realm_id BIGINT NOT NULL,
+				marked_at BIGINT NOT NULL,
+				PRIMARY KEY (season_id, dungeon_id, region, realm_id)
+			)`,
+			`CREATE TABLE IF NOT EXISTS ranking_dirty_players (
+				player_id BIGINT NOT NULL PRIMARY KEY,
+				marked_at BIGINT NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS ranking_state (
+				id BIGINT NOT NULL PRIMARY KEY,
+				reference_signature TEXT NOT NULL,
+				full_at BIGINT,
+				incremental_at BIGINT
+			)`,
+		},
+		Down: rankingDirtyPartitionsDown,
+	},
+	{
+		Version: 9,
+		Name:    "player_ranking_history",
+		Up: append([]string{
+			`CREATE TABLE IF NOT EXISTS player_ranking_history (
+				player_id BIGINT NOT NULL,
+				season_id BIGINT NOT NULL,
+				recorded_at BIGINT NOT NULL,
+				combined_best_time BIGINT,
+				global_ranking BIGINT,
+				regional_ranking BIGINT,
+				realm_ranking BIGINT,
+				global_class_rank BIGINT,
+				region_class_rank BIGINT,
+				realm_class_rank BIGINT,
+				PRIMARY KEY (player_id, season_id, recorded_at)
+			)`,
+		}, playerRankingHistorySeed...),
+		Down: []string{"DROP TABLE IF EXISTS player_ranking_history"},
+	},
+	{
+		Version: 10,
+		Name:    "equipment_history",
+		Up: []string{
+			"ALTER TABLE player_equipment ADD COLUMN IF NOT EXISTS item_level BIGINT",
+			"CREATE INDEX IF NOT EXISTS idx_player_equipment_slot_ts ON player_equipment(player_id, slot_type, snapshot_timestamp)",
+			`CREATE TABLE IF NOT EXISTS equipment_retention (
+				id BIGINT NOT NULL PRIMARY KEY,
+				pruned_before BIGINT NOT NULL,
+				pruned_at BIGINT
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE IF EXISTS equipment_retention",
+			"DROP INDEX IF EXISTS idx_player_equipment_slot_ts",
+			"ALTER TABLE player_equipment DROP COLUMN IF EXISTS item_level",
+		},
+	},
+	{
+		Version: 11,
+		Name:    "build_stages",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS build_stages (
+				stage TEXT NOT NULL PRIMARY KEY,
+				input_hash TEXT NOT NULL,
+				started_at BIGINT NOT NULL,
+				completed_at BIGINT,
+				duration_ms BIGINT
+			)`,
+		},
+		Down: []string{"DROP TABLE IF EXISTS build_stages"},
+	},
+	{
+		Version: 12,
+		Name:    "generation_tracking",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS generation_dirty_partitions (
+				season_id BIGINT NOT NULL,
+				dungeon_id BIGINT NOT NULL,
+				region TEXT NOT NULL,
+				realm_id BIGINT NOT NULL,
+				marked_at BIGINT NOT NULL,
+				PRIMARY KEY (season_id, dungeon_id, region, realm_id)
+			)`,
+			`CREATE TABLE IF NOT EXISTS generation_dirty_players (
+				player_id BIGINT NOT NULL PRIMARY KEY,
+				marked_at BIGINT NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS generation_state (
+				id BIGINT NOT NULL PRIMARY KEY,
+				signature TEXT NOT NULL,
+				watermark BIGINT NOT NULL,
+				full_at BIGINT,
+				incremental_at BIGINT
+			)`,
+			`CREATE TABLE IF NOT EXISTS generated_player_files (
+				player_id BIGINT NOT NULL PRIMARY KEY,
+				path TEXT NOT NULL
+			)`,
+		},
+		Down: generationTrackingDown,
+	},
+}
+
+var postgresBaselineTables = []string{
+	// Reference tables
+	`CREATE TABLE IF NOT EXISTS dungeons (
+		id BIGINT PRIMARY KEY,
+		slug TEXT UNIQUE,
+		name TEXT,
+		map_id BIGINT,
+		map_challenge_mode_id BIGINT UNIQUE
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS realms (
+            id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+            slug TEXT,
+            name TEXT,
+            region TEXT,
+            connected_realm_id BIGINT UNIQUE,
+            parent_realm_slug TEXT
+        )`,
+
+	// Core leaderboard data
+	`CREATE TABLE IF NOT EXISTS challenge_runs (
+		id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		duration BIGINT,
+		completed_timestamp BIGINT,
+		keystone_level BIGINT DEFAULT 1,
+		dungeon_id BIGINT,
+		realm_id BIGINT,
+		period_id BIGINT,
+		period_start_timestamp BIGINT,
+		period_end_timestamp BIGINT,
+		team_signature TEXT,
+		season_id BIGINT
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS players (
+		id BIGINT PRIMARY KEY,
+		blizzard_character_id BIGINT,
+		name TEXT,
+		name_lower TEXT,
+		realm_id BIGINT,
+		is_valid BIGINT DEFAULT 1,
+		status_checked_at BIGINT
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS run_members (
+		run_id BIGINT,
+		player_id BIGINT,
+		spec_id BIGINT,
+		faction TEXT
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS player_fingerprints (
+		player_id BIGINT PRIMARY KEY,
+		fingerprint_hash TEXT UNIQUE,
+		class_id BIGINT NOT NULL,
+		level85_timestamp BIGINT NOT NULL,
+		level90_timestamp BIGINT NOT NULL,
+		earliest_heroic_timestamp BIGINT NOT NULL,
+		last_seen_name TEXT,
+		last_seen_realm_slug TEXT,
+		last_seen_timestamp BIGINT,
+		first_run_timestamp BIGINT,
+		created_at BIGINT
+	)`,
+
+	// Player aggregation and rankings (season-scoped)
+	`CREATE TABLE IF NOT EXISTS player_profiles (
+		player_id BIGINT,
+		season_id BIGINT NOT NULL,
+		name TEXT,
+		realm_id BIGINT,
+		main_spec_id BIGINT,
+		class_name TEXT,
+		dungeons_completed BIGINT DEFAULT 0,
+		total_runs BIGINT DEFAULT 0,
+		combined_best_time BIGINT,
+		average_best_time BIGINT,
+		global_ranking BIGINT,
+		regional_ranking BIGINT,
+		realm_ranking BIGINT,
+		global_ranking_bracket TEXT,
+		regional_ranking_bracket TEXT,
+		realm_ranking_bracket TEXT,
+		global_class_rank BIGINT,
+		region_class_rank BIGINT,
+		realm_class_rank BIGINT,
+		global_class_bracket TEXT,
+		region_class_bracket TEXT,
+		realm_class_bracket TEXT,
+		has_complete_coverage BIGINT DEFAULT 0,
+		last_updated BIGINT,
+		PRIMARY KEY (player_id, season_id)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS player_best_runs (
+		player_id BIGINT,
+		dungeon_id BIGINT,
+		run_id BIGINT,
+		duration BIGINT,
+		season_id BIGINT NOT NULL,
+		global_ranking BIGINT,
+		global_ranking_filtered BIGINT,
+		regional_ranking BIGINT,
+		realm_ranking BIGINT,
+		regional_ranking_filtered BIGINT,
+		realm_ranking_filtered BIGINT,
+		percentile_bracket TEXT,
+		global_percentile_bracket TEXT,
+		regional_percentile_bracket TEXT,
+		realm_percentile_bracket TEXT,
+		completed_timestamp BIGINT,
+		PRIMARY KEY (player_id, dungeon_id, season_id)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS player_rankings (
+		player_id BIGINT NOT NULL,
+		ranking_type TEXT NOT NULL,
+		ranking_scope TEXT NOT NULL,
+		ranking BIGINT,
+		combined_best_time BIGINT,
+		last_updated BIGINT,
+		PRIMARY KEY (player_id, ranking_type, ranking_scope)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS player_seasonal_rankings (
+		player_id BIGINT,
+		season_id BIGINT NOT NULL,
+		dungeons_completed BIGINT DEFAULT 0,
+		combined_best_time BIGINT,
+		global_ranking BIGINT,
+		regional_ranking BIGINT,
+		realm_ranking BIGINT,
+		global_ranking_bracket TEXT,
+		regional_ranking_bracket TEXT,
+		realm_ranking_bracket TEXT,
+		last_updated BIGINT,
+		PRIMARY KEY (player_id, season_id)
+	)`,
+
+	// Extended player information
+	`CREATE TABLE IF NOT EXISTS player_details (
+		player_id BIGINT PRIMARY KEY,
+		race_id BIGINT,
+		race_name TEXT,
+		gender TEXT,
+		class_id BIGINT,
+		class_name TEXT,
+		active_spec_id BIGINT,
+		active_spec_name TEXT,
+		guild_name TEXT,
+		level BIGINT,
+		average_item_level BIGINT,
+		equipped_item_level BIGINT,
+		avatar_url TEXT,
+		last_login_timestamp BIGINT,
+		last_updated BIGINT
+	)`,
+
+	// Equipment system
+	`CREATE TABLE IF NOT EXISTS player_equipment (
+		id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		player_id BIGINT,
+		slot_type TEXT,
+		item_id BIGINT,
+		upgrade_id BIGINT,
+		quality TEXT,
+		item_name TEXT,
+		snapshot_timestamp BIGINT
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS player_equipment_enchantments (
+		id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		equipment_id BIGINT,
+		enchantment_id BIGINT,
+		slot_id BIGINT,
+		slot_type TEXT,
+		display_string TEXT,
+		source_item_id BIGINT,
+		source_item_name TEXT,
+		spell_id BIGINT
+	)`,
+
+	// Computed rankings
+	`CREATE TABLE IF NOT EXISTS run_rankings (
+		run_id BIGINT,
+		dungeon_id BIGINT,
+		ranking_type TEXT,
+		ranking_scope TEXT,
+		ranking BIGINT,
+		percentile_bracket TEXT,
+		season_id BIGINT NOT NULL,
+		computed_at BIGINT,
+		PRIMARY KEY (run_id, ranking_type, ranking_scope, season_id)
+	)`,
+
+	// Metadata and items
+	`CREATE TABLE IF NOT EXISTS api_fetch_metadata (
+		id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		fetch_type TEXT UNIQUE,
+		last_fetch_timestamp BIGINT,
+		last_successful_fetch BIGINT,
+		runs_fetched BIGINT DEFAULT 0,
+		players_fetched BIGINT DEFAULT 0
+	)`,
+	// Incremental markers used by fetchers/batch processing
+	`CREATE TABLE IF NOT EXISTS api_fetch_markers (
+		realm_slug TEXT NOT NULL,
+		dungeon_id BIGINT NOT NULL,
+		period_id BIGINT NOT NULL,
+		last_completed_ts BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (realm_slug, dungeon_id, period_id)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS fetch_status (
+		region TEXT NOT NULL,
+		realm_slug TEXT NOT NULL,
+		dungeon_id BIGINT NOT NULL,
+		period_id BIGINT NOT NULL,
+		status TEXT NOT NULL,
+		http_status BIGINT,
+		checked_at BIGINT NOT NULL,
+		message TEXT,
+		PRIMARY KEY (region, realm_slug, dungeon_id, period_id)
+	)`,
+
+	// HTTP validators (ETag/Last-Modified) for conditional leaderboard requests
+	`CREATE TABLE IF NOT EXISTS leaderboard_validators (
+		region TEXT NOT NULL,
+		realm_slug TEXT NOT NULL,
+		dungeon_id BIGINT NOT NULL,
+		period_id BIGINT NOT NULL,
+		etag TEXT,
+		last_modified TEXT,
+		updated_at BIGINT NOT NULL,
+		PRIMARY KEY (region, realm_slug, dungeon_id, period_id)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS items (
+		id BIGINT PRIMARY KEY,
+		name TEXT,
+		icon TEXT,
+		quality BIGINT,
+		type BIGINT,
+		stats TEXT
+	)`,
+
+	// Season tables
+	`CREATE TABLE IF NOT EXISTS seasons (
+		id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		season_number BIGINT NOT NULL,
+		region TEXT NOT NULL,
+		start_timestamp BIGINT,
+		end_timestamp BIGINT,
+		season_name TEXT,
+		first_period_id BIGINT,
+		last_period_id BIGINT,
+		UNIQUE(season_number, region)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS period_seasons (
+		period_id BIGINT,
+		season_id BIGINT,
+		PRIMARY KEY (period_id, season_id)
+	)`,
+}
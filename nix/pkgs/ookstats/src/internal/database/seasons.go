@@ -3,6 +3,9 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"io"
+	"os"
+	"time"
 )
 
 // UpsertSeason inserts or updates a season record and returns the auto-increment ID
@@ -40,7 +43,7 @@ func (ds *DatabaseService) UpdateSeasonEndTimestamp(seasonID int, endTimestamp i
 
 // LinkPeriodToSeason creates a mapping between a period and season
 func (ds *DatabaseService) LinkPeriodToSeason(periodID, seasonID int) error {
-	query := `INSERT OR IGNORE INTO period_seasons (period_id, season_id) VALUES (?, ?)`
+	query := `INSERT INTO period_seasons (period_id, season_id) VALUES (?, ?) ON CONFLICT DO NOTHING`
 	_, err := ds.db.Exec(query, periodID, seasonID)
 	return err
 }
@@ -205,13 +208,15 @@ func (ds *DatabaseService) GetPeriodsForSeason(seasonID int) ([]int, error) {
 // GetPeriodsForRegion retrieves all period IDs for all seasons in a given region
 func (ds *DatabaseService) GetPeriodsForRegion(region string) ([]int, error) {
 	query := `
-		SELECT DISTINCT ps.period_id
+		SELECT ps.period_id
 		FROM period_seasons ps
 		JOIN seasons s ON ps.season_id = s.id
 		WHERE s.region = ?
-		ORDER BY ps.period_id DESC
+		UNION
+		SELECT id FROM periods WHERE region = ? AND season_id IS NOT NULL
+		ORDER BY 1 DESC
 	`
-	rows, err := ds.db.Query(query, region)
+	rows, err := ds.db.Query(query, region, region)
 	if err != nil {
 		return nil, err
 	}
@@ -228,9 +233,24 @@ func (ds *DatabaseService) GetPeriodsForRegion(region string) ([]int, error) {
 	return periods, rows.Err()
 }
 
-// GetLatestPeriodsPerRegion retrieves only the latest 2 periods from the current season for a given region
+// GetLatestPeriodsPerRegion retrieves the current and previous period for a region.
+// Periods with synced boundaries are picked by start time; without them it falls back
+// to the two highest period IDs of the current season.
 func (ds *DatabaseService) GetLatestPeriodsPerRegion(region string) ([]int, error) {
-	query := `
+	periods, err := ds.queryPeriodIDs(`
+		SELECT id
+		FROM periods
+		WHERE region = ?
+		  AND start_timestamp IS NOT NULL
+		  AND start_timestamp <= ?
+		ORDER BY start_timestamp DESC
+		LIMIT 2
+	`, region, time.Now().UnixMilli())
+	if err != nil || len(periods) > 0 {
+		return periods, err
+	}
+
+	return ds.queryPeriodIDs(`
 		SELECT DISTINCT ps.period_id
 		FROM period_seasons ps
 		JOIN seasons s ON ps.season_id = s.id
@@ -238,8 +258,11 @@ func (ds *DatabaseService) GetLatestPeriodsPerRegion(region string) ([]int, erro
 		  AND s.end_timestamp IS NULL
 		ORDER BY ps.period_id DESC
 		LIMIT 2
-	`
-	rows, err := ds.db.Query(query, region)
+	`, region)
+}
+
+func (ds *DatabaseService) queryPeriodIDs(query string, args ...any) ([]int, error) {
+	rows, err := ds.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -256,8 +279,18 @@ func (ds *DatabaseService) GetLatestPeriodsPerRegion(region string) ([]int, erro
 	return periods, rows.Err()
 }
 
-// determineSeasonForRunTx determines which season a run belongs to based on timestamp and region
+// determineSeasonForRunTx determines which season a run belongs to from the period
+// containing its completion time, falling back to season start/end timestamps
 func (ds *DatabaseService) determineSeasonForRunTx(tx *sql.Tx, region string, completedTimestamp int64) (int, error) {
+	var seasonNumber int
+	err := tx.QueryRow(periodSeasonQuery, region, completedTimestamp, completedTimestamp).Scan(&seasonNumber)
+	if err == nil {
+		return seasonNumber, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
 	query := `
 		SELECT season_number
 		FROM seasons
@@ -267,39 +300,112 @@ func (ds *DatabaseService) determineSeasonForRunTx(tx *sql.Tx, region string, co
 		ORDER BY start_timestamp DESC
 		LIMIT 1
 	`
-	var seasonNumber int
-	err := tx.QueryRow(query, region, completedTimestamp, completedTimestamp).Scan(&seasonNumber)
+	err = tx.QueryRow(query, region, completedTimestamp, completedTimestamp).Scan(&seasonNumber)
 	if err == sql.ErrNoRows {
 		return 0, nil
 	}
 	return seasonNumber, err
 }
 
-// AssignRunsToSeasons assigns season_id to all challenge_runs based on completed_timestamp
+// periodSeasonQuery finds the season number of the period containing a timestamp
+const periodSeasonQuery = `
+	SELECT s.season_number
+	FROM periods p
+	JOIN seasons s ON s.id = p.season_id
+	WHERE p.region = ?
+	  AND p.start_timestamp <= ?
+	  AND p.end_timestamp > ?
+	LIMIT 1
+`
+
+// runSeasonExpr resolves the season of the challenge_runs row in scope from the period
+// whose boundaries contain completed_timestamp, falling back to the season start/end
+// timestamps. Both parameters are the region.
+const runSeasonExpr = `COALESCE(
+	(
+		SELECT s.season_number
+		FROM periods p
+		JOIN seasons s ON s.id = p.season_id
+		WHERE p.region = ?
+		  AND p.start_timestamp <= challenge_runs.completed_timestamp
+		  AND p.end_timestamp > challenge_runs.completed_timestamp
+		LIMIT 1
+	),
+	(
+		SELECT s.season_number
+		FROM seasons s
+		WHERE s.region = ?
+		  AND s.start_timestamp <= challenge_runs.completed_timestamp
+		  AND (s.end_timestamp IS NULL OR s.end_timestamp > challenge_runs.completed_timestamp)
+		ORDER BY s.start_timestamp DESC
+		LIMIT 1
+	)
+)`
+
+// seasonMovesCTE lists the runs of a region whose season assignment is about to change
+const seasonMovesCTE = `
+	WITH moves AS (
+		SELECT id, dungeon_id, old_season, new_season
+		FROM (
+			SELECT id, dungeon_id, COALESCE(season_id, 0) AS old_season, COALESCE(` + runSeasonExpr + `, 0) AS new_season
+			FROM challenge_runs
+			WHERE realm_id IN (SELECT id FROM realms WHERE region = ?)
+		) assigned
+		WHERE old_season <> new_season
+	)`
+
+// AssignRunsToSeasons assigns season_id to all challenge_runs from the period whose
+// boundaries contain completed_timestamp. Runs outside every synced period fall back
+// to the season start/end timestamps. Runs that move are marked for re-ranking in
+// both their old and new season.
 func (ds *DatabaseService) AssignRunsToSeasons() error {
+	return ds.AssignRunsToSeasonsWithProgress(os.Stdout)
+}
+
+// AssignRunsToSeasonsWithProgress is AssignRunsToSeasons printing its progress to w
+func (ds *DatabaseService) AssignRunsToSeasonsWithProgress(w io.Writer) error {
 	regions := []string{"us", "eu", "kr", "tw"}
 
 	for _, region := range regions {
-		fmt.Printf("Assigning runs to seasons for region: %s\n", region)
+		fmt.Fprintf(w, "Assigning runs to seasons for region: %s\n", region)
+
+		markedAt := time.Now().UnixMilli()
+		if _, err := ds.db.Exec(seasonMovesCTE+`
+			INSERT INTO ranking_dirty_partitions (season_id, dungeon_id, region, realm_id, marked_at)
+			SELECT DISTINCT s.season_id, m.dungeon_id, r.region, rs.realm_id, ?
+			FROM moves m
+			JOIN (
+				SELECT id, old_season AS season_id FROM moves
+				UNION
+				SELECT id, new_season AS season_id FROM moves
+			) s ON s.id = m.id
+			JOIN run_sightings rs ON rs.run_id = m.id
+			JOIN realms r ON r.id = rs.realm_id
+			WHERE true
+			ON CONFLICT(season_id, dungeon_id, region, realm_id) DO UPDATE SET marked_at = excluded.marked_at
+		`, region, region, region, markedAt); err != nil {
+			return fmt.Errorf("failed to mark season moves for region %s: %w", region, err)
+		}
+		if _, err := ds.db.Exec(seasonMovesCTE+`
+			INSERT INTO ranking_dirty_players (player_id, marked_at)
+			SELECT DISTINCT rm.player_id, ?
+			FROM run_members rm
+			JOIN moves m ON m.id = rm.run_id
+			WHERE true
+			ON CONFLICT(player_id) DO UPDATE SET marked_at = excluded.marked_at
+		`, region, region, region, markedAt); err != nil {
+			return fmt.Errorf("failed to mark season moves for region %s: %w", region, err)
+		}
 
 		query := `
 			UPDATE challenge_runs
-			SET season_id = (
-				SELECT s.season_number
-				FROM seasons s
-				JOIN realms r ON r.region = s.region
-				WHERE r.id = challenge_runs.realm_id
-				  AND s.start_timestamp <= challenge_runs.completed_timestamp
-				  AND (s.end_timestamp IS NULL OR s.end_timestamp > challenge_runs.completed_timestamp)
-				ORDER BY s.start_timestamp DESC
-				LIMIT 1
-			)
+			SET season_id = ` + runSeasonExpr + `
 			WHERE realm_id IN (
 				SELECT id FROM realms WHERE region = ?
 			)
 		`
 
-		result, err := ds.db.Exec(query, region)
+		result, err := ds.db.Exec(query, region, region, region)
 		if err != nil {
 			return fmt.Errorf("failed to assign seasons for region %s: %w", region, err)
 		}
@@ -309,7 +415,7 @@ func (ds *DatabaseService) AssignRunsToSeasons() error {
 			return fmt.Errorf("failed to get rows affected for region %s: %w", region, err)
 		}
 
-		fmt.Printf("  Updated %d runs for region %s\n", rowsAffected, region)
+		fmt.Fprintf(w, "  Updated %d runs for region %s\n", rowsAffected, region)
 	}
 
 	var orphanedRuns int
@@ -319,7 +425,7 @@ func (ds *DatabaseService) AssignRunsToSeasons() error {
 	}
 
 	if orphanedRuns > 0 {
-		fmt.Printf("Warning: %d runs could not be assigned to any season\n", orphanedRuns)
+		fmt.Fprintf(w, "Warning: %d runs could not be assigned to any season\n", orphanedRuns)
 	}
 
 	return nil
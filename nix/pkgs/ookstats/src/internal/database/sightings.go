@@ -0,0 +1,192 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"ookstats/internal/blizzard"
+)
+
+// RunSighting is one (realm, dungeon, period) leaderboard a run was seen on
+type RunSighting struct {
+	RunID       int64
+	Region      string
+	RealmSlug   string
+	DungeonSlug string
+	PeriodID    int
+	FirstSeenAt sql.NullInt64
+	LastSeenAt  sql.NullInt64
+}
+
+// LeaderboardSighting is a run seen on a single leaderboard. Dropped is set when
+// the most recent fetch of that leaderboard no longer listed the run.
+type LeaderboardSighting struct {
+	RunID              int64
+	Duration           int
+	CompletedTimestamp int64
+	FirstSeenAt        sql.NullInt64
+	LastSeenAt         sql.NullInt64
+	Dropped            bool
+}
+
+// SightingCoverage summarizes what was seen for one region and period
+type SightingCoverage struct {
+	Region       string
+	PeriodID     int
+	Leaderboards int
+	Runs         int
+	FirstSeenAt  sql.NullInt64
+	LastSeenAt   sql.NullInt64
+}
+
+// GetRunSightings returns every leaderboard a run has been seen on, oldest period first
+func (ds *DatabaseService) GetRunSightings(runID int64) ([]RunSighting, error) {
+	rows, err := ds.db.Query(`
+		SELECT s.run_id, r.region, r.slug, d.slug, s.period_id, s.first_seen_at, s.last_seen_at
+		FROM run_period_sightings s
+		JOIN realms r ON r.id = s.realm_id
+		JOIN dungeons d ON d.id = s.dungeon_id
+		WHERE s.run_id = ?
+		ORDER BY s.period_id, r.region, r.slug
+	`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("query run sightings: %w", err)
+	}
+	defer rows.Close()
+
+	var out []RunSighting
+	for rows.Next() {
+		var s RunSighting
+		if err := rows.Scan(&s.RunID, &s.Region, &s.RealmSlug, &s.DungeonSlug, &s.PeriodID, &s.FirstSeenAt, &s.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("scan run sighting: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// GetLeaderboardSightings returns the runs seen on one realm/dungeon/period leaderboard,
+// fastest first
+func (ds *DatabaseService) GetLeaderboardSightings(region, realmSlug, dungeonSlug string, periodID int) ([]LeaderboardSighting, error) {
+	rows, err := ds.db.Query(`
+		WITH board AS (
+			SELECT s.run_id, s.first_seen_at, s.last_seen_at,
+			       MAX(s.last_seen_at) OVER () AS latest_fetch
+			FROM run_period_sightings s
+			JOIN realms r ON r.id = s.realm_id
+			JOIN dungeons d ON d.id = s.dungeon_id
+			WHERE r.region = ? AND r.slug = ? AND d.slug = ? AND s.period_id = ?
+		)
+		SELECT b.run_id, cr.duration, cr.completed_timestamp, b.first_seen_at, b.last_seen_at,
+		       CASE WHEN b.last_seen_at < b.latest_fetch THEN 1 ELSE 0 END AS dropped
+		FROM board b
+		JOIN challenge_runs cr ON cr.id = b.run_id
+		ORDER BY cr.duration ASC, cr.completed_timestamp ASC
+	`, region, realmSlug, dungeonSlug, periodID)
+	if err != nil {
+		return nil, fmt.Errorf("query leaderboard sightings: %w", err)
+	}
+	defer rows.Close()
+
+	var out []LeaderboardSighting
+	for rows.Next() {
+		var s LeaderboardSighting
+		var dropped int
+		if err := rows.Scan(&s.RunID, &s.Duration, &s.CompletedTimestamp, &s.FirstSeenAt, &s.LastSeenAt, &dropped); err != nil {
+			return nil, fmt.Errorf("scan leaderboard sighting: %w", err)
+		}
+		s.Dropped = dropped == 1
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// GetSightingCoverage returns per-period counts of leaderboards and runs seen,
+// optionally limited to one region
+func (ds *DatabaseService) GetSightingCoverage(region string) ([]SightingCoverage, error) {
+	where := ""
+	args := []any{}
+	if region != "" {
+		where = "WHERE r.region = ?"
+		args = append(args, region)
+	}
+	rows, err := ds.db.Query(fmt.Sprintf(`
+		WITH seen AS (
+			SELECT r.region, s.period_id, s.realm_id, s.dungeon_id, s.run_id, s.first_seen_at, s.last_seen_at
+			FROM run_period_sightings s
+			JOIN realms r ON r.id = s.realm_id
+			%s
+		),
+		boards AS (
+			SELECT region, period_id, COUNT(*) AS leaderboards
+			FROM (SELECT DISTINCT region, period_id, realm_id, dungeon_id FROM seen) b
+			GROUP BY region, period_id
+		)
+		SELECT seen.region, seen.period_id, MAX(boards.leaderboards),
+		       COUNT(DISTINCT seen.run_id), MIN(seen.first_seen_at), MAX(seen.last_seen_at)
+		FROM seen
+		JOIN boards ON boards.region = seen.region AND boards.period_id = seen.period_id
+		GROUP BY seen.region, seen.period_id
+		ORDER BY seen.region, seen.period_id
+	`, where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query sighting coverage: %w", err)
+	}
+	defer rows.Close()
+
+	var out []SightingCoverage
+	for rows.Next() {
+		var c SightingCoverage
+		if err := rows.Scan(&c.Region, &c.PeriodID, &c.Leaderboards, &c.Runs, &c.FirstSeenAt, &c.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("scan sighting coverage: %w", err)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// CountSightingsSince splits the runs seen at or after since into runs seen for the
+// first time and re-sightings of runs already known from an earlier fetch
+func (ds *DatabaseService) CountSightingsSince(since int64) (newRuns, resighted int, err error) {
+	err = ds.db.QueryRow(`
+		SELECT
+			COUNT(CASE WHEN first_seen >= ? THEN 1 END),
+			COUNT(CASE WHEN first_seen < ? THEN 1 END)
+		FROM (
+			SELECT run_id, COALESCE(MIN(first_seen_at), 0) AS first_seen
+			FROM run_period_sightings
+			GROUP BY run_id
+			HAVING MAX(last_seen_at) >= ?
+		) seen
+	`, since, since, since).Scan(&newRuns, &resighted)
+	if err != nil {
+		return 0, 0, fmt.Errorf("count sightings: %w", err)
+	}
+	return newRuns, resighted, nil
+}
+
+// touchUnchangedLeaderboard extends the sightings of a leaderboard that answered 304:
+// every run listed by its previous fetch is still listed. Runs that had already
+// dropped off keep their older last_seen_at.
+func (ds *DatabaseService) touchUnchangedLeaderboard(res blizzard.FetchResult, seenAt int64) error {
+	periodID := parsePeriodID(res)
+	if periodID <= 0 {
+		return nil
+	}
+	realmID, err := ds.GetRealmIDByRegionAndSlug(res.RealmInfo.Region, res.RealmInfo.Slug)
+	if err != nil || realmID == 0 {
+		return err
+	}
+	return retryOnBusy(func() error {
+		_, err := ds.db.Exec(`
+			UPDATE run_period_sightings
+			SET last_seen_at = ?
+			WHERE realm_id = ? AND dungeon_id = ? AND period_id = ?
+			  AND COALESCE(last_seen_at, 0) = (
+				SELECT COALESCE(MAX(prev.last_seen_at), 0) FROM run_period_sightings prev
+				WHERE prev.realm_id = ? AND prev.dungeon_id = ? AND prev.period_id = ?
+			  )
+		`, seenAt, realmID, res.Dungeon.ID, periodID, realmID, res.Dungeon.ID, periodID)
+		return err
+	})
+}
@@ -0,0 +1,426 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotManifestSuffix and snapshotChecksumSuffix name the files written next to
+// every snapshot. The checksum file uses the sha256sum format so `sha256sum -c`
+// keeps working on it.
+const (
+	snapshotManifestSuffix = ".manifest.json"
+	snapshotChecksumSuffix = ".sha256"
+)
+
+// SnapshotManifest describes a database snapshot: where it came from, its checksum
+// and what it contained when it was taken
+type SnapshotManifest struct {
+	File          string           `json:"file"`
+	Source        string           `json:"source"`
+	CreatedAt     string           `json:"created_at"`
+	SHA256        string           `json:"sha256"`
+	SizeBytes     int64            `json:"size_bytes"`
+	SchemaVersion int              `json:"schema_version"`
+	Tables        map[string]int64 `json:"tables"`
+}
+
+// SnapshotVerification is the result of checking a snapshot against its manifest.
+// Problems is empty when the snapshot is intact.
+type SnapshotVerification struct {
+	Manifest *SnapshotManifest
+	SHA256   string
+	Problems []string
+}
+
+// OK reports whether the snapshot matched its manifest
+func (v *SnapshotVerification) OK() bool {
+	return len(v.Problems) == 0
+}
+
+// SnapshotManifestPath returns the manifest path of a snapshot file
+func SnapshotManifestPath(snapshotPath string) string {
+	return snapshotPath + snapshotManifestSuffix
+}
+
+// Snapshot writes a consistent copy of the connected SQLite database into dir
+// using VACUUM INTO, which reads inside a single transaction and so is safe while
+// a fetch is writing. The copy is followed by a checksum file and a manifest with
+// per-table row counts and the schema version.
+func Snapshot(db *sql.DB, dir string) (string, *SnapshotManifest, error) {
+	if DialectOf(db) != SQLite {
+		return "", nil, fmt.Errorf("snapshots are only supported for the SQLite backend")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", nil, fmt.Errorf("mkdir %s: %w", dir, err)
+	}
+
+	createdAt := time.Now().UTC()
+	name := "ookstats-" + createdAt.Format("20060102T150405Z") + ".db"
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return "", nil, fmt.Errorf("snapshot %s already exists", path)
+	}
+
+	// VACUUM INTO refuses to overwrite, so write under a temporary name and
+	// rename once complete; a crash never leaves a half-written snapshot
+	tmp := filepath.Join(dir, ".tmp-"+name)
+	_ = os.Remove(tmp)
+	if _, err := db.Exec(`VACUUM INTO ?`, tmp); err != nil {
+		os.Remove(tmp)
+		return "", nil, fmt.Errorf("vacuum into %s: %w", tmp, err)
+	}
+
+	manifest, err := describeSnapshotFile(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return "", nil, err
+	}
+	manifest.File = name
+	manifest.Source = DBFilePath()
+	manifest.CreatedAt = createdAt.Format(time.RFC3339)
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", nil, fmt.Errorf("rename %s: %w", path, err)
+	}
+	if err := writeSnapshotSidecars(path, manifest); err != nil {
+		return "", nil, err
+	}
+	return path, manifest, nil
+}
+
+// VerifySnapshot recomputes a snapshot's checksum, runs SQLite's integrity check
+// and compares the row counts and schema version with the manifest
+func VerifySnapshot(path string) (*SnapshotVerification, error) {
+	manifest, err := ReadSnapshotManifest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &SnapshotVerification{Manifest: manifest}
+	sum, _, err := fileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+	res.SHA256 = sum
+	if sum != manifest.SHA256 {
+		res.Problems = append(res.Problems, fmt.Sprintf("checksum mismatch: manifest %s, file %s", manifest.SHA256, sum))
+		// a file that doesn't match its checksum isn't worth opening
+		return res, nil
+	}
+
+	ro, err := openSnapshotReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	defer ro.Close()
+
+	rows, err := ro.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("integrity check: %w", err)
+	}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan integrity check: %w", err)
+		}
+		if line != "ok" {
+			res.Problems = append(res.Problems, "integrity: "+line)
+		}
+	}
+	rows.Close()
+
+	version, err := snapshotSchemaVersion(ro)
+	if err != nil {
+		return nil, err
+	}
+	if version != manifest.SchemaVersion {
+		res.Problems = append(res.Problems, fmt.Sprintf("schema version: manifest %d, file %d", manifest.SchemaVersion, version))
+	}
+
+	counts, err := tableRowCounts(ro)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range sortedKeys(manifest.Tables) {
+		got, ok := counts[table]
+		if !ok {
+			res.Problems = append(res.Problems, fmt.Sprintf("table %s: missing", table))
+		} else if got != manifest.Tables[table] {
+			res.Problems = append(res.Problems, fmt.Sprintf("table %s: manifest %d rows, file %d", table, manifest.Tables[table], got))
+		}
+	}
+	for _, table := range sortedKeys(counts) {
+		if _, ok := manifest.Tables[table]; !ok {
+			res.Problems = append(res.Problems, fmt.Sprintf("table %s: not in manifest", table))
+		}
+	}
+	return res, nil
+}
+
+// RestoreSnapshot verifies a snapshot and atomically replaces the SQLite database
+// at target with it. The copy is written and synced beside target, then renamed
+// over it, so target is always either the old or the new database. target must not
+// be open by another ookstats process.
+func RestoreSnapshot(snapshotPath, target string) (*SnapshotVerification, error) {
+	verification, err := VerifySnapshot(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	if !verification.OK() {
+		return verification, fmt.Errorf("snapshot %s failed verification", snapshotPath)
+	}
+
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir %s: %w", dir, err)
+	}
+	tmpFile, err := os.CreateTemp(dir, ".restore-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("create temp in %s: %w", dir, err)
+	}
+	tmp := tmpFile.Name()
+	if err := copySnapshotInto(tmpFile, snapshotPath, verification.SHA256); err != nil {
+		tmpFile.Close()
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("close temp %s: %w", tmp, err)
+	}
+
+	// fold the current WAL into target before swapping; a leftover -wal file
+	// would otherwise be replayed on top of the restored database
+	if _, err := os.Stat(target); err == nil {
+		if err := checkpointAndClose(target); err != nil {
+			os.Remove(tmp)
+			return nil, err
+		}
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(target + suffix); err != nil && !os.IsNotExist(err) {
+			os.Remove(tmp)
+			return nil, fmt.Errorf("remove %s: %w", target+suffix, err)
+		}
+	}
+
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("rename %s: %w", target, err)
+	}
+	return verification, nil
+}
+
+// ListSnapshots returns the snapshots in dir that have a manifest, oldest first
+func ListSnapshots(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "ookstats-*.db"))
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, m := range matches {
+		if _, err := os.Stat(SnapshotManifestPath(m)); err == nil {
+			out = append(out, m)
+		}
+	}
+	// names embed a sortable UTC timestamp
+	sort.Strings(out)
+	return out, nil
+}
+
+// RemoveSnapshot deletes a snapshot together with its checksum and manifest
+func RemoveSnapshot(path string) error {
+	for _, p := range []string{path, path + snapshotChecksumSuffix, SnapshotManifestPath(path)} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadSnapshotManifest loads the manifest stored next to a snapshot
+func ReadSnapshotManifest(snapshotPath string) (*SnapshotManifest, error) {
+	data, err := os.ReadFile(SnapshotManifestPath(snapshotPath))
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	var m SnapshotManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest %s: %w", SnapshotManifestPath(snapshotPath), err)
+	}
+	return &m, nil
+}
+
+// describeSnapshotFile hashes a freshly written snapshot and records its contents.
+// Counting the copy rather than the live database keeps the manifest exact while
+// writers keep going.
+func describeSnapshotFile(path string) (*SnapshotManifest, error) {
+	sum, size, err := fileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+	ro, err := openSnapshotReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	defer ro.Close()
+
+	version, err := snapshotSchemaVersion(ro)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := tableRowCounts(ro)
+	if err != nil {
+		return nil, err
+	}
+	return &SnapshotManifest{
+		SHA256:        sum,
+		SizeBytes:     size,
+		SchemaVersion: version,
+		Tables:        counts,
+	}, nil
+}
+
+func writeSnapshotSidecars(path string, manifest *SnapshotManifest) error {
+	checksum := fmt.Sprintf("%s  %s\n", manifest.SHA256, filepath.Base(path))
+	if err := os.WriteFile(path+snapshotChecksumSuffix, []byte(checksum), 0o644); err != nil {
+		return fmt.Errorf("write checksum: %w", err)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode manifest: %w", err)
+	}
+	if err := os.WriteFile(SnapshotManifestPath(path), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+func openSnapshotReadOnly(path string) (*sql.DB, error) {
+	db, err := sql.Open("libsql", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// snapshotSchemaVersion reads the schema version without creating the
+// migrations table, which SchemaVersion would do on a read-only file
+func snapshotSchemaVersion(db *sql.DB) (int, error) {
+	var exists int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("query schema version: %w", err)
+	}
+	if exists == 0 {
+		return 0, nil
+	}
+	var v sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&v); err != nil {
+		return 0, fmt.Errorf("query schema version: %w", err)
+	}
+	return int(v.Int64), nil
+}
+
+func tableRowCounts(db *sql.DB) (map[string]int64, error) {
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan table name: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	counts := make(map[string]int64, len(tables))
+	for _, t := range tables {
+		var n int64
+		if err := db.QueryRow(`SELECT COUNT(*) FROM "` + strings.ReplaceAll(t, `"`, `""`) + `"`).Scan(&n); err != nil {
+			return nil, fmt.Errorf("count %s: %w", t, err)
+		}
+		counts[t] = n
+	}
+	return counts, nil
+}
+
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// copySnapshotInto copies the snapshot into dst, checking the bytes written against
+// the verified checksum so a file changed after verification is never installed
+func copySnapshotInto(dst *os.File, snapshotPath, wantSHA256 string) error {
+	src, err := os.Open(snapshotPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dst, h), src); err != nil {
+		return fmt.Errorf("copy %s: %w", snapshotPath, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != wantSHA256 {
+		return fmt.Errorf("snapshot %s changed during restore", snapshotPath)
+	}
+	if err := dst.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %w", dst.Name(), err)
+	}
+	return nil
+}
+
+func checkpointAndClose(path string) error {
+	db, err := sql.Open("libsql", "file:"+path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer db.Close()
+	var busy, logFrames, checkpointed int
+	if err := db.QueryRow(`PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &logFrames, &checkpointed); err != nil {
+		return fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	if busy != 0 {
+		return fmt.Errorf("database %s is in use; stop other ookstats processes before restoring", path)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
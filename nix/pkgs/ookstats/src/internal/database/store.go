@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"ookstats/internal/blizzard"
+)
+
+// Store is the persistence surface the fetch and processing pipeline depends on.
+// DatabaseService implements it for both libsql and PostgreSQL; the few statements
+// that differ between them are chosen from the connection's Dialect.
+type Store interface {
+	RealmStore
+	RunStore
+	PlayerStore
+	RankingStore
+	SeasonStore
+	FetchStatusStore
+	SightingStore
+	BuildStageStore
+
+	// DB exposes the handle the set-based ranking and generation queries run on. They
+	// stick to SQL both backends accept; the Postgres integration test runs them.
+	DB() *sql.DB
+	Dialect() Dialect
+}
+
+// RealmStore covers the realm registry and dungeon reference data
+type RealmStore interface {
+	UpsertRealms(realms []blizzard.RealmInfo) (added, updated int, err error)
+	GetRealms() (map[string]blizzard.RealmInfo, error)
+	GetRealmIDByRegionAndSlug(region, slug string) (int, error)
+	GetRealmPoolIDs(region, slug string) ([]int, error)
+	GetConnectedRealmSlugs(region, realmSlug string) ([]string, error)
+	EnsureDungeonsOnce(dungeons []blizzard.DungeonInfo) error
+	MergeDungeons(dungeons []blizzard.DungeonInfo) (int, error)
+	GetDungeons() ([]blizzard.DungeonInfo, error)
+}
+
+// RunStore covers leaderboard ingestion
+type RunStore interface {
+	InsertLeaderboardData(leaderboard *blizzard.LeaderboardResponse, realmInfo blizzard.RealmInfo, dungeon blizzard.DungeonInfo) (int, int, error)
+	BatchProcessFetchResults(ctx context.Context, results <-chan blizzard.FetchResult) (int, int, error)
+	LoadLeaderboardValidators() (*LeaderboardValidatorCache, error)
+}
+
+// PlayerStore covers player identity, status, fingerprints and profile data
+type PlayerStore interface {
+	GetEligiblePlayersForProfileFetch(staleBefore int64) ([]blizzard.PlayerInfo, error)
+	InsertPlayerProfileData(result blizzard.PlayerProfileResult, timestamp int64) (int, int, error)
+	PruneEquipmentSnapshots(before int64) (int64, error)
+	GetPlayerByNameRealmRegion(name, realmSlug, region string) (int64, error)
+	GetPlayerCurrentIdentity(playerID int) (string, string, string, error)
+	GetLastRunRealmForPlayer(playerID int) (string, string, int64, error)
+	UpdatePlayerIdentity(playerID int, name, region, realmSlug string) error
+	CountPlayersNeedingStatusCheck(staleBefore int64) (int, error)
+	GetPlayersNeedingStatusCheck(limit int, staleBefore int64) ([]PlayerStatusCandidate, error)
+	UpdatePlayerStatus(playerID int64, isValid bool, checkedAt int64, blizzardID *int) error
+	CountPlayersMissingFingerprints() (int, error)
+	GetPlayersNeedingFingerprints(limit int) ([]PlayerFingerprintCandidate, error)
+	GetAllFingerprintHashes() (map[string]int64, error)
+	GetPlayerIDByFingerprintHash(hash string) (int64, error)
+	UpsertPlayerFingerprint(fp PlayerFingerprint) error
+	DeletePlayerFingerprint(playerID int64) error
+	MigratePlayerRuns(fromPlayerID, toPlayerID int64) (int, error)
+}
+
+// RankingStore covers the state ranking passes keep between runs and the upkeep
+// around them; the rankings themselves are recomputed set-wise through DB()
+type RankingStore interface {
+	InvalidatePlayerProfile(playerID int64) error
+	CountRuns() (int, error)
+	// Optimize runs the backend's VACUUM after a bulk rewrite
+	Optimize() error
+
+	// ranking_state holds the reference signature `process all` decides between an
+	// incremental and a full pass with
+	RequireFullRankingRebuild() error
+	LoadRankingSignature(tx *sql.Tx) (signature string, ok bool, err error)
+	SaveRankingState(tx *sql.Tx, signature string, full bool, at int64) error
+	ResetRankingState(tx *sql.Tx) error
+}
+
+// SeasonStore covers seasons and keystone periods
+type SeasonStore interface {
+	UpsertSeason(seasonID int, region string, seasonName string, startTimestamp int64) (int, error)
+	UpdateSeasonPeriodRange(seasonID, firstPeriodID, lastPeriodID int) error
+	UpdateSeasonEndTimestamp(seasonID int, endTimestamp int64) error
+	LinkPeriodToSeason(periodID, seasonID int) error
+	GetSeasonByID(seasonID int) (*Season, error)
+	GetAllSeasons() ([]Season, error)
+	GetCurrentSeason() (*Season, error)
+	AssignRunsToSeasons() error
+	UpsertPeriod(region string, periodID int, startTimestamp, endTimestamp int64) error
+	LinkPeriodsToSeasons(region string) (int64, error)
+	GetPeriodsForRegion(region string) ([]int, error)
+	GetLatestPeriodsPerRegion(region string) ([]int, error)
+	GetSettledPeriodIDs(region string, nowMillis int64) (map[int]bool, error)
+}
+
+// FetchStatusStore covers per-leaderboard fetch bookkeeping
+type FetchStatusStore interface {
+	RecordFetchStatus(region, realmSlug string, dungeonID, periodID int, status string, httpStatus int, message string) error
+	GetLastFetchInfo(fetchType string) (*int64, int, int, error)
+	UpdateFetchMetadata(fetchType string, runsFetched, playersFetched int) error
+}
+
+// SightingStore covers the per-period history of which leaderboards listed a run
+type SightingStore interface {
+	GetRunSightings(runID int64) ([]RunSighting, error)
+	GetLeaderboardSightings(region, realmSlug, dungeonSlug string, periodID int) ([]LeaderboardSighting, error)
+	GetSightingCoverage(region string) ([]SightingCoverage, error)
+	CountSightingsSince(since int64) (newRuns, resighted int, err error)
+}
+
+var _ Store = (*DatabaseService)(nil)
+
+// DB returns the underlying database handle
+func (ds *DatabaseService) DB() *sql.DB {
+	return ds.db
+}
+
+// Dialect returns the SQL backend the service is connected to
+func (ds *DatabaseService) Dialect() Dialect {
+	return ds.dialect
+}
+
+// CountRuns returns how many challenge runs are stored
+func (ds *DatabaseService) CountRuns() (int, error) {
+	var n int
+	if err := ds.db.QueryRow(`SELECT COUNT(*) FROM challenge_runs`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count runs: %w", err)
+	}
+	return n, nil
+}
+
+// Optimize reclaims space and refreshes planner statistics after a bulk rewrite of
+// the ranking tables
+func (ds *DatabaseService) Optimize() error {
+	stmt := "VACUUM"
+	if ds.dialect == Postgres {
+		stmt = "VACUUM ANALYZE"
+	}
+	_, err := ds.db.Exec(stmt)
+	return err
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"ookstats/internal/blizzard"
+	"strconv"
+	"time"
+)
+
+// LeaderboardValidatorCache is an in-memory snapshot of stored leaderboard
+// validators, used by the API client to send conditional requests.
+type LeaderboardValidatorCache struct {
+	entries map[string]blizzard.Validators
+}
+
+func validatorKey(region, realmSlug string, dungeonID int, periodID string) string {
+	return fmt.Sprintf("%s|%s|%d|%s", region, realmSlug, dungeonID, periodID)
+}
+
+// LeaderboardValidators implements blizzard.ValidatorCache
+func (c *LeaderboardValidatorCache) LeaderboardValidators(region, realmSlug string, dungeonID int, periodID string) (blizzard.Validators, bool) {
+	if c == nil {
+		return blizzard.Validators{}, false
+	}
+	v, ok := c.entries[validatorKey(region, realmSlug, dungeonID, periodID)]
+	return v, ok
+}
+
+// Len returns the number of cached validators
+func (c *LeaderboardValidatorCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.entries)
+}
+
+// LoadLeaderboardValidators loads all stored leaderboard validators
+func (ds *DatabaseService) LoadLeaderboardValidators() (*LeaderboardValidatorCache, error) {
+	rows, err := ds.db.Query(`
+		SELECT region, realm_slug, dungeon_id, period_id, COALESCE(etag, ''), COALESCE(last_modified, '')
+		FROM leaderboard_validators
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query leaderboard validators: %w", err)
+	}
+	defer rows.Close()
+
+	cache := &LeaderboardValidatorCache{entries: make(map[string]blizzard.Validators)}
+	for rows.Next() {
+		var region, realmSlug string
+		var dungeonID, periodID int
+		var v blizzard.Validators
+		if err := rows.Scan(&region, &realmSlug, &dungeonID, &periodID, &v.ETag, &v.LastModified); err != nil {
+			return nil, fmt.Errorf("scan leaderboard validator: %w", err)
+		}
+		cache.entries[validatorKey(region, realmSlug, dungeonID, strconv.Itoa(periodID))] = v
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// upsertLeaderboardValidatorsTx stores validators for a leaderboard. It runs in the same
+// transaction as the leaderboard insert so a validator never outlives unsaved data.
+func upsertLeaderboardValidatorsTx(tx *sql.Tx, region, realmSlug string, dungeonID, periodID int, v blizzard.Validators) error {
+	if periodID == 0 {
+		return nil
+	}
+	if v.IsZero() {
+		_, err := tx.Exec(`DELETE FROM leaderboard_validators WHERE region = ? AND realm_slug = ? AND dungeon_id = ? AND period_id = ?`,
+			region, realmSlug, dungeonID, periodID)
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO leaderboard_validators (region, realm_slug, dungeon_id, period_id, etag, last_modified, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(region, realm_slug, dungeon_id, period_id)
+		DO UPDATE SET
+			etag = excluded.etag,
+			last_modified = excluded.last_modified,
+			updated_at = excluded.updated_at
+	`, region, realmSlug, dungeonID, periodID, v.ETag, v.LastModified, time.Now().Unix())
+	return err
+}
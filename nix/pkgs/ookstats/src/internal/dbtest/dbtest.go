@@ -0,0 +1,223 @@
+// Package dbtest opens throwaway databases and fills them with deterministic
+// leaderboard fixtures for tests of the ingest, ranking and generation passes.
+package dbtest
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ookstats/internal/blizzard"
+	"ookstats/internal/database"
+)
+
+// PostgresDSNEnv names the PostgreSQL server the Postgres tests run against; they
+// are skipped when it is unset
+const PostgresDSNEnv = "OOKSTATS_TEST_PG_DSN"
+
+// OpenSQLite creates a migrated SQLite database in a temp dir
+func OpenSQLite(t testing.TB) *sql.DB {
+	t.Helper()
+	t.Setenv("OOKSTATS_DATABASE_URL", "")
+	database.SetDatabaseURL("")
+	database.SetDBPath(filepath.Join(t.TempDir(), "test.db"))
+	t.Cleanup(func() { database.SetDBPath("") })
+
+	return connect(t)
+}
+
+// OpenPostgres creates a migrated database in a fresh schema of the server named by
+// OOKSTATS_TEST_PG_DSN, dropped again when the test ends
+func OpenPostgres(t testing.TB) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", PostgresDSNEnv)
+	}
+
+	schema := fmt.Sprintf("ookstats_test_%d", time.Now().UnixNano())
+	database.SetDatabaseURL(dsn)
+	admin, err := database.Connect()
+	if err != nil {
+		t.Fatalf("connect postgres: %v", err)
+	}
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("parse %s: %v", PostgresDSNEnv, err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	database.SetDatabaseURL(u.String())
+	t.Cleanup(func() { database.SetDatabaseURL("") })
+
+	return connect(t)
+}
+
+func connect(t testing.TB) *sql.DB {
+	t.Helper()
+	db, err := database.Connect()
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.EnsureCompleteSchema(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// Fixture describes the reference data Seed writes
+type Fixture struct {
+	Region   string
+	SeasonID int
+	// Realms are the leaderboard realms; Connected is listed on Realms[0]'s board
+	Realms    []blizzard.RealmInfo
+	Connected blizzard.RealmInfo
+	Dungeons  []blizzard.DungeonInfo
+	Periods   []Period
+}
+
+// Period is a keystone week
+type Period struct {
+	ID    int
+	Start int64
+	End   int64
+}
+
+// playersPerRealm is the size of each realm's player pool
+const playersPerRealm = 15
+
+// specs are the member specs, one per slot of a five-player group
+var specs = [5]int{73, 105, 70, 254, 261}
+
+// Seed writes one US season of two weekly periods, three realms (one connected to
+// another) and two dungeons
+func Seed(t testing.TB, ds *database.DatabaseService) Fixture {
+	t.Helper()
+	start := time.Date(2026, 9, 1, 15, 0, 0, 0, time.UTC).UnixMilli()
+	week := int64(7 * 24 * time.Hour / time.Millisecond)
+	f := Fixture{
+		Region:   "us",
+		SeasonID: 11,
+		Realms: []blizzard.RealmInfo{
+			{ID: 4372, Name: "Atiesh", Region: "us", Slug: "atiesh"},
+			{ID: 4385, Name: "Pagle", Region: "us", Slug: "pagle"},
+		},
+		Connected: blizzard.RealmInfo{ID: 4372, Name: "Old Blanchy", Region: "us", Slug: "old-blanchy", ParentRealmSlug: "atiesh"},
+		Dungeons: []blizzard.DungeonInfo{
+			{ID: 2, Name: "Temple of the Jade Serpent", Slug: "temple-of-the-jade-serpent"},
+			{ID: 60, Name: "Mogu'shan Palace", Slug: "mogushan-palace"},
+		},
+		Periods: []Period{
+			{ID: 1035, Start: start, End: start + week},
+			{ID: 1036, Start: start + week, End: start + 2*week},
+		},
+	}
+
+	must := func(what string, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("seed %s: %v", what, err)
+		}
+	}
+	_, _, err := ds.UpsertRealms(append(append([]blizzard.RealmInfo{}, f.Realms...), f.Connected))
+	must("realms", err)
+	must("dungeons", ds.EnsureDungeonsOnce(f.Dungeons))
+
+	seasonID, err := ds.UpsertSeason(f.SeasonID, f.Region, fmt.Sprintf("Season %d", f.SeasonID), start)
+	must("season", err)
+	must("season range", ds.UpdateSeasonPeriodRange(seasonID, f.Periods[0].ID, f.Periods[len(f.Periods)-1].ID))
+	for _, p := range f.Periods {
+		must("period", ds.UpsertPeriod(f.Region, p.ID, p.Start, p.End))
+		must("period season", ds.LinkPeriodToSeason(p.ID, seasonID))
+	}
+	_, err = ds.LinkPeriodsToSeasons(f.Region)
+	must("link periods", err)
+	return f
+}
+
+// Leaderboard builds the board of realm ri for dungeon di in period pi, with n runs
+// drawn from the realm's player pool. The same arguments always build the same board.
+func (f Fixture) Leaderboard(ri, di, pi, n int) *blizzard.LeaderboardResponse {
+	realm, dungeon, period := f.Realms[ri], f.Dungeons[di], f.Periods[pi]
+	rng := rand.New(rand.NewSource(int64(ri*10000 + di*100 + pi)))
+
+	lb := &blizzard.LeaderboardResponse{
+		Period:               period.ID,
+		PeriodStartTimestamp: period.Start,
+		PeriodEndTimestamp:   period.End,
+		Map:                  blizzard.MapRef{ID: 900 + dungeon.ID, Name: dungeon.Name},
+		MapChallengeModeID:   dungeon.ID,
+	}
+	for i := 0; i < n; i++ {
+		run := blizzard.ChallengeRun{
+			Duration:           900000 + rng.Intn(600000),
+			CompletedTimestamp: period.Start + int64(rng.Intn(6*24*3600))*1000,
+			KeystoneLevel:      1,
+		}
+		for slot, p := range rng.Perm(playersPerRealm)[:5] {
+			home := realm
+			// every third player of the first realm's pool lives on its connected realm
+			if ri == 0 && p%3 == 0 {
+				home = f.Connected
+			}
+			run.Members = append(run.Members, f.member(ri, p, home, specs[slot]))
+		}
+		lb.LeadingGroups = append(lb.LeadingGroups, run)
+	}
+	return lb
+}
+
+// member builds player p of realm ri's pool as a leaderboard member
+func (f Fixture) member(ri, p int, home blizzard.RealmInfo, spec int) blizzard.Member {
+	id := (ri+1)*1000 + p
+	name := fmt.Sprintf("P%d%02d", ri, p)
+	slug := home.Slug
+	faction := "ALLIANCE"
+	return blizzard.Member{
+		ID:        &id,
+		Name:      &name,
+		RealmSlug: &slug,
+		SpecID:    &spec,
+		Faction:   &blizzard.FactionType{Type: faction},
+	}
+}
+
+// Ingest writes the boards of every realm, dungeon and period with n runs each, plus
+// the first realm's fastest run of each board on the second realm's board, the way
+// cross-realm groups show up on both, then assigns runs to seasons
+func Ingest(t testing.TB, ds *database.DatabaseService, f Fixture, n int) {
+	t.Helper()
+	for pi := range f.Periods {
+		for di, dungeon := range f.Dungeons {
+			shared := f.Leaderboard(0, di, pi, n).LeadingGroups[0]
+			for ri, realm := range f.Realms {
+				lb := f.Leaderboard(ri, di, pi, n)
+				if ri == 1 {
+					lb.LeadingGroups = append(lb.LeadingGroups, shared)
+				}
+				if _, _, err := ds.InsertLeaderboardData(lb, realm, dungeon); err != nil {
+					t.Fatalf("ingest %s %s period %d: %v", realm.Slug, dungeon.Slug, f.Periods[pi].ID, err)
+				}
+			}
+		}
+	}
+	if err := ds.AssignRunsToSeasons(); err != nil {
+		t.Fatalf("assign seasons: %v", err)
+	}
+}
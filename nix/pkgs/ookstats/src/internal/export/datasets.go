@@ -0,0 +1,409 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+
+	"ookstats/internal/wow"
+)
+
+// equipmentBatchSize is how many equipment rows share one enchantment lookup
+const equipmentBatchSize = 2000
+
+func exportRuns(ctx context.Context, db *sql.DB, f filters, dir, format string) ([]field, []FileManifest, error) {
+	cond, args := f.where("COALESCE(cr.season_id, 0)", "r.region", "cr.dungeon_id")
+
+	runs, err := db.QueryContext(ctx, `
+		SELECT cr.id, COALESCE(cr.season_id, 0), cr.dungeon_id, COALESCE(d.slug, ''), COALESCE(d.name, ''),
+		       r.region, r.slug, cr.period_id, cr.completed_timestamp, cr.duration,
+		       COALESCE(cr.keystone_level, 1), COALESCE(cr.team_signature, '')
+		FROM challenge_runs cr
+		JOIN realms r ON r.id = cr.realm_id
+		LEFT JOIN dungeons d ON d.id = cr.dungeon_id
+		WHERE 1 = 1`+cond+`
+		ORDER BY COALESCE(cr.season_id, 0), cr.id
+	`, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query runs: %w", err)
+	}
+	defer runs.Close()
+
+	// members come back in the same (season, run) order and are merged in as they stream
+	members, err := db.QueryContext(ctx, `
+		SELECT rm.run_id, rm.player_id, COALESCE(p.name, ''), COALESCE(pr.slug, ''), rm.spec_id, COALESCE(rm.faction, '')
+		FROM run_members rm
+		JOIN challenge_runs cr ON cr.id = rm.run_id
+		JOIN realms r ON r.id = cr.realm_id
+		LEFT JOIN players p ON p.id = rm.player_id
+		LEFT JOIN realms pr ON pr.id = p.realm_id
+		WHERE 1 = 1`+cond+`
+		ORDER BY COALESCE(cr.season_id, 0), rm.run_id, rm.player_id
+	`, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query run members: %w", err)
+	}
+	defer members.Close()
+
+	type member struct {
+		runID    int64
+		playerID int64
+		name     string
+		realm    string
+		specID   sql.NullInt64
+		faction  string
+	}
+	var pending *member
+	nextMember := func() error {
+		pending = nil
+		if !members.Next() {
+			return members.Err()
+		}
+		var m member
+		if err := members.Scan(&m.runID, &m.playerID, &m.name, &m.realm, &m.specID, &m.faction); err != nil {
+			return fmt.Errorf("scan run member: %w", err)
+		}
+		pending = &m
+		return nil
+	}
+	if err := nextMember(); err != nil {
+		return nil, nil, err
+	}
+
+	w := newPartitionWriter[RunRecord](dir, "runs", format)
+	for runs.Next() {
+		var rec RunRecord
+		var periodID sql.NullInt64
+		if err := runs.Scan(&rec.RunID, &rec.SeasonID, &rec.DungeonID, &rec.DungeonSlug, &rec.DungeonName,
+			&rec.Region, &rec.RealmSlug, &periodID, &rec.CompletedTimestamp, &rec.Duration,
+			&rec.KeystoneLevel, &rec.TeamSignature); err != nil {
+			return nil, nil, fmt.Errorf("scan run: %w", err)
+		}
+		rec.PeriodID = nullInt(periodID)
+
+		for pending != nil && pending.runID == rec.RunID {
+			className, specName := "", ""
+			if pending.specID.Valid {
+				className, specName, _ = wow.GetClassAndSpec(int(pending.specID.Int64))
+			}
+			rec.MemberIDs = append(rec.MemberIDs, pending.playerID)
+			rec.MemberNames = append(rec.MemberNames, pending.name)
+			rec.MemberRealmSlugs = append(rec.MemberRealmSlugs, pending.realm)
+			rec.MemberSpecIDs = append(rec.MemberSpecIDs, pending.specID.Int64)
+			rec.MemberClasses = append(rec.MemberClasses, className)
+			rec.MemberSpecs = append(rec.MemberSpecs, specName)
+			rec.MemberFactions = append(rec.MemberFactions, pending.faction)
+			if err := nextMember(); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if err := w.Write(rec.SeasonID, rec); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := runs.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate runs: %w", err)
+	}
+
+	files, err := w.Close()
+	return w.fields, files, err
+}
+
+func exportPlayerProfiles(ctx context.Context, db *sql.DB, f filters, dir, format string) ([]field, []FileManifest, error) {
+	cond, args := f.where("pp.season_id", "r.region", "")
+	rows, err := db.QueryContext(ctx, `
+		SELECT pp.player_id, pp.season_id, COALESCE(pp.name, p.name, ''), COALESCE(r.region, ''), COALESCE(r.slug, ''),
+		       pp.class_name, pp.main_spec_id, COALESCE(pp.dungeons_completed, 0), COALESCE(pp.total_runs, 0),
+		       COALESCE(pp.has_complete_coverage, 0), pp.combined_best_time, pp.average_best_time,
+		       pp.global_ranking, pp.regional_ranking, pp.realm_ranking,
+		       pp.global_ranking_bracket, pp.regional_ranking_bracket, pp.realm_ranking_bracket,
+		       pp.global_class_rank, pp.region_class_rank, pp.realm_class_rank, pp.last_updated
+		FROM player_profiles pp
+		LEFT JOIN players p ON p.id = pp.player_id
+		LEFT JOIN realms r ON r.id = COALESCE(pp.realm_id, p.realm_id)
+		WHERE 1 = 1`+cond+`
+		ORDER BY pp.season_id, pp.player_id
+	`, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query player profiles: %w", err)
+	}
+	defer rows.Close()
+
+	w := newPartitionWriter[PlayerProfileRecord](dir, "player_profiles", format)
+	for rows.Next() {
+		var rec PlayerProfileRecord
+		var className, globalBracket, regionalBracket, realmBracket sql.NullString
+		var mainSpec, combined, global, regional, realm, globalClass, regionClass, realmClass, updated sql.NullInt64
+		// libsql stores the average as a float
+		var average sql.NullFloat64
+		var complete int64
+		if err := rows.Scan(&rec.PlayerID, &rec.SeasonID, &rec.Name, &rec.Region, &rec.RealmSlug,
+			&className, &mainSpec, &rec.DungeonsCompleted, &rec.TotalRuns,
+			&complete, &combined, &average,
+			&global, &regional, &realm,
+			&globalBracket, &regionalBracket, &realmBracket,
+			&globalClass, &regionClass, &realmClass, &updated); err != nil {
+			return nil, nil, fmt.Errorf("scan player profile: %w", err)
+		}
+		rec.ClassName = nullString(className)
+		rec.MainSpecID = nullInt(mainSpec)
+		rec.HasCompleteCoverage = complete != 0
+		rec.CombinedBestTime = nullInt(combined)
+		if average.Valid {
+			v := int64(math.Round(average.Float64))
+			rec.AverageBestTime = &v
+		}
+		rec.GlobalRanking = nullInt(global)
+		rec.RegionalRanking = nullInt(regional)
+		rec.RealmRanking = nullInt(realm)
+		rec.GlobalBracket = nullString(globalBracket)
+		rec.RegionalBracket = nullString(regionalBracket)
+		rec.RealmBracket = nullString(realmBracket)
+		rec.GlobalClassRank = nullInt(globalClass)
+		rec.RegionClassRank = nullInt(regionClass)
+		rec.RealmClassRank = nullInt(realmClass)
+		rec.LastUpdated = nullInt(updated)
+
+		if err := w.Write(rec.SeasonID, rec); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate player profiles: %w", err)
+	}
+
+	files, err := w.Close()
+	return w.fields, files, err
+}
+
+func exportBestRuns(ctx context.Context, db *sql.DB, f filters, dir, format string) ([]field, []FileManifest, error) {
+	cond, args := f.where("pbr.season_id", "r.region", "pbr.dungeon_id")
+	rows, err := db.QueryContext(ctx, `
+		SELECT pbr.player_id, pbr.season_id, COALESCE(p.name, ''), COALESCE(r.region, ''), COALESCE(r.slug, ''),
+		       pbr.dungeon_id, COALESCE(d.slug, ''), pbr.run_id, pbr.duration, pbr.completed_timestamp,
+		       pbr.global_ranking, pbr.global_ranking_filtered,
+		       pbr.regional_ranking, pbr.regional_ranking_filtered,
+		       pbr.realm_ranking, pbr.realm_ranking_filtered,
+		       pbr.global_percentile_bracket, pbr.regional_percentile_bracket, pbr.realm_percentile_bracket
+		FROM player_best_runs pbr
+		LEFT JOIN players p ON p.id = pbr.player_id
+		LEFT JOIN realms r ON r.id = p.realm_id
+		LEFT JOIN dungeons d ON d.id = pbr.dungeon_id
+		WHERE 1 = 1`+cond+`
+		ORDER BY pbr.season_id, pbr.player_id, pbr.dungeon_id
+	`, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query best runs: %w", err)
+	}
+	defer rows.Close()
+
+	w := newPartitionWriter[BestRunRecord](dir, "best_runs", format)
+	for rows.Next() {
+		var rec BestRunRecord
+		var completed, global, globalFiltered, regional, regionalFiltered, realm, realmFiltered sql.NullInt64
+		var globalBracket, regionalBracket, realmBracket sql.NullString
+		if err := rows.Scan(&rec.PlayerID, &rec.SeasonID, &rec.Name, &rec.Region, &rec.RealmSlug,
+			&rec.DungeonID, &rec.DungeonSlug, &rec.RunID, &rec.Duration, &completed,
+			&global, &globalFiltered,
+			&regional, &regionalFiltered,
+			&realm, &realmFiltered,
+			&globalBracket, &regionalBracket, &realmBracket); err != nil {
+			return nil, nil, fmt.Errorf("scan best run: %w", err)
+		}
+		rec.CompletedTimestamp = nullInt(completed)
+		rec.GlobalRanking = nullInt(global)
+		rec.GlobalRankingFiltered = nullInt(globalFiltered)
+		rec.RegionalRanking = nullInt(regional)
+		rec.RegionalRankingFiltered = nullInt(regionalFiltered)
+		rec.RealmRanking = nullInt(realm)
+		rec.RealmRankingFiltered = nullInt(realmFiltered)
+		rec.GlobalPercentileBracket = nullString(globalBracket)
+		rec.RegionalPercentileBracket = nullString(regionalBracket)
+		rec.RealmPercentileBracket = nullString(realmBracket)
+
+		if err := w.Write(rec.SeasonID, rec); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate best runs: %w", err)
+	}
+
+	files, err := w.Close()
+	return w.fields, files, err
+}
+
+func exportEquipment(ctx context.Context, db *sql.DB, f filters, dir, format string) ([]field, []FileManifest, error) {
+	cond, args := f.where("x.season_id", "x.region", "")
+	rows, err := db.QueryContext(ctx, `
+		SELECT x.id, x.season_id, x.player_id, x.name, x.region, x.realm_slug, x.snapshot_timestamp,
+		       x.slot_type, x.item_id, x.item_name, x.quality, x.upgrade_id, x.item_level
+		FROM (
+			SELECT e.id, e.player_id, COALESCE(p.name, '') AS name, r.region, r.slug AS realm_slug,
+			       e.snapshot_timestamp, COALESCE(e.slot_type, '') AS slot_type,
+			       e.item_id, e.item_name, e.quality, e.upgrade_id, e.item_level,
+			       COALESCE((
+			           SELECT MAX(s.season_number) FROM seasons s
+			           WHERE s.region = r.region AND s.start_timestamp <= e.snapshot_timestamp
+			       ), 0) AS season_id
+			FROM player_equipment e
+			JOIN players p ON p.id = e.player_id
+			JOIN realms r ON r.id = p.realm_id
+		) x
+		WHERE 1 = 1`+cond+`
+		ORDER BY x.season_id, x.id
+	`, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query equipment: %w", err)
+	}
+	defer rows.Close()
+
+	w := newPartitionWriter[EquipmentRecord](dir, "equipment", format)
+	batch := make([]EquipmentRecord, 0, equipmentBatchSize)
+	flush := func() error {
+		if err := attachEnchantments(ctx, db, batch); err != nil {
+			return err
+		}
+		for _, rec := range batch {
+			if err := w.Write(rec.SeasonID, rec); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for rows.Next() {
+		var rec EquipmentRecord
+		var itemID, upgradeID, itemLevel sql.NullInt64
+		var itemName, quality sql.NullString
+		if err := rows.Scan(&rec.EquipmentID, &rec.SeasonID, &rec.PlayerID, &rec.Name, &rec.Region, &rec.RealmSlug,
+			&rec.SnapshotTimestamp, &rec.SlotType, &itemID, &itemName, &quality, &upgradeID, &itemLevel); err != nil {
+			return nil, nil, fmt.Errorf("scan equipment: %w", err)
+		}
+		rec.ItemID = nullInt(itemID)
+		rec.ItemName = nullString(itemName)
+		rec.Quality = nullString(quality)
+		rec.UpgradeID = nullInt(upgradeID)
+		rec.ItemLevel = nullInt(itemLevel)
+
+		batch = append(batch, rec)
+		if len(batch) >= equipmentBatchSize {
+			if err := flush(); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate equipment: %w", err)
+	}
+	if err := flush(); err != nil {
+		return nil, nil, err
+	}
+
+	files, err := w.Close()
+	return w.fields, files, err
+}
+
+// attachEnchantments fills the enchant and gem columns of a batch of equipment rows
+func attachEnchantments(ctx context.Context, db *sql.DB, batch []EquipmentRecord) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*EquipmentRecord, len(batch))
+	args := make([]any, len(batch))
+	for i := range batch {
+		byID[batch[i].EquipmentID] = &batch[i]
+		args[i] = batch[i].EquipmentID
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT equipment_id, enchantment_id, display_string, source_item_id, source_item_name
+		FROM player_equipment_enchantments
+		WHERE equipment_id IN (`+placeholders(len(batch))+`)
+		ORDER BY equipment_id, slot_id, id
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("query enchantments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var equipmentID int64
+		var enchantmentID, sourceItemID sql.NullInt64
+		var display, sourceName sql.NullString
+		if err := rows.Scan(&equipmentID, &enchantmentID, &display, &sourceItemID, &sourceName); err != nil {
+			return fmt.Errorf("scan enchantment: %w", err)
+		}
+		rec := byID[equipmentID]
+		if rec == nil {
+			continue
+		}
+		// gems are the enchantments that come from a source item
+		if sourceItemID.Valid {
+			rec.GemItemIDs = append(rec.GemItemIDs, sourceItemID.Int64)
+			rec.GemNames = append(rec.GemNames, sourceName.String)
+		} else {
+			rec.EnchantmentIDs = append(rec.EnchantmentIDs, enchantmentID.Int64)
+			rec.EnchantNames = append(rec.EnchantNames, display.String)
+		}
+	}
+	return rows.Err()
+}
+
+func exportRunRankings(ctx context.Context, db *sql.DB, f filters, dir, format string) ([]field, []FileManifest, error) {
+	cond, args := f.where("rr.season_id", "r.region", "rr.dungeon_id")
+	rows, err := db.QueryContext(ctx, `
+		SELECT rr.run_id, rr.season_id, rr.dungeon_id, COALESCE(d.slug, ''), rr.ranking_type, rr.ranking_scope,
+		       rr.ranking, rr.percentile_bracket, r.region, r.slug, cr.duration, cr.completed_timestamp
+		FROM run_rankings rr
+		JOIN challenge_runs cr ON cr.id = rr.run_id
+		JOIN realms r ON r.id = cr.realm_id
+		LEFT JOIN dungeons d ON d.id = rr.dungeon_id
+		WHERE 1 = 1`+cond+`
+		ORDER BY rr.season_id, rr.dungeon_id, rr.ranking_type, rr.ranking_scope, rr.ranking, rr.run_id
+	`, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query run rankings: %w", err)
+	}
+	defer rows.Close()
+
+	w := newPartitionWriter[RunRankingRecord](dir, "run_rankings", format)
+	for rows.Next() {
+		var rec RunRankingRecord
+		var bracket sql.NullString
+		if err := rows.Scan(&rec.RunID, &rec.SeasonID, &rec.DungeonID, &rec.DungeonSlug, &rec.RankingType, &rec.RankingScope,
+			&rec.Ranking, &bracket, &rec.Region, &rec.RealmSlug, &rec.Duration, &rec.CompletedTimestamp); err != nil {
+			return nil, nil, fmt.Errorf("scan run ranking: %w", err)
+		}
+		rec.PercentileBracket = nullString(bracket)
+
+		if err := w.Write(rec.SeasonID, rec); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate run rankings: %w", err)
+	}
+
+	files, err := w.Close()
+	return w.fields, files, err
+}
+
+func nullInt(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	i := v.Int64
+	return &i
+}
+
+func nullString(v sql.NullString) *string {
+	if !v.Valid {
+		return nil
+	}
+	s := v.String
+	return &s
+}
@@ -0,0 +1,243 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"ookstats/internal/database"
+	"ookstats/internal/writer"
+)
+
+// Options selects what Run exports and where
+type Options struct {
+	Dir    string
+	Format string
+	// Seasons, Regions and Dungeons restrict the export; empty means everything.
+	// Dungeons accepts ids or slugs.
+	Seasons  []int
+	Regions  []string
+	Dungeons []string
+}
+
+// Manifest describes an export bundle; it is written to <dir>/manifest.json
+type Manifest struct {
+	GeneratedAt   int64             `json:"generated_at"`
+	SchemaVersion int               `json:"schema_version"`
+	Format        string            `json:"format"`
+	Filters       ManifestFilters   `json:"filters"`
+	Datasets      []DatasetManifest `json:"datasets"`
+}
+
+// ManifestFilters records the filters the bundle was exported with
+type ManifestFilters struct {
+	Seasons    []int    `json:"seasons,omitempty"`
+	Regions    []string `json:"regions,omitempty"`
+	DungeonIDs []int    `json:"dungeon_ids,omitempty"`
+}
+
+// DatasetManifest describes one dataset's columns and files
+type DatasetManifest struct {
+	Name          string           `json:"name"`
+	Description   string           `json:"description"`
+	PartitionedBy string           `json:"partitioned_by"`
+	Rows          int64            `json:"rows"`
+	Columns       []ColumnManifest `json:"columns"`
+	Files         []FileManifest   `json:"files"`
+}
+
+// ColumnManifest describes one column
+type ColumnManifest struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Nullable    bool   `json:"nullable"`
+	Description string `json:"description,omitempty"`
+}
+
+// FileManifest is one written file, relative to the bundle directory
+type FileManifest struct {
+	Path     string `json:"path"`
+	SeasonID int64  `json:"season_id"`
+	Rows     int64  `json:"rows"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// dataset is one exported table
+type dataset struct {
+	name        string
+	description string
+	// dungeonScoped datasets honour the dungeon filter
+	dungeonScoped bool
+	run           func(ctx context.Context, db *sql.DB, f filters, dir, format string) (fields []field, files []FileManifest, err error)
+}
+
+var datasets = []dataset{
+	{
+		name:          "runs",
+		description:   "Challenge mode runs with their team as parallel member arrays",
+		dungeonScoped: true,
+		run:           exportRuns,
+	},
+	{
+		name:        "player_profiles",
+		description: "Per-season player aggregates and rankings",
+		run:         exportPlayerProfiles,
+	},
+	{
+		name:          "best_runs",
+		description:   "Each player's best run per dungeon and season",
+		dungeonScoped: true,
+		run:           exportBestRuns,
+	},
+	{
+		name:        "equipment",
+		description: "Equipment snapshot rows with enchants and gems",
+		run:         exportEquipment,
+	},
+	{
+		name:          "run_rankings",
+		description:   "Run ranks per ranking type and scope",
+		dungeonScoped: true,
+		run:           exportRunRankings,
+	},
+}
+
+// Run writes every dataset and the manifest to opts.Dir. Each dataset directory is
+// replaced, so partitions from an earlier export with other filters do not linger.
+func Run(ctx context.Context, db *sql.DB, opts Options) (*Manifest, error) {
+	if opts.Format != FormatParquet && opts.Format != FormatCSV {
+		return nil, fmt.Errorf("unsupported format %q (use %s or %s)", opts.Format, FormatParquet, FormatCSV)
+	}
+	f, err := resolveFilters(db, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaVersion, err := database.SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{
+		GeneratedAt:   time.Now().UnixMilli(),
+		SchemaVersion: schemaVersion,
+		Format:        opts.Format,
+		Filters: ManifestFilters{
+			Seasons:    f.seasons,
+			Regions:    f.regions,
+			DungeonIDs: f.dungeonIDs,
+		},
+	}
+
+	for _, ds := range datasets {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := os.RemoveAll(filepath.Join(opts.Dir, ds.name)); err != nil {
+			return nil, fmt.Errorf("clear %s: %w", ds.name, err)
+		}
+
+		start := time.Now()
+		df := f
+		if !ds.dungeonScoped {
+			df.dungeonIDs = nil
+		}
+		fields, files, err := ds.run(ctx, db, df, opts.Dir, opts.Format)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", ds.name, err)
+		}
+
+		dm := DatasetManifest{
+			Name:          ds.name,
+			Description:   ds.description,
+			PartitionedBy: "season_id",
+			Files:         files,
+		}
+		if dm.Files == nil {
+			dm.Files = []FileManifest{}
+		}
+		for _, fld := range fields {
+			dm.Columns = append(dm.Columns, ColumnManifest{
+				Name:        fld.name,
+				Type:        fld.typ,
+				Nullable:    fld.nullable,
+				Description: fld.description,
+			})
+		}
+		for _, file := range files {
+			dm.Rows += file.Rows
+		}
+		manifest.Datasets = append(manifest.Datasets, dm)
+		log.Info("exported dataset", "name", ds.name, "rows", dm.Rows, "files", len(files), "duration", time.Since(start).Round(time.Millisecond))
+	}
+
+	if err := writer.WriteJSONFile(filepath.Join(opts.Dir, "manifest.json"), manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// filters are the resolved export filters
+type filters struct {
+	seasons    []int
+	regions    []string
+	dungeonIDs []int
+}
+
+// where returns " AND ..." conditions for the given columns; an empty column name
+// skips that filter
+func (f filters) where(seasonCol, regionCol, dungeonCol string) (string, []any) {
+	var sb strings.Builder
+	var args []any
+	if seasonCol != "" && len(f.seasons) > 0 {
+		sb.WriteString(" AND " + seasonCol + " IN (" + placeholders(len(f.seasons)) + ")")
+		for _, s := range f.seasons {
+			args = append(args, s)
+		}
+	}
+	if regionCol != "" && len(f.regions) > 0 {
+		sb.WriteString(" AND " + regionCol + " IN (" + placeholders(len(f.regions)) + ")")
+		for _, r := range f.regions {
+			args = append(args, r)
+		}
+	}
+	if dungeonCol != "" && len(f.dungeonIDs) > 0 {
+		sb.WriteString(" AND " + dungeonCol + " IN (" + placeholders(len(f.dungeonIDs)) + ")")
+		for _, d := range f.dungeonIDs {
+			args = append(args, d)
+		}
+	}
+	return sb.String(), args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// resolveFilters normalises regions and turns dungeon slugs into ids
+func resolveFilters(db *sql.DB, opts Options) (filters, error) {
+	f := filters{seasons: opts.Seasons}
+	for _, r := range opts.Regions {
+		f.regions = append(f.regions, strings.ToLower(strings.TrimSpace(r)))
+	}
+	for _, d := range opts.Dungeons {
+		if id, err := strconv.Atoi(d); err == nil {
+			f.dungeonIDs = append(f.dungeonIDs, id)
+			continue
+		}
+		var id int
+		if err := db.QueryRow(`SELECT id FROM dungeons WHERE slug = ?`, d).Scan(&id); err != nil {
+			if err == sql.ErrNoRows {
+				return f, fmt.Errorf("unknown dungeon %q", d)
+			}
+			return f, fmt.Errorf("resolve dungeon %q: %w", d, err)
+		}
+		f.dungeonIDs = append(f.dungeonIDs, id)
+	}
+	return f, nil
+}
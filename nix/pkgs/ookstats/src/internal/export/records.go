@@ -0,0 +1,115 @@
+package export
+
+// Record types define each dataset's columns. The parquet tag names the column (and
+// marks lists), desc documents it in the manifest, and pointer fields are nullable.
+
+// RunRecord is one challenge run with its team flattened into parallel member arrays;
+// index i of every member_* column describes the same player
+type RunRecord struct {
+	RunID              int64    `parquet:"run_id" desc:"Run id (challenge_runs.id)"`
+	SeasonID           int64    `parquet:"season_id" desc:"Season number, 0 when the run has no season"`
+	DungeonID          int64    `parquet:"dungeon_id" desc:"Dungeon id"`
+	DungeonSlug        string   `parquet:"dungeon_slug" desc:"Dungeon slug"`
+	DungeonName        string   `parquet:"dungeon_name" desc:"Dungeon name"`
+	Region             string   `parquet:"region" desc:"Region of the realm the run is stored under"`
+	RealmSlug          string   `parquet:"realm_slug" desc:"Realm the run is stored under"`
+	PeriodID           *int64   `parquet:"period_id,optional" desc:"Keystone period the run was completed in"`
+	CompletedTimestamp int64    `parquet:"completed_timestamp" desc:"Completion time in unix milliseconds"`
+	Duration           int64    `parquet:"duration" desc:"Run duration in milliseconds"`
+	KeystoneLevel      int64    `parquet:"keystone_level" desc:"Keystone level"`
+	TeamSignature      string   `parquet:"team_signature" desc:"Stable hash of the sorted member player ids"`
+	MemberIDs          []int64  `parquet:"member_ids,list" desc:"Player ids"`
+	MemberNames        []string `parquet:"member_names,list" desc:"Player names"`
+	MemberRealmSlugs   []string `parquet:"member_realm_slugs,list" desc:"Player home realms"`
+	MemberSpecIDs      []int64  `parquet:"member_spec_ids,list" desc:"Spec ids played in the run, 0 when unknown"`
+	MemberClasses      []string `parquet:"member_classes,list" desc:"Class names derived from the spec, empty when unknown"`
+	MemberSpecs        []string `parquet:"member_specs,list" desc:"Spec names, empty when unknown"`
+	MemberFactions     []string `parquet:"member_factions,list" desc:"Factions (ALLIANCE or HORDE)"`
+}
+
+// PlayerProfileRecord is a player's aggregated profile for one season
+type PlayerProfileRecord struct {
+	PlayerID            int64   `parquet:"player_id" desc:"Player id"`
+	SeasonID            int64   `parquet:"season_id" desc:"Season number"`
+	Name                string  `parquet:"name" desc:"Player name"`
+	Region              string  `parquet:"region" desc:"Region of the player's realm"`
+	RealmSlug           string  `parquet:"realm_slug" desc:"Player's realm"`
+	ClassName           *string `parquet:"class_name,optional" desc:"Class name"`
+	MainSpecID          *int64  `parquet:"main_spec_id,optional" desc:"Most played spec id in the season"`
+	DungeonsCompleted   int64   `parquet:"dungeons_completed" desc:"Dungeons with at least one completed run"`
+	TotalRuns           int64   `parquet:"total_runs" desc:"Completed runs in the season"`
+	HasCompleteCoverage bool    `parquet:"has_complete_coverage" desc:"Whether every dungeon of the season was completed"`
+	CombinedBestTime    *int64  `parquet:"combined_best_time,optional" desc:"Sum of best durations across dungeons in milliseconds"`
+	AverageBestTime     *int64  `parquet:"average_best_time,optional" desc:"Average best duration in milliseconds"`
+	GlobalRanking       *int64  `parquet:"global_ranking,optional" desc:"Rank among all players by combined best time"`
+	RegionalRanking     *int64  `parquet:"regional_ranking,optional" desc:"Rank within the region"`
+	RealmRanking        *int64  `parquet:"realm_ranking,optional" desc:"Rank within the realm pool"`
+	GlobalBracket       *string `parquet:"global_ranking_bracket,optional" desc:"Percentile bracket of the global rank"`
+	RegionalBracket     *string `parquet:"regional_ranking_bracket,optional" desc:"Percentile bracket of the regional rank"`
+	RealmBracket        *string `parquet:"realm_ranking_bracket,optional" desc:"Percentile bracket of the realm rank"`
+	GlobalClassRank     *int64  `parquet:"global_class_rank,optional" desc:"Rank among players of the same class"`
+	RegionClassRank     *int64  `parquet:"region_class_rank,optional" desc:"Class rank within the region"`
+	RealmClassRank      *int64  `parquet:"realm_class_rank,optional" desc:"Class rank within the realm pool"`
+	LastUpdated         *int64  `parquet:"last_updated,optional" desc:"When the profile was computed, unix milliseconds"`
+}
+
+// BestRunRecord is a player's best run of a dungeon in a season
+type BestRunRecord struct {
+	PlayerID                  int64   `parquet:"player_id" desc:"Player id"`
+	SeasonID                  int64   `parquet:"season_id" desc:"Season number"`
+	Name                      string  `parquet:"name" desc:"Player name"`
+	Region                    string  `parquet:"region" desc:"Region of the player's realm"`
+	RealmSlug                 string  `parquet:"realm_slug" desc:"Player's realm"`
+	DungeonID                 int64   `parquet:"dungeon_id" desc:"Dungeon id"`
+	DungeonSlug               string  `parquet:"dungeon_slug" desc:"Dungeon slug"`
+	RunID                     int64   `parquet:"run_id" desc:"Run id of the best run"`
+	Duration                  int64   `parquet:"duration" desc:"Run duration in milliseconds"`
+	CompletedTimestamp        *int64  `parquet:"completed_timestamp,optional" desc:"Completion time in unix milliseconds"`
+	GlobalRanking             *int64  `parquet:"global_ranking,optional" desc:"Global run rank"`
+	GlobalRankingFiltered     *int64  `parquet:"global_ranking_filtered,optional" desc:"Global rank counting each team once"`
+	RegionalRanking           *int64  `parquet:"regional_ranking,optional" desc:"Regional run rank"`
+	RegionalRankingFiltered   *int64  `parquet:"regional_ranking_filtered,optional" desc:"Regional rank counting each team once"`
+	RealmRanking              *int64  `parquet:"realm_ranking,optional" desc:"Realm pool run rank"`
+	RealmRankingFiltered      *int64  `parquet:"realm_ranking_filtered,optional" desc:"Realm pool rank counting each team once"`
+	GlobalPercentileBracket   *string `parquet:"global_percentile_bracket,optional" desc:"Percentile bracket of the global rank"`
+	RegionalPercentileBracket *string `parquet:"regional_percentile_bracket,optional" desc:"Percentile bracket of the regional rank"`
+	RealmPercentileBracket    *string `parquet:"realm_percentile_bracket,optional" desc:"Percentile bracket of the realm rank"`
+}
+
+// EquipmentRecord is one stored equipment snapshot row. Snapshots only store the slots
+// that changed, so a slot's gear holds until its next row.
+type EquipmentRecord struct {
+	EquipmentID       int64    `parquet:"equipment_id" desc:"Snapshot row id"`
+	SeasonID          int64    `parquet:"season_id" desc:"Latest season of the player's region started at snapshot time, 0 if none"`
+	PlayerID          int64    `parquet:"player_id" desc:"Player id"`
+	Name              string   `parquet:"name" desc:"Player name"`
+	Region            string   `parquet:"region" desc:"Region of the player's realm"`
+	RealmSlug         string   `parquet:"realm_slug" desc:"Player's realm"`
+	SnapshotTimestamp int64    `parquet:"snapshot_timestamp" desc:"When the profile was fetched, unix milliseconds"`
+	SlotType          string   `parquet:"slot_type" desc:"Equipment slot (HEAD, TRINKET_1, ...)"`
+	ItemID            *int64   `parquet:"item_id,optional" desc:"Item id, null when the slot was emptied"`
+	ItemName          *string  `parquet:"item_name,optional" desc:"Item name"`
+	Quality           *string  `parquet:"quality,optional" desc:"Item quality"`
+	UpgradeID         *int64   `parquet:"upgrade_id,optional" desc:"Upgrade step"`
+	ItemLevel         *int64   `parquet:"item_level,optional" desc:"Item level, null for snapshots taken before it was recorded"`
+	EnchantmentIDs    []int64  `parquet:"enchantment_ids,list" desc:"Enchantment ids of enchants that are not gems"`
+	EnchantNames      []string `parquet:"enchant_names,list" desc:"Display strings of those enchants"`
+	GemItemIDs        []int64  `parquet:"gem_item_ids,list" desc:"Item ids of socketed gems"`
+	GemNames          []string `parquet:"gem_names,list" desc:"Names of socketed gems"`
+}
+
+// RunRankingRecord is a run's rank within one ranking scope
+type RunRankingRecord struct {
+	RunID              int64   `parquet:"run_id" desc:"Run id"`
+	SeasonID           int64   `parquet:"season_id" desc:"Season number"`
+	DungeonID          int64   `parquet:"dungeon_id" desc:"Dungeon id"`
+	DungeonSlug        string  `parquet:"dungeon_slug" desc:"Dungeon slug"`
+	RankingType        string  `parquet:"ranking_type" desc:"global, regional or realm"`
+	RankingScope       string  `parquet:"ranking_scope" desc:"all, the region or the pool's realm slug; filtered scopes (filtered, us_filtered, ...) count each team once"`
+	Ranking            int64   `parquet:"ranking" desc:"Rank within the scope"`
+	PercentileBracket  *string `parquet:"percentile_bracket,optional" desc:"Percentile bracket of the rank"`
+	Region             string  `parquet:"region" desc:"Region of the realm the run is stored under"`
+	RealmSlug          string  `parquet:"realm_slug" desc:"Realm the run is stored under"`
+	Duration           int64   `parquet:"duration" desc:"Run duration in milliseconds"`
+	CompletedTimestamp int64   `parquet:"completed_timestamp" desc:"Completion time in unix milliseconds"`
+}
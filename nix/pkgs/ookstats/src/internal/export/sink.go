@@ -0,0 +1,235 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Output formats
+const (
+	FormatParquet = "parquet"
+	FormatCSV     = "csv"
+)
+
+// parquetBatchSize is how many records are buffered before a parquet write
+const parquetBatchSize = 4096
+
+// field is one exported column of a record type
+type field struct {
+	index       int
+	name        string
+	typ         string
+	nullable    bool
+	description string
+}
+
+// recordFields describes the columns of a record struct from its tags
+func recordFields(t reflect.Type) []field {
+	fields := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("parquet"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		f := field{index: i, name: name, description: sf.Tag.Get("desc")}
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			f.nullable = true
+			ft = ft.Elem()
+		}
+		f.typ = columnType(ft)
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func columnType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int64:
+		return "int64"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Slice:
+		return "list<" + columnType(t.Elem()) + ">"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// partitionWriter writes records of one dataset into a file per season, laid out as
+// <dir>/<dataset>/season=<id>/<dataset>.<format> so DuckDB and pandas pick the season
+// up as a hive partition. Records must arrive grouped by season.
+type partitionWriter[T any] struct {
+	dir     string
+	dataset string
+	format  string
+	fields  []field
+
+	season  int64
+	file    *os.File
+	path    string
+	rows    int64
+	pq      *parquet.GenericWriter[T]
+	pqBatch []T
+	csv     *csv.Writer
+
+	files []FileManifest
+}
+
+func newPartitionWriter[T any](dir, dataset, format string) *partitionWriter[T] {
+	var zero T
+	return &partitionWriter[T]{
+		dir:     dir,
+		dataset: dataset,
+		format:  format,
+		fields:  recordFields(reflect.TypeOf(zero)),
+	}
+}
+
+// Write appends a record to the file of its season
+func (w *partitionWriter[T]) Write(season int64, rec T) error {
+	if w.file == nil || season != w.season {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+		if err := w.openFile(season); err != nil {
+			return err
+		}
+	}
+	w.rows++
+
+	if w.pq != nil {
+		w.pqBatch = append(w.pqBatch, rec)
+		if len(w.pqBatch) >= parquetBatchSize {
+			return w.flushParquet()
+		}
+		return nil
+	}
+	return w.csv.Write(w.csvRow(reflect.ValueOf(rec)))
+}
+
+// Close finishes the open file and returns the files written
+func (w *partitionWriter[T]) Close() ([]FileManifest, error) {
+	if err := w.closeFile(); err != nil {
+		return nil, err
+	}
+	return w.files, nil
+}
+
+func (w *partitionWriter[T]) openFile(season int64) error {
+	rel := filepath.Join(w.dataset, fmt.Sprintf("season=%d", season), w.dataset+"."+w.format)
+	path := filepath.Join(w.dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("mkdir %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+
+	w.season = season
+	w.file = f
+	w.path = rel
+	w.rows = 0
+	switch w.format {
+	case FormatParquet:
+		w.pq = parquet.NewGenericWriter[T](f, parquet.Compression(&parquet.Zstd))
+	case FormatCSV:
+		w.csv = csv.NewWriter(f)
+		header := make([]string, len(w.fields))
+		for i, fld := range w.fields {
+			header[i] = fld.name
+		}
+		if err := w.csv.Write(header); err != nil {
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (w *partitionWriter[T]) flushParquet() error {
+	if len(w.pqBatch) == 0 {
+		return nil
+	}
+	if _, err := w.pq.Write(w.pqBatch); err != nil {
+		return fmt.Errorf("write %s: %w", w.path, err)
+	}
+	w.pqBatch = w.pqBatch[:0]
+	return nil
+}
+
+func (w *partitionWriter[T]) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	var err error
+	switch {
+	case w.pq != nil:
+		if err = w.flushParquet(); err == nil {
+			err = w.pq.Close()
+		}
+		w.pq = nil
+	case w.csv != nil:
+		w.csv.Flush()
+		err = w.csv.Error()
+		w.csv = nil
+	}
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+	if err != nil {
+		return fmt.Errorf("finish %s: %w", w.path, err)
+	}
+
+	entry := FileManifest{Path: filepath.ToSlash(w.path), SeasonID: w.season, Rows: w.rows}
+	if info, err := os.Stat(filepath.Join(w.dir, w.path)); err == nil {
+		entry.Bytes = info.Size()
+	}
+	w.files = append(w.files, entry)
+	return nil
+}
+
+// csvRow formats a record for CSV: nulls become empty cells and lists are written as
+// JSON arrays
+func (w *partitionWriter[T]) csvRow(v reflect.Value) []string {
+	row := make([]string, len(w.fields))
+	for i, fld := range w.fields {
+		fv := v.Field(fld.index)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		switch fv.Kind() {
+		case reflect.Int64:
+			row[i] = strconv.FormatInt(fv.Int(), 10)
+		case reflect.Bool:
+			row[i] = strconv.FormatBool(fv.Bool())
+		case reflect.String:
+			row[i] = fv.String()
+		case reflect.Slice:
+			if fv.Len() == 0 {
+				row[i] = "[]"
+				continue
+			}
+			b, _ := json.Marshal(fv.Interface())
+			row[i] = string(b)
+		default:
+			row[i] = fmt.Sprint(fv.Interface())
+		}
+	}
+	return row
+}
@@ -0,0 +1,194 @@
+package gear
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Change kinds reported per slot
+const (
+	// ChangeAdded means a previously empty slot now holds an item
+	ChangeAdded = "added"
+	// ChangeRemoved means the slot's item was taken off and nothing replaced it
+	ChangeRemoved = "removed"
+	// ChangeReplaced means the slot holds a different item
+	ChangeReplaced = "replaced"
+	// ChangeModified means the same item was upgraded, enchanted or regemmed
+	ChangeModified = "modified"
+)
+
+// Enchant is an enchantment, gem or tinker applied to an item. Gems are the
+// enchantments that come from a source item.
+type Enchant struct {
+	EnchantmentID int    `json:"enchantment_id,omitempty"`
+	SourceItemID  int    `json:"source_item_id,omitempty"`
+	Name          string `json:"name,omitempty"`
+	DisplayString string `json:"display_string,omitempty"`
+}
+
+// IsGem reports whether the enchant is a socketed gem
+func (e Enchant) IsGem() bool {
+	return e.SourceItemID != 0
+}
+
+func (e Enchant) key() string {
+	return fmt.Sprintf("%d|%d|%s", e.EnchantmentID, e.SourceItemID, e.DisplayString)
+}
+
+// Item is what one equipment slot held in a snapshot
+type Item struct {
+	ItemID    int
+	Name      string
+	Quality   string
+	UpgradeID int
+	// ItemLevel is 0 when the snapshot predates item levels being recorded
+	ItemLevel int
+	Enchants  []Enchant
+}
+
+// ItemRef identifies the item on either side of a slot change
+type ItemRef struct {
+	ItemID    int    `json:"item_id"`
+	Name      string `json:"item_name"`
+	Quality   string `json:"quality,omitempty"`
+	UpgradeID int    `json:"upgrade_id,omitempty"`
+	ItemLevel int    `json:"item_level,omitempty"`
+}
+
+func (it *Item) ref() *ItemRef {
+	if it == nil {
+		return nil
+	}
+	return &ItemRef{
+		ItemID:    it.ItemID,
+		Name:      it.Name,
+		Quality:   it.Quality,
+		UpgradeID: it.UpgradeID,
+		ItemLevel: it.ItemLevel,
+	}
+}
+
+// SlotChange describes how one slot differs between two snapshots
+type SlotChange struct {
+	Slot            string    `json:"slot"`
+	Kind            string    `json:"kind"`
+	Before          *ItemRef  `json:"before,omitempty"`
+	After           *ItemRef  `json:"after,omitempty"`
+	ItemLevelDelta  *int      `json:"item_level_delta,omitempty"`
+	EnchantsAdded   []Enchant `json:"enchants_added,omitempty"`
+	EnchantsRemoved []Enchant `json:"enchants_removed,omitempty"`
+	GemsAdded       []Enchant `json:"gems_added,omitempty"`
+	GemsRemoved     []Enchant `json:"gems_removed,omitempty"`
+}
+
+// Loadout is the gear worn at one point in time, keyed by slot type
+type Loadout map[string]Item
+
+// Diff compares two loadouts slot by slot and returns the changed slots in slot order
+func Diff(before, after Loadout) []SlotChange {
+	slots := make(map[string]bool, len(before)+len(after))
+	for slot := range before {
+		slots[slot] = true
+	}
+	for slot := range after {
+		slots[slot] = true
+	}
+	ordered := make([]string, 0, len(slots))
+	for slot := range slots {
+		ordered = append(ordered, slot)
+	}
+	sort.Strings(ordered)
+
+	var changes []SlotChange
+	for _, slot := range ordered {
+		var b, a *Item
+		if it, ok := before[slot]; ok {
+			b = &it
+		}
+		if it, ok := after[slot]; ok {
+			a = &it
+		}
+		if change, ok := diffSlot(slot, b, a); ok {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// diffSlot compares one slot; either side may be empty
+func diffSlot(slot string, before, after *Item) (SlotChange, bool) {
+	change := SlotChange{Slot: slot, Before: before.ref(), After: after.ref()}
+	switch {
+	case before == nil && after == nil:
+		return change, false
+	case before == nil:
+		change.Kind = ChangeAdded
+	case after == nil:
+		change.Kind = ChangeRemoved
+	case before.ItemID != after.ItemID:
+		change.Kind = ChangeReplaced
+	default:
+		change.Kind = ChangeModified
+	}
+
+	var beforeEnchants, afterEnchants []Enchant
+	if before != nil {
+		beforeEnchants = before.Enchants
+	}
+	if after != nil {
+		afterEnchants = after.Enchants
+	}
+	added, removed := diffEnchants(beforeEnchants, afterEnchants)
+	for _, e := range added {
+		if e.IsGem() {
+			change.GemsAdded = append(change.GemsAdded, e)
+		} else {
+			change.EnchantsAdded = append(change.EnchantsAdded, e)
+		}
+	}
+	for _, e := range removed {
+		if e.IsGem() {
+			change.GemsRemoved = append(change.GemsRemoved, e)
+		} else {
+			change.EnchantsRemoved = append(change.EnchantsRemoved, e)
+		}
+	}
+
+	if before != nil && after != nil && before.ItemLevel > 0 && after.ItemLevel > 0 && before.ItemLevel != after.ItemLevel {
+		delta := after.ItemLevel - before.ItemLevel
+		change.ItemLevelDelta = &delta
+	}
+
+	if change.Kind == ChangeModified &&
+		before.UpgradeID == after.UpgradeID &&
+		before.Quality == after.Quality &&
+		before.Name == after.Name &&
+		change.ItemLevelDelta == nil &&
+		len(added) == 0 && len(removed) == 0 {
+		return change, false
+	}
+	return change, true
+}
+
+// diffEnchants returns the enchants only present after and only present before.
+// Enchants are compared as a multiset so two identical gems count twice.
+func diffEnchants(before, after []Enchant) (added, removed []Enchant) {
+	remaining := make(map[string]int, len(before))
+	for _, e := range before {
+		remaining[e.key()]++
+	}
+	for _, e := range after {
+		if remaining[e.key()] > 0 {
+			remaining[e.key()]--
+			continue
+		}
+		added = append(added, e)
+	}
+	for _, e := range before {
+		if remaining[e.key()] > 0 {
+			remaining[e.key()]--
+			removed = append(removed, e)
+		}
+	}
+	return added, removed
+}
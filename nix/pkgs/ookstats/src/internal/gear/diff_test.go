@@ -0,0 +1,153 @@
+package gear
+
+import (
+	"reflect"
+	"testing"
+)
+
+var (
+	enchant = Enchant{EnchantmentID: 4804, DisplayString: "+200 Intellect"}
+	tinker  = Enchant{EnchantmentID: 4898, DisplayString: "Synapse Springs"}
+	gemA    = Enchant{EnchantmentID: 4587, SourceItemID: 76668, DisplayString: "+160 Intellect"}
+	gemB    = Enchant{EnchantmentID: 4600, SourceItemID: 76694, DisplayString: "+160 Mastery"}
+)
+
+func helm(ilvl int, enchants ...Enchant) Item {
+	return Item{ItemID: 86000, Name: "Helm", Quality: "EPIC", ItemLevel: ilvl, Enchants: enchants}
+}
+
+func chest(ilvl int) Item {
+	return Item{ItemID: 86001, Name: "Chest", Quality: "EPIC", ItemLevel: ilvl}
+}
+
+func refOf(it Item) *ItemRef {
+	return it.ref()
+}
+
+func delta(n int) *int {
+	return &n
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after Loadout
+		want          []SlotChange
+	}{
+		{
+			name:   "unchanged",
+			before: Loadout{"HEAD": helm(496, enchant, gemA)},
+			after:  Loadout{"HEAD": helm(496, enchant, gemA)},
+		},
+		{
+			name:   "enchant and tinker are not gems",
+			before: Loadout{"HEAD": helm(496)},
+			after:  Loadout{"HEAD": helm(496, enchant, tinker)},
+			want: []SlotChange{{
+				Slot: "HEAD", Kind: ChangeModified,
+				Before:        refOf(helm(496)),
+				After:         refOf(helm(496)),
+				EnchantsAdded: []Enchant{enchant, tinker},
+			}},
+		},
+		{
+			name:   "gem swapped",
+			before: Loadout{"HEAD": helm(496, enchant, gemA)},
+			after:  Loadout{"HEAD": helm(496, enchant, gemB)},
+			want: []SlotChange{{
+				Slot: "HEAD", Kind: ChangeModified,
+				Before:      refOf(helm(496)),
+				After:       refOf(helm(496)),
+				GemsAdded:   []Enchant{gemB},
+				GemsRemoved: []Enchant{gemA},
+			}},
+		},
+		{
+			name:   "one of two identical gems removed",
+			before: Loadout{"HEAD": helm(496, gemA, gemA)},
+			after:  Loadout{"HEAD": helm(496, gemA)},
+			want: []SlotChange{{
+				Slot: "HEAD", Kind: ChangeModified,
+				Before:      refOf(helm(496)),
+				After:       refOf(helm(496)),
+				GemsRemoved: []Enchant{gemA},
+			}},
+		},
+		{
+			name:   "item level upgraded",
+			before: Loadout{"CHEST": chest(483)},
+			after:  Loadout{"CHEST": chest(496)},
+			want: []SlotChange{{
+				Slot: "CHEST", Kind: ChangeModified,
+				Before:         refOf(chest(483)),
+				After:          refOf(chest(496)),
+				ItemLevelDelta: delta(13),
+			}},
+		},
+		{
+			name:   "item level unrecorded before",
+			before: Loadout{"CHEST": chest(0)},
+			after:  Loadout{"CHEST": chest(496)},
+		},
+		{
+			name:   "item level unrecorded after a replacement",
+			before: Loadout{"HEAD": helm(496)},
+			after:  Loadout{"HEAD": chest(0)},
+			want: []SlotChange{{
+				Slot: "HEAD", Kind: ChangeReplaced,
+				Before: refOf(helm(496)),
+				After:  refOf(chest(0)),
+			}},
+		},
+		{
+			name:   "slot emptied",
+			before: Loadout{"HEAD": helm(496, enchant, gemA), "CHEST": chest(496)},
+			after:  Loadout{"CHEST": chest(496)},
+			want: []SlotChange{{
+				Slot: "HEAD", Kind: ChangeRemoved,
+				Before:          refOf(helm(496)),
+				EnchantsRemoved: []Enchant{enchant},
+				GemsRemoved:     []Enchant{gemA},
+			}},
+		},
+		{
+			name:   "slots filled in slot order",
+			before: Loadout{},
+			after:  Loadout{"HEAD": helm(496), "CHEST": chest(483)},
+			want: []SlotChange{
+				{Slot: "CHEST", Kind: ChangeAdded, After: refOf(chest(483))},
+				{Slot: "HEAD", Kind: ChangeAdded, After: refOf(helm(496))},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffEnchants(t *testing.T) {
+	tests := []struct {
+		name                   string
+		before, after          []Enchant
+		wantAdded, wantRemoved []Enchant
+	}{
+		{name: "same"},
+		{name: "same gems in another order", before: []Enchant{gemA, gemB}, after: []Enchant{gemB, gemA}},
+		{name: "second identical gem added", before: []Enchant{gemA}, after: []Enchant{gemA, gemA}, wantAdded: []Enchant{gemA}},
+		{name: "identical gems replaced", before: []Enchant{gemA, gemA}, after: []Enchant{gemB, gemB},
+			wantAdded: []Enchant{gemB, gemB}, wantRemoved: []Enchant{gemA, gemA}},
+		{name: "all removed", before: []Enchant{enchant, gemA, gemA}, wantRemoved: []Enchant{enchant, gemA, gemA}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := diffEnchants(tt.before, tt.after)
+			if !reflect.DeepEqual(added, tt.wantAdded) || !reflect.DeepEqual(removed, tt.wantRemoved) {
+				t.Errorf("diffEnchants() = %v, %v, want %v, %v", added, removed, tt.wantAdded, tt.wantRemoved)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package gear
+
+import "sort"
+
+// SlotRecord is one stored equipment row: the item a slot held from Timestamp on.
+// Snapshots only store the slots that changed, and a nil Item marks an emptied slot.
+type SlotRecord struct {
+	Timestamp int64
+	Slot      string
+	Item      *Item
+}
+
+// TimelineEntry lists the slots that changed in one profile snapshot
+type TimelineEntry struct {
+	Timestamp int64        `json:"timestamp"`
+	Changes   []SlotChange `json:"changes"`
+}
+
+// Replay applies a player's slot records in time order and returns the gear worn
+// after the last one along with the changes between consecutive snapshots, oldest
+// first. Records at or before horizon, and those of the player's first snapshot,
+// make up the starting loadout and produce no entries; horizon is where older
+// snapshots were pruned.
+func Replay(records []SlotRecord, horizon int64) (Loadout, []TimelineEntry) {
+	sorted := make([]SlotRecord, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp < sorted[j].Timestamp
+	})
+
+	current := make(Loadout)
+	if len(sorted) == 0 {
+		return current, nil
+	}
+	if sorted[0].Timestamp > horizon {
+		horizon = sorted[0].Timestamp
+	}
+
+	var timeline []TimelineEntry
+	for i := 0; i < len(sorted); {
+		ts := sorted[i].Timestamp
+		next := make(Loadout, len(current))
+		for slot, it := range current {
+			next[slot] = it
+		}
+		for ; i < len(sorted) && sorted[i].Timestamp == ts; i++ {
+			if sorted[i].Item == nil {
+				delete(next, sorted[i].Slot)
+			} else {
+				next[sorted[i].Slot] = *sorted[i].Item
+			}
+		}
+		if ts > horizon {
+			if changes := Diff(current, next); len(changes) > 0 {
+				timeline = append(timeline, TimelineEntry{Timestamp: ts, Changes: changes})
+			}
+		}
+		current = next
+	}
+	return current, timeline
+}
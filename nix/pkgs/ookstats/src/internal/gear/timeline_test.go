@@ -0,0 +1,102 @@
+package gear
+
+import (
+	"reflect"
+	"testing"
+)
+
+func record(ts int64, slot string, it Item) SlotRecord {
+	return SlotRecord{Timestamp: ts, Slot: slot, Item: &it}
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name         string
+		records      []SlotRecord
+		horizon      int64
+		wantLoadout  Loadout
+		wantTimeline []TimelineEntry
+	}{
+		{
+			name:        "no records",
+			wantLoadout: Loadout{},
+		},
+		{
+			name: "first snapshot is the starting loadout",
+			records: []SlotRecord{
+				record(100, "HEAD", helm(496)),
+				record(100, "CHEST", chest(483)),
+			},
+			wantLoadout: Loadout{"HEAD": helm(496), "CHEST": chest(483)},
+		},
+		{
+			name: "later snapshots in time order",
+			records: []SlotRecord{
+				record(300, "HEAD", helm(496, gemB)),
+				record(100, "HEAD", helm(496, gemA)),
+				record(100, "CHEST", chest(483)),
+				record(200, "CHEST", chest(496)),
+			},
+			wantLoadout: Loadout{"HEAD": helm(496, gemB), "CHEST": chest(496)},
+			wantTimeline: []TimelineEntry{
+				{Timestamp: 200, Changes: []SlotChange{{
+					Slot: "CHEST", Kind: ChangeModified,
+					Before: refOf(chest(483)), After: refOf(chest(496)), ItemLevelDelta: delta(13),
+				}}},
+				{Timestamp: 300, Changes: []SlotChange{{
+					Slot: "HEAD", Kind: ChangeModified,
+					Before: refOf(helm(496)), After: refOf(helm(496)),
+					GemsAdded: []Enchant{gemB}, GemsRemoved: []Enchant{gemA},
+				}}},
+			},
+		},
+		{
+			name: "emptied slot",
+			records: []SlotRecord{
+				record(100, "HEAD", helm(496)),
+				record(100, "TRINKET_1", chest(483)),
+				{Timestamp: 200, Slot: "TRINKET_1"},
+			},
+			wantLoadout: Loadout{"HEAD": helm(496)},
+			wantTimeline: []TimelineEntry{
+				{Timestamp: 200, Changes: []SlotChange{{
+					Slot: "TRINKET_1", Kind: ChangeRemoved, Before: refOf(chest(483)),
+				}}},
+			},
+		},
+		{
+			name: "unchanged snapshot",
+			records: []SlotRecord{
+				record(100, "HEAD", helm(496)),
+				record(200, "HEAD", helm(496)),
+			},
+			wantLoadout: Loadout{"HEAD": helm(496)},
+		},
+		{
+			name: "snapshots up to the horizon start the loadout",
+			records: []SlotRecord{
+				record(100, "HEAD", helm(483)),
+				record(200, "HEAD", helm(496)),
+				record(300, "CHEST", chest(496)),
+			},
+			horizon:     200,
+			wantLoadout: Loadout{"HEAD": helm(496), "CHEST": chest(496)},
+			wantTimeline: []TimelineEntry{
+				{Timestamp: 300, Changes: []SlotChange{{
+					Slot: "CHEST", Kind: ChangeAdded, After: refOf(chest(496)),
+				}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadout, timeline := Replay(tt.records, tt.horizon)
+			if !reflect.DeepEqual(loadout, tt.wantLoadout) {
+				t.Errorf("loadout = %+v, want %+v", loadout, tt.wantLoadout)
+			}
+			if !reflect.DeepEqual(timeline, tt.wantTimeline) {
+				t.Errorf("timeline = %+v, want %+v", timeline, tt.wantTimeline)
+			}
+		})
+	}
+}
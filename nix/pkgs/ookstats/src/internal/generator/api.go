@@ -0,0 +1,742 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"ookstats/internal/generator/indexes"
+	"ookstats/internal/wow"
+	"ookstats/internal/writer"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// generationMarkerFile sits next to the api directory and records which generation
+// of the database the directory holds, so an output directory that was generated from
+// another database, or not at all, gets a full generation
+const generationMarkerFile = ".ookstats-generation.json"
+
+// APIOptions configures GenerateAPI
+type APIOptions struct {
+	PageSize  int
+	ShardSize int
+	Workers   int
+	Regions   []string
+	Version   string
+	// Full regenerates everything even when the last generation can be updated in place
+	Full bool
+}
+
+// APIResult summarizes what GenerateAPI wrote
+type APIResult struct {
+	Full bool
+	// Reason is why the generation was full
+	Reason string
+	// Leaderboards and PlayerLeaderboards count the leaderboards the generation wrote
+	Leaderboards       int64
+	PlayerLeaderboards int64
+	Players            int
+	RemovedPlayers     int
+	// Watermark is the generation's start time; changes after it go into the next one
+	Watermark int64
+}
+
+// generationState is the generation_state row of the last generation
+type generationState struct {
+	Signature string
+	Watermark int64
+}
+
+// generationMarker is the content of generationMarkerFile
+type generationMarker struct {
+	Signature string `json:"signature"`
+	Watermark int64  `json:"watermark"`
+}
+
+// GenerateAPI writes the static API under outParent/api. When the directory holds the
+// last generation of this database it only regenerates what changed since: the
+// leaderboard pages of marked partitions, the player leaderboards and files of marked
+// players and of players whose profile was fetched since, and the search index and
+// the per-realm player counts of their regions when players changed. Files of players that were merged away or lost
+// complete coverage are deleted. Anything the marks can't describe (options,
+// dungeons, realms or seasons changed, a full ranking rebuild) makes it full.
+func GenerateAPI(db *sql.DB, outParent string, opts APIOptions) (*APIResult, error) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = 25
+	}
+	if opts.ShardSize <= 0 {
+		opts.ShardSize = 5000
+	}
+	if len(opts.Regions) == 0 {
+		opts.Regions = []string{"us", "eu", "kr", "tw"}
+	}
+
+	base := filepath.Join(outParent, "api")
+	if err := os.MkdirAll(base, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir: %w", err)
+	}
+
+	res := &APIResult{Watermark: time.Now().UnixMilli()}
+	signature, err := generationSignature(db, opts)
+	if err != nil {
+		return nil, err
+	}
+	state, err := loadGenerationState(db)
+	if err != nil {
+		return nil, err
+	}
+	if res.Reason, err = fullGenerationReason(db, outParent, opts, signature, state); err != nil {
+		return nil, err
+	}
+	res.Full = res.Reason != ""
+
+	var files map[int64]string
+	if res.Full {
+		fmt.Printf("Generating static API (full: %s)...\n", res.Reason)
+		files, err = generateFullAPI(db, outParent, opts, res)
+	} else {
+		fmt.Printf("Generating static API (incremental since %s)...\n", time.UnixMilli(state.Watermark).Format(time.RFC3339))
+		files, err = generateIncrementalAPI(db, outParent, opts, state.Watermark, res)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := finishGeneration(db, signature, res, files); err != nil {
+		return nil, err
+	}
+	marker := generationMarker{Signature: signature, Watermark: res.Watermark}
+	if err := writer.WriteJSONFile(filepath.Join(outParent, generationMarkerFile), marker); err != nil {
+		return nil, fmt.Errorf("write generation marker: %w", err)
+	}
+	return res, nil
+}
+
+// generationSignature hashes the options and reference data every generated file
+// depends on but that no dirty mark tracks: dungeons, realms and seasons
+func generationSignature(db *sql.DB, opts APIOptions) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "page_size=%d shard_size=%d regions=%s version=%s\n",
+		opts.PageSize, opts.ShardSize, strings.Join(opts.Regions, ","), opts.Version)
+
+	queries := []string{
+		`SELECT id, COALESCE(slug, ''), COALESCE(name, '') FROM dungeons ORDER BY id`,
+		`SELECT id, COALESCE(region, ''), COALESCE(slug, ''), COALESCE(name, ''), COALESCE(parent_realm_slug, '') FROM realms ORDER BY id`,
+		`SELECT season_number, region, COALESCE(season_name, ''), COALESCE(start_timestamp, 0), COALESCE(end_timestamp, 0) FROM seasons ORDER BY season_number, region`,
+	}
+	for _, q := range queries {
+		rows, err := db.Query(q)
+		if err != nil {
+			return "", fmt.Errorf("failed to read generation reference data: %w", err)
+		}
+		cols, err := rows.Columns()
+		if err != nil {
+			rows.Close()
+			return "", err
+		}
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		for rows.Next() {
+			if err := rows.Scan(ptrs...); err != nil {
+				rows.Close()
+				return "", fmt.Errorf("failed to scan generation reference data: %w", err)
+			}
+			fmt.Fprintln(h, vals...)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadGenerationState returns the last generation, or nil when there was none
+func loadGenerationState(db *sql.DB) (*generationState, error) {
+	var st generationState
+	err := db.QueryRow(`SELECT signature, watermark FROM generation_state WHERE id = 1`).Scan(&st.Signature, &st.Watermark)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generation state: %w", err)
+	}
+	return &st, nil
+}
+
+// fullGenerationReason returns why the output can't be updated incrementally, or ""
+// when it can
+func fullGenerationReason(db *sql.DB, outParent string, opts APIOptions, signature string, state *generationState) (string, error) {
+	switch {
+	case opts.Full:
+		return "requested", nil
+	case state == nil:
+		return "no previous generation", nil
+	case state.Signature != signature:
+		return "options or reference data changed", nil
+	}
+
+	var marker generationMarker
+	data, err := os.ReadFile(filepath.Join(outParent, generationMarkerFile))
+	if err != nil || json.Unmarshal(data, &marker) != nil || marker != (generationMarker{Signature: state.Signature, Watermark: state.Watermark}) {
+		return "output directory does not hold the last generation", nil
+	}
+
+	var fullAt sql.NullInt64
+	err = db.QueryRow(`SELECT full_at FROM ranking_state WHERE id = 1`).Scan(&fullAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "rankings were never computed", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read ranking state: %w", err)
+	}
+	if fullAt.Int64 > state.Watermark {
+		return "rankings were fully rebuilt", nil
+	}
+	return "", nil
+}
+
+// generateFullAPI regenerates every file, deletes player files of players that no
+// longer get one, and returns the player files it wrote
+func generateFullAPI(db *sql.DB, outParent string, opts APIOptions, res *APIResult) (map[int64]string, error) {
+	base := filepath.Join(outParent, "api")
+	playerDir := filepath.Join(base, "player")
+
+	players, err := generatePlayerFiles(db, playerDir, opts.Version, nil)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[int64]string, len(players))
+	keep := make(map[string]bool, 2*len(players))
+	for _, p := range players {
+		rel := playerFilePath(p)
+		files[p.ID] = rel
+		keep[rel] = true
+		keep[playerHistoryPath(rel)] = true
+	}
+	res.Players = len(players)
+
+	// Anything else under the player directory belongs to a player that is gone
+	err = filepath.WalkDir(playerDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		rel, err := filepath.Rel(playerDir, path)
+		if err != nil || keep[rel] {
+			return err
+		}
+		if !strings.HasSuffix(rel, ".history.json") {
+			res.RemovedPlayers++
+		}
+		return os.Remove(path)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("remove stale player files: %w", err)
+	}
+
+	leaderboardDir := filepath.Join(base, "leaderboard")
+	if res.Leaderboards, err = generateAllLeaderboards(db, leaderboardDir, opts.PageSize, opts.Regions, opts.Workers); err != nil {
+		return nil, err
+	}
+	if res.PlayerLeaderboards, err = generateAllPlayerLeaderboards(db, leaderboardDir, opts.PageSize, opts.Regions, opts.Workers); err != nil {
+		return nil, err
+	}
+	if err := GenerateSearchIndex(db, filepath.Join(base, "search"), opts.ShardSize); err != nil {
+		return nil, err
+	}
+	if err := indexes.GenerateAllIndexes(db, outParent); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// dirtyPartition is a leaderboard partition marked since the last generation
+type dirtyPartition struct {
+	seasonID, dungeonID int
+	region, realmSlug   string
+}
+
+// playerScope is a player leaderboard a player shows up in
+type playerScope struct {
+	seasonID                   int
+	region, poolSlug, classKey string
+}
+
+// generateIncrementalAPI regenerates what changed since watermark and returns the
+// player files it wrote; removed players are returned with an empty path
+func generateIncrementalAPI(db *sql.DB, outParent string, opts APIOptions, watermark int64, res *APIResult) (map[int64]string, error) {
+	base := filepath.Join(outParent, "api")
+	playerDir := filepath.Join(base, "player")
+	leaderboardDir := filepath.Join(base, "leaderboard")
+
+	partitions, err := loadDirtyPartitions(db, res.Watermark)
+	if err != nil {
+		return nil, err
+	}
+	dirtyPlayers, err := loadDirtyPlayers(db, watermark, res.Watermark)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := loadGeneratedPlayerFiles(db)
+	if err != nil {
+		return nil, err
+	}
+	eligible, err := loadCompleteCoveragePlayerIDs(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Players whose file exists but who no longer get one: merged away or invalidated
+	var removed []int64
+	for id := range previous {
+		if !eligible[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i] < removed[j] })
+
+	seasons, err := loadSeasons(db)
+	if err != nil {
+		return nil, err
+	}
+	knownSeason := make(map[int]bool, len(seasons))
+	for _, s := range seasons {
+		knownSeason[s.ID] = true
+	}
+	inRegions := make(map[string]bool, len(opts.Regions))
+	for _, r := range opts.Regions {
+		inRegions[r] = true
+	}
+
+	// Run leaderboards of the marked partitions
+	dungeons, err := loadDungeons(db)
+	if err != nil {
+		return nil, err
+	}
+	dungeonByID := make(map[int]dungeonInfo, len(dungeons))
+	for _, d := range dungeons {
+		dungeonByID[d.ID] = d
+	}
+	var jobs []leaderboardJob
+	queued := make(map[leaderboardJob]bool)
+	queue := func(job leaderboardJob) {
+		if !queued[job] {
+			queued[job] = true
+			jobs = append(jobs, job)
+		}
+	}
+	for _, p := range partitions {
+		d, ok := dungeonByID[p.dungeonID]
+		if !ok || !knownSeason[p.seasonID] {
+			continue
+		}
+		job := leaderboardJob{
+			seasonID: p.seasonID,
+			dungeon:  d,
+			out:      filepath.Join(leaderboardDir, "season", fmt.Sprintf("%d", p.seasonID)),
+			pageSize: opts.PageSize,
+		}
+		queue(job)
+		if !inRegions[p.region] {
+			continue
+		}
+		job.region = p.region
+		queue(job)
+		if p.realmSlug != "" {
+			job.realmSlug = p.realmSlug
+			queue(job)
+		}
+	}
+	if len(jobs) > 0 {
+		if res.Leaderboards, err = runLeaderboardJobs(db, jobs, opts.Workers); err != nil {
+			return nil, err
+		}
+	}
+
+	// Player leaderboards of every scope a changed or removed player is listed in
+	scopes, err := loadPlayerScopes(db, dirtyPlayers, removed, previous, seasons)
+	if err != nil {
+		return nil, err
+	}
+	var playerJobs []playerLeaderboardJob
+	queuedPlayerJobs := make(map[string]bool)
+	queuePlayerJob := func(job playerLeaderboardJob) {
+		key := fmt.Sprintf("%d/%s/%s/%s/%s/%s", job.seasonID, job.scope, job.classScope, job.classKey, job.region, job.realmSlug)
+		if !queuedPlayerJobs[key] {
+			queuedPlayerJobs[key] = true
+			playerJobs = append(playerJobs, job)
+		}
+	}
+	for _, sc := range scopes {
+		if !knownSeason[sc.seasonID] {
+			continue
+		}
+		seasonOut := filepath.Join(leaderboardDir, "season", fmt.Sprintf("%d", sc.seasonID))
+		classKeys := playerClassKeys
+		if sc.classKey != "" {
+			classKeys = []string{sc.classKey}
+		}
+		queuePlayerJob(playerLeaderboardJob{seasonID: sc.seasonID, scope: "global", out: seasonOut, pageSize: opts.PageSize})
+		for _, cls := range classKeys {
+			queuePlayerJob(playerLeaderboardJob{seasonID: sc.seasonID, scope: "class", classScope: "global", classKey: cls, out: seasonOut, pageSize: opts.PageSize})
+		}
+		if !inRegions[sc.region] {
+			continue
+		}
+		queuePlayerJob(playerLeaderboardJob{seasonID: sc.seasonID, scope: "regional", region: sc.region, out: seasonOut, pageSize: opts.PageSize})
+		for _, cls := range classKeys {
+			queuePlayerJob(playerLeaderboardJob{seasonID: sc.seasonID, scope: "class", classScope: "regional", classKey: cls, region: sc.region, out: seasonOut, pageSize: opts.PageSize})
+		}
+		if sc.poolSlug == "" {
+			continue
+		}
+		queuePlayerJob(playerLeaderboardJob{seasonID: sc.seasonID, scope: "realm", region: sc.region, realmSlug: sc.poolSlug, out: seasonOut, pageSize: opts.PageSize})
+		for _, cls := range classKeys {
+			queuePlayerJob(playerLeaderboardJob{seasonID: sc.seasonID, scope: "class", classScope: "realm", classKey: cls, region: sc.region, realmSlug: sc.poolSlug, out: seasonOut, pageSize: opts.PageSize})
+		}
+	}
+	if len(playerJobs) > 0 {
+		if res.PlayerLeaderboards, err = runPlayerLeaderboardJobs(db, playerJobs, opts.Workers); err != nil {
+			return nil, err
+		}
+	}
+
+	// Player files of changed players, moving any whose path changed
+	files := make(map[int64]string)
+	regenerate := make(map[int64]bool)
+	for id := range dirtyPlayers {
+		if eligible[id] {
+			regenerate[id] = true
+		}
+	}
+	if len(regenerate) > 0 {
+		players, err := generatePlayerFiles(db, playerDir, opts.Version, func(id int64) bool { return regenerate[id] })
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range players {
+			rel := playerFilePath(p)
+			if old, ok := previous[p.ID]; ok && old != rel {
+				if err := removePlayerFile(playerDir, old); err != nil {
+					return nil, err
+				}
+			}
+			files[p.ID] = rel
+		}
+		res.Players = len(players)
+	}
+	for _, id := range removed {
+		if err := removePlayerFile(playerDir, previous[id]); err != nil {
+			return nil, err
+		}
+		files[id] = ""
+	}
+	res.RemovedPlayers = len(removed)
+
+	// The search index lists players, and the regional realms indexes count them; every
+	// other index only lists reference data, which the signature covers
+	if len(dirtyPlayers) > 0 || len(removed) > 0 {
+		if err := GenerateSearchIndex(db, filepath.Join(base, "search"), opts.ShardSize); err != nil {
+			return nil, err
+		}
+		regions, err := loadChangedPlayerRegions(db, dirtyPlayers, removed, previous)
+		if err != nil {
+			return nil, err
+		}
+		if err := indexes.GeneratePlayerCountIndexes(db, outParent, regions); err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Printf("[OK] Regenerated %d leaderboards, %d player leaderboards and %d players; removed %d players\n",
+		res.Leaderboards, res.PlayerLeaderboards, res.Players, res.RemovedPlayers)
+	return files, nil
+}
+
+// loadDirtyPartitions returns the leaderboard partitions marked up to until
+func loadDirtyPartitions(db *sql.DB, until int64) ([]dirtyPartition, error) {
+	rows, err := db.Query(`
+		SELECT g.season_id, g.dungeon_id, g.region, COALESCE(r.slug, '')
+		FROM generation_dirty_partitions g
+		LEFT JOIN realms r ON r.id = g.realm_id
+		WHERE g.marked_at <= ?
+		ORDER BY g.season_id, g.dungeon_id, g.region, r.slug
+	`, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dirty generation partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []dirtyPartition
+	for rows.Next() {
+		var p dirtyPartition
+		if err := rows.Scan(&p.seasonID, &p.dungeonID, &p.region, &p.realmSlug); err != nil {
+			return nil, fmt.Errorf("failed to scan dirty generation partition: %w", err)
+		}
+		partitions = append(partitions, p)
+	}
+	return partitions, rows.Err()
+}
+
+// loadDirtyPlayers returns the players marked up to until, and those whose details or
+// equipment a profile fetch changed after watermark
+func loadDirtyPlayers(db *sql.DB, watermark, until int64) (map[int64]bool, error) {
+	rows, err := db.Query(`
+		SELECT player_id FROM generation_dirty_players WHERE marked_at <= ?
+		UNION
+		SELECT player_id FROM player_details WHERE last_updated > ?
+		UNION
+		SELECT player_id FROM player_equipment WHERE snapshot_timestamp > ? AND player_id IS NOT NULL
+	`, until, watermark, watermark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dirty generation players: %w", err)
+	}
+	defer rows.Close()
+
+	players := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan dirty generation player: %w", err)
+		}
+		players[id] = true
+	}
+	return players, rows.Err()
+}
+
+// loadGeneratedPlayerFiles returns where each player's file was last written
+func loadGeneratedPlayerFiles(db *sql.DB) (map[int64]string, error) {
+	rows, err := db.Query(`SELECT player_id, path FROM generated_player_files`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generated player files: %w", err)
+	}
+	defer rows.Close()
+
+	files := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var path string
+		if err := rows.Scan(&id, &path); err != nil {
+			return nil, fmt.Errorf("failed to scan generated player file: %w", err)
+		}
+		files[id] = filepath.FromSlash(path)
+	}
+	return files, rows.Err()
+}
+
+// loadCompleteCoveragePlayerIDs returns the players that get a player file
+func loadCompleteCoveragePlayerIDs(db *sql.DB) (map[int64]bool, error) {
+	rows, err := db.Query(`SELECT DISTINCT player_id FROM player_profiles WHERE has_complete_coverage = 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read players with complete coverage: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// loadPlayerScopes returns the player leaderboards the dirty players are listed in
+// per their profiles, and those removed players were listed in going by the region
+// and realm of their last file, in every season and class
+func loadPlayerScopes(db *sql.DB, dirty map[int64]bool, removed []int64, previous map[int64]string, seasons []seasonInfo) ([]playerScope, error) {
+	var scopes []playerScope
+
+	if len(dirty) > 0 {
+		ids := make([]int64, 0, len(dirty))
+		for id := range dirty {
+			ids = append(ids, id)
+		}
+		placeholders := make([]string, len(ids))
+		args := make([]any, len(ids))
+		for i, id := range ids {
+			placeholders[i] = "?"
+			args[i] = id
+		}
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT DISTINCT pp.season_id, r.region,
+			       COALESCE(NULLIF(r.parent_realm_slug, ''), r.slug),
+			       COALESCE(pd.class_name, ''), pp.main_spec_id
+			FROM player_profiles pp
+			JOIN players p ON p.id = pp.player_id
+			JOIN realms r ON r.id = p.realm_id
+			LEFT JOIN player_details pd ON pd.player_id = pp.player_id
+			WHERE pp.player_id IN (%s)
+		`, strings.Join(placeholders, ",")), args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dirty player scopes: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var sc playerScope
+			var className string
+			var mainSpecID sql.NullInt64
+			if err := rows.Scan(&sc.seasonID, &sc.region, &sc.poolSlug, &className, &mainSpecID); err != nil {
+				return nil, fmt.Errorf("failed to scan dirty player scope: %w", err)
+			}
+			if className == "" && mainSpecID.Valid {
+				className, _, _ = wow.GetClassAndSpec(int(mainSpecID.Int64))
+			}
+			sc.classKey = strings.ReplaceAll(strings.ToLower(className), " ", "_")
+			scopes = append(scopes, sc)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, id := range removed {
+		parts := strings.Split(filepath.ToSlash(previous[id]), "/")
+		if len(parts) != 3 {
+			continue
+		}
+		region, realmSlug := parts[0], parts[1]
+		var poolSlug string
+		err := db.QueryRow(`
+			SELECT COALESCE(NULLIF(parent_realm_slug, ''), slug) FROM realms WHERE region = ? AND slug = ?
+		`, region, realmSlug).Scan(&poolSlug)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to read realm pool of %s/%s: %w", region, realmSlug, err)
+		}
+		for _, s := range seasons {
+			scopes = append(scopes, playerScope{seasonID: s.ID, region: region, poolSlug: poolSlug})
+		}
+	}
+	return scopes, nil
+}
+
+// loadChangedPlayerRegions returns the regions of the dirty players, and of the
+// removed players going by their last file, sorted
+func loadChangedPlayerRegions(db *sql.DB, dirty map[int64]bool, removed []int64, previous map[int64]string) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, id := range removed {
+		if region, _, ok := strings.Cut(filepath.ToSlash(previous[id]), "/"); ok {
+			seen[region] = true
+		}
+	}
+
+	if len(dirty) > 0 {
+		placeholders := make([]string, 0, len(dirty))
+		args := make([]any, 0, len(dirty))
+		for id := range dirty {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		rows, err := db.Query(fmt.Sprintf(`
+			SELECT DISTINCT r.region
+			FROM players p
+			JOIN realms r ON r.id = p.realm_id
+			WHERE p.id IN (%s)
+		`, strings.Join(placeholders, ",")), args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read regions of dirty players: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var region string
+			if err := rows.Scan(&region); err != nil {
+				return nil, fmt.Errorf("failed to scan dirty player region: %w", err)
+			}
+			seen[region] = true
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	regions := make([]string, 0, len(seen))
+	for region := range seen {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions, nil
+}
+
+// removePlayerFile deletes a player's file and rank history, relative to dir
+func removePlayerFile(dir, rel string) error {
+	path := filepath.Join(dir, rel)
+	for _, p := range []string{path, playerHistoryPath(path)} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove player file: %w", err)
+		}
+	}
+	return nil
+}
+
+// finishGeneration records the generation, consumes the marks it covered and stores
+// where player files were written. A full generation replaces every stored path; an
+// empty path drops the player's.
+func finishGeneration(db *sql.DB, signature string, res *APIResult, files map[int64]string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin generation state update: %w", err)
+	}
+	defer tx.Rollback()
+
+	var fullAt, incrementalAt any
+	if res.Full {
+		fullAt = res.Watermark
+	} else {
+		incrementalAt = res.Watermark
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO generation_state (id, signature, watermark, full_at, incremental_at)
+		VALUES (1, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			signature = excluded.signature,
+			watermark = excluded.watermark,
+			full_at = COALESCE(excluded.full_at, generation_state.full_at),
+			incremental_at = COALESCE(excluded.incremental_at, generation_state.incremental_at)
+	`, signature, res.Watermark, fullAt, incrementalAt); err != nil {
+		return fmt.Errorf("failed to record generation state: %w", err)
+	}
+
+	for _, table := range []string{"generation_dirty_partitions", "generation_dirty_players"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE marked_at <= ?`, res.Watermark); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+
+	if res.Full {
+		if _, err := tx.Exec(`DELETE FROM generated_player_files`); err != nil {
+			return fmt.Errorf("failed to clear generated player files: %w", err)
+		}
+	}
+	for id, path := range files {
+		if path == "" {
+			if _, err := tx.Exec(`DELETE FROM generated_player_files WHERE player_id = ?`, id); err != nil {
+				return fmt.Errorf("failed to drop generated player file: %w", err)
+			}
+			continue
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO generated_player_files (player_id, path) VALUES (?, ?)
+			ON CONFLICT(player_id) DO UPDATE SET path = excluded.path
+		`, id, filepath.ToSlash(path)); err != nil {
+			return fmt.Errorf("failed to record generated player file: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit generation state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package generator_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"ookstats/internal/database"
+	"ookstats/internal/dbtest"
+	"ookstats/internal/generator"
+	"ookstats/internal/pipeline"
+)
+
+// playerCountIndex matches the regional realms indexes, the only indexes listing
+// player counts
+var playerCountIndex = regexp.MustCompile(`^leaderboard/season/\d+/(us|eu|kr|tw)/index\.json$`)
+
+func TestIncrementalGenerationKeepsReferenceIndexes(t *testing.T) {
+	db := dbtest.OpenSQLite(t)
+	ds := database.NewDatabaseService(db)
+	f := dbtest.Seed(t, ds)
+	out := t.TempDir()
+
+	dbtest.Ingest(t, ds, f, 8)
+	process(t, ds)
+	res := generate(t, db, out, generator.APIOptions{})
+	if !res.Full {
+		t.Fatal("first generation was incremental")
+	}
+	if res.Leaderboards == 0 || res.PlayerLeaderboards == 0 {
+		t.Errorf("full generation counted %d leaderboards and %d player leaderboards", res.Leaderboards, res.PlayerLeaderboards)
+	}
+	before := writeManifest(t, out)
+
+	dbtest.Ingest(t, ds, f, 9)
+	process(t, ds)
+	res = generate(t, db, out, generator.APIOptions{})
+	if res.Full {
+		t.Fatalf("generation after new runs was full: %s", res.Reason)
+	}
+	if res.Players == 0 {
+		t.Fatal("new runs regenerated no players")
+	}
+	diff := generator.DiffManifests(before, writeManifest(t, out))
+	for _, p := range append(diff.Added, diff.Changed...) {
+		if strings.HasSuffix(p, "index.json") && !playerCountIndex.MatchString(p) {
+			t.Errorf("incremental generation rewrote %s", p)
+		}
+	}
+}
+
+// TestRegenerationWithoutChangesIsEmpty regenerates everything from an unchanged
+// database and checks no file's hash moved, generation timestamps included
+func TestRegenerationWithoutChangesIsEmpty(t *testing.T) {
+	db := dbtest.OpenSQLite(t)
+	ds := database.NewDatabaseService(db)
+	f := dbtest.Seed(t, ds)
+	out := t.TempDir()
+
+	dbtest.Ingest(t, ds, f, 8)
+	process(t, ds)
+	generate(t, db, out, generator.APIOptions{})
+	before := writeManifest(t, out)
+
+	// timestamps in the files have second resolution
+	time.Sleep(1100 * time.Millisecond)
+	generate(t, db, out, generator.APIOptions{Full: true})
+	diff := generator.DiffManifests(before, writeManifest(t, out))
+	if n := len(diff.Added) + len(diff.Changed) + len(diff.Removed); n > 0 {
+		t.Errorf("regeneration without changes touched %d files: added %v, changed %v, removed %v",
+			n, diff.Added, diff.Changed, diff.Removed)
+	}
+}
+
+func process(t *testing.T, ds *database.DatabaseService) {
+	t.Helper()
+	if _, err := pipeline.ProcessAll(context.Background(), ds, pipeline.ProcessAllOptions{}); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+}
+
+func generate(t *testing.T, db *sql.DB, out string, opts generator.APIOptions) *generator.APIResult {
+	t.Helper()
+	res, err := generator.GenerateAPI(db, out, opts)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	return res
+}
+
+func writeManifest(t *testing.T, out string) *generator.Manifest {
+	t.Helper()
+	m, err := generator.WriteManifest(filepath.Join(out, "api"))
+	if err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	return m
+}
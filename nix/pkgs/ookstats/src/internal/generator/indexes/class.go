@@ -31,7 +31,7 @@ func GenerateClassScopeIndex(outDir string, seasonID int, classKey string) error
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), "players", "class", classKey, "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
@@ -53,7 +53,7 @@ func GenerateClassRegionalIndex(outDir string, seasonID int, classKey string) er
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), "players", "class", classKey, "regional", "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
@@ -75,7 +75,7 @@ func GenerateClassRealmRegionIndex(outDir string, seasonID int, classKey string)
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), "players", "class", classKey, "realm", "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
@@ -108,7 +108,7 @@ func GenerateClassRealmListIndex(db *sql.DB, outDir string, seasonID int, classK
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), "players", "class", classKey, "realm", region, "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
@@ -109,7 +109,7 @@ func GenerateGlobalDungeonsIndex(db *sql.DB, outDir string, seasonID int) error
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), "global", "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
@@ -137,7 +137,7 @@ func GenerateRealmDungeonsIndex(db *sql.DB, outDir string, seasonID int, region,
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), region, realm, "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
@@ -3,6 +3,7 @@ package indexes
 import (
 	"database/sql"
 	"fmt"
+	"slices"
 
 	"github.com/charmbracelet/log"
 )
@@ -126,6 +127,28 @@ func GenerateAllIndexes(db *sql.DB, outDir string) error {
 	return nil
 }
 
+// GeneratePlayerCountIndexes regenerates the only indexes that depend on players
+// rather than on seasons, dungeons and realms: the regional realms indexes of the
+// given regions, which carry per-realm player counts
+func GeneratePlayerCountIndexes(db *sql.DB, outDir string, regions []string) error {
+	seasonID, err := getCurrentSeasonID(db)
+	if err != nil {
+		return fmt.Errorf("get current season: %w", err)
+	}
+	if seasonID == 0 {
+		seasonID = 1
+	}
+	for _, region := range regions {
+		if !slices.Contains(allRegions, region) {
+			continue
+		}
+		if err := GenerateRegionalRealmsIndex(db, outDir, seasonID, region); err != nil {
+			return fmt.Errorf("regional realms index for %s: %w", region, err)
+		}
+	}
+	return nil
+}
+
 func getCurrentSeasonID(db *sql.DB) (int, error) {
 	var seasonID int
 	err := db.QueryRow(`
@@ -64,7 +64,7 @@ func GeneratePlayersScopeIndex(outDir string, seasonID int) error {
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), "players", "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
@@ -89,7 +89,7 @@ func GeneratePlayersRegionalIndex(outDir string, seasonID int) error {
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), "players", "regional", "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
@@ -114,7 +114,7 @@ func GeneratePlayersRealmRegionIndex(outDir string, seasonID int) error {
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), "players", "realm", "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
@@ -150,7 +150,7 @@ func GeneratePlayersRealmListIndex(db *sql.DB, outDir string, seasonID int, regi
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), "players", "realm", region, "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
@@ -206,7 +206,7 @@ func GeneratePlayersClassIndex(outDir string, seasonID int) error {
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), "players", "class", "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
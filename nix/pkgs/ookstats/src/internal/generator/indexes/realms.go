@@ -24,7 +24,7 @@ func GenerateRegionalRealmsIndex(db *sql.DB, outDir string, seasonID int, region
 		FROM realms r
 		LEFT JOIN players p ON p.realm_id = r.id
 		WHERE r.region = ?
-		GROUP BY r.slug
+		GROUP BY r.id, r.slug, r.name, r.connected_realm_id, r.parent_realm_slug
 		ORDER BY r.slug
 	`, region)
 	if err != nil {
@@ -77,7 +77,7 @@ func GenerateRegionalRealmsIndex(db *sql.DB, outDir string, seasonID int, region
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), region, "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
@@ -30,7 +30,7 @@ func GenerateRootIndex(outDir string) error {
 	}
 
 	outPath := filepath.Join(outDir, "api", "index.json")
-	if err := writer.WriteJSONFile(outPath, root); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, root, "last_updated"); err != nil {
 		return err
 	}
 
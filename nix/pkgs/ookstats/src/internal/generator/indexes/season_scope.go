@@ -57,7 +57,7 @@ func GenerateSeasonScopeIndex(outDir string, seasonID int) error {
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", fmt.Sprintf("%d", seasonID), "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
@@ -16,7 +16,7 @@ func GenerateSeasonsIndex(db *sql.DB, outDir string) error {
 	rows, err := db.Query(`
 		SELECT
 			season_number,
-			season_name,
+			MAX(season_name) as season_name,
 			MIN(start_timestamp) as start_ts,
 			MAX(end_timestamp) as end_ts
 		FROM seasons
@@ -82,7 +82,7 @@ func GenerateSeasonsIndex(db *sql.DB, outDir string) error {
 	}
 
 	outPath := filepath.Join(outDir, "api", "leaderboard", "season", "index.json")
-	if err := writer.WriteJSONFile(outPath, index); err != nil {
+	if err := writer.WriteJSONFileStable(outPath, index, "metadata.last_updated"); err != nil {
 		return err
 	}
 
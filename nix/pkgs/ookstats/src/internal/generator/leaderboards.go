@@ -7,6 +7,8 @@ import (
 	"ookstats/internal/writer"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
@@ -71,6 +73,12 @@ type leaderboardJob struct {
 // GenerateLeaderboards generates leaderboard JSON files for all scopes (global, regional, realm) per season
 // Uses a worker pool for parallel generation
 func GenerateLeaderboards(db *sql.DB, out string, pageSize int, regions []string, workers int) error {
+	_, err := generateAllLeaderboards(db, out, pageSize, regions, workers)
+	return err
+}
+
+// generateAllLeaderboards generates every leaderboard and returns how many were written
+func generateAllLeaderboards(db *sql.DB, out string, pageSize int, regions []string, workers int) (int64, error) {
 	if pageSize <= 0 {
 		pageSize = 25
 	}
@@ -78,24 +86,24 @@ func GenerateLeaderboards(db *sql.DB, out string, pageSize int, regions []string
 		workers = 10
 	}
 	if err := os.MkdirAll(out, 0o755); err != nil {
-		return err
+		return 0, err
 	}
 
 	// Load dungeons
 	dungeons, err := loadDungeons(db)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Load seasons
 	seasons, err := loadSeasons(db)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if len(seasons) == 0 {
 		fmt.Println("Warning: No seasons found - skipping leaderboard generation")
-		return nil
+		return 0, nil
 	}
 
 	// Regions
@@ -108,25 +116,81 @@ func GenerateLeaderboards(db *sql.DB, out string, pageSize int, regions []string
 	for _, reg := range regions {
 		slugs, err := loadRealmSlugs(db, reg)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		realmSlugs[reg] = slugs
 	}
 
-	// Count total jobs for progress reporting
-	var totalJobs int
-	for range seasons {
-		totalJobs += len(dungeons) // global
+	// Queue all jobs
+	var jobs []leaderboardJob
+	for _, season := range seasons {
+		seasonOut := filepath.Join(out, "season", fmt.Sprintf("%d", season.ID))
+
+		// Global leaderboards
+		for _, d := range dungeons {
+			jobs = append(jobs, leaderboardJob{
+				seasonID:   season.ID,
+				seasonName: season.Name,
+				dungeon:    d,
+				region:     "",
+				realmSlug:  "",
+				out:        seasonOut,
+				pageSize:   pageSize,
+			})
+		}
+
+		// Regional leaderboards
+		for _, reg := range regions {
+			for _, d := range dungeons {
+				jobs = append(jobs, leaderboardJob{
+					seasonID:   season.ID,
+					seasonName: season.Name,
+					dungeon:    d,
+					region:     reg,
+					realmSlug:  "",
+					out:        seasonOut,
+					pageSize:   pageSize,
+				})
+			}
+		}
+
+		// Realm leaderboards
 		for _, reg := range regions {
-			totalJobs += len(dungeons)                        // regional
-			totalJobs += len(realmSlugs[reg]) * len(dungeons) // realm
+			for _, rslug := range realmSlugs[reg] {
+				for _, d := range dungeons {
+					jobs = append(jobs, leaderboardJob{
+						seasonID:   season.ID,
+						seasonName: season.Name,
+						dungeon:    d,
+						region:     reg,
+						realmSlug:  rslug,
+						out:        seasonOut,
+						pageSize:   pageSize,
+					})
+				}
+			}
 		}
 	}
 
+	completed, err := runLeaderboardJobs(db, jobs, workers)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Printf("\n[OK] Generated %d leaderboards\n", completed)
+	return completed, nil
+}
+
+// runLeaderboardJobs generates the given leaderboards on a pool of workers and returns
+// how many were written
+func runLeaderboardJobs(db *sql.DB, jobs []leaderboardJob, workers int) (int64, error) {
+	if workers <= 0 {
+		workers = 10
+	}
+	totalJobs := len(jobs)
 	fmt.Printf("Generating leaderboards with %d workers (%d total jobs)...\n", workers, totalJobs)
 
 	// Create job channel and error channel
-	jobs := make(chan leaderboardJob, 100)
+	queue := make(chan leaderboardJob, 100)
 	var firstErr atomic.Value
 	var completed atomic.Int64
 	var wg sync.WaitGroup
@@ -136,7 +200,7 @@ func GenerateLeaderboards(db *sql.DB, out string, pageSize int, regions []string
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for job := range jobs {
+			for job := range queue {
 				// Skip if we already have an error
 				if firstErr.Load() != nil {
 					continue
@@ -164,68 +228,19 @@ func GenerateLeaderboards(db *sql.DB, out string, pageSize int, regions []string
 		}()
 	}
 
-	// Queue all jobs
-	for _, season := range seasons {
-		fmt.Printf("\n=== Queuing Season %d (%s) ===\n", season.ID, season.Name)
-		seasonOut := filepath.Join(out, "season", fmt.Sprintf("%d", season.ID))
-
-		// Global leaderboards
-		for _, d := range dungeons {
-			jobs <- leaderboardJob{
-				seasonID:   season.ID,
-				seasonName: season.Name,
-				dungeon:    d,
-				region:     "",
-				realmSlug:  "",
-				out:        seasonOut,
-				pageSize:   pageSize,
-			}
-		}
-
-		// Regional leaderboards
-		for _, reg := range regions {
-			for _, d := range dungeons {
-				jobs <- leaderboardJob{
-					seasonID:   season.ID,
-					seasonName: season.Name,
-					dungeon:    d,
-					region:     reg,
-					realmSlug:  "",
-					out:        seasonOut,
-					pageSize:   pageSize,
-				}
-			}
-		}
-
-		// Realm leaderboards
-		for _, reg := range regions {
-			for _, rslug := range realmSlugs[reg] {
-				for _, d := range dungeons {
-					jobs <- leaderboardJob{
-						seasonID:   season.ID,
-						seasonName: season.Name,
-						dungeon:    d,
-						region:     reg,
-						realmSlug:  rslug,
-						out:        seasonOut,
-						pageSize:   pageSize,
-					}
-				}
-			}
-		}
+	for _, job := range jobs {
+		queue <- job
 	}
-	close(jobs)
+	close(queue)
 
 	// Wait for all workers to complete
 	wg.Wait()
 
 	// Check for errors
 	if err := firstErr.Load(); err != nil {
-		return err.(error)
+		return completed.Load(), err.(error)
 	}
-
-	fmt.Printf("\n[OK] Generated %d leaderboards\n", completed.Load())
-	return nil
+	return completed.Load(), nil
 }
 
 // loadDungeons loads all dungeons from the database
@@ -326,7 +341,7 @@ func generateGlobalLeaderboard(db *sql.DB, out string, d dungeonInfo, seasonID,
 			return err
 		}
 	}
-	return nil
+	return removePagesAfter(dir, pages)
 }
 
 // generateRegionalLeaderboard generates regional leaderboard pages for a dungeon
@@ -363,7 +378,7 @@ func generateRegionalLeaderboard(db *sql.DB, out, region string, d dungeonInfo,
 			return err
 		}
 	}
-	return nil
+	return removePagesAfter(dir, pages)
 }
 
 // generateRealmLeaderboard generates realm leaderboard pages for a dungeon
@@ -384,7 +399,8 @@ func generateRealmLeaderboard(db *sql.DB, out, region, realmSlug string, d dunge
 		SELECT COUNT(*) FROM (
 			SELECT team_signature
 			FROM challenge_runs cr
-			JOIN realms rr ON cr.realm_id = rr.id
+			JOIN run_sightings rs ON rs.run_id = cr.id
+			JOIN realms rr ON rs.realm_id = rr.id
 			
 			WHERE cr.dungeon_id = ? AND rr.region = ? AND rr.slug = ? AND cr.season_id = ?
 			GROUP BY team_signature
@@ -405,6 +421,28 @@ func generateRealmLeaderboard(db *sql.DB, out, region, realmSlug string, d dunge
 			return err
 		}
 	}
+	return removePagesAfter(dir, pages)
+}
+
+// removePagesAfter deletes the numbered pages past the last one of a leaderboard that
+// shrank since it was last generated
+func removePagesAfter(dir string, pages int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSuffix(name, ".json")); err != nil || n <= pages {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
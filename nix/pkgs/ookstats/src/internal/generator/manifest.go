@@ -0,0 +1,172 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ookstats/internal/writer"
+)
+
+// ManifestFileName is the manifest's name inside the api directory
+const ManifestFileName = "manifest.json"
+
+// manifestMtimeSlack covers filesystems with coarse modification times: a file counts
+// as untouched since the previous manifest only if it is older than that by this much
+const manifestMtimeSlack = 2 * time.Second
+
+// Manifest lists every file of a generated api directory
+type Manifest struct {
+	// Generation counts manifests written into the directory, starting at 1
+	Generation  int64          `json:"generation"`
+	GeneratedAt int64          `json:"generated_at"`
+	Files       []ManifestFile `json:"files"`
+}
+
+// ManifestFile is one file of the api directory
+type ManifestFile struct {
+	// Path is relative to the api directory, with forward slashes
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Generation is the manifest generation the file's current content first appeared in
+	Generation int64 `json:"generation"`
+}
+
+// ManifestDiff lists the paths that differ between two manifests
+type ManifestDiff struct {
+	Added   []string `json:"added"`
+	Changed []string `json:"changed"`
+	Removed []string `json:"removed"`
+}
+
+// WriteManifest hashes every file under apiDir and writes apiDir/manifest.json. Files
+// the previous manifest lists and that weren't written since keep its entry; a file
+// rewritten with the same content keeps its generation.
+func WriteManifest(apiDir string) (*Manifest, error) {
+	path := filepath.Join(apiDir, ManifestFileName)
+	prev, err := LoadManifest(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		// an unreadable manifest only costs rehashing everything
+		fmt.Printf("Warning: ignoring previous manifest: %v\n", err)
+	}
+	if prev == nil {
+		prev = &Manifest{}
+	}
+	previous := make(map[string]ManifestFile, len(prev.Files))
+	for _, f := range prev.Files {
+		previous[f.Path] = f
+	}
+	untouchedBefore := time.UnixMilli(prev.GeneratedAt).Add(-manifestMtimeSlack)
+
+	m := &Manifest{Generation: prev.Generation + 1, GeneratedAt: time.Now().UnixMilli()}
+	var hashed int
+	err = filepath.WalkDir(apiDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(apiDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		// skip the manifest itself and writers' temp files
+		if rel == ManifestFileName || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		old, listed := previous[rel]
+		if listed && old.Size == info.Size() && info.ModTime().Before(untouchedBefore) {
+			m.Files = append(m.Files, old)
+			return nil
+		}
+		sum, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		hashed++
+		entry := ManifestFile{Path: rel, Size: info.Size(), SHA256: sum, Generation: m.Generation}
+		if listed && old.SHA256 == sum {
+			entry.Generation = old.Generation
+		}
+		m.Files = append(m.Files, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan %s: %w", apiDir, err)
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+
+	if err := writer.WriteJSONFileCompact(path, m); err != nil {
+		return nil, err
+	}
+	fmt.Printf("[OK] Wrote manifest generation %d: %d files, %d hashed\n", m.Generation, len(m.Files), hashed)
+	return m, nil
+}
+
+// LoadManifest reads a manifest written by WriteManifest
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// DiffManifests returns the paths added, changed and removed going from old to cur,
+// each sorted
+func DiffManifests(old, cur *Manifest) ManifestDiff {
+	before := make(map[string]ManifestFile, len(old.Files))
+	for _, f := range old.Files {
+		before[f.Path] = f
+	}
+	diff := ManifestDiff{Added: []string{}, Changed: []string{}, Removed: []string{}}
+	for _, f := range cur.Files {
+		prev, ok := before[f.Path]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, f.Path)
+		case prev.SHA256 != f.SHA256 || prev.Size != f.Size:
+			diff.Changed = append(diff.Changed, f.Path)
+		}
+		delete(before, f.Path)
+	}
+	for p := range before {
+		diff.Removed = append(diff.Removed, p)
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Changed)
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+// hashFile returns the hex sha256 of a file's content
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
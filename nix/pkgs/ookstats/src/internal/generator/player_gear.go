@@ -0,0 +1,133 @@
+package generator
+
+import (
+	"sort"
+
+	"ookstats/internal/gear"
+	"ookstats/internal/loader"
+)
+
+// latestEquipment returns the newest row of every slot that still holds an item
+func latestEquipment(snapshots map[int64][]loader.EquipmentData) []loader.EquipmentData {
+	timestamps := make([]int64, 0, len(snapshots))
+	for ts := range snapshots {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	bySlot := make(map[string]loader.EquipmentData)
+	for _, ts := range timestamps {
+		for _, eq := range snapshots[ts] {
+			if eq.ItemID.Valid {
+				bySlot[eq.SlotType] = eq
+			} else {
+				delete(bySlot, eq.SlotType)
+			}
+		}
+	}
+
+	latest := make([]loader.EquipmentData, 0, len(bySlot))
+	for _, eq := range bySlot {
+		latest = append(latest, eq)
+	}
+	sort.Slice(latest, func(i, j int) bool { return latest[i].SlotType < latest[j].SlotType })
+	return latest
+}
+
+// buildEquipmentJSON renders a player's current gear keyed by slot type
+func buildEquipmentJSON(snapshots map[int64][]loader.EquipmentData, enchantmentsMap map[int64][]loader.EnchantmentData) map[string]any {
+	equipment := make(map[string]any)
+	for _, eq := range latestEquipment(snapshots) {
+		eqData := map[string]any{
+			"id":                 eq.ID,
+			"slot_type":          eq.SlotType,
+			"item_id":            int(eq.ItemID.Int64),
+			"upgrade_id":         nil,
+			"item_level":         nil,
+			"quality":            eq.Quality,
+			"item_name":          eq.ItemName,
+			"snapshot_timestamp": eq.SnapshotTs,
+			"item_icon_slug":     eq.ItemIcon.String,
+			"item_type":          eq.ItemType.String,
+			"enchantments":       []map[string]any{},
+		}
+
+		if eq.UpgradeID.Valid {
+			eqData["upgrade_id"] = int(eq.UpgradeID.Int64)
+		}
+		if eq.ItemLevel.Valid {
+			eqData["item_level"] = int(eq.ItemLevel.Int64)
+		}
+
+		// Add enchantments
+		enchantments := []map[string]any{}
+		for _, ench := range enchantmentsMap[eq.ID] {
+			enchData := map[string]any{
+				"enchantment_id":   nil,
+				"slot_id":          nil,
+				"slot_type":        ench.SlotType.String,
+				"display_string":   ench.DisplayString.String,
+				"source_item_id":   nil,
+				"source_item_name": ench.SourceItemName.String,
+				"spell_id":         nil,
+			}
+
+			if ench.EnchantmentID.Valid {
+				enchData["enchantment_id"] = int(ench.EnchantmentID.Int64)
+			}
+			if ench.SlotID.Valid {
+				enchData["slot_id"] = int(ench.SlotID.Int64)
+			}
+			if ench.SourceItemID.Valid {
+				enchData["source_item_id"] = int(ench.SourceItemID.Int64)
+			}
+			if ench.SpellID.Valid {
+				enchData["spell_id"] = int(ench.SpellID.Int64)
+			}
+			if ench.GemIconSlug.Valid {
+				enchData["gem_icon_slug"] = ench.GemIconSlug.String
+			}
+			enchantments = append(enchantments, enchData)
+		}
+		eqData["enchantments"] = enchantments
+
+		equipment[eq.SlotType] = eqData
+	}
+	return equipment
+}
+
+// buildGearTimeline diffs a player's equipment snapshots; horizon is where older
+// snapshots were pruned
+func buildGearTimeline(snapshots map[int64][]loader.EquipmentData, enchantmentsMap map[int64][]loader.EnchantmentData, horizon int64) []gear.TimelineEntry {
+	var records []gear.SlotRecord
+	for ts, rows := range snapshots {
+		for _, eq := range rows {
+			rec := gear.SlotRecord{Timestamp: ts, Slot: eq.SlotType}
+			if eq.ItemID.Valid {
+				rec.Item = toGearItem(eq, enchantmentsMap[eq.ID])
+			}
+			records = append(records, rec)
+		}
+	}
+	_, timeline := gear.Replay(records, horizon)
+	return timeline
+}
+
+func toGearItem(eq loader.EquipmentData, enchants []loader.EnchantmentData) *gear.Item {
+	it := &gear.Item{
+		ItemID:    int(eq.ItemID.Int64),
+		Name:      eq.ItemName,
+		Quality:   eq.Quality,
+		UpgradeID: int(eq.UpgradeID.Int64),
+		ItemLevel: int(eq.ItemLevel.Int64),
+	}
+	for _, ench := range enchants {
+		it.Enchants = append(it.Enchants, gear.Enchant{
+			EnchantmentID: int(ench.EnchantmentID.Int64),
+			SourceItemID:  int(ench.SourceItemID.Int64),
+			Name:          ench.SourceItemName.String,
+			DisplayString: ench.DisplayString.String,
+		})
+	}
+	return it
+}
@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"database/sql"
+	"fmt"
+
+	"ookstats/internal/loader"
+)
+
+// RankHistoryPointJSON is a player's ranks as recorded by one processing run. A point
+// is only recorded when something changed, so each holds until the next one.
+type RankHistoryPointJSON struct {
+	RecordedAt       int64  `json:"recorded_at"`
+	CombinedBestTime *int64 `json:"combined_best_time,omitempty"`
+	GlobalRanking    *int   `json:"global_ranking,omitempty"`
+	RegionalRanking  *int   `json:"regional_ranking,omitempty"`
+	RealmRanking     *int   `json:"realm_ranking,omitempty"`
+	GlobalClassRank  *int   `json:"global_class_rank,omitempty"`
+	RegionClassRank  *int   `json:"region_class_rank,omitempty"`
+	RealmClassRank   *int   `json:"realm_class_rank,omitempty"`
+}
+
+// PlayerHistoryJSON is the rank-over-time file written next to a player's JSON
+type PlayerHistoryJSON struct {
+	ID          int64                             `json:"id"`
+	Name        string                            `json:"name"`
+	RealmSlug   string                            `json:"realm_slug"`
+	Region      string                            `json:"region"`
+	Seasons     map[string][]RankHistoryPointJSON `json:"seasons"`
+	GeneratedAt int64                             `json:"generated_at"`
+	Version     string                            `json:"version"`
+}
+
+// buildPlayerHistoryJSON groups a player's ranking history by season, oldest first
+func buildPlayerHistoryJSON(pj PlayerJSON, history []loader.RankingHistoryData, generatedAt int64, version string) PlayerHistoryJSON {
+	out := PlayerHistoryJSON{
+		ID:          pj.ID,
+		Name:        pj.Name,
+		RealmSlug:   pj.RealmSlug,
+		Region:      pj.Region,
+		Seasons:     make(map[string][]RankHistoryPointJSON),
+		GeneratedAt: generatedAt,
+		Version:     version,
+	}
+	for _, h := range history {
+		point := RankHistoryPointJSON{
+			RecordedAt:       h.RecordedAt,
+			CombinedBestTime: nullInt64Ptr(h.CombinedBest),
+			GlobalRanking:    nullIntPtr(h.GlobalRanking),
+			RegionalRanking:  nullIntPtr(h.RegionalRanking),
+			RealmRanking:     nullIntPtr(h.RealmRanking),
+			GlobalClassRank:  nullIntPtr(h.GlobalClassRank),
+			RegionClassRank:  nullIntPtr(h.RegionClassRank),
+			RealmClassRank:   nullIntPtr(h.RealmClassRank),
+		}
+		key := fmt.Sprintf("%d", h.SeasonID)
+		out.Seasons[key] = append(out.Seasons[key], point)
+	}
+	return out
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+func nullInt64Ptr(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	i := v.Int64
+	return &i
+}
@@ -21,14 +21,24 @@ type playerLeaderboardJob struct {
 	region     string // for regional/realm/class scopes
 	realmSlug  string // for realm scope
 	classKey   string // for class scope
+	classScope string // for class scope: "global", "regional" or "realm"; empty for all of them
 	out        string
 	pageSize   int
 	regions    []string // for class scope
 }
 
+// playerClassKeys are the class leaderboards generated per season
+var playerClassKeys = []string{"death_knight", "druid", "hunter", "mage", "monk", "paladin", "priest", "rogue", "shaman", "warlock", "warrior"}
+
 // GeneratePlayerLeaderboards generates player ranking JSON files for all scopes per season
 // Uses a worker pool for parallel generation
 func GeneratePlayerLeaderboards(db *sql.DB, out string, pageSize int, regions []string, workers int) error {
+	_, err := generateAllPlayerLeaderboards(db, out, pageSize, regions, workers)
+	return err
+}
+
+// generateAllPlayerLeaderboards generates every player leaderboard and returns how many were written
+func generateAllPlayerLeaderboards(db *sql.DB, out string, pageSize int, regions []string, workers int) (int64, error) {
 	if pageSize <= 0 {
 		pageSize = 25
 	}
@@ -44,12 +54,12 @@ func GeneratePlayerLeaderboards(db *sql.DB, out string, pageSize int, regions []
 	// Load seasons
 	seasons, err := loadSeasons(db)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if len(seasons) == 0 {
 		fmt.Println("Warning: No seasons found - skipping player leaderboard generation")
-		return nil
+		return 0, nil
 	}
 
 	// Pre-load realm slugs per region (only parent realms)
@@ -62,14 +72,14 @@ func GeneratePlayerLeaderboards(db *sql.DB, out string, pageSize int, regions []
 			ORDER BY slug
 		`, reg)
 		if err != nil {
-			return fmt.Errorf("load realm slugs: %w", err)
+			return 0, fmt.Errorf("load realm slugs: %w", err)
 		}
 		var slugs []string
 		for rrows.Next() {
 			var s string
 			if err := rrows.Scan(&s); err != nil {
 				rrows.Close()
-				return err
+				return 0, err
 			}
 			slugs = append(slugs, s)
 		}
@@ -77,23 +87,76 @@ func GeneratePlayerLeaderboards(db *sql.DB, out string, pageSize int, regions []
 		realmSlugs[reg] = slugs
 	}
 
-	classKeys := []string{"death_knight", "druid", "hunter", "mage", "monk", "paladin", "priest", "rogue", "shaman", "warlock", "warrior"}
+	// Queue all jobs
+	var jobs []playerLeaderboardJob
+	for _, season := range seasons {
+		seasonOut := filepath.Join(out, "season", fmt.Sprintf("%d", season.ID))
 
-	// Count total jobs
-	var totalJobs int
-	for range seasons {
-		totalJobs += 1            // global
-		totalJobs += len(regions) // regional
+		// Global
+		jobs = append(jobs, playerLeaderboardJob{
+			seasonID: season.ID,
+			scope:    "global",
+			out:      seasonOut,
+			pageSize: pageSize,
+		})
+
+		// Regional
 		for _, reg := range regions {
-			totalJobs += len(realmSlugs[reg]) // realm
+			jobs = append(jobs, playerLeaderboardJob{
+				seasonID: season.ID,
+				scope:    "regional",
+				region:   reg,
+				out:      seasonOut,
+				pageSize: pageSize,
+			})
 		}
-		totalJobs += len(classKeys) // class (each handles all scopes internally)
+
+		// Realm
+		for _, reg := range regions {
+			for _, rslug := range realmSlugs[reg] {
+				jobs = append(jobs, playerLeaderboardJob{
+					seasonID:  season.ID,
+					scope:     "realm",
+					region:    reg,
+					realmSlug: rslug,
+					out:       seasonOut,
+					pageSize:  pageSize,
+				})
+			}
+		}
+
+		// Class (each handles global/regional/realm internally)
+		for _, cls := range playerClassKeys {
+			jobs = append(jobs, playerLeaderboardJob{
+				seasonID: season.ID,
+				scope:    "class",
+				classKey: cls,
+				out:      seasonOut,
+				pageSize: pageSize,
+				regions:  regions,
+			})
+		}
+	}
+
+	completed, err := runPlayerLeaderboardJobs(db, jobs, workers)
+	if err != nil {
+		return 0, err
 	}
+	fmt.Printf("\n[OK] Generated %d player leaderboards\n", completed)
+	return completed, nil
+}
 
+// runPlayerLeaderboardJobs generates the given player leaderboards on a pool of
+// workers and returns how many were written
+func runPlayerLeaderboardJobs(db *sql.DB, jobs []playerLeaderboardJob, workers int) (int64, error) {
+	if workers <= 0 {
+		workers = 10
+	}
+	totalJobs := len(jobs)
 	fmt.Printf("Generating player leaderboards with %d workers (%d total jobs)...\n", workers, totalJobs)
 
 	// Create job channel
-	jobs := make(chan playerLeaderboardJob, 100)
+	queue := make(chan playerLeaderboardJob, 100)
 	var firstErr atomic.Value
 	var completed atomic.Int64
 	var wg sync.WaitGroup
@@ -103,7 +166,7 @@ func GeneratePlayerLeaderboards(db *sql.DB, out string, pageSize int, regions []
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for job := range jobs {
+			for job := range queue {
 				if firstErr.Load() != nil {
 					continue
 				}
@@ -117,7 +180,11 @@ func GeneratePlayerLeaderboards(db *sql.DB, out string, pageSize int, regions []
 				case "realm":
 					err = generateSingleRealmPlayerLeaderboard(db, job.out, job.region, job.realmSlug, job.pageSize, job.seasonID)
 				case "class":
-					err = generateClassPlayerLeaderboards(db, job.out, job.classKey, job.pageSize, job.regions, job.seasonID)
+					if job.classScope != "" {
+						err = generateClassScope(db, job.out, job.classScope, job.region, job.realmSlug, job.classKey, job.pageSize, job.seasonID)
+					} else {
+						err = generateClassPlayerLeaderboards(db, job.out, job.classKey, job.pageSize, job.regions, job.seasonID)
+					}
 				}
 
 				if err != nil {
@@ -133,66 +200,17 @@ func GeneratePlayerLeaderboards(db *sql.DB, out string, pageSize int, regions []
 		}()
 	}
 
-	// Queue all jobs
-	for _, season := range seasons {
-		fmt.Printf("\n=== Queuing Player Leaderboards for Season %d (%s) ===\n", season.ID, season.Name)
-		seasonOut := filepath.Join(out, "season", fmt.Sprintf("%d", season.ID))
-
-		// Global
-		jobs <- playerLeaderboardJob{
-			seasonID: season.ID,
-			scope:    "global",
-			out:      seasonOut,
-			pageSize: pageSize,
-		}
-
-		// Regional
-		for _, reg := range regions {
-			jobs <- playerLeaderboardJob{
-				seasonID: season.ID,
-				scope:    "regional",
-				region:   reg,
-				out:      seasonOut,
-				pageSize: pageSize,
-			}
-		}
-
-		// Realm
-		for _, reg := range regions {
-			for _, rslug := range realmSlugs[reg] {
-				jobs <- playerLeaderboardJob{
-					seasonID:  season.ID,
-					scope:     "realm",
-					region:    reg,
-					realmSlug: rslug,
-					out:       seasonOut,
-					pageSize:  pageSize,
-				}
-			}
-		}
-
-		// Class (each handles global/regional/realm internally)
-		for _, cls := range classKeys {
-			jobs <- playerLeaderboardJob{
-				seasonID: season.ID,
-				scope:    "class",
-				classKey: cls,
-				out:      seasonOut,
-				pageSize: pageSize,
-				regions:  regions,
-			}
-		}
+	for _, job := range jobs {
+		queue <- job
 	}
-	close(jobs)
+	close(queue)
 
 	wg.Wait()
 
 	if err := firstErr.Load(); err != nil {
-		return err.(error)
+		return completed.Load(), err.(error)
 	}
-
-	fmt.Printf("\n[OK] Generated %d player leaderboards\n", completed.Load())
-	return nil
+	return completed.Load(), nil
 }
 
 // generateGlobalPlayerLeaderboard generates global player rankings for a season
@@ -237,11 +255,11 @@ func generateGlobalPlayerLeaderboard(db *sql.DB, out string, pageSize int, seaso
 		}
 
 		page := buildPlayerLeaderboardPage(list, "Global Player Rankings", total, pages, p, pageSize)
-		if err := writer.WriteJSONFileCompact(filepath.Join(dir, fmt.Sprintf("%d.json", p)), page); err != nil {
+		if err := writer.WriteJSONFileCompactStable(filepath.Join(dir, fmt.Sprintf("%d.json", p)), page, "generated_timestamp"); err != nil {
 			return err
 		}
 	}
-	return nil
+	return removePagesAfter(dir, pages)
 }
 
 // generateRegionalPlayerLeaderboard generates regional player rankings
@@ -289,11 +307,11 @@ func generateRegionalPlayerLeaderboard(db *sql.DB, out, region string, pageSize
 
 		title := strings.ToUpper(region) + " Player Rankings"
 		page := buildPlayerLeaderboardPage(list, title, total, pages, p, pageSize)
-		if err := writer.WriteJSONFileCompact(filepath.Join(dir, fmt.Sprintf("%d.json", p)), page); err != nil {
+		if err := writer.WriteJSONFileCompactStable(filepath.Join(dir, fmt.Sprintf("%d.json", p)), page, "generated_timestamp"); err != nil {
 			return err
 		}
 	}
-	return nil
+	return removePagesAfter(dir, pages)
 }
 
 // generateSingleRealmPlayerLeaderboard generates player rankings for a single realm
@@ -346,11 +364,11 @@ func generateSingleRealmPlayerLeaderboard(db *sql.DB, out, region, rslug string,
 
 		title := strings.ToUpper(region) + "/" + rslug + " Player Rankings"
 		page := buildPlayerLeaderboardPage(list, title, total, pages, p, pageSize)
-		if err := writer.WriteJSONFileCompact(filepath.Join(dir, fmt.Sprintf("%d.json", p)), page); err != nil {
+		if err := writer.WriteJSONFileCompactStable(filepath.Join(dir, fmt.Sprintf("%d.json", p)), page, "generated_timestamp"); err != nil {
 			return err
 		}
 	}
-	return nil
+	return removePagesAfter(dir, pages)
 }
 
 // generateClassPlayerLeaderboards generates class-filtered player rankings for a season
@@ -549,11 +567,11 @@ func generateClassScope(db *sql.DB, out, scope, region, realmSlug, classKey stri
 		}
 
 		page := buildPlayerLeaderboardPage(list, title, total, pages, p, pageSize)
-		if err := writer.WriteJSONFileCompact(filepath.Join(dir, fmt.Sprintf("%d.json", p)), page); err != nil {
+		if err := writer.WriteJSONFileCompactStable(filepath.Join(dir, fmt.Sprintf("%d.json", p)), page, "generated_timestamp"); err != nil {
 			return err
 		}
 	}
-	return nil
+	return removePagesAfter(dir, pages)
 }
 
 // scanPlayerRows scans player rows and applies class/spec fallback
@@ -3,12 +3,14 @@ package generator
 import (
 	"database/sql"
 	"fmt"
+	"ookstats/internal/gear"
 	"ookstats/internal/loader"
 	"ookstats/internal/utils"
 	"ookstats/internal/wow"
 	"ookstats/internal/writer"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -73,37 +75,57 @@ type BestRunJSON struct {
 
 // PlayerPageJSON represents the complete player page output
 type PlayerPageJSON struct {
-	Player      PlayerJSON     `json:"player"`
-	Equipment   map[string]any `json:"equipment"`
-	GeneratedAt int64          `json:"generated_at"`
-	Version     string         `json:"version"`
+	Player    PlayerJSON     `json:"player"`
+	Equipment map[string]any `json:"equipment"`
+	// GearTimeline lists equipment changes between profile snapshots, oldest first
+	GearTimeline []gear.TimelineEntry `json:"gear_timeline,omitempty"`
+	// GeneratedAt is when the rest of the file last changed; regenerating the same
+	// content keeps the file as it is
+	GeneratedAt int64  `json:"generated_at"`
+	Version     string `json:"version"`
 }
 
 // GeneratePlayers orchestrates the full player JSON generation pipeline
 func GeneratePlayers(db *sql.DB, out string, version string) error {
+	_, err := generatePlayerFiles(db, out, version, nil)
+	return err
+}
+
+// generatePlayerFiles writes the JSON files of the players with complete coverage that
+// include accepts, or of all of them when include is nil, and returns those players
+func generatePlayerFiles(db *sql.DB, out string, version string, include func(playerID int64) bool) ([]loader.PlayerData, error) {
 	fmt.Println("Generating player JSON endpoints...")
 	if err := os.MkdirAll(out, 0o755); err != nil {
-		return fmt.Errorf("mkdir players out: %w", err)
+		return nil, fmt.Errorf("mkdir players out: %w", err)
 	}
 
 	// Step 1: Load all players with complete coverage
 	fmt.Printf("Loading players with complete coverage...\n")
 	players, err := loader.LoadAllCompleteCoveragePlayers(db)
 	if err != nil {
-		return fmt.Errorf("load players: %w", err)
+		return nil, fmt.Errorf("load players: %w", err)
+	}
+	if include != nil {
+		selected := players[:0]
+		for _, p := range players {
+			if include(p.ID) {
+				selected = append(selected, p)
+			}
+		}
+		players = selected
 	}
 	fmt.Printf("[OK] Loaded %d players with complete coverage\n", len(players))
 
 	if len(players) == 0 {
 		fmt.Println("No players with complete coverage found")
-		return nil
+		return players, nil
 	}
 
 	// Step 2: Load player season data
 	fmt.Printf("Loading player season data...\n")
 	playerSeasonsMap, err := loader.LoadAllPlayerSeasons(db, loader.GetPlayerIDs(players))
 	if err != nil {
-		return fmt.Errorf("load player seasons: %w", err)
+		return nil, fmt.Errorf("load player seasons: %w", err)
 	}
 	fmt.Printf("[OK] Loaded season data for %d players\n", len(playerSeasonsMap))
 
@@ -111,31 +133,45 @@ func GeneratePlayers(db *sql.DB, out string, version string) error {
 	fmt.Printf("Loading best runs data...\n")
 	bestRunsMap, allRunIDs, err := loader.LoadAllBestRuns(db, loader.GetPlayerIDs(players))
 	if err != nil {
-		return fmt.Errorf("load best runs: %w", err)
+		return nil, fmt.Errorf("load best runs: %w", err)
 	}
 	fmt.Printf("[OK] Loaded best runs for %d players (%d total runs)\n", len(bestRunsMap), len(allRunIDs))
 
 	fmt.Printf("Loading team members...\n")
 	teamMembersMap, err := loader.LoadAllTeamMembers(db, allRunIDs)
 	if err != nil {
-		return fmt.Errorf("load team members: %w", err)
+		return nil, fmt.Errorf("load team members: %w", err)
 	}
 	fmt.Printf("[OK] Loaded team members for %d runs\n", len(teamMembersMap))
 
 	fmt.Printf("Loading equipment data...\n")
 	equipmentMap, enchantmentsMap, err := loader.LoadAllEquipment(db, loader.GetPlayerIDs(players))
 	if err != nil {
-		return fmt.Errorf("load equipment: %w", err)
+		return nil, fmt.Errorf("load equipment: %w", err)
+	}
+	gearHorizon, err := loader.LoadEquipmentHorizon(db)
+	if err != nil {
+		return nil, fmt.Errorf("load equipment horizon: %w", err)
 	}
 	fmt.Printf("[OK] Loaded equipment for %d players\n", len(equipmentMap))
 
+	fmt.Printf("Loading ranking history...\n")
+	historyMap, err := loader.LoadAllRankingHistory(db, loader.GetPlayerIDs(players))
+	if err != nil {
+		return nil, fmt.Errorf("load ranking history: %w", err)
+	}
+	fmt.Printf("[OK] Loaded ranking history for %d players\n", len(historyMap))
+
 	// Step 4: Process players concurrently
 	fmt.Printf("Generating JSON files concurrently...\n")
-	return GeneratePlayerJSONs(players, playerSeasonsMap, bestRunsMap, teamMembersMap, equipmentMap, enchantmentsMap, out, version)
+	if err := GeneratePlayerJSONs(players, playerSeasonsMap, bestRunsMap, teamMembersMap, equipmentMap, enchantmentsMap, historyMap, gearHorizon, out, version); err != nil {
+		return nil, err
+	}
+	return players, nil
 }
 
 // GeneratePlayerJSONs generates JSON files for all players concurrently
-func GeneratePlayerJSONs(players []loader.PlayerData, playerSeasonsMap map[int64][]loader.PlayerSeasonData, bestRunsMap map[int64][]loader.BestRunData, teamMembersMap map[int64][]loader.TeamMemberData, equipmentMap map[int64]map[int64][]loader.EquipmentData, enchantmentsMap map[int64][]loader.EnchantmentData, out, version string) error {
+func GeneratePlayerJSONs(players []loader.PlayerData, playerSeasonsMap map[int64][]loader.PlayerSeasonData, bestRunsMap map[int64][]loader.BestRunData, teamMembersMap map[int64][]loader.TeamMemberData, equipmentMap map[int64]map[int64][]loader.EquipmentData, enchantmentsMap map[int64][]loader.EnchantmentData, historyMap map[int64][]loader.RankingHistoryData, gearHorizon int64, out, version string) error {
 	startTime := time.Now()
 	const batchSize = 100
 	const numWorkers = 10
@@ -156,7 +192,7 @@ func GeneratePlayerJSONs(players []loader.PlayerData, playerSeasonsMap map[int64
 		go func() {
 			defer wg.Done()
 			for item := range workChan {
-				if err := generateSinglePlayerJSON(item.player, playerSeasonsMap, bestRunsMap, teamMembersMap, equipmentMap, enchantmentsMap, out, version); err != nil {
+				if err := generateSinglePlayerJSON(item.player, playerSeasonsMap, bestRunsMap, teamMembersMap, equipmentMap, enchantmentsMap, historyMap, gearHorizon, out, version); err != nil {
 					errChan <- fmt.Errorf("player %s: %w", item.player.Name, err)
 					return
 				}
@@ -192,7 +228,7 @@ func GeneratePlayerJSONs(players []loader.PlayerData, playerSeasonsMap map[int64
 }
 
 // generateSinglePlayerJSON generates a JSON file for a single player
-func generateSinglePlayerJSON(player loader.PlayerData, playerSeasonsMap map[int64][]loader.PlayerSeasonData, bestRunsMap map[int64][]loader.BestRunData, teamMembersMap map[int64][]loader.TeamMemberData, equipmentMap map[int64]map[int64][]loader.EquipmentData, enchantmentsMap map[int64][]loader.EnchantmentData, out, version string) error {
+func generateSinglePlayerJSON(player loader.PlayerData, playerSeasonsMap map[int64][]loader.PlayerSeasonData, bestRunsMap map[int64][]loader.BestRunData, teamMembersMap map[int64][]loader.TeamMemberData, equipmentMap map[int64]map[int64][]loader.EquipmentData, enchantmentsMap map[int64][]loader.EnchantmentData, historyMap map[int64][]loader.RankingHistoryData, gearHorizon int64, out, version string) error {
 	// Build PlayerJSON with base info
 	pj := PlayerJSON{
 		ID:             player.ID,
@@ -317,80 +353,42 @@ func generateSinglePlayerJSON(player loader.PlayerData, playerSeasonsMap map[int
 		}
 	}
 
-	// Build equipment
-	equipment := make(map[string]any)
-	for _, eqList := range equipmentMap[player.ID] {
-		for _, eq := range eqList {
-			eqData := map[string]any{
-				"id":                 eq.ID,
-				"slot_type":          eq.SlotType,
-				"item_id":            nil,
-				"upgrade_id":         nil,
-				"quality":            eq.Quality,
-				"item_name":          eq.ItemName,
-				"snapshot_timestamp": eq.SnapshotTs,
-				"item_icon_slug":     eq.ItemIcon.String,
-				"item_type":          eq.ItemType.String,
-				"enchantments":       []map[string]any{},
-			}
-
-			if eq.ItemID.Valid {
-				eqData["item_id"] = int(eq.ItemID.Int64)
-			}
-			if eq.UpgradeID.Valid {
-				eqData["upgrade_id"] = int(eq.UpgradeID.Int64)
-			}
-
-			// Add enchantments
-			for _, ench := range enchantmentsMap[eq.ID] {
-				enchData := map[string]any{
-					"enchantment_id":   nil,
-					"slot_id":          nil,
-					"slot_type":        ench.SlotType.String,
-					"display_string":   ench.DisplayString.String,
-					"source_item_id":   nil,
-					"source_item_name": ench.SourceItemName.String,
-					"spell_id":         nil,
-				}
-
-				if ench.EnchantmentID.Valid {
-					enchData["enchantment_id"] = int(ench.EnchantmentID.Int64)
-				}
-				if ench.SlotID.Valid {
-					enchData["slot_id"] = int(ench.SlotID.Int64)
-				}
-				if ench.SourceItemID.Valid {
-					enchData["source_item_id"] = int(ench.SourceItemID.Int64)
-				}
-				if ench.SpellID.Valid {
-					enchData["spell_id"] = int(ench.SpellID.Int64)
-				}
-				if ench.GemIconSlug.Valid {
-					enchData["gem_icon_slug"] = ench.GemIconSlug.String
-				}
-
-				if arr, ok := eqData["enchantments"].([]map[string]any); ok {
-					eqData["enchantments"] = append(arr, enchData)
-				} else {
-					eqData["enchantments"] = []map[string]any{enchData}
-				}
-			}
-
-			equipment[eq.SlotType] = eqData
-		}
-		break // Only process the latest timestamp
-	}
+	// Build equipment from the latest row of every slot, and the changes between snapshots
+	equipment := buildEquipmentJSON(equipmentMap[player.ID], enchantmentsMap)
+	timeline := buildGearTimeline(equipmentMap[player.ID], enchantmentsMap, gearHorizon)
 
 	// Create final JSON
 	page := PlayerPageJSON{
-		Player:      pj,
-		Equipment:   equipment,
-		GeneratedAt: time.Now().UnixMilli(),
-		Version:     version,
+		Player:       pj,
+		Equipment:    equipment,
+		GearTimeline: timeline,
+		GeneratedAt:  time.Now().UnixMilli(),
+		Version:      version,
 	}
 
 	// Write file
-	dir := filepath.Join(out, pj.Region, pj.RealmSlug)
-	fname := filepath.Join(dir, utils.SafeSlugName(pj.Name)+".json")
-	return writer.WriteJSONFileCompact(fname, page)
+	fname := filepath.Join(out, playerFilePath(player))
+	if err := writer.WriteJSONFileCompactStable(fname, page, "generated_at"); err != nil {
+		return err
+	}
+
+	// Rank history goes to a sibling file so the player page stays small
+	hname := playerHistoryPath(fname)
+	if history := historyMap[player.ID]; len(history) > 0 {
+		return writer.WriteJSONFileCompactStable(hname, buildPlayerHistoryJSON(pj, history, page.GeneratedAt, version), "generated_at")
+	}
+	if err := os.Remove(hname); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// playerFilePath is where a player's JSON file goes, relative to the player directory
+func playerFilePath(player loader.PlayerData) string {
+	return filepath.Join(player.Region, player.RealmSlug, utils.SafeSlugName(player.Name)+".json")
+}
+
+// playerHistoryPath is the rank history file next to a player's JSON file
+func playerHistoryPath(playerFile string) string {
+	return strings.TrimSuffix(playerFile, ".json") + ".history.json"
 }
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"ookstats/internal/writer"
+	"os"
 	"path/filepath"
 	"time"
 )
@@ -83,7 +84,7 @@ func GenerateSearchIndex(db *sql.DB, out string, shardSize int) error {
 			"limit":            shardSize,
 			"last_updated":     time.Now().Format(time.RFC3339),
 		}
-		if err := writer.WriteJSONFileCompact(path, map[string]any{"players": buf, "metadata": meta}); err != nil {
+		if err := writer.WriteJSONFileCompactStable(path, map[string]any{"players": buf, "metadata": meta}, "metadata.last_updated"); err != nil {
 			return err
 		}
 		shard++
@@ -121,6 +122,21 @@ func GenerateSearchIndex(db *sql.DB, out string, shardSize int) error {
 		return err
 	}
 
+	// Drop shards left over from a larger index
+	stale, err := filepath.Glob(filepath.Join(out, "players-*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range stale {
+		var n int
+		if _, err := fmt.Sscanf(filepath.Base(path), "players-%d.json", &n); err != nil || n < shard {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
 	fmt.Printf("[OK] Generated search index: %d players in %d shards\n", count, shard)
 	return nil
 }
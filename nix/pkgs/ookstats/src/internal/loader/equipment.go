@@ -12,6 +12,7 @@ type EquipmentData struct {
 	SlotType   string
 	ItemID     sql.NullInt64
 	UpgradeID  sql.NullInt64
+	ItemLevel  sql.NullInt64
 	Quality    string
 	ItemName   string
 	SnapshotTs int64
@@ -32,7 +33,9 @@ type EnchantmentData struct {
 	GemIconSlug    sql.NullString
 }
 
-// LoadAllEquipment loads equipment and enchantments for a set of players
+// LoadAllEquipment loads every retained equipment snapshot and its enchantments for a
+// set of players. Snapshots only hold the slots that changed; a row without an item
+// marks a slot that was emptied.
 // Returns: map[playerID]map[timestamp][]EquipmentData, map[equipmentID][]EnchantmentData, error
 func LoadAllEquipment(db *sql.DB, playerIDs []int64) (map[int64]map[int64][]EquipmentData, map[int64][]EnchantmentData, error) {
 	if len(playerIDs) == 0 {
@@ -47,62 +50,20 @@ func LoadAllEquipment(db *sql.DB, playerIDs []int64) (map[int64]map[int64][]Equi
 		args[i] = id
 	}
 
-	// Get latest timestamp per player first
-	latestQuery := fmt.Sprintf(`
-        SELECT player_id, MAX(snapshot_timestamp) 
-        FROM player_equipment 
-        WHERE player_id IN (%s) 
-        GROUP BY player_id
-    `, strings.Join(placeholders, ","))
-
-	latestRows, err := db.Query(latestQuery, args...)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer latestRows.Close()
-
-	playerTimestamps := make(map[int64]int64)
-	for latestRows.Next() {
-		var playerID, timestamp int64
-		if err := latestRows.Scan(&playerID, &timestamp); err != nil {
-			return nil, nil, err
-		}
-		playerTimestamps[playerID] = timestamp
-	}
-
-	if len(playerTimestamps) == 0 {
-		return make(map[int64]map[int64][]EquipmentData), make(map[int64][]EnchantmentData), nil
-	}
-
-	// Load equipment for latest timestamps in a single batched query
 	equipmentMap := make(map[int64]map[int64][]EquipmentData)
 	var allEquipmentIDs []int64
 
-	// Build VALUES clause for all player/timestamp pairs
-	valuesBuilder := strings.Builder{}
-	eqArgs := make([]any, 0, len(playerTimestamps)*2)
-	first := true
-	for playerID, timestamp := range playerTimestamps {
-		if !first {
-			valuesBuilder.WriteString(",")
-		}
-		valuesBuilder.WriteString("(?,?)")
-		eqArgs = append(eqArgs, playerID, timestamp)
-		first = false
-	}
-
-	// Single query to load all equipment
 	eqQuery := fmt.Sprintf(`
-		WITH pairs(player_id, ts) AS (VALUES %s)
-		SELECT e.player_id, e.id, e.slot_type, e.item_id, e.upgrade_id, e.quality, e.item_name, e.snapshot_timestamp,
+		SELECT e.player_id, e.id, e.slot_type, e.item_id, e.upgrade_id, e.item_level,
+		       COALESCE(e.quality, ''), COALESCE(e.item_name, ''), e.snapshot_timestamp,
 		       i.icon AS item_icon_slug, i.type AS item_type
-		FROM pairs
-		JOIN player_equipment e ON e.player_id = pairs.player_id AND e.snapshot_timestamp = pairs.ts
+		FROM player_equipment e
 		LEFT JOIN items i ON e.item_id = i.id
-		ORDER BY e.player_id, e.slot_type
-	`, valuesBuilder.String())
+		WHERE e.player_id IN (%s)
+		ORDER BY e.player_id, e.snapshot_timestamp, e.slot_type
+	`, strings.Join(placeholders, ","))
 
-	eqRows, err := db.Query(eqQuery, eqArgs...)
+	eqRows, err := db.Query(eqQuery, args...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("batch equipment query: %w", err)
 	}
@@ -112,7 +73,8 @@ func LoadAllEquipment(db *sql.DB, playerIDs []int64) (map[int64]map[int64][]Equi
 		var playerID int64
 		var eq EquipmentData
 		if err := eqRows.Scan(
-			&playerID, &eq.ID, &eq.SlotType, &eq.ItemID, &eq.UpgradeID, &eq.Quality, &eq.ItemName, &eq.SnapshotTs,
+			&playerID, &eq.ID, &eq.SlotType, &eq.ItemID, &eq.UpgradeID, &eq.ItemLevel,
+			&eq.Quality, &eq.ItemName, &eq.SnapshotTs,
 			&eq.ItemIcon, &eq.ItemType); err != nil {
 			return nil, nil, fmt.Errorf("scan equipment: %w", err)
 		}
@@ -121,7 +83,12 @@ func LoadAllEquipment(db *sql.DB, playerIDs []int64) (map[int64]map[int64][]Equi
 			equipmentMap[playerID] = make(map[int64][]EquipmentData)
 		}
 		equipmentMap[playerID][eq.SnapshotTs] = append(equipmentMap[playerID][eq.SnapshotTs], eq)
-		allEquipmentIDs = append(allEquipmentIDs, eq.ID)
+		if eq.ItemID.Valid {
+			allEquipmentIDs = append(allEquipmentIDs, eq.ID)
+		}
+	}
+	if err := eqRows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate equipment: %w", err)
 	}
 
 	// Load enchantments in batches
@@ -172,3 +139,17 @@ func LoadAllEquipment(db *sql.DB, playerIDs []int64) (map[int64]map[int64][]Equi
 
 	return equipmentMap, enchantmentsMap, nil
 }
+
+// LoadEquipmentHorizon returns the cutoff of the last equipment pruning in unix millis,
+// or 0 when snapshots were never pruned
+func LoadEquipmentHorizon(db *sql.DB) (int64, error) {
+	var horizon int64
+	err := db.QueryRow(`SELECT pruned_before FROM equipment_retention WHERE id = 1`).Scan(&horizon)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("equipment horizon: %w", err)
+	}
+	return horizon, nil
+}
@@ -38,6 +38,19 @@ type PlayerSeasonData struct {
 	LastUpdated       sql.NullInt64
 }
 
+// RankingHistoryData is one recorded change of a player's ranks in a season
+type RankingHistoryData struct {
+	SeasonID        int
+	RecordedAt      int64
+	CombinedBest    sql.NullInt64
+	GlobalRanking   sql.NullInt64
+	RegionalRanking sql.NullInt64
+	RealmRanking    sql.NullInt64
+	GlobalClassRank sql.NullInt64
+	RegionClassRank sql.NullInt64
+	RealmClassRank  sql.NullInt64
+}
+
 // LoadAllCompleteCoveragePlayers loads all unique players who have complete coverage in ANY season
 func LoadAllCompleteCoveragePlayers(db *sql.DB) ([]PlayerData, error) {
 	rows, err := db.Query(`
@@ -116,6 +129,49 @@ func LoadAllPlayerSeasons(db *sql.DB, playerIDs []int64) (map[int64][]PlayerSeas
 	return seasonsMap, nil
 }
 
+// LoadAllRankingHistory loads the ranking history of a set of players, oldest first
+func LoadAllRankingHistory(db *sql.DB, playerIDs []int64) (map[int64][]RankingHistoryData, error) {
+	if len(playerIDs) == 0 {
+		return make(map[int64][]RankingHistoryData), nil
+	}
+
+	placeholders := make([]string, len(playerIDs))
+	args := make([]any, len(playerIDs))
+	for i, id := range playerIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(`
+        SELECT player_id, season_id, recorded_at, combined_best_time,
+               global_ranking, regional_ranking, realm_ranking,
+               global_class_rank, region_class_rank, realm_class_rank
+        FROM player_ranking_history
+        WHERE player_id IN (%s)
+        ORDER BY player_id, season_id, recorded_at
+    `, strings.Join(placeholders, ","))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	historyMap := make(map[int64][]RankingHistoryData)
+	for rows.Next() {
+		var playerID int64
+		var h RankingHistoryData
+		if err := rows.Scan(
+			&playerID, &h.SeasonID, &h.RecordedAt, &h.CombinedBest,
+			&h.GlobalRanking, &h.RegionalRanking, &h.RealmRanking,
+			&h.GlobalClassRank, &h.RegionClassRank, &h.RealmClassRank); err != nil {
+			return nil, fmt.Errorf("scan ranking history: %w", err)
+		}
+		historyMap[playerID] = append(historyMap[playerID], h)
+	}
+	return historyMap, rows.Err()
+}
+
 // GetPlayerIDs extracts player IDs from a slice of PlayerData
 func GetPlayerIDs(players []PlayerData) []int64 {
 	ids := make([]int64, len(players))
@@ -49,10 +49,11 @@ func LoadAllBestRuns(db *sql.DB, playerIDs []int64) (map[int64][]BestRunData, []
 
 	query := fmt.Sprintf(`
         WITH realm_rankings AS (
-            SELECT cr.id as run_id, cr.dungeon_id, cr.realm_id,
-                   ROW_NUMBER() OVER (PARTITION BY cr.dungeon_id, cr.realm_id ORDER BY cr.duration ASC, cr.completed_timestamp ASC, cr.id ASC) as realm_ranking,
-                   COUNT(*) OVER (PARTITION BY cr.dungeon_id, cr.realm_id) as total_in_realm_dungeon
-            FROM challenge_runs cr
+            SELECT cr.id as run_id, cr.dungeon_id, rs.realm_id,
+                   ROW_NUMBER() OVER (PARTITION BY cr.dungeon_id, rs.realm_id ORDER BY cr.duration ASC, cr.completed_timestamp ASC, cr.id ASC) as realm_ranking,
+                   COUNT(*) OVER (PARTITION BY cr.dungeon_id, rs.realm_id) as total_in_realm_dungeon
+            FROM run_sightings rs
+            JOIN challenge_runs cr ON rs.run_id = cr.id
         )
         SELECT pbr.player_id, pbr.dungeon_id, d.name, d.slug, pbr.run_id, pbr.duration, pbr.completed_timestamp,
                pbr.season_id,
@@ -65,11 +66,11 @@ func LoadAllBestRuns(db *sql.DB, playerIDs []int64) (map[int64][]BestRunData, []
                    WHEN realm_rankings.realm_ranking = 1 THEN 'artifact'
                    ELSE
                        CASE
-                           WHEN (CAST(realm_rankings.realm_ranking AS REAL) / CAST(realm_rankings.total_in_realm_dungeon AS REAL) * 100) <= 1.0 THEN 'excellent'
-                           WHEN (CAST(realm_rankings.realm_ranking AS REAL) / CAST(realm_rankings.total_in_realm_dungeon AS REAL) * 100) <= 5.0 THEN 'legendary'
-                           WHEN (CAST(realm_rankings.realm_ranking AS REAL) / CAST(realm_rankings.total_in_realm_dungeon AS REAL) * 100) <= 20.0 THEN 'epic'
-                           WHEN (CAST(realm_rankings.realm_ranking AS REAL) / CAST(realm_rankings.total_in_realm_dungeon AS REAL) * 100) <= 40.0 THEN 'rare'
-                           WHEN (CAST(realm_rankings.realm_ranking AS REAL) / CAST(realm_rankings.total_in_realm_dungeon AS REAL) * 100) <= 60.0 THEN 'uncommon'
+                           WHEN (CAST(realm_rankings.realm_ranking AS DOUBLE PRECISION) / CAST(realm_rankings.total_in_realm_dungeon AS DOUBLE PRECISION) * 100) <= 1.0 THEN 'excellent'
+                           WHEN (CAST(realm_rankings.realm_ranking AS DOUBLE PRECISION) / CAST(realm_rankings.total_in_realm_dungeon AS DOUBLE PRECISION) * 100) <= 5.0 THEN 'legendary'
+                           WHEN (CAST(realm_rankings.realm_ranking AS DOUBLE PRECISION) / CAST(realm_rankings.total_in_realm_dungeon AS DOUBLE PRECISION) * 100) <= 20.0 THEN 'epic'
+                           WHEN (CAST(realm_rankings.realm_ranking AS DOUBLE PRECISION) / CAST(realm_rankings.total_in_realm_dungeon AS DOUBLE PRECISION) * 100) <= 40.0 THEN 'rare'
+                           WHEN (CAST(realm_rankings.realm_ranking AS DOUBLE PRECISION) / CAST(realm_rankings.total_in_realm_dungeon AS DOUBLE PRECISION) * 100) <= 60.0 THEN 'uncommon'
                            ELSE 'common'
                        END
                END as realm_percentile_bracket
@@ -207,8 +208,9 @@ func LoadCanonicalRuns(db *sql.DB, dungeonID int, region string, realmSlug strin
 		args = append(args, region)
 	}
 	if realmSlug != "" {
-		where += " AND r.slug = ?"
-		args = append(args, realmSlug)
+		// a run belongs to every realm leaderboard it was sighted on, not just the realm it was first stored under
+		where += " AND cr.id IN (SELECT rs.run_id FROM run_sightings rs JOIN realms sr ON rs.realm_id = sr.id WHERE sr.region = ? AND sr.slug = ?)"
+		args = append(args, region, realmSlug)
 	}
 	// Filter by season
 	where += " AND cr.season_id = ?"
@@ -277,10 +279,11 @@ func LoadCanonicalRuns(db *sql.DB, dungeonID int, region string, realmSlug strin
       WITH realm_rankings AS (
         SELECT cr.id as run_id,
                cr.dungeon_id,
-               cr.realm_id,
-               ROW_NUMBER() OVER (PARTITION BY cr.dungeon_id, cr.realm_id ORDER BY cr.duration ASC, cr.completed_timestamp ASC, cr.id ASC) as realm_ranking,
-               COUNT(*) OVER (PARTITION BY cr.dungeon_id, cr.realm_id) as total_in_realm_dungeon
-        FROM challenge_runs cr
+               rs.realm_id,
+               ROW_NUMBER() OVER (PARTITION BY cr.dungeon_id, rs.realm_id ORDER BY cr.duration ASC, cr.completed_timestamp ASC, cr.id ASC) as realm_ranking,
+               COUNT(*) OVER (PARTITION BY cr.dungeon_id, rs.realm_id) as total_in_realm_dungeon
+        FROM run_sightings rs
+        JOIN challenge_runs cr ON rs.run_id = cr.id
         WHERE cr.season_id = ?
       )
       SELECT cr.id, cr.duration, cr.completed_timestamp, cr.keystone_level,
@@ -289,11 +292,11 @@ func LoadCanonicalRuns(db *sql.DB, dungeonID int, region string, realmSlug strin
                WHEN realm_rankings.realm_ranking = 1 THEN 'artifact'
                ELSE
                  CASE
-                   WHEN (CAST(realm_rankings.realm_ranking AS REAL) / CAST(realm_rankings.total_in_realm_dungeon AS REAL) * 100) <= 1.0 THEN 'excellent'
-                   WHEN (CAST(realm_rankings.realm_ranking AS REAL) / CAST(realm_rankings.total_in_realm_dungeon AS REAL) * 100) <= 5.0 THEN 'legendary'
-                   WHEN (CAST(realm_rankings.realm_ranking AS REAL) / CAST(realm_rankings.total_in_realm_dungeon AS REAL) * 100) <= 20.0 THEN 'epic'
-                   WHEN (CAST(realm_rankings.realm_ranking AS REAL) / CAST(realm_rankings.total_in_realm_dungeon AS REAL) * 100) <= 40.0 THEN 'rare'
-                   WHEN (CAST(realm_rankings.realm_ranking AS REAL) / CAST(realm_rankings.total_in_realm_dungeon AS REAL) * 100) <= 60.0 THEN 'uncommon'
+                   WHEN (CAST(realm_rankings.realm_ranking AS DOUBLE PRECISION) / CAST(realm_rankings.total_in_realm_dungeon AS DOUBLE PRECISION) * 100) <= 1.0 THEN 'excellent'
+                   WHEN (CAST(realm_rankings.realm_ranking AS DOUBLE PRECISION) / CAST(realm_rankings.total_in_realm_dungeon AS DOUBLE PRECISION) * 100) <= 5.0 THEN 'legendary'
+                   WHEN (CAST(realm_rankings.realm_ranking AS DOUBLE PRECISION) / CAST(realm_rankings.total_in_realm_dungeon AS DOUBLE PRECISION) * 100) <= 20.0 THEN 'epic'
+                   WHEN (CAST(realm_rankings.realm_ranking AS DOUBLE PRECISION) / CAST(realm_rankings.total_in_realm_dungeon AS DOUBLE PRECISION) * 100) <= 40.0 THEN 'rare'
+                   WHEN (CAST(realm_rankings.realm_ranking AS DOUBLE PRECISION) / CAST(realm_rankings.total_in_realm_dungeon AS DOUBLE PRECISION) * 100) <= 60.0 THEN 'uncommon'
                    ELSE 'common'
                  END
              END as percentile_bracket
@@ -302,11 +305,11 @@ func LoadCanonicalRuns(db *sql.DB, dungeonID int, region string, realmSlug strin
       JOIN realms rr ON cr.realm_id = rr.id
       LEFT JOIN realm_rankings ON cr.id = realm_rankings.run_id
         AND cr.dungeon_id = realm_rankings.dungeon_id
-        AND cr.realm_id = realm_rankings.realm_id
+        AND realm_rankings.realm_id = (SELECT id FROM realms WHERE region = ? AND slug = ?)
       WHERE cr.id IN (%s)
     `, strings.Join(placeholders, ","))
-		// Prepend seasonID for the CTE
-		iargs = append([]any{seasonID}, iargs...)
+		// Prepend seasonID for the CTE and the realm being ranked
+		iargs = append([]any{seasonID, region, realmSlug}, iargs...)
 	} else {
 		// For global/regional, use pre-computed rankings
 		rQuery = fmt.Sprintf(`
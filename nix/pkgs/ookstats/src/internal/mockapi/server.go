@@ -0,0 +1,227 @@
+package mockapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault forces a status code for every request whose path contains Match
+type Fault struct {
+	Match  string
+	Status int
+}
+
+// Options configures the mock Blizzard API server
+type Options struct {
+	// FixturesDir holds JSON responses laid out as <region>/<api path>.json, e.g.
+	//   us/data/wow/connected-realm/4372/mythic-leaderboard/2/period/1036.json
+	//   us/data/wow/mythic-keystone/season/index.json
+	//   us/profile/wow/character/atiesh/somename.json
+	//   us/profile/wow/character/atiesh/somename/equipment.json
+	FixturesDir string
+	// Faults are checked before random injection, in order
+	Faults []Fault
+	// NotFoundRate, TooManyRequestsRate and ServerErrorRate are probabilities (0-1)
+	// of answering a data request with 404, 429 or 5xx respectively
+	NotFoundRate        float64
+	TooManyRequestsRate float64
+	ServerErrorRate     float64
+	// RetryAfter is sent with injected 429 responses
+	RetryAfter time.Duration
+	// Seed makes random fault injection reproducible (0 = time-based)
+	Seed    int64
+	Verbose bool
+}
+
+// Server serves fixture files in place of the Blizzard API and OAuth endpoints
+type Server struct {
+	opts  Options
+	rngMu sync.Mutex
+	rng   *rand.Rand
+}
+
+// mockToken is handed out by the token endpoint and required on data requests
+const mockToken = "mock-access-token"
+
+// NewServer creates a mock API handler
+func NewServer(opts Options) (*Server, error) {
+	info, err := os.Stat(opts.FixturesDir)
+	if err != nil {
+		return nil, fmt.Errorf("fixtures dir: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("fixtures dir %s is not a directory", opts.FixturesDir)
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Server{opts: opts, rng: rand.New(rand.NewSource(seed))}, nil
+}
+
+// ParseFault parses a "substring=status" fault spec
+func ParseFault(spec string) (Fault, error) {
+	match, statusStr, ok := strings.Cut(spec, "=")
+	if !ok || strings.TrimSpace(match) == "" {
+		return Fault{}, fmt.Errorf("invalid fault %q (expected PATH_SUBSTRING=STATUS)", spec)
+	}
+	status, err := strconv.Atoi(strings.TrimSpace(statusStr))
+	if err != nil || status < 100 || status > 599 {
+		return Fault{}, fmt.Errorf("invalid fault status in %q", spec)
+	}
+	return Fault{Match: strings.TrimSpace(match), Status: status}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := s.serve(w, r)
+	if s.opts.Verbose {
+		fmt.Printf("%s %d %s\n", r.Method, status, r.URL.RequestURI())
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) int {
+	urlPath := path.Clean("/" + r.URL.Path)
+
+	// OAuth client-credentials endpoint
+	if urlPath == "/token" || strings.HasSuffix(urlPath, "/oauth/token") {
+		if r.Method != http.MethodPost {
+			return writeError(w, http.StatusMethodNotAllowed)
+		}
+		if id, secret, ok := r.BasicAuth(); !ok || id == "" || secret == "" {
+			return writeError(w, http.StatusUnauthorized)
+		}
+		return writeJSON(w, http.StatusOK, map[string]any{
+			"access_token": mockToken,
+			"token_type":   "bearer",
+			"expires_in":   86399,
+		})
+	}
+
+	if r.Method != http.MethodGet {
+		return writeError(w, http.StatusMethodNotAllowed)
+	}
+	if r.Header.Get("Authorization") != "Bearer "+mockToken {
+		return writeError(w, http.StatusUnauthorized)
+	}
+
+	if status := s.injectedStatus(urlPath); status != 0 {
+		if status == http.StatusTooManyRequests && s.opts.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(s.opts.RetryAfter/time.Second)))
+		}
+		return writeError(w, status)
+	}
+
+	region := regionFromRequest(r)
+	// allow base URLs of the form http://host/{region}
+	trimmed := strings.TrimPrefix(urlPath, "/"+region+"/")
+	if trimmed != urlPath {
+		urlPath = "/" + trimmed
+	}
+
+	fixture := filepath.Join(s.opts.FixturesDir, region, filepath.FromSlash(urlPath)+".json")
+	info, err := os.Stat(fixture)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return writeError(w, http.StatusNotFound)
+		}
+		return writeError(w, http.StatusInternalServerError)
+	}
+	body, err := os.ReadFile(fixture)
+	if err != nil {
+		return writeError(w, http.StatusInternalServerError)
+	}
+
+	// validators derived from the fixture so conditional requests can be exercised
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	modTime := info.ModTime().UTC().Truncate(time.Second)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+	if notModified(r, etag, modTime) {
+		w.WriteHeader(http.StatusNotModified)
+		return http.StatusNotModified
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+	return http.StatusOK
+}
+
+// notModified evaluates If-None-Match (preferred) or If-Modified-Since
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			if c := strings.TrimSpace(candidate); c == etag || c == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && !modTime.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// injectedStatus returns a forced error status for the request, or 0
+func (s *Server) injectedStatus(urlPath string) int {
+	for _, f := range s.opts.Faults {
+		if strings.Contains(urlPath, f.Match) {
+			return f.Status
+		}
+	}
+
+	s.rngMu.Lock()
+	roll := s.rng.Float64()
+	s.rngMu.Unlock()
+
+	switch {
+	case roll < s.opts.TooManyRequestsRate:
+		return http.StatusTooManyRequests
+	case roll < s.opts.TooManyRequestsRate+s.opts.ServerErrorRate:
+		return http.StatusServiceUnavailable
+	case roll < s.opts.TooManyRequestsRate+s.opts.ServerErrorRate+s.opts.NotFoundRate:
+		return http.StatusNotFound
+	}
+	return 0
+}
+
+// regionFromRequest derives the region from the namespace query param
+// (e.g. dynamic-classic-us), falling back to "us"
+func regionFromRequest(r *http.Request) string {
+	ns := r.URL.Query().Get("namespace")
+	if i := strings.LastIndex(ns, "-"); i >= 0 && i < len(ns)-1 {
+		return strings.ToLower(ns[i+1:])
+	}
+	return "us"
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) int {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+	return status
+}
+
+// writeError mimics the Blizzard API error body
+func writeError(w http.ResponseWriter, status int) int {
+	return writeJSON(w, status, map[string]any{
+		"code":   status,
+		"type":   fmt.Sprintf("BLZWEBAPI%08d", status),
+		"detail": http.StatusText(status),
+	})
+}
@@ -0,0 +1,303 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"ookstats/internal/database"
+)
+
+// expectedRunMembers is the size of a challenge mode group
+const expectedRunMembers = 5
+
+// DoctorOptions controls a doctor run
+type DoctorOptions struct {
+	// Repair applies the fix for every check that has one
+	Repair bool
+	// SampleLimit caps the example keys listed per check
+	SampleLimit int
+	// Progress receives what repairs print while they run; nil means stdout
+	Progress io.Writer
+}
+
+// DoctorCheckResult is the outcome of one integrity check
+type DoctorCheckResult struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Issues      int64    `json:"issues"`
+	Samples     []string `json:"samples,omitempty"`
+	Repairable  bool     `json:"repairable"`
+	Repaired    int64    `json:"repaired"`
+	Remaining   int64    `json:"remaining"`
+	// Hint suggests a follow-up for issues the check cannot fully repair itself
+	Hint string `json:"hint,omitempty"`
+}
+
+// DoctorReport collects every check of a doctor run
+type DoctorReport struct {
+	GeneratedAt string              `json:"generated_at"`
+	Backend     string              `json:"backend"`
+	Repair      bool                `json:"repair"`
+	TotalIssues int64               `json:"total_issues"`
+	Remaining   int64               `json:"remaining"`
+	Checks      []DoctorCheckResult `json:"checks"`
+}
+
+// doctorCheck counts and samples one kind of inconsistency. sample returns one
+// identifying key per row; repair, when set, fixes the rows and reports how many
+// it changed.
+type doctorCheck struct {
+	name        string
+	description string
+	count       string
+	sample      string
+	repair      func(db *sql.DB, progress io.Writer) (int64, error)
+	hint        string
+}
+
+var doctorChecks = []doctorCheck{
+	{
+		name:        "run_members_missing_player",
+		description: "run_members rows whose player_id is not in players",
+		count:       `SELECT COUNT(*) FROM run_members rm WHERE NOT EXISTS (SELECT 1 FROM players p WHERE p.id = rm.player_id)`,
+		sample: `SELECT 'run ' || CAST(rm.run_id AS TEXT) || ' player ' || CAST(rm.player_id AS TEXT)
+			FROM run_members rm WHERE NOT EXISTS (SELECT 1 FROM players p WHERE p.id = rm.player_id)
+			ORDER BY rm.run_id, rm.player_id LIMIT ?`,
+		repair: execRepair(`DELETE FROM run_members WHERE NOT EXISTS (SELECT 1 FROM players p WHERE p.id = run_members.player_id)`),
+	},
+	{
+		name:        "run_members_missing_run",
+		description: "run_members rows whose run_id is not in challenge_runs",
+		count:       `SELECT COUNT(*) FROM run_members rm WHERE NOT EXISTS (SELECT 1 FROM challenge_runs cr WHERE cr.id = rm.run_id)`,
+		sample: `SELECT 'run ' || CAST(rm.run_id AS TEXT) || ' player ' || CAST(rm.player_id AS TEXT)
+			FROM run_members rm WHERE NOT EXISTS (SELECT 1 FROM challenge_runs cr WHERE cr.id = rm.run_id)
+			ORDER BY rm.run_id, rm.player_id LIMIT ?`,
+		repair: execRepair(`DELETE FROM run_members WHERE NOT EXISTS (SELECT 1 FROM challenge_runs cr WHERE cr.id = run_members.run_id)`),
+	},
+	{
+		name:        "run_member_count",
+		description: fmt.Sprintf("runs that do not have exactly %d members", expectedRunMembers),
+		count: fmt.Sprintf(`SELECT COUNT(*) FROM (
+				SELECT cr.id FROM challenge_runs cr
+				LEFT JOIN run_members rm ON rm.run_id = cr.id
+				GROUP BY cr.id
+				HAVING COUNT(rm.player_id) <> %d
+			) x`, expectedRunMembers),
+		sample: fmt.Sprintf(`SELECT 'run ' || CAST(cr.id AS TEXT) || ' has ' || CAST(COUNT(rm.player_id) AS TEXT)
+			FROM challenge_runs cr
+			LEFT JOIN run_members rm ON rm.run_id = cr.id
+			GROUP BY cr.id
+			HAVING COUNT(rm.player_id) <> %d
+			ORDER BY cr.id LIMIT ?`, expectedRunMembers),
+		hint: "members come from the leaderboard response; refetch the affected periods with `fetch cm --no-conditional`",
+	},
+	{
+		name:        "runs_without_season",
+		description: "runs whose season_id is NULL or not a synced season of the run's region",
+		count: `SELECT COUNT(*) FROM challenge_runs cr
+			JOIN realms r ON r.id = cr.realm_id
+			WHERE cr.season_id IS NULL
+			   OR NOT EXISTS (SELECT 1 FROM seasons s WHERE s.season_number = cr.season_id AND s.region = r.region)`,
+		sample: `SELECT 'run ' || CAST(cr.id AS TEXT) || ' season ' || COALESCE(CAST(cr.season_id AS TEXT), 'NULL')
+			FROM challenge_runs cr
+			JOIN realms r ON r.id = cr.realm_id
+			WHERE cr.season_id IS NULL
+			   OR NOT EXISTS (SELECT 1 FROM seasons s WHERE s.season_number = cr.season_id AND s.region = r.region)
+			ORDER BY cr.id LIMIT ?`,
+		repair: func(db *sql.DB, progress io.Writer) (int64, error) {
+			// reassigning without any synced seasons would clear every run's season
+			var seasons int64
+			if err := db.QueryRow(`SELECT COUNT(*) FROM seasons`).Scan(&seasons); err != nil || seasons == 0 {
+				return 0, err
+			}
+			if err := database.NewDatabaseService(db).AssignRunsToSeasonsWithProgress(progress); err != nil {
+				return 0, err
+			}
+			return 0, nil
+		},
+		hint: "runs outside every synced season stay unmapped; run `fetch seasons` first",
+	},
+	{
+		name:        "players_unknown_realm",
+		description: "players whose realm_id is not in realms",
+		count:       `SELECT COUNT(*) FROM players p WHERE NOT EXISTS (SELECT 1 FROM realms r WHERE r.id = p.realm_id)`,
+		sample: `SELECT CAST(p.id AS TEXT) || ' ' || COALESCE(p.name, '') || ' realm ' || COALESCE(CAST(p.realm_id AS TEXT), 'NULL')
+			FROM players p WHERE NOT EXISTS (SELECT 1 FROM realms r WHERE r.id = p.realm_id)
+			ORDER BY p.id LIMIT ?`,
+		hint: "run `fetch realms`, then refetch leaderboards so players are re-linked to their realm",
+	},
+	{
+		name:        "fingerprints_invalid_player",
+		description: "player_fingerprints rows attached to missing or invalid players",
+		count: `SELECT COUNT(*) FROM player_fingerprints pf
+			LEFT JOIN players p ON p.id = pf.player_id
+			WHERE p.id IS NULL OR COALESCE(p.is_valid, 1) = 0`,
+		sample: `SELECT 'player ' || CAST(pf.player_id AS TEXT) || CASE WHEN p.id IS NULL THEN ' (missing)' ELSE ' (invalid)' END
+			FROM player_fingerprints pf
+			LEFT JOIN players p ON p.id = pf.player_id
+			WHERE p.id IS NULL OR COALESCE(p.is_valid, 1) = 0
+			ORDER BY pf.player_id LIMIT ?`,
+		repair: execRepair(`DELETE FROM player_fingerprints
+			WHERE NOT EXISTS (SELECT 1 FROM players p WHERE p.id = player_fingerprints.player_id AND COALESCE(p.is_valid, 1) <> 0)`),
+	},
+	{
+		name:        "profile_dungeon_count",
+		description: "player_profiles whose dungeons_completed disagrees with player_best_runs",
+		count: `SELECT COUNT(*) FROM player_profiles pp
+			WHERE pp.dungeons_completed IS DISTINCT FROM (
+				SELECT COUNT(*) FROM player_best_runs pbr
+				WHERE pbr.player_id = pp.player_id AND pbr.season_id = pp.season_id
+			)`,
+		sample: `SELECT 'player ' || CAST(pp.player_id AS TEXT) || ' season ' || CAST(pp.season_id AS TEXT)
+			FROM player_profiles pp
+			WHERE pp.dungeons_completed IS DISTINCT FROM (
+				SELECT COUNT(*) FROM player_best_runs pbr
+				WHERE pbr.player_id = pp.player_id AND pbr.season_id = pp.season_id
+			)
+			ORDER BY pp.player_id, pp.season_id LIMIT ?`,
+		repair: execRepair(`UPDATE player_profiles
+			SET dungeons_completed = (
+				SELECT COUNT(*) FROM player_best_runs pbr
+				WHERE pbr.player_id = player_profiles.player_id AND pbr.season_id = player_profiles.season_id
+			)
+			WHERE dungeons_completed IS DISTINCT FROM (
+				SELECT COUNT(*) FROM player_best_runs pbr
+				WHERE pbr.player_id = player_profiles.player_id AND pbr.season_id = player_profiles.season_id
+			)`),
+		hint: "times and rankings derived from the same rows may also be stale; rerun `process players`",
+	},
+	{
+		name:        "duplicate_player_rankings",
+		description: "player_rankings rows repeating a (player_id, ranking_type, ranking_scope) key",
+		count: `SELECT CAST(COALESCE(SUM(cnt - 1), 0) AS BIGINT) FROM (
+				SELECT COUNT(*) AS cnt FROM player_rankings
+				GROUP BY player_id, ranking_type, ranking_scope
+				HAVING COUNT(*) > 1
+			) x`,
+		sample: `SELECT 'player ' || CAST(player_id AS TEXT) || ' ' || COALESCE(ranking_type, '') || '/' || COALESCE(ranking_scope, '')
+			FROM player_rankings
+			GROUP BY player_id, ranking_type, ranking_scope
+			HAVING COUNT(*) > 1
+			ORDER BY player_id LIMIT ?`,
+		repair: func(db *sql.DB, progress io.Writer) (int64, error) {
+			// the postgres table has always been keyed, and rowid only exists in sqlite
+			if database.DialectOf(db) == database.Postgres {
+				return 0, nil
+			}
+			return execRepair(`DELETE FROM player_rankings
+				WHERE rowid NOT IN (
+					SELECT MAX(rowid) FROM player_rankings
+					GROUP BY player_id, ranking_type, ranking_scope
+				)`)(db, progress)
+		},
+	},
+}
+
+// execRepair builds a repair that runs a single statement
+func execRepair(query string) func(db *sql.DB, progress io.Writer) (int64, error) {
+	return func(db *sql.DB, _ io.Writer) (int64, error) {
+		res, err := db.Exec(query)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+}
+
+// RunDoctor checks the database for integrity and consistency problems and, with
+// opts.Repair, fixes those that can be fixed safely
+func RunDoctor(ctx context.Context, db *sql.DB, opts DoctorOptions) (*DoctorReport, error) {
+	sampleLimit := opts.SampleLimit
+	if sampleLimit <= 0 {
+		sampleLimit = 5
+	}
+	progress := opts.Progress
+	if progress == nil {
+		progress = os.Stdout
+	}
+
+	report := &DoctorReport{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Backend:     database.DialectOf(db).String(),
+		Repair:      opts.Repair,
+	}
+
+	repaired := false
+	for _, check := range doctorChecks {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		res := DoctorCheckResult{
+			Name:        check.name,
+			Description: check.description,
+			Repairable:  check.repair != nil,
+			Hint:        check.hint,
+		}
+		if err := db.QueryRow(check.count).Scan(&res.Issues); err != nil {
+			return nil, fmt.Errorf("check %s: %w", check.name, err)
+		}
+		res.Remaining = res.Issues
+
+		if res.Issues > 0 {
+			samples, err := sampleKeys(db, check.sample, sampleLimit)
+			if err != nil {
+				return nil, fmt.Errorf("check %s: sample: %w", check.name, err)
+			}
+			res.Samples = samples
+
+			if opts.Repair && check.repair != nil {
+				log.Info("repairing", "check", check.name, "issues", res.Issues)
+				changed, err := check.repair(db, progress)
+				if err != nil {
+					return nil, fmt.Errorf("repair %s: %w", check.name, err)
+				}
+				if err := db.QueryRow(check.count).Scan(&res.Remaining); err != nil {
+					return nil, fmt.Errorf("recheck %s: %w", check.name, err)
+				}
+				res.Repaired = changed
+				if res.Repaired == 0 {
+					// repairs delegated to other code don't report affected rows
+					res.Repaired = res.Issues - res.Remaining
+				}
+				repaired = repaired || res.Repaired > 0
+			}
+		}
+
+		report.TotalIssues += res.Issues
+		report.Remaining += res.Remaining
+		report.Checks = append(report.Checks, res)
+	}
+
+	if repaired {
+		// repairs don't mark what they changed for the incremental ranking pass
+		if err := database.NewDatabaseService(db).RequireFullRankingRebuild(); err != nil {
+			return nil, fmt.Errorf("reset ranking state: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+func sampleKeys(db *sql.DB, query string, limit int) ([]string, error) {
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"ookstats/internal/blizzard"
+	"ookstats/internal/database"
+)
+
+// ResolveDungeons returns the dungeon list from the dungeons table. When a client is
+// given, the mythic-leaderboard index of one parent realm per region is fetched first
+// and any new dungeons are merged in. The hardcoded list seeds an empty table so a
+// failed discovery on a fresh database still has something to work with.
+func ResolveDungeons(ctx context.Context, db database.Store, client *blizzard.Client, realms map[string]blizzard.RealmInfo) ([]blizzard.DungeonInfo, error) {
+	if client != nil {
+		discovered := discoverDungeons(ctx, client, realms)
+		if len(discovered) > 0 {
+			added, err := db.MergeDungeons(discovered)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Printf("[OK] Leaderboard index lists %d dungeons (%d new)\n", len(discovered), added)
+		}
+	}
+
+	dungeons, err := db.GetDungeons()
+	if err != nil {
+		return nil, err
+	}
+	if len(dungeons) > 0 {
+		return dungeons, nil
+	}
+
+	_, fallback := blizzard.GetHardcodedPeriodAndDungeons()
+	fmt.Printf("[WARN] No dungeons discovered or stored, seeding %d hardcoded dungeons\n", len(fallback))
+	if err := db.EnsureDungeonsOnce(fallback); err != nil {
+		return nil, err
+	}
+	return fallback, nil
+}
+
+// discoverDungeons asks regions in order for their leaderboard index and returns the
+// first non-empty answer. Every region runs the same dungeon set, so one is enough.
+func discoverDungeons(ctx context.Context, client *blizzard.Client, realms map[string]blizzard.RealmInfo) []blizzard.DungeonInfo {
+	for _, realm := range indexRealms(realms) {
+		if ctx.Err() != nil {
+			return nil
+		}
+		index, err := client.FetchLeaderboardIndex(ctx, realm)
+		if err != nil {
+			fmt.Printf("[WARN] Leaderboard index unavailable for %s (%s): %v\n", realm.Slug, realm.Region, err)
+			continue
+		}
+		if dungeons := index.Dungeons(); len(dungeons) > 0 {
+			return dungeons
+		}
+	}
+	return nil
+}
+
+// indexRealms picks the lowest-ID parent realm of each region, regions sorted
+func indexRealms(realms map[string]blizzard.RealmInfo) []blizzard.RealmInfo {
+	byRegion := make(map[string]blizzard.RealmInfo)
+	for _, info := range realms {
+		if info.ParentRealmSlug != "" {
+			continue
+		}
+		if cur, ok := byRegion[info.Region]; !ok || info.ID < cur.ID {
+			byRegion[info.Region] = info
+		}
+	}
+
+	regions := make([]string, 0, len(byRegion))
+	for region := range byRegion {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	picked := make([]blizzard.RealmInfo, 0, len(regions))
+	for _, region := range regions {
+		picked = append(picked, byRegion[region])
+	}
+	return picked
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"ookstats/internal/blizzard"
 	"ookstats/internal/database"
+	"sort"
 	"strings"
 	"time"
 )
@@ -17,6 +18,7 @@ type FetchCMOptions struct {
 	Dungeons          []string
 	Periods           []string
 	LatestPeriodsOnly bool
+	NoConditional     bool // skip If-None-Match/If-Modified-Since validators
 	Concurrency       int
 	Timeout           time.Duration
 }
@@ -26,21 +28,31 @@ type FetchCMResult struct {
 	TotalRuns    int
 	TotalPlayers int
 	Duration     time.Duration
+	// NewRuns and Resighted split the runs listed by this sweep's leaderboards into
+	// runs never seen before and runs already known from an earlier fetch
+	NewRuns   int
+	Resighted int
+	// Interrupted is set when the sweep stopped early (signal or timeout). Pending
+	// lists, per region, the periods not fully fetched; nil means all of them.
+	Interrupted bool
+	Pending     map[string][]string
 }
 
 // FetchChallengeMode fetches challenge mode leaderboard data for specified realms/dungeons/periods
-func FetchChallengeMode(db *database.DatabaseService, client *blizzard.Client, opts FetchCMOptions) (*FetchCMResult, error) {
-	// Get dungeons and realms from hardcoded lists
-	_, dungeons := blizzard.GetHardcodedPeriodAndDungeons()
-	allRealms := blizzard.GetAllRealms()
-
-	fmt.Printf("Dungeons: %d, Realms: %d\n", len(dungeons), len(allRealms))
+// Finished batches are committed as they arrive, so a cancelled ctx returns a partial
+// result with Interrupted set rather than an error.
+func FetchChallengeMode(ctx context.Context, db database.Store, client *blizzard.Client, opts FetchCMOptions) (*FetchCMResult, error) {
+	// realms come from the registry synced by `fetch realms`
+	allRealms, err := LoadRealms(db)
+	if err != nil {
+		return nil, err
+	}
 
 	// Apply region filter
 	if len(opts.Regions) > 0 {
 		allowed := make(map[string]bool)
 		for _, r := range opts.Regions {
-			allowed[strings.TrimSpace(r)] = true
+			allowed[strings.ToLower(strings.TrimSpace(r))] = true
 		}
 		for slug, info := range allRealms {
 			if !allowed[info.Region] {
@@ -67,6 +79,14 @@ func FetchChallengeMode(db *database.DatabaseService, client *blizzard.Client, o
 		allRealms = filtered
 	}
 
+	// Dungeons come from the leaderboard index, so new ones are picked up without a release
+	dungeons, err := ResolveDungeons(ctx, db, client, allRealms)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve dungeons: %w", err)
+	}
+
+	fmt.Printf("Dungeons: %d, Realms: %d\n", len(dungeons), len(allRealms))
+
 	// Apply dungeon filter
 	if len(opts.Dungeons) > 0 {
 		allowed := make(map[string]bool)
@@ -85,19 +105,6 @@ func FetchChallengeMode(db *database.DatabaseService, client *blizzard.Client, o
 		}
 	}
 
-	// Pre-populate reference data (includes ALL realms, even children)
-	fmt.Printf("Pre-populating reference data...\n")
-	fmt.Printf("  - Ensuring dungeons (%d)\n", len(dungeons))
-	if err := db.EnsureDungeonsOnce(dungeons); err != nil {
-		return nil, fmt.Errorf("failed to ensure dungeons: %w", err)
-	}
-	fmt.Printf("  [OK] Dungeons ensured\n")
-	fmt.Printf("  - Ensuring realms (%d, including child realms)\n", len(allRealms))
-	if err := db.EnsureRealmsBatch(allRealms); err != nil {
-		return nil, fmt.Errorf("failed to ensure realms: %w", err)
-	}
-	fmt.Printf("  [OK] Realms ensured\n")
-
 	// Filter out child realms for fetching (they don't have their own leaderboards)
 	// Players from child realms appear on parent realm leaderboards
 	fetchRealms := make(map[string]blizzard.RealmInfo)
@@ -112,7 +119,7 @@ func FetchChallengeMode(db *database.DatabaseService, client *blizzard.Client, o
 	if childRealmsFiltered > 0 {
 		fmt.Printf("Filtered out %d child realms from fetch (no leaderboards to fetch)\n", childRealmsFiltered)
 	}
-	fmt.Printf("Reference data populated for %d realms (%d to fetch from) and %d dungeons\n",
+	fmt.Printf("Using %d realms (%d to fetch from) and %d dungeons\n",
 		len(allRealms), len(fetchRealms), len(dungeons))
 
 	// Group realms by region (only fetch realms, excluding children)
@@ -124,15 +131,42 @@ func FetchChallengeMode(db *database.DatabaseService, client *blizzard.Client, o
 		realmsByRegion[info.Region][slug] = info
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	// Conditional requests: unchanged leaderboards come back as 304 and are skipped
+	if !opts.NoConditional {
+		validators, err := db.LoadLeaderboardValidators()
+		if err != nil {
+			fmt.Printf("[WARN] Failed to load leaderboard validators, fetching unconditionally: %v\n", err)
+		} else {
+			client.SetValidatorCache(validators)
+			fmt.Printf("Loaded %d leaderboard validators for conditional requests\n", validators.Len())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
 	defer cancel()
 
 	totalRuns := 0
 	totalPlayers := 0
 	sweepStart := time.Now()
 
+	// sorted so an interrupted sweep can be resumed region by region
+	regions := make([]string, 0, len(realmsByRegion))
+	for region := range realmsByRegion {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	pending := make(map[string][]string)
+
 	// Process each region independently
-	for region, regionRealms := range realmsByRegion {
+	for i, region := range regions {
+		regionRealms := realmsByRegion[region]
+		if ctx.Err() != nil {
+			for _, r := range regions[i:] {
+				pending[r] = nil
+			}
+			break
+		}
+
 		fmt.Printf("\n========== Region: %s (%d realms) ==========\n", strings.ToUpper(region), len(regionRealms))
 
 		// Determine periods for this region
@@ -187,7 +221,7 @@ func FetchChallengeMode(db *database.DatabaseService, client *blizzard.Client, o
 
 		// Period sweep for this region
 		fmt.Printf("Starting period sweep for %s: %d periods\n", strings.ToUpper(region), len(periods))
-		for _, period := range periods {
+		for j, period := range periods {
 			fmt.Printf("\n--- %s Period %s ---\n", strings.ToUpper(region), period)
 			res := client.FetchAllRealmsConcurrent(ctx, regionRealms, dungeons, period)
 			runs, players, berr := db.BatchProcessFetchResults(ctx, res)
@@ -197,22 +231,44 @@ func FetchChallengeMode(db *database.DatabaseService, client *blizzard.Client, o
 			fmt.Printf("%s Period %s -> inserted runs: %d, new players: %d\n", strings.ToUpper(region), period, runs, players)
 			totalRuns += runs
 			totalPlayers += players
+
+			if ctx.Err() != nil {
+				// this period was cut short, so it stays pending along with the rest
+				pending[region] = append([]string(nil), periods[j:]...)
+				break
+			}
 		}
 	}
 
 	duration := time.Since(sweepStart)
+	result := &FetchCMResult{
+		TotalRuns:    totalRuns,
+		TotalPlayers: totalPlayers,
+		Duration:     duration,
+	}
+	if newRuns, resighted, err := db.CountSightingsSince(sweepStart.UnixMilli()); err != nil {
+		fmt.Printf("[WARN] Failed to count run sightings: %v\n", err)
+	} else {
+		result.NewRuns, result.Resighted = newRuns, resighted
+	}
+
+	if ctx.Err() != nil {
+		fmt.Printf("\n========== Sweep interrupted after %v: %v ==========\n", duration, ctx.Err())
+		printClientStats(client)
+		result.Interrupted = true
+		result.Pending = pending
+		return result, nil
+	}
+
 	fmt.Printf("\n========== Sweep complete in %v ==========\n", duration)
+	printClientStats(client)
 
 	// Update fetch metadata
 	if err := db.UpdateFetchMetadata("challenge_mode_leaderboard", totalRuns, totalPlayers); err != nil {
 		return nil, fmt.Errorf("failed to update fetch metadata: %w", err)
 	}
 
-	return &FetchCMResult{
-		TotalRuns:    totalRuns,
-		TotalPlayers: totalPlayers,
-		Duration:     duration,
-	}, nil
+	return result, nil
 }
 
 // FetchProfilesOptions contains options for fetching player profiles
@@ -221,6 +277,9 @@ type FetchProfilesOptions struct {
 	BatchSize  int
 	MaxPlayers int
 	StaleAfter time.Duration
+	// EquipmentRetention prunes equipment snapshots older than this before fetching;
+	// the gear each slot had at the cutoff is kept (0 = keep everything)
+	EquipmentRetention time.Duration
 }
 
 // FetchProfilesResult contains statistics from the profile fetch operation
@@ -229,16 +288,31 @@ type FetchProfilesResult struct {
 	TotalEquipment int
 	ProcessedCount int
 	Duration       time.Duration
+	// Interrupted is set when ctx was cancelled; Remaining players were not attempted
+	Interrupted bool
+	Remaining   int
+	// EquipmentPruned counts equipment rows removed by the retention policy
+	EquipmentPruned int64
 }
 
 // FetchPlayerProfiles fetches detailed player profile data including equipment
-func FetchPlayerProfiles(db *database.DatabaseService, client *blizzard.Client, opts FetchProfilesOptions) (*FetchProfilesResult, error) {
+func FetchPlayerProfiles(ctx context.Context, db database.Store, client *blizzard.Client, opts FetchProfilesOptions) (*FetchProfilesResult, error) {
 	// Compute staleness cutoff
 	staleCutoff := int64(0)
 	if opts.StaleAfter > 0 {
 		staleCutoff = time.Now().Add(-opts.StaleAfter).UnixMilli()
 	}
 
+	var pruned int64
+	if opts.EquipmentRetention > 0 {
+		var err error
+		pruned, err = db.PruneEquipmentSnapshots(time.Now().Add(-opts.EquipmentRetention).UnixMilli())
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("Pruned %d equipment snapshot rows older than %v\n", pruned, opts.EquipmentRetention)
+	}
+
 	// Get eligible players (9/9 completion)
 	fmt.Println("Finding eligible players with complete coverage (9/9 dungeons)...")
 	players, err := db.GetEligiblePlayersForProfileFetch(staleCutoff)
@@ -248,7 +322,7 @@ func FetchPlayerProfiles(db *database.DatabaseService, client *blizzard.Client,
 
 	if len(players) == 0 {
 		fmt.Println("No eligible players found. Run 'ookstats process players' first to generate player profiles.")
-		return &FetchProfilesResult{}, nil
+		return &FetchProfilesResult{EquipmentPruned: pruned}, nil
 	}
 
 	fmt.Printf("Found %d eligible players with 9/9 completion\n", len(players))
@@ -275,6 +349,10 @@ func FetchPlayerProfiles(db *database.DatabaseService, client *blizzard.Client,
 
 	// Process in batches to avoid overwhelming the API
 	for i := 0; i < len(players); i += batchSize {
+		if ctx.Err() != nil {
+			break
+		}
+
 		end := i + batchSize
 		if end > len(players) {
 			end = len(players)
@@ -337,17 +415,17 @@ func FetchPlayerProfiles(db *database.DatabaseService, client *blizzard.Client,
 					tried[key] = true
 
 					// Fetch summary first; if it 404s, skip to next candidate
-					sum, err := client.FetchCharacterSummary(c[1], c[0], region)
+					sum, err := client.FetchCharacterSummary(ctx, c[1], c[0], region)
 					if err != nil {
 						// try next candidate
 						finalErr = err
 						continue
 					}
-					eq, err2 := client.FetchCharacterEquipment(c[1], c[0], region)
+					eq, err2 := client.FetchCharacterEquipment(ctx, c[1], c[0], region)
 					if err2 != nil {
 						finalErr = err2
 					}
-					med, err3 := client.FetchCharacterMedia(c[1], c[0], region)
+					med, err3 := client.FetchCharacterMedia(ctx, c[1], c[0], region)
 					if err3 != nil {
 						finalErr = err3
 					}
@@ -405,15 +483,26 @@ func FetchPlayerProfiles(db *database.DatabaseService, client *blizzard.Client,
 		// Small delay between batches to be respectful to the API
 		if i+batchSize < len(players) {
 			fmt.Printf("  [INFO] Waiting 1 second before next batch...\n")
-			time.Sleep(1 * time.Second)
+			select {
+			case <-time.After(1 * time.Second):
+			case <-ctx.Done():
+			}
 		}
 	}
 
 	elapsed := time.Since(startTime)
+	interrupted := ctx.Err() != nil
+	if interrupted {
+		fmt.Printf("\n[WARN] Profile fetch interrupted: %v\n", ctx.Err())
+	}
+	printClientStats(client)
 	return &FetchProfilesResult{
-		TotalProfiles:  totalProfiles,
-		TotalEquipment: totalEquipment,
-		ProcessedCount: processedCount,
-		Duration:       elapsed,
+		TotalProfiles:   totalProfiles,
+		TotalEquipment:  totalEquipment,
+		ProcessedCount:  processedCount,
+		Duration:        elapsed,
+		Interrupted:     interrupted,
+		Remaining:       len(players) - processedCount,
+		EquipmentPruned: pruned,
 	}, nil
 }
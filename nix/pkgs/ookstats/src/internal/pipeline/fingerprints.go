@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -28,10 +29,12 @@ type FingerprintResult struct {
 	Skipped       int
 	MarkedInvalid int
 	Duration      time.Duration
+	// Interrupted is set when ctx was cancelled before all candidates were processed
+	Interrupted bool
 }
 
 // BuildPlayerFingerprints fetches achievements for players missing fingerprints and stores them.
-func BuildPlayerFingerprints(db *database.DatabaseService, client *blizzard.Client, opts FingerprintOptions) (*FingerprintResult, error) {
+func BuildPlayerFingerprints(ctx context.Context, db database.Store, client *blizzard.Client, opts FingerprintOptions) (*FingerprintResult, error) {
 	start := time.Now()
 	batchSize := opts.BatchSize
 	if batchSize <= 0 {
@@ -62,6 +65,10 @@ func BuildPlayerFingerprints(db *database.DatabaseService, client *blizzard.Clie
 
 	batchNumber := 0
 	for {
+		if ctx.Err() != nil {
+			result.Interrupted = true
+			break
+		}
 		if tracker.ShouldStop(result.Processed) {
 			break
 		}
@@ -105,7 +112,7 @@ func BuildPlayerFingerprints(db *database.DatabaseService, client *blizzard.Clie
 			wg.Add(1)
 			go func(c database.PlayerFingerprintCandidate) {
 				defer wg.Done()
-				outcomes <- processFingerprintCandidate(db, client, c, collisionMap, logger)
+				outcomes <- processFingerprintCandidate(ctx, db, client, c, collisionMap, logger)
 			}(cand)
 		}
 
@@ -115,6 +122,10 @@ func BuildPlayerFingerprints(db *database.DatabaseService, client *blizzard.Clie
 		}()
 
 		for oc := range outcomes {
+			if oc.cancelled {
+				// not attempted; the next run picks it up again
+				continue
+			}
 			result.Processed++
 			if oc.err != nil {
 				return nil, oc.err
@@ -215,10 +226,11 @@ type fingerprintOutcome struct {
 	created       bool
 	skipped       bool
 	invalid       bool
+	cancelled     bool
 	err           error
 }
 
-func processFingerprintCandidate(db *database.DatabaseService, client *blizzard.Client, cand database.PlayerFingerprintCandidate, collisionMap map[string]int64, logger *log.Logger) fingerprintOutcome {
+func processFingerprintCandidate(ctx context.Context, db database.Store, client *blizzard.Client, cand database.PlayerFingerprintCandidate, collisionMap map[string]int64, logger *log.Logger) fingerprintOutcome {
 	out := fingerprintOutcome{playerID: cand.PlayerID}
 	logger.Info("fingerprinting player",
 		"player_id", cand.PlayerID,
@@ -236,8 +248,12 @@ func processFingerprintCandidate(db *database.DatabaseService, client *blizzard.
 	}
 
 	canonicalRealm := blizzard.NormalizeRealmSlug(cand.Region, cand.RealmSlug)
-	statusResp, err := client.FetchCharacterStatus(cand.Name, canonicalRealm, cand.Region)
+	statusResp, err := client.FetchCharacterStatus(ctx, cand.Name, canonicalRealm, cand.Region)
 	if err != nil {
+		if ctx.Err() != nil {
+			out.cancelled = true
+			return out
+		}
 		if isNotFoundError(err) {
 			logger.Warn("status 404, marking invalid",
 				"player_id", cand.PlayerID,
@@ -285,8 +301,12 @@ func processFingerprintCandidate(db *database.DatabaseService, client *blizzard.
 		cand.Name = name
 	}
 
-	resp, err := client.FetchCharacterAchievements(cand.Name, canonicalRealm, cand.Region)
+	resp, err := client.FetchCharacterAchievements(ctx, cand.Name, canonicalRealm, cand.Region)
 	if err != nil {
+		if ctx.Err() != nil {
+			out.cancelled = true
+			return out
+		}
 		if isNotFoundError(err) {
 			logger.Warn("achievements 404, marking invalid",
 				"player_id", cand.PlayerID,
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -41,3 +42,21 @@ func logBatchProgress(tracker *BatchTracker, logger *log.Logger, processed, batc
 		"req_per_sec", fmt.Sprintf("%.1f", stats.RPS),
 		"elapsed", stats.Elapsed.Truncate(time.Second))
 }
+
+// printClientStats summarizes API usage and rate limiter state after a sweep
+func printClientStats(client *blizzard.Client) {
+	stats := client.Stats()
+	fmt.Printf("API requests: %d (404: %d, avg latency %.0fms), 429 backoffs: %d, throttle: %.0f%%\n",
+		stats.Requests, stats.NotFound, stats.AvgLatencyMs, stats.Limiter.RateLimited, stats.Limiter.Throttle*100)
+
+	regions := make([]string, 0, len(stats.Limiter.Regions))
+	for region := range stats.Limiter.Regions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	for _, region := range regions {
+		rs := stats.Limiter.Regions[region]
+		fmt.Printf("  %-3s requests: %d, worker time spent waiting on rate limit: %v, hourly budget left: %.0f/%.0f\n",
+			region, rs.Requests, rs.RateWait.Truncate(time.Millisecond), rs.HourTokens, rs.Quota.PerHour)
+	}
+}
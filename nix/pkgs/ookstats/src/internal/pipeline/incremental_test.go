@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"ookstats/internal/database"
+	"ookstats/internal/dbtest"
+)
+
+// TestIncrementalMatchesFullRebuild ingests more runs after a full pass, ranks them
+// incrementally, and checks a full rebuild of the same data leaves every derived
+// table, run_rankings and player_rankings included, unchanged
+func TestIncrementalMatchesFullRebuild(t *testing.T) {
+	db := dbtest.OpenSQLite(t)
+	ds := database.NewDatabaseService(db)
+	f := dbtest.Seed(t, ds)
+	ctx := context.Background()
+
+	dbtest.Ingest(t, ds, f, 6)
+	if _, err := ProcessAll(ctx, ds, ProcessAllOptions{}); err != nil {
+		t.Fatalf("initial pass: %v", err)
+	}
+
+	// each step widens the boards, so new runs displace old bests and ranks shift
+	for _, n := range []int{9, 12, 12} {
+		dbtest.Ingest(t, ds, f, n)
+
+		res, err := ProcessAll(ctx, ds, ProcessAllOptions{})
+		if err != nil {
+			t.Fatalf("incremental pass with %d runs per board: %v", n, err)
+		}
+		if res.Full {
+			t.Fatalf("pass with %d runs per board was full: %s", n, res.Reason)
+		}
+		incremental := digest(t, db)
+
+		if _, err := ProcessAll(ctx, ds, ProcessAllOptions{Full: true}); err != nil {
+			t.Fatalf("full pass with %d runs per board: %v", n, err)
+		}
+		full := digest(t, db)
+
+		for _, table := range derivedTables {
+			if incremental[table.name] != full[table.name] {
+				t.Errorf("%d runs per board: %s differs: incremental %s, full %s",
+					n, table.name, incremental[table.name], full[table.name])
+			}
+		}
+	}
+}
+
+func digest(t *testing.T, db *sql.DB) map[string]string {
+	t.Helper()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	d, err := digestDerivedTables(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ookstats/internal/blizzard"
+	"ookstats/internal/database"
+)
+
+// SyncPeriodsResult contains statistics from a period sync
+type SyncPeriodsResult struct {
+	Fetched       int
+	Skipped       int
+	Linked        int64
+	FailedRegions []string
+	Interrupted   bool
+}
+
+// SyncPeriods walks the keystone period index of each region and stores the exact
+// start/end of every period, then links them to seasons already synced into
+// period_seasons. Periods that ended before the last sync are not refetched.
+func SyncPeriods(ctx context.Context, db database.Store, client *blizzard.Client, regions []string) (*SyncPeriodsResult, error) {
+	result := &SyncPeriodsResult{}
+	for _, region := range regions {
+		region = strings.ToLower(strings.TrimSpace(region))
+		if region == "" {
+			continue
+		}
+
+		index, err := client.FetchPeriodIndex(ctx, region)
+		if err != nil {
+			if ctx.Err() != nil {
+				result.Interrupted = true
+				return result, nil
+			}
+			fmt.Printf("[ERROR] Period index for %s: %v\n", strings.ToUpper(region), err)
+			result.FailedRegions = append(result.FailedRegions, region)
+			continue
+		}
+
+		settled, err := db.GetSettledPeriodIDs(region, nowMillis())
+		if err != nil {
+			return result, fmt.Errorf("load stored periods for %s: %w", region, err)
+		}
+
+		ids := make([]int, 0, len(index.Periods))
+		for _, ref := range index.Periods {
+			ids = append(ids, ref.ID)
+		}
+		sort.Ints(ids)
+
+		fetched, failed := 0, 0
+		for _, id := range ids {
+			if settled[id] {
+				result.Skipped++
+				continue
+			}
+			detail, err := client.FetchPeriodDetail(ctx, region, id)
+			if err != nil {
+				if ctx.Err() != nil {
+					result.Interrupted = true
+					return result, nil
+				}
+				fmt.Printf("  [WARN] %s: %v\n", strings.ToUpper(region), err)
+				failed++
+				continue
+			}
+			if err := db.UpsertPeriod(region, detail.ID, detail.StartTimestamp, detail.EndTimestamp); err != nil {
+				return result, fmt.Errorf("store period %d for %s: %w", detail.ID, region, err)
+			}
+			fetched++
+		}
+		if failed > 0 {
+			result.FailedRegions = append(result.FailedRegions, region)
+		}
+		result.Fetched += fetched
+
+		linked, err := db.LinkPeriodsToSeasons(region)
+		if err != nil {
+			return result, err
+		}
+		result.Linked += linked
+		fmt.Printf("[OK] %s periods: %d listed (current %d), %d fetched, %d already settled\n",
+			strings.ToUpper(region), len(ids), index.CurrentPeriod.ID, fetched, len(ids)-fetched-failed)
+	}
+	return result, nil
+}
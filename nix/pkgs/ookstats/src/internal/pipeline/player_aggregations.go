@@ -9,15 +9,31 @@ import (
 	"ookstats/internal/wow"
 )
 
-// createPlayerAggregations creates player profiles and best runs
-func createPlayerAggregations(tx *sql.Tx) (int, error) {
-	log.Info("computing player aggregations")
+// createPlayerAggregations creates player profiles and best runs. When scoped, it only
+// rebuilds the best runs in the pass's dirty dungeon/season partitions or of its dirty
+// players, and the profiles of those players (see beginRankingPass).
+func createPlayerAggregations(tx *sql.Tx, scoped bool) (int, error) {
+	log.Info("computing player aggregations", "scoped", scoped)
+
+	// the filters below are empty for a full rebuild
+	var bestRunsScope, bestTimesScope, bestRunsDelete, profilesScope, specScope string
+	if scoped {
+		bestRunsScope = "WHERE " + passBestRunFilter("rm", "cr")
+		bestTimesScope = "WHERE " + passBestRunFilter("rm2", "cr2")
+		bestRunsDelete = " WHERE " + passBestRunFilter("player_best_runs", "player_best_runs")
+		profilesScope = "WHERE p.id IN (SELECT player_id FROM " + passPlayersTable + ")"
+		specScope = "WHERE player_profiles.player_id IN (SELECT player_id FROM " + passPlayersTable + ")"
+	}
 
 	// clear existing aggregation data
-	if _, err := tx.Exec("DELETE FROM player_profiles"); err != nil {
+	if scoped {
+		if _, err := tx.Exec("DELETE FROM player_profiles WHERE player_id IN (SELECT player_id FROM " + passPlayersTable + ")"); err != nil {
+			return 0, err
+		}
+	} else if _, err := tx.Exec("DELETE FROM player_profiles"); err != nil {
 		return 0, err
 	}
-	if _, err := tx.Exec("DELETE FROM player_best_runs"); err != nil {
+	if _, err := tx.Exec("DELETE FROM player_best_runs" + bestRunsDelete); err != nil {
 		return 0, err
 	}
 	log.Info("cleared existing player aggregation data")
@@ -33,46 +49,54 @@ func createPlayerAggregations(tx *sql.Tx) (int, error) {
 			global_percentile_bracket, regional_percentile_bracket, realm_percentile_bracket
 		)
 		SELECT
-			rm.player_id,
-			cr.dungeon_id,
-			cr.id as run_id,
-			cr.duration,
-			cr.season_id,
-			cr.completed_timestamp,
-			rr_gf.ranking as global_ranking_filtered,
-			rr_rf.ranking as regional_ranking_filtered,
-			rr_lf.ranking as realm_ranking_filtered,
-			rr_gf.percentile_bracket as global_percentile_bracket,
-			rr_rf.percentile_bracket as regional_percentile_bracket,
-			rr_lf.percentile_bracket as realm_percentile_bracket
-		FROM run_members rm
-		INNER JOIN challenge_runs cr ON rm.run_id = cr.id
-		INNER JOIN realms r ON cr.realm_id = r.id
-		LEFT JOIN realms parent_r ON r.parent_realm_slug = parent_r.slug AND r.region = parent_r.region
-		INNER JOIN (
+			player_id, dungeon_id, run_id, duration, season_id, completed_timestamp,
+			global_ranking_filtered, regional_ranking_filtered, realm_ranking_filtered,
+			global_percentile_bracket, regional_percentile_bracket, realm_percentile_bracket
+		FROM (
 			SELECT
-				rm2.player_id,
-				cr2.dungeon_id,
-				cr2.season_id,
-				MIN(cr2.duration) as best_duration
-			FROM run_members rm2
-			INNER JOIN challenge_runs cr2 ON rm2.run_id = cr2.id
-			GROUP BY rm2.player_id, cr2.dungeon_id, cr2.season_id
-		) best_times ON rm.player_id = best_times.player_id
-					 AND cr.dungeon_id = best_times.dungeon_id
-					 AND cr.season_id = best_times.season_id
-					 AND cr.duration = best_times.best_duration
-		LEFT JOIN run_rankings rr_gf ON cr.id = rr_gf.run_id
-			AND rr_gf.ranking_type = 'global' AND rr_gf.ranking_scope = 'filtered'
-			AND rr_gf.season_id = cr.season_id
-		LEFT JOIN run_rankings rr_rf ON cr.id = rr_rf.run_id
-			AND rr_rf.ranking_type = 'regional' AND rr_rf.ranking_scope = 'filtered'
-			AND rr_rf.season_id = cr.season_id
-		LEFT JOIN run_rankings rr_lf ON cr.id = rr_lf.run_id
-			AND rr_lf.ranking_type = 'realm' AND rr_lf.ranking_scope = COALESCE(parent_r.slug, r.slug) || '_filtered'
-			AND rr_lf.season_id = cr.season_id
-		GROUP BY rm.player_id, cr.dungeon_id, cr.season_id
-		HAVING cr.id = MIN(cr.id)
+				rm.player_id,
+				cr.dungeon_id,
+				cr.id as run_id,
+				cr.duration,
+				cr.season_id,
+				cr.completed_timestamp,
+				rr_gf.ranking as global_ranking_filtered,
+				rr_rf.ranking as regional_ranking_filtered,
+				rr_lf.ranking as realm_ranking_filtered,
+				rr_gf.percentile_bracket as global_percentile_bracket,
+				rr_rf.percentile_bracket as regional_percentile_bracket,
+				rr_lf.percentile_bracket as realm_percentile_bracket,
+				ROW_NUMBER() OVER (PARTITION BY rm.player_id, cr.dungeon_id, cr.season_id ORDER BY cr.id ASC) as run_pick
+			FROM run_members rm
+			INNER JOIN challenge_runs cr ON rm.run_id = cr.id
+			INNER JOIN realms r ON cr.realm_id = r.id
+			LEFT JOIN realms parent_r ON r.parent_realm_slug = parent_r.slug AND r.region = parent_r.region
+			INNER JOIN (
+				SELECT
+					rm2.player_id,
+					cr2.dungeon_id,
+					cr2.season_id,
+					MIN(cr2.duration) as best_duration
+				FROM run_members rm2
+				INNER JOIN challenge_runs cr2 ON rm2.run_id = cr2.id
+				` + bestTimesScope + `
+				GROUP BY rm2.player_id, cr2.dungeon_id, cr2.season_id
+			) best_times ON rm.player_id = best_times.player_id
+						 AND cr.dungeon_id = best_times.dungeon_id
+						 AND cr.season_id = best_times.season_id
+						 AND cr.duration = best_times.best_duration
+			LEFT JOIN run_rankings rr_gf ON cr.id = rr_gf.run_id
+				AND rr_gf.ranking_type = 'global' AND rr_gf.ranking_scope = 'filtered'
+				AND rr_gf.season_id = cr.season_id
+			LEFT JOIN run_rankings rr_rf ON cr.id = rr_rf.run_id
+				AND rr_rf.ranking_type = 'regional' AND rr_rf.ranking_scope = 'filtered'
+				AND rr_rf.season_id = cr.season_id
+			LEFT JOIN run_rankings rr_lf ON cr.id = rr_lf.run_id
+				AND rr_lf.ranking_type = 'realm' AND rr_lf.ranking_scope = COALESCE(parent_r.slug, r.slug) || '_filtered'
+				AND rr_lf.season_id = cr.season_id
+			` + bestRunsScope + `
+		) best
+		WHERE run_pick = 1
 	`)
 	if err != nil {
 		return 0, err
@@ -98,7 +122,7 @@ func createPlayerAggregations(tx *sql.Tx) (int, error) {
 			COALESCE(SUM(pbr.duration), 0) as combined_best_time,
 			CASE
 				WHEN COUNT(pbr.dungeon_id) > 0
-				THEN CAST(SUM(pbr.duration) AS REAL) / COUNT(pbr.dungeon_id)
+				THEN CAST(SUM(pbr.duration) AS DOUBLE PRECISION) / COUNT(pbr.dungeon_id)
 				ELSE 0
 			END as average_best_time,
 			CASE WHEN COUNT(pbr.dungeon_id) = (SELECT COUNT(*) FROM dungeons) THEN 1 ELSE 0 END as has_complete_coverage,
@@ -111,6 +135,7 @@ func createPlayerAggregations(tx *sql.Tx) (int, error) {
 			INNER JOIN challenge_runs cr ON rm.run_id = cr.id
 			GROUP BY rm.player_id, cr.season_id
 		) season_runs ON p.id = season_runs.player_id AND pbr.season_id = season_runs.season_id
+		`+profilesScope+`
 		GROUP BY p.id, pbr.season_id, p.name, p.realm_id, season_runs.run_count
 	`, currentTime)
 	if err != nil {
@@ -143,7 +168,7 @@ func createPlayerAggregations(tx *sql.Tx) (int, error) {
 				AND spec_counts.season_id = player_profiles.season_id
 				AND spec_counts.rank = 1
 		)
-	`)
+		` + specScope)
 	if err != nil {
 		return 0, err
 	}
@@ -151,7 +176,7 @@ func createPlayerAggregations(tx *sql.Tx) (int, error) {
 
 	// step 5: derive class from main spec
 	log.Info("deriving class names from main specs")
-	if err := deriveClassFromMainSpec(tx); err != nil {
+	if err := deriveClassFromMainSpec(tx, scoped); err != nil {
 		return 0, fmt.Errorf("failed to derive class names: %w", err)
 	}
 	log.Info("updated class names")
@@ -159,14 +184,20 @@ func createPlayerAggregations(tx *sql.Tx) (int, error) {
 	return profilesCount, nil
 }
 
-// deriveClassFromMainSpec derives class_name from main_spec_id for all player profiles
-func deriveClassFromMainSpec(tx *sql.Tx) error {
-	// Query all player profiles with a main_spec_id
-	rows, err := tx.Query(`
+// deriveClassFromMainSpec derives class_name from main_spec_id for all player profiles,
+// or only for the pass's dirty players when scoped
+func deriveClassFromMainSpec(tx *sql.Tx, scoped bool) error {
+	query := `
 		SELECT player_id, season_id, main_spec_id
 		FROM player_profiles
 		WHERE main_spec_id IS NOT NULL
-	`)
+	`
+	if scoped {
+		query += " AND player_id IN (SELECT player_id FROM " + passPlayersTable + ")"
+	}
+
+	// Query all player profiles with a main_spec_id
+	rows, err := tx.Query(query)
 	if err != nil {
 		return fmt.Errorf("failed to query player profiles: %w", err)
 	}
@@ -8,26 +8,35 @@ import (
 	"github.com/charmbracelet/log"
 )
 
-// computePlayerRankings computes rankings for players with complete coverage per season
-func computePlayerRankings(tx *sql.Tx) (int, error) {
-	log.Info("computing player rankings per season")
-
-	currentTime := time.Now().UnixMilli()
-
+// rankedSeasons lists the seasons player rankings are computed for, in order. A
+// non-nil only restricts them to those seasons.
+func rankedSeasons(tx *sql.Tx, only map[int]bool) ([]int, error) {
 	// Get all distinct season numbers (global seasons across all regions)
-	seasonRows, err := tx.Query("SELECT DISTINCT season_number FROM seasons ORDER BY season_number")
+	all, err := queryInts(tx, "SELECT DISTINCT season_number FROM seasons ORDER BY season_number")
 	if err != nil {
-		return 0, fmt.Errorf("failed to query seasons: %w", err)
+		return nil, fmt.Errorf("failed to query seasons: %w", err)
+	}
+	if only == nil {
+		return all, nil
 	}
-	defer seasonRows.Close()
-
 	var seasons []int
-	for seasonRows.Next() {
-		var seasonNumber int
-		if err := seasonRows.Scan(&seasonNumber); err != nil {
-			return 0, err
+	for _, seasonID := range all {
+		if only[seasonID] {
+			seasons = append(seasons, seasonID)
 		}
-		seasons = append(seasons, seasonNumber)
+	}
+	return seasons, nil
+}
+
+// computePlayerRankings computes rankings for players with complete coverage per season,
+// for every season or only those in only. The player_rankings table is published
+// separately by publishPlayerRankings.
+func computePlayerRankings(tx *sql.Tx, only map[int]bool) (int, error) {
+	log.Info("computing player rankings per season")
+
+	seasons, err := rankedSeasons(tx, only)
+	if err != nil {
+		return 0, err
 	}
 
 	if len(seasons) == 0 {
@@ -65,7 +74,7 @@ func computePlayerRankings(tx *sql.Tx) (int, error) {
 				SELECT ranking FROM (
 					SELECT
 						player_id,
-						ROW_NUMBER() OVER (ORDER BY combined_best_time ASC) as ranking
+						ROW_NUMBER() OVER (ORDER BY combined_best_time ASC, player_id ASC) as ranking
 					FROM player_profiles
 					WHERE season_id = ? AND has_complete_coverage = 1
 				) global_ranks
@@ -77,17 +86,6 @@ func computePlayerRankings(tx *sql.Tx) (int, error) {
 			return 0, err
 		}
 
-		_, err = tx.Exec(`
-			INSERT OR REPLACE INTO player_rankings (player_id, ranking_type, ranking_scope, ranking, combined_best_time, last_updated)
-			SELECT
-				player_id, 'best_overall', 'global', global_ranking, combined_best_time, ?
-			FROM player_profiles
-			WHERE season_id = ? AND has_complete_coverage = 1 AND global_ranking IS NOT NULL
-		`, currentTime, seasonID)
-		if err != nil {
-			return 0, err
-		}
-
 		// update global ranking brackets for this season
 		log.Info("computing global ranking brackets", "season_id", seasonID)
 		_, err = tx.Exec(`
@@ -97,11 +95,11 @@ func computePlayerRankings(tx *sql.Tx) (int, error) {
 					WHEN player_profiles.combined_best_time = (SELECT MIN(combined_best_time) FROM player_profiles WHERE season_id = ? AND has_complete_coverage = 1) THEN 'artifact'
 					ELSE
 						CASE
-							WHEN (CAST(player_profiles.global_ranking AS REAL) / CAST(? AS REAL) * 100) <= 1.0 THEN 'excellent'
-							WHEN (CAST(player_profiles.global_ranking AS REAL) / CAST(? AS REAL) * 100) <= 5.0 THEN 'legendary'
-							WHEN (CAST(player_profiles.global_ranking AS REAL) / CAST(? AS REAL) * 100) <= 20.0 THEN 'epic'
-							WHEN (CAST(player_profiles.global_ranking AS REAL) / CAST(? AS REAL) * 100) <= 40.0 THEN 'rare'
-							WHEN (CAST(player_profiles.global_ranking AS REAL) / CAST(? AS REAL) * 100) <= 60.0 THEN 'uncommon'
+							WHEN (CAST(player_profiles.global_ranking AS DOUBLE PRECISION) / CAST(? AS DOUBLE PRECISION) * 100) <= 1.0 THEN 'excellent'
+							WHEN (CAST(player_profiles.global_ranking AS DOUBLE PRECISION) / CAST(? AS DOUBLE PRECISION) * 100) <= 5.0 THEN 'legendary'
+							WHEN (CAST(player_profiles.global_ranking AS DOUBLE PRECISION) / CAST(? AS DOUBLE PRECISION) * 100) <= 20.0 THEN 'epic'
+							WHEN (CAST(player_profiles.global_ranking AS DOUBLE PRECISION) / CAST(? AS DOUBLE PRECISION) * 100) <= 40.0 THEN 'rare'
+							WHEN (CAST(player_profiles.global_ranking AS DOUBLE PRECISION) / CAST(? AS DOUBLE PRECISION) * 100) <= 60.0 THEN 'uncommon'
 							ELSE 'common'
 						END
 				END
@@ -120,7 +118,7 @@ func computePlayerRankings(tx *sql.Tx) (int, error) {
 				SELECT ranking FROM (
 					SELECT
 						pp.player_id,
-						ROW_NUMBER() OVER (PARTITION BY r.region ORDER BY pp.combined_best_time ASC) as ranking
+						ROW_NUMBER() OVER (PARTITION BY r.region ORDER BY pp.combined_best_time ASC, pp.player_id ASC) as ranking
 					FROM player_profiles pp
 					INNER JOIN realms r ON pp.realm_id = r.id
 					WHERE pp.season_id = ? AND pp.has_complete_coverage = 1
@@ -133,18 +131,6 @@ func computePlayerRankings(tx *sql.Tx) (int, error) {
 			return 0, err
 		}
 
-		_, err = tx.Exec(`
-			INSERT OR REPLACE INTO player_rankings (player_id, ranking_type, ranking_scope, ranking, combined_best_time, last_updated)
-			SELECT
-				pp.player_id, 'best_overall', r.region, pp.regional_ranking, pp.combined_best_time, ?
-			FROM player_profiles pp
-			INNER JOIN realms r ON pp.realm_id = r.id
-			WHERE pp.season_id = ? AND pp.has_complete_coverage = 1 AND pp.regional_ranking IS NOT NULL
-		`, currentTime, seasonID)
-		if err != nil {
-			return 0, err
-		}
-
 		// update regional ranking brackets for this season
 		log.Info("computing regional ranking brackets", "season_id", seasonID)
 		_, err = tx.Exec(`
@@ -154,11 +140,11 @@ func computePlayerRankings(tx *sql.Tx) (int, error) {
 					WHEN counts.combined_best_time = counts.regional_min_time THEN 'artifact'
 					ELSE
 						CASE
-							WHEN (CAST(counts.regional_ranking AS REAL) / CAST(counts.regional_total AS REAL) * 100) <= 1.0 THEN 'excellent'
-							WHEN (CAST(counts.regional_ranking AS REAL) / CAST(counts.regional_total AS REAL) * 100) <= 5.0 THEN 'legendary'
-							WHEN (CAST(counts.regional_ranking AS REAL) / CAST(counts.regional_total AS REAL) * 100) <= 20.0 THEN 'epic'
-							WHEN (CAST(counts.regional_ranking AS REAL) / CAST(counts.regional_total AS REAL) * 100) <= 40.0 THEN 'rare'
-							WHEN (CAST(counts.regional_ranking AS REAL) / CAST(counts.regional_total AS REAL) * 100) <= 60.0 THEN 'uncommon'
+							WHEN (CAST(counts.regional_ranking AS DOUBLE PRECISION) / CAST(counts.regional_total AS DOUBLE PRECISION) * 100) <= 1.0 THEN 'excellent'
+							WHEN (CAST(counts.regional_ranking AS DOUBLE PRECISION) / CAST(counts.regional_total AS DOUBLE PRECISION) * 100) <= 5.0 THEN 'legendary'
+							WHEN (CAST(counts.regional_ranking AS DOUBLE PRECISION) / CAST(counts.regional_total AS DOUBLE PRECISION) * 100) <= 20.0 THEN 'epic'
+							WHEN (CAST(counts.regional_ranking AS DOUBLE PRECISION) / CAST(counts.regional_total AS DOUBLE PRECISION) * 100) <= 40.0 THEN 'rare'
+							WHEN (CAST(counts.regional_ranking AS DOUBLE PRECISION) / CAST(counts.regional_total AS DOUBLE PRECISION) * 100) <= 60.0 THEN 'uncommon'
 							ELSE 'common'
 						END
 				END
@@ -193,7 +179,7 @@ func computePlayerRankings(tx *sql.Tx) (int, error) {
 						pp.player_id,
 						ROW_NUMBER() OVER (
 							PARTITION BY COALESCE(parent_r.id, r.id)
-							ORDER BY pp.combined_best_time ASC
+							ORDER BY pp.combined_best_time ASC, pp.player_id ASC
 						) as ranking
 					FROM player_profiles pp
 					JOIN realms r ON pp.realm_id = r.id
@@ -208,17 +194,6 @@ func computePlayerRankings(tx *sql.Tx) (int, error) {
 			return 0, err
 		}
 
-		_, err = tx.Exec(`
-			INSERT OR REPLACE INTO player_rankings (player_id, ranking_type, ranking_scope, ranking, combined_best_time, last_updated)
-			SELECT
-				player_id, 'best_overall', CAST(realm_id AS TEXT), realm_ranking, combined_best_time, ?
-			FROM player_profiles
-			WHERE season_id = ? AND has_complete_coverage = 1 AND realm_ranking IS NOT NULL
-		`, currentTime, seasonID)
-		if err != nil {
-			return 0, err
-		}
-
 		// update realm ranking brackets for this season (pool-based for connected realms)
 		log.Info("computing realm ranking brackets (using realm pools)", "season_id", seasonID)
 		_, err = tx.Exec(`
@@ -228,11 +203,11 @@ func computePlayerRankings(tx *sql.Tx) (int, error) {
 					WHEN counts.combined_best_time = counts.pool_min_time THEN 'artifact'
 					ELSE
 						CASE
-							WHEN (CAST(counts.realm_ranking AS REAL) / CAST(counts.pool_total AS REAL) * 100) <= 1.0 THEN 'excellent'
-							WHEN (CAST(counts.realm_ranking AS REAL) / CAST(counts.pool_total AS REAL) * 100) <= 5.0 THEN 'legendary'
-							WHEN (CAST(counts.realm_ranking AS REAL) / CAST(counts.pool_total AS REAL) * 100) <= 20.0 THEN 'epic'
-							WHEN (CAST(counts.realm_ranking AS REAL) / CAST(counts.pool_total AS REAL) * 100) <= 40.0 THEN 'rare'
-							WHEN (CAST(counts.realm_ranking AS REAL) / CAST(counts.pool_total AS REAL) * 100) <= 60.0 THEN 'uncommon'
+							WHEN (CAST(counts.realm_ranking AS DOUBLE PRECISION) / CAST(counts.pool_total AS DOUBLE PRECISION) * 100) <= 1.0 THEN 'excellent'
+							WHEN (CAST(counts.realm_ranking AS DOUBLE PRECISION) / CAST(counts.pool_total AS DOUBLE PRECISION) * 100) <= 5.0 THEN 'legendary'
+							WHEN (CAST(counts.realm_ranking AS DOUBLE PRECISION) / CAST(counts.pool_total AS DOUBLE PRECISION) * 100) <= 20.0 THEN 'epic'
+							WHEN (CAST(counts.realm_ranking AS DOUBLE PRECISION) / CAST(counts.pool_total AS DOUBLE PRECISION) * 100) <= 40.0 THEN 'rare'
+							WHEN (CAST(counts.realm_ranking AS DOUBLE PRECISION) / CAST(counts.pool_total AS DOUBLE PRECISION) * 100) <= 60.0 THEN 'uncommon'
 							ELSE 'common'
 						END
 				END
@@ -269,24 +244,14 @@ func computePlayerRankings(tx *sql.Tx) (int, error) {
 	return totalQualified, nil
 }
 
-// computePlayerClassRankings computes class-specific rankings for players per season
-func computePlayerClassRankings(tx *sql.Tx) error {
+// computePlayerClassRankings computes class-specific rankings for players per season,
+// for every season or only those in only
+func computePlayerClassRankings(tx *sql.Tx, only map[int]bool) error {
 	log.Info("computing class-specific player rankings per season")
 
-	// get all distinct season numbers
-	seasonRows, err := tx.Query("SELECT DISTINCT season_number FROM seasons ORDER BY season_number")
+	seasons, err := rankedSeasons(tx, only)
 	if err != nil {
-		return fmt.Errorf("failed to query seasons: %w", err)
-	}
-	defer seasonRows.Close()
-
-	var seasons []int
-	for seasonRows.Next() {
-		var seasonNumber int
-		if err := seasonRows.Scan(&seasonNumber); err != nil {
-			return err
-		}
-		seasons = append(seasons, seasonNumber)
+		return err
 	}
 
 	if len(seasons) == 0 {
@@ -304,7 +269,7 @@ func computePlayerClassRankings(tx *sql.Tx) error {
 				SELECT ranking FROM (
 					SELECT
 						player_id,
-						ROW_NUMBER() OVER (PARTITION BY class_name ORDER BY combined_best_time ASC) as ranking
+						ROW_NUMBER() OVER (PARTITION BY class_name ORDER BY combined_best_time ASC, player_id ASC) as ranking
 					FROM player_profiles
 					WHERE season_id = ? AND has_complete_coverage = 1 AND class_name IS NOT NULL
 				) class_ranks
@@ -324,11 +289,11 @@ func computePlayerClassRankings(tx *sql.Tx) error {
 					WHEN counts.combined_best_time = counts.class_min_time THEN 'artifact'
 					ELSE
 						CASE
-							WHEN (CAST(counts.global_class_rank AS REAL) / CAST(counts.class_total AS REAL) * 100) <= 1.0 THEN 'excellent'
-							WHEN (CAST(counts.global_class_rank AS REAL) / CAST(counts.class_total AS REAL) * 100) <= 5.0 THEN 'legendary'
-							WHEN (CAST(counts.global_class_rank AS REAL) / CAST(counts.class_total AS REAL) * 100) <= 20.0 THEN 'epic'
-							WHEN (CAST(counts.global_class_rank AS REAL) / CAST(counts.class_total AS REAL) * 100) <= 40.0 THEN 'rare'
-							WHEN (CAST(counts.global_class_rank AS REAL) / CAST(counts.class_total AS REAL) * 100) <= 60.0 THEN 'uncommon'
+							WHEN (CAST(counts.global_class_rank AS DOUBLE PRECISION) / CAST(counts.class_total AS DOUBLE PRECISION) * 100) <= 1.0 THEN 'excellent'
+							WHEN (CAST(counts.global_class_rank AS DOUBLE PRECISION) / CAST(counts.class_total AS DOUBLE PRECISION) * 100) <= 5.0 THEN 'legendary'
+							WHEN (CAST(counts.global_class_rank AS DOUBLE PRECISION) / CAST(counts.class_total AS DOUBLE PRECISION) * 100) <= 20.0 THEN 'epic'
+							WHEN (CAST(counts.global_class_rank AS DOUBLE PRECISION) / CAST(counts.class_total AS DOUBLE PRECISION) * 100) <= 40.0 THEN 'rare'
+							WHEN (CAST(counts.global_class_rank AS DOUBLE PRECISION) / CAST(counts.class_total AS DOUBLE PRECISION) * 100) <= 60.0 THEN 'uncommon'
 							ELSE 'common'
 						END
 				END
@@ -360,7 +325,7 @@ func computePlayerClassRankings(tx *sql.Tx) error {
 				SELECT ranking FROM (
 					SELECT
 						pp.player_id,
-						ROW_NUMBER() OVER (PARTITION BY r.region, pp.class_name ORDER BY pp.combined_best_time ASC) as ranking
+						ROW_NUMBER() OVER (PARTITION BY r.region, pp.class_name ORDER BY pp.combined_best_time ASC, pp.player_id ASC) as ranking
 					FROM player_profiles pp
 					INNER JOIN realms r ON pp.realm_id = r.id
 					WHERE pp.season_id = ? AND pp.has_complete_coverage = 1 AND pp.class_name IS NOT NULL
@@ -381,11 +346,11 @@ func computePlayerClassRankings(tx *sql.Tx) error {
 					WHEN counts.combined_best_time = counts.class_regional_min_time THEN 'artifact'
 					ELSE
 						CASE
-							WHEN (CAST(counts.region_class_rank AS REAL) / CAST(counts.class_regional_total AS REAL) * 100) <= 1.0 THEN 'excellent'
-							WHEN (CAST(counts.region_class_rank AS REAL) / CAST(counts.class_regional_total AS REAL) * 100) <= 5.0 THEN 'legendary'
-							WHEN (CAST(counts.region_class_rank AS REAL) / CAST(counts.class_regional_total AS REAL) * 100) <= 20.0 THEN 'epic'
-							WHEN (CAST(counts.region_class_rank AS REAL) / CAST(counts.class_regional_total AS REAL) * 100) <= 40.0 THEN 'rare'
-							WHEN (CAST(counts.region_class_rank AS REAL) / CAST(counts.class_regional_total AS REAL) * 100) <= 60.0 THEN 'uncommon'
+							WHEN (CAST(counts.region_class_rank AS DOUBLE PRECISION) / CAST(counts.class_regional_total AS DOUBLE PRECISION) * 100) <= 1.0 THEN 'excellent'
+							WHEN (CAST(counts.region_class_rank AS DOUBLE PRECISION) / CAST(counts.class_regional_total AS DOUBLE PRECISION) * 100) <= 5.0 THEN 'legendary'
+							WHEN (CAST(counts.region_class_rank AS DOUBLE PRECISION) / CAST(counts.class_regional_total AS DOUBLE PRECISION) * 100) <= 20.0 THEN 'epic'
+							WHEN (CAST(counts.region_class_rank AS DOUBLE PRECISION) / CAST(counts.class_regional_total AS DOUBLE PRECISION) * 100) <= 40.0 THEN 'rare'
+							WHEN (CAST(counts.region_class_rank AS DOUBLE PRECISION) / CAST(counts.class_regional_total AS DOUBLE PRECISION) * 100) <= 60.0 THEN 'uncommon'
 							ELSE 'common'
 						END
 				END
@@ -420,7 +385,7 @@ func computePlayerClassRankings(tx *sql.Tx) error {
 						pp.player_id,
 						ROW_NUMBER() OVER (
 							PARTITION BY COALESCE(parent_r.id, r.id), pp.class_name
-							ORDER BY pp.combined_best_time ASC
+							ORDER BY pp.combined_best_time ASC, pp.player_id ASC
 						) as ranking
 					FROM player_profiles pp
 					JOIN realms r ON pp.realm_id = r.id
@@ -443,11 +408,11 @@ func computePlayerClassRankings(tx *sql.Tx) error {
 					WHEN counts.combined_best_time = counts.class_pool_min_time THEN 'artifact'
 					ELSE
 						CASE
-							WHEN (CAST(counts.realm_class_rank AS REAL) / CAST(counts.class_pool_total AS REAL) * 100) <= 1.0 THEN 'excellent'
-							WHEN (CAST(counts.realm_class_rank AS REAL) / CAST(counts.class_pool_total AS REAL) * 100) <= 5.0 THEN 'legendary'
-							WHEN (CAST(counts.realm_class_rank AS REAL) / CAST(counts.class_pool_total AS REAL) * 100) <= 20.0 THEN 'epic'
-							WHEN (CAST(counts.realm_class_rank AS REAL) / CAST(counts.class_pool_total AS REAL) * 100) <= 40.0 THEN 'rare'
-							WHEN (CAST(counts.realm_class_rank AS REAL) / CAST(counts.class_pool_total AS REAL) * 100) <= 60.0 THEN 'uncommon'
+							WHEN (CAST(counts.realm_class_rank AS DOUBLE PRECISION) / CAST(counts.class_pool_total AS DOUBLE PRECISION) * 100) <= 1.0 THEN 'excellent'
+							WHEN (CAST(counts.realm_class_rank AS DOUBLE PRECISION) / CAST(counts.class_pool_total AS DOUBLE PRECISION) * 100) <= 5.0 THEN 'legendary'
+							WHEN (CAST(counts.realm_class_rank AS DOUBLE PRECISION) / CAST(counts.class_pool_total AS DOUBLE PRECISION) * 100) <= 20.0 THEN 'epic'
+							WHEN (CAST(counts.realm_class_rank AS DOUBLE PRECISION) / CAST(counts.class_pool_total AS DOUBLE PRECISION) * 100) <= 40.0 THEN 'rare'
+							WHEN (CAST(counts.realm_class_rank AS DOUBLE PRECISION) / CAST(counts.class_pool_total AS DOUBLE PRECISION) * 100) <= 60.0 THEN 'uncommon'
 							ELSE 'common'
 						END
 				END
@@ -481,3 +446,62 @@ func computePlayerClassRankings(tx *sql.Tx) error {
 	log.Info("computed class rankings for all seasons")
 	return nil
 }
+
+// publishPlayerRankings upserts every season's rankings into player_rankings, oldest
+// season first, so a player ranked in several seasons keeps the latest one
+func publishPlayerRankings(tx *sql.Tx) error {
+	currentTime := time.Now().UnixMilli()
+
+	seasons, err := rankedSeasons(tx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, seasonID := range seasons {
+		if _, err := tx.Exec(`
+			INSERT INTO player_rankings (player_id, ranking_type, ranking_scope, ranking, combined_best_time, last_updated)
+			SELECT
+				player_id, 'best_overall', 'global', global_ranking, combined_best_time, ?
+			FROM player_profiles
+			WHERE season_id = ? AND has_complete_coverage = 1 AND global_ranking IS NOT NULL
+			ON CONFLICT(player_id, ranking_type, ranking_scope) DO UPDATE SET
+				ranking = excluded.ranking,
+				combined_best_time = excluded.combined_best_time,
+				last_updated = excluded.last_updated
+		`, currentTime, seasonID); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO player_rankings (player_id, ranking_type, ranking_scope, ranking, combined_best_time, last_updated)
+			SELECT
+				pp.player_id, 'best_overall', r.region, pp.regional_ranking, pp.combined_best_time, ?
+			FROM player_profiles pp
+			INNER JOIN realms r ON pp.realm_id = r.id
+			WHERE pp.season_id = ? AND pp.has_complete_coverage = 1 AND pp.regional_ranking IS NOT NULL
+			ON CONFLICT(player_id, ranking_type, ranking_scope) DO UPDATE SET
+				ranking = excluded.ranking,
+				combined_best_time = excluded.combined_best_time,
+				last_updated = excluded.last_updated
+		`, currentTime, seasonID); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO player_rankings (player_id, ranking_type, ranking_scope, ranking, combined_best_time, last_updated)
+			SELECT
+				player_id, 'best_overall', CAST(realm_id AS TEXT), realm_ranking, combined_best_time, ?
+			FROM player_profiles
+			WHERE season_id = ? AND has_complete_coverage = 1 AND realm_ranking IS NOT NULL
+			ON CONFLICT(player_id, ranking_type, ranking_scope) DO UPDATE SET
+				ranking = excluded.ranking,
+				combined_best_time = excluded.combined_best_time,
+				last_updated = excluded.last_updated
+		`, currentTime, seasonID); err != nil {
+			return err
+		}
+	}
+
+	log.Info("published player rankings", "seasons", len(seasons))
+	return nil
+}
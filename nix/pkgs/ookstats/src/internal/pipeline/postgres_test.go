@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"ookstats/internal/database"
+	"ookstats/internal/dbtest"
+)
+
+// TestProcessAllPostgres migrates a Postgres schema, ingests fixtures and runs a full
+// and a verified incremental ranking pass. It needs OOKSTATS_TEST_PG_DSN.
+func TestProcessAllPostgres(t *testing.T) {
+	db := dbtest.OpenPostgres(t)
+	ds := database.NewDatabaseService(db)
+	f := dbtest.Seed(t, ds)
+	dbtest.Ingest(t, ds, f, 8)
+
+	ctx := context.Background()
+	res, err := ProcessAll(ctx, ds, ProcessAllOptions{})
+	if err != nil {
+		t.Fatalf("full pass: %v", err)
+	}
+	if !res.Full {
+		t.Fatalf("first pass was incremental")
+	}
+	for _, table := range []string{"run_rankings", "player_rankings", "player_profiles"} {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if n == 0 {
+			t.Errorf("%s is empty after the full pass", table)
+		}
+	}
+
+	dbtest.Ingest(t, ds, f, 10)
+	res, err = ProcessAll(ctx, ds, ProcessAllOptions{Verify: true})
+	if err != nil {
+		t.Fatalf("incremental pass: %v", err)
+	}
+	if res.Full || !res.Verified {
+		t.Errorf("second pass: full=%v verified=%v, want a verified incremental pass", res.Full, res.Verified)
+	}
+}
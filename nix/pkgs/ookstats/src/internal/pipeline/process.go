@@ -1,13 +1,14 @@
 package pipeline
 
 // season assignment has been migrated to use cr.season_id directly instead of period_seasons lookups.
-// all queries now use timestamp-based season assignment from the challenge_runs table.
+// runs get their season_id from the keystone period containing them (see AssignRunsToSeasons).
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 
 	"github.com/charmbracelet/log"
+	"ookstats/internal/database"
 )
 
 // ProcessPlayersOptions contains options for player processing
@@ -16,10 +17,11 @@ type ProcessPlayersOptions struct {
 }
 
 // ProcessPlayers processes player aggregations and rankings
-func ProcessPlayers(db *sql.DB, opts ProcessPlayersOptions) (profilesCreated int, qualifiedPlayers int, err error) {
+func ProcessPlayers(ctx context.Context, store database.Store, opts ProcessPlayersOptions) (profilesCreated int, qualifiedPlayers int, err error) {
 	log.Info("player aggregation")
 
 	// check if we have data
+	db := store.DB()
 	var runCount, playerCount int
 	db.QueryRow("SELECT COUNT(*) FROM challenge_runs").Scan(&runCount)
 	db.QueryRow("SELECT COUNT(*) FROM players").Scan(&playerCount)
@@ -30,8 +32,8 @@ func ProcessPlayers(db *sql.DB, opts ProcessPlayersOptions) (profilesCreated int
 		return 0, 0, fmt.Errorf("no runs found in database - run 'fetch cm' first")
 	}
 
-	// begin transaction for all player operations
-	tx, err := db.Begin()
+	// begin transaction for all player operations; cancelling ctx rolls it back
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -49,23 +51,36 @@ func ProcessPlayers(db *sql.DB, opts ProcessPlayersOptions) (profilesCreated int
 
 	// step 1: create player aggregations (season-aware if seasons exist)
 	log.Info("creating player aggregations")
-	profilesCreated, err = createPlayerAggregations(tx)
+	profilesCreated, err = createPlayerAggregations(tx, false)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to create player aggregations: %w", err)
 	}
 
 	// step 2: compute player rankings (global, regional, realm) per season
 	log.Info("computing player rankings")
-	qualifiedPlayers, err = computePlayerRankings(tx)
+	qualifiedPlayers, err = computePlayerRankings(tx, nil)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to compute player rankings: %w", err)
 	}
 
 	// step 3: compute class-specific rankings per season
 	log.Info("computing class-specific rankings")
-	if err = computePlayerClassRankings(tx); err != nil {
+	if err = computePlayerClassRankings(tx, nil); err != nil {
 		return 0, 0, fmt.Errorf("failed to compute class rankings: %w", err)
 	}
+	if err = publishPlayerRankings(tx); err != nil {
+		return 0, 0, fmt.Errorf("failed to publish player rankings: %w", err)
+	}
+
+	if _, err = recordPlayerRankingHistory(tx, nowMillis()); err != nil {
+		return 0, 0, err
+	}
+
+	// run rankings were not recomputed alongside, so the next `process all` can't
+	// trust its dirty marks and rebuilds everything
+	if err = store.ResetRankingState(tx); err != nil {
+		return 0, 0, err
+	}
 
 	// commit all changes
 	if err := tx.Commit(); err != nil {
@@ -74,7 +89,7 @@ func ProcessPlayers(db *sql.DB, opts ProcessPlayersOptions) (profilesCreated int
 
 	// optimize database
 	log.Info("optimizing database")
-	if _, err := db.Exec("VACUUM"); err != nil {
+	if err := store.Optimize(); err != nil {
 		log.Warn("database optimization failed", "error", err)
 	}
 
@@ -91,12 +106,14 @@ type ProcessRunRankingsOptions struct {
 }
 
 // ProcessRunRankings computes global, regional, and realm rankings for all runs
-func ProcessRunRankings(db *sql.DB, opts ProcessRunRankingsOptions) error {
+func ProcessRunRankings(ctx context.Context, store database.Store, opts ProcessRunRankingsOptions) error {
 	log.Info("run ranking processor")
 
 	// check if we have data
-	var runCount int
-	db.QueryRow("SELECT COUNT(*) FROM challenge_runs").Scan(&runCount)
+	runCount, err := store.CountRuns()
+	if err != nil {
+		return err
+	}
 
 	if runCount == 0 {
 		return fmt.Errorf("no runs found in database - run 'fetch cm' first")
@@ -104,8 +121,8 @@ func ProcessRunRankings(db *sql.DB, opts ProcessRunRankingsOptions) error {
 
 	log.Info("found runs in database", "runs", runCount)
 
-	// begin transaction for all ranking operations
-	tx, err := db.Begin()
+	// begin transaction for all ranking operations; cancelling ctx rolls it back
+	tx, err := store.DB().BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -113,22 +130,28 @@ func ProcessRunRankings(db *sql.DB, opts ProcessRunRankingsOptions) error {
 
 	// step 1: compute global run rankings
 	log.Info("computing global run rankings")
-	if err := computeGlobalRankings(tx); err != nil {
+	if err := computeGlobalRankings(tx, nil); err != nil {
 		return fmt.Errorf("failed to compute global rankings: %w", err)
 	}
 
 	// step 2: compute regional run rankings
 	log.Info("computing regional run rankings")
-	if err := computeRegionalRankings(tx); err != nil {
+	if err := computeRegionalRankings(tx, nil); err != nil {
 		return fmt.Errorf("failed to compute regional rankings: %w", err)
 	}
 
 	// step 3: compute realm run rankings (pool-based for connected realms)
 	log.Info("computing realm run rankings (pool-based)")
-	if err := computeRealmRankings(tx); err != nil {
+	if err := computeRealmRankings(tx, nil); err != nil {
 		return fmt.Errorf("failed to compute realm rankings: %w", err)
 	}
 
+	// best runs copy these rankings but were not rebuilt, so the next `process all`
+	// rebuilds everything
+	if err := store.ResetRankingState(tx); err != nil {
+		return err
+	}
+
 	// commit all changes
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit run rankings: %w", err)
@@ -136,7 +159,7 @@ func ProcessRunRankings(db *sql.DB, opts ProcessRunRankingsOptions) error {
 
 	// optimize database
 	log.Info("optimizing database")
-	if _, err := db.Exec("VACUUM"); err != nil {
+	if err := store.Optimize(); err != nil {
 		log.Warn("database optimization failed", "error", err)
 	}
 
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -27,7 +28,7 @@ type BatchResult struct {
 	Duration  time.Duration
 }
 
-func RunBatchProcessor[T any](cfg BatchConfig, callbacks BatchCallbacks[T]) (*BatchResult, error) {
+func RunBatchProcessor[T any](ctx context.Context, cfg BatchConfig, callbacks BatchCallbacks[T]) (*BatchResult, error) {
 	start := time.Now()
 	logger := log.With("component", cfg.ComponentName)
 
@@ -52,6 +53,10 @@ func RunBatchProcessor[T any](cfg BatchConfig, callbacks BatchCallbacks[T]) (*Ba
 
 	batchNumber := 0
 	for {
+		// stop between batches so finished work stays committed
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
 		if tracker.ShouldStop(result.Processed) {
 			break
 		}
@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+)
+
+// recordPlayerRankingHistory appends the current ranks of every ranked player profile
+// to player_ranking_history, skipping profiles whose ranks and combined time equal
+// their latest recorded row
+func recordPlayerRankingHistory(tx *sql.Tx, recordedAt int64) (int64, error) {
+	res, err := tx.Exec(`
+		INSERT INTO player_ranking_history (
+			player_id, season_id, recorded_at, combined_best_time,
+			global_ranking, regional_ranking, realm_ranking,
+			global_class_rank, region_class_rank, realm_class_rank
+		)
+		SELECT
+			pp.player_id, pp.season_id, ?, pp.combined_best_time,
+			pp.global_ranking, pp.regional_ranking, pp.realm_ranking,
+			pp.global_class_rank, pp.region_class_rank, pp.realm_class_rank
+		FROM player_profiles pp
+		LEFT JOIN player_ranking_history h ON h.player_id = pp.player_id
+			AND h.season_id = pp.season_id
+			AND h.recorded_at = (
+				SELECT MAX(h2.recorded_at)
+				FROM player_ranking_history h2
+				WHERE h2.player_id = pp.player_id AND h2.season_id = pp.season_id
+			)
+		WHERE pp.global_ranking IS NOT NULL
+			AND (
+				h.player_id IS NULL
+				OR h.combined_best_time IS DISTINCT FROM pp.combined_best_time
+				OR h.global_ranking IS DISTINCT FROM pp.global_ranking
+				OR h.regional_ranking IS DISTINCT FROM pp.regional_ranking
+				OR h.realm_ranking IS DISTINCT FROM pp.realm_ranking
+				OR h.global_class_rank IS DISTINCT FROM pp.global_class_rank
+				OR h.region_class_rank IS DISTINCT FROM pp.region_class_rank
+				OR h.realm_class_rank IS DISTINCT FROM pp.realm_class_rank
+			)
+		ON CONFLICT(player_id, season_id, recorded_at) DO NOTHING
+	`, recordedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record player ranking history: %w", err)
+	}
+	recorded, _ := res.RowsAffected()
+	log.Info("recorded player ranking history", "changed", recorded)
+	return recorded, nil
+}
@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ookstats/internal/blizzard"
+	"ookstats/internal/database"
+)
+
+// DefaultRealmRegions are the regions walked by a realm sync when none are given
+var DefaultRealmRegions = []string{"us", "eu", "kr", "tw"}
+
+// ErrNoRealms is returned when the realm registry hasn't been synced yet
+var ErrNoRealms = errors.New("no realms in database; run `ookstats fetch realms` first")
+
+// SyncRealmsOptions contains options for syncing the realm registry
+type SyncRealmsOptions struct {
+	Regions []string
+	// Exclude lists realm slugs to leave out (e.g. PTR realms listed in the index)
+	Exclude []string
+}
+
+// SyncRealmsResult contains statistics from a realm sync
+type SyncRealmsResult struct {
+	ConnectedRealms int
+	Realms          int
+	Added           int
+	Updated         int
+	FailedRegions   []string
+	Interrupted     bool
+}
+
+// SyncRealms walks the connected realm index of each region and writes every member
+// realm to the realms table with its connected realm ID and pool parent
+func SyncRealms(ctx context.Context, db database.Store, client *blizzard.Client, opts SyncRealmsOptions) (*SyncRealmsResult, error) {
+	regions := opts.Regions
+	if len(regions) == 0 {
+		regions = DefaultRealmRegions
+	}
+	exclude := make(map[string]bool, len(opts.Exclude))
+	for _, slug := range opts.Exclude {
+		exclude[strings.ToLower(strings.TrimSpace(slug))] = true
+	}
+
+	result := &SyncRealmsResult{}
+	for _, region := range regions {
+		region = strings.ToLower(strings.TrimSpace(region))
+		if region == "" {
+			continue
+		}
+
+		ids, err := client.FetchConnectedRealmIDs(ctx, region)
+		if err != nil {
+			if ctx.Err() != nil {
+				result.Interrupted = true
+				return result, nil
+			}
+			fmt.Printf("[ERROR] Connected realm index for %s: %v\n", strings.ToUpper(region), err)
+			result.FailedRegions = append(result.FailedRegions, region)
+			continue
+		}
+		fmt.Printf("%s: %d connected realms\n", strings.ToUpper(region), len(ids))
+
+		var realms []blizzard.RealmInfo
+		complete := true
+		for _, id := range ids {
+			cr, err := client.FetchConnectedRealm(ctx, region, id)
+			if err != nil {
+				if ctx.Err() != nil {
+					result.Interrupted = true
+					return result, nil
+				}
+				fmt.Printf("  [WARN] Connected realm %d: %v\n", id, err)
+				complete = false
+				continue
+			}
+			result.ConnectedRealms++
+			for _, ri := range cr.RealmInfos(region) {
+				if exclude[ri.Slug] {
+					continue
+				}
+				realms = append(realms, ri)
+			}
+		}
+		if !complete {
+			result.FailedRegions = append(result.FailedRegions, region)
+		}
+
+		added, updated, err := db.UpsertRealms(realms)
+		if err != nil {
+			return result, err
+		}
+		result.Realms += len(realms)
+		result.Added += added
+		result.Updated += updated
+		fmt.Printf("  [OK] %d realms (%d new, %d updated)\n", len(realms), added, updated)
+	}
+
+	return result, nil
+}
+
+// LoadRealms reads the realm registry, failing with ErrNoRealms when it's empty
+func LoadRealms(db database.Store) (map[string]blizzard.RealmInfo, error) {
+	realms, err := db.GetRealms()
+	if err != nil {
+		return nil, err
+	}
+	if len(realms) == 0 {
+		return nil, ErrNoRealms
+	}
+	return realms, nil
+}
+
+// FindRealm looks a realm up by registry key or Blizzard slug, optionally within a region
+func FindRealm(realms map[string]blizzard.RealmInfo, region, slug string) (blizzard.RealmInfo, bool) {
+	region = strings.ToLower(strings.TrimSpace(region))
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if info, ok := realms[slug]; ok && (region == "" || info.Region == region) {
+		return info, true
+	}
+
+	keys := make([]string, 0, len(realms))
+	for key := range realms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		info := realms[key]
+		if info.Slug == slug && (region == "" || info.Region == region) {
+			return info, true
+		}
+	}
+	return blizzard.RealmInfo{}, false
+}
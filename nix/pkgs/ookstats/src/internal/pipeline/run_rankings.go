@@ -2,23 +2,124 @@ package pipeline
 
 import (
 	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/charmbracelet/log"
 )
 
-// computeGlobalRankings computes global rankings for all runs (per season)
-func computeGlobalRankings(tx *sql.Tx) error {
-	log.Info("computing global rankings per season")
+// seasonDungeon is one ranking partition. The zero value stands for every partition,
+// which is how the full rebuild calls the helpers below.
+type seasonDungeon struct {
+	SeasonID  int
+	DungeonID int
+}
+
+func (p seasonDungeon) all() bool {
+	return p.SeasonID == 0 && p.DungeonID == 0
+}
+
+// where returns the condition restricting alias's rows to the partition, joined with
+// conj ("WHERE" or "AND"); it is empty for the whole table
+func (p seasonDungeon) where(conj, alias string) (string, []any) {
+	if p.all() {
+		return "", nil
+	}
+	return fmt.Sprintf(" %s %s.dungeon_id = ? AND %s.season_id = ?", conj, alias, alias), []any{p.DungeonID, p.SeasonID}
+}
+
+// realmPool is a connected-realm group; realm rankings are scoped by its slug
+type realmPool struct {
+	PoolSlug string
+	Region   string
+}
+
+// poolRunsFilter selects the runs sighted on any realm of a pool; parameters are
+// region, pool slug
+const poolRunsFilter = `cr.id IN (
+	SELECT rs.run_id
+	FROM run_sightings rs
+	INNER JOIN realms r ON rs.realm_id = r.id
+	LEFT JOIN realms parent_r ON r.parent_realm_slug = parent_r.slug AND r.region = parent_r.region
+	WHERE r.region = ? AND COALESCE(parent_r.slug, r.slug) = ?
+)`
+
+// computeGlobalRankings computes global rankings for all runs (per season). Runs
+// seen on several realm leaderboards are stored once, so each counts once here.
+// With parts it only rebuilds those season/dungeon partitions.
+func computeGlobalRankings(tx *sql.Tx, parts []seasonDungeon) error {
+	log.Info("computing global rankings per season", "partitions", describeParts(parts))
 
 	currentTime := time.Now().UnixMilli()
 
-	// clear existing global rankings
-	if _, err := tx.Exec("DELETE FROM run_rankings WHERE ranking_type = 'global'"); err != nil {
+	if parts == nil {
+		// clear existing global rankings
+		if _, err := tx.Exec("DELETE FROM run_rankings WHERE ranking_type = 'global'"); err != nil {
+			return err
+		}
+		if err := insertGlobalRankings(tx, seasonDungeon{}, currentTime); err != nil {
+			return err
+		}
+		// update percentile brackets for unfiltered global rankings using efficient SQL (per season)
+		log.Info("computing global ranking brackets per season")
+		if err := updateRunRankingBrackets(tx, "global", "all", seasonDungeon{}); err != nil {
+			return err
+		}
+
+		// filtered global rankings (best time per team, per season)
+		all, err := rankablePartitions(tx, "")
+		if err != nil {
+			return err
+		}
+		for _, part := range all {
+			if err := insertGlobalFilteredRankings(tx, part, currentTime); err != nil {
+				return err
+			}
+		}
+
+		// update percentile brackets for filtered global rankings using efficient SQL (per season)
+		log.Info("computing filtered global ranking brackets per season")
+		if err := updateRunRankingBrackets(tx, "global", "filtered", seasonDungeon{}); err != nil {
+			return err
+		}
+
+		log.Info("computed global rankings with percentile brackets (all and filtered)")
+		return nil
+	}
+
+	known, err := knownDungeons(tx)
+	if err != nil {
 		return err
 	}
+	for _, part := range parts {
+		if _, err := tx.Exec(`DELETE FROM run_rankings WHERE ranking_type = 'global' AND dungeon_id = ? AND season_id = ?`,
+			part.DungeonID, part.SeasonID); err != nil {
+			return err
+		}
+		if err := insertGlobalRankings(tx, part, currentTime); err != nil {
+			return err
+		}
+		if err := updateRunRankingBrackets(tx, "global", "all", part); err != nil {
+			return err
+		}
+		if !known[part.DungeonID] {
+			continue
+		}
+		if err := insertGlobalFilteredRankings(tx, part, currentTime); err != nil {
+			return err
+		}
+		if err := updateRunRankingBrackets(tx, "global", "filtered", part); err != nil {
+			return err
+		}
+	}
 
-	// unfiltered global rankings - first insert without brackets, partitioned by season
+	log.Info("computed global rankings with percentile brackets (all and filtered)", "partitions", len(parts))
+	return nil
+}
+
+// insertGlobalRankings ranks every run of the partition(s) by time
+func insertGlobalRankings(tx *sql.Tx, part seasonDungeon, currentTime int64) error {
+	where, args := part.where("WHERE", "cr")
 	_, err := tx.Exec(`
 		INSERT INTO run_rankings (run_id, dungeon_id, ranking_type, ranking_scope, ranking, season_id, computed_at)
 		SELECT
@@ -26,418 +127,317 @@ func computeGlobalRankings(tx *sql.Tx) error {
 			cr.dungeon_id,
 			'global' as ranking_type,
 			'all' as ranking_scope,
-			ROW_NUMBER() OVER (PARTITION BY cr.dungeon_id, cr.season_id ORDER BY cr.duration ASC, cr.completed_timestamp ASC) as ranking,
+			ROW_NUMBER() OVER (PARTITION BY cr.dungeon_id, cr.season_id ORDER BY cr.duration ASC, cr.completed_timestamp ASC, cr.id ASC) as ranking,
 			cr.season_id as season_id,
 			? as computed_at
-		FROM challenge_runs cr
-	`, currentTime)
-	if err != nil {
-		return err
-	}
+		FROM challenge_runs cr`+where, append([]any{currentTime}, args...)...)
+	return err
+}
 
-	// update percentile brackets for unfiltered global rankings using efficient SQL (per season)
-	log.Info("computing global ranking brackets per season")
-	_, err = tx.Exec(`
-		UPDATE run_rankings
-		SET percentile_bracket = (
-			CASE
-				WHEN counts.duration = counts.min_duration THEN 'artifact'
-				ELSE
-					CASE
-						WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_season_dungeon AS REAL) * 100) <= 1.0 THEN 'excellent'
-						WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_season_dungeon AS REAL) * 100) <= 5.0 THEN 'legendary'
-						WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_season_dungeon AS REAL) * 100) <= 20.0 THEN 'epic'
-						WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_season_dungeon AS REAL) * 100) <= 40.0 THEN 'rare'
-						WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_season_dungeon AS REAL) * 100) <= 60.0 THEN 'uncommon'
-						ELSE 'common'
-					END
-			END
-		)
-		FROM (
+// insertGlobalFilteredRankings ranks each team's best run of one dungeon and season
+func insertGlobalFilteredRankings(tx *sql.Tx, part seasonDungeon, currentTime int64) error {
+	dungeonID, seasonID := part.DungeonID, part.SeasonID
+	_, err := tx.Exec(`
+		WITH best_team_runs AS (
 			SELECT
-				rr.run_id,
-				rr.dungeon_id,
-				rr.season_id,
-				rr.ranking,
+				cr.team_signature,
+				MIN(cr.duration) as best_duration
+			FROM challenge_runs cr
+			WHERE cr.dungeon_id = ? AND cr.season_id = ?
+			GROUP BY cr.team_signature
+		),
+		team_best_runs AS (
+			SELECT
+				cr.id as run_id,
 				cr.duration,
-				MIN(cr.duration) OVER (PARTITION BY rr.dungeon_id, rr.season_id) as min_duration,
-				COUNT(*) OVER (PARTITION BY rr.dungeon_id, rr.season_id) as total_in_season_dungeon
-			FROM run_rankings rr
-			INNER JOIN challenge_runs cr ON rr.run_id = cr.id
-			WHERE rr.ranking_type = 'global' AND rr.ranking_scope = 'all'
-		) counts
-		WHERE run_rankings.run_id = counts.run_id
-		AND run_rankings.dungeon_id = counts.dungeon_id
-		AND run_rankings.season_id = counts.season_id
-		AND run_rankings.ranking_type = 'global'
-		AND run_rankings.ranking_scope = 'all'
-	`)
-	if err != nil {
-		return err
-	}
+				cr.completed_timestamp,
+				ROW_NUMBER() OVER (PARTITION BY cr.team_signature ORDER BY cr.id ASC) as team_pick
+			FROM challenge_runs cr
+			INNER JOIN best_team_runs btr ON cr.team_signature = btr.team_signature
+										 AND cr.duration = btr.best_duration
+			WHERE cr.dungeon_id = ? AND cr.season_id = ?
+		),
+		filtered_runs AS (
+			SELECT
+				run_id,
+				duration,
+				completed_timestamp,
+				ROW_NUMBER() OVER (ORDER BY duration ASC, completed_timestamp ASC, run_id ASC) as filtered_rank
+			FROM team_best_runs
+			WHERE team_pick = 1
+		)
+		INSERT INTO run_rankings (run_id, dungeon_id, ranking_type, ranking_scope, ranking, season_id, computed_at)
+		SELECT
+			run_id,
+			? as dungeon_id,
+			'global' as ranking_type,
+			'filtered' as ranking_scope,
+			filtered_rank as ranking,
+			? as season_id,
+			? as computed_at
+		FROM filtered_runs
+	`, dungeonID, seasonID, dungeonID, seasonID, dungeonID, seasonID, currentTime)
+	return err
+}
 
-	// filtered global rankings (best time per team, per season)
-	// get all dungeon IDs and season IDs for filtered rankings
-	dungeonRows, err := tx.Query("SELECT id FROM dungeons")
-	if err != nil {
-		return err
-	}
-	defer dungeonRows.Close()
+// computeRegionalRankings computes regional rankings for all runs. With parts it
+// only rebuilds those partitions, keyed by region.
+func computeRegionalRankings(tx *sql.Tx, parts map[string][]seasonDungeon) error {
+	log.Info("computing regional rankings")
 
-	var dungeonIDs []int
-	for dungeonRows.Next() {
-		var id int
-		if err := dungeonRows.Scan(&id); err != nil {
+	currentTime := time.Now().UnixMilli()
+
+	if parts == nil {
+		// clear existing regional rankings
+		if _, err := tx.Exec("DELETE FROM run_rankings WHERE ranking_type = 'regional'"); err != nil {
 			return err
 		}
-		dungeonIDs = append(dungeonIDs, id)
-	}
-
-	// Get all seasons (including fallback season 1 for unmapped periods)
-	seasonRows, err := tx.Query(`
-		SELECT DISTINCT cr.season_id as season_id
-		FROM challenge_runs cr
-	`)
-	if err != nil {
-		return err
-	}
-	defer seasonRows.Close()
 
-	var seasonIDs []int
-	for seasonRows.Next() {
-		var id int
-		if err := seasonRows.Scan(&id); err != nil {
+		// get all regions
+		regions, err := queryStrings(tx, "SELECT DISTINCT region FROM realms")
+		if err != nil {
 			return err
 		}
-		seasonIDs = append(seasonIDs, id)
-	}
 
-	// Process each dungeon x season combination
-	for _, dungeonID := range dungeonIDs {
-		for _, seasonID := range seasonIDs {
-			_, err := tx.Exec(`
-				WITH best_team_runs AS (
-					SELECT
-						cr.team_signature,
-						MIN(cr.duration) as best_duration
-					FROM challenge_runs cr
-					WHERE cr.dungeon_id = ? AND cr.season_id = ?
-					GROUP BY cr.team_signature
-				),
-				filtered_runs AS (
-					SELECT
-						cr.id as run_id,
-						cr.duration,
-						cr.completed_timestamp,
-						ROW_NUMBER() OVER (ORDER BY cr.duration ASC, cr.completed_timestamp ASC) as filtered_rank
-					FROM challenge_runs cr
-					INNER JOIN best_team_runs btr ON cr.team_signature = btr.team_signature
-												 AND cr.duration = btr.best_duration
-					WHERE cr.dungeon_id = ? AND cr.season_id = ?
-					GROUP BY cr.team_signature
-					HAVING cr.id = MIN(cr.id)
-				)
-				INSERT INTO run_rankings (run_id, dungeon_id, ranking_type, ranking_scope, ranking, season_id, computed_at)
-				SELECT
-					run_id,
-					? as dungeon_id,
-					'global' as ranking_type,
-					'filtered' as ranking_scope,
-					filtered_rank as ranking,
-					? as season_id,
-					? as computed_at
-				FROM filtered_runs
-			`, dungeonID, seasonID, dungeonID, seasonID, dungeonID, seasonID, currentTime)
+		for _, region := range regions {
+			// unfiltered regional rankings (per season)
+			if err := insertRegionalRankings(tx, region, seasonDungeon{}, currentTime); err != nil {
+				return err
+			}
 
+			// update percentile brackets for unfiltered regional rankings using efficient SQL (per season)
+			log.Info("computing unfiltered regional ranking brackets per season", "region", region)
+			if err := updateRunRankingBrackets(tx, "regional", region, seasonDungeon{}); err != nil {
+				return err
+			}
+
+			// filtered regional rankings - per dungeon x season
+			all, err := rankablePartitions(tx, region)
 			if err != nil {
 				return err
 			}
+			for _, part := range all {
+				if err := insertRegionalFilteredRankings(tx, region, part, currentTime); err != nil {
+					return err
+				}
+			}
+
+			// update percentile brackets for filtered regional rankings using efficient SQL (per season)
+			log.Info("computing filtered regional ranking brackets per season", "region", region)
+			if err := updateRunRankingBrackets(tx, "regional", region+"_filtered", seasonDungeon{}); err != nil {
+				return err
+			}
 		}
+
+		log.Info("computed regional rankings with percentile brackets", "regions", len(regions))
+		return nil
 	}
 
-	// update percentile brackets for filtered global rankings using efficient SQL (per season)
-	log.Info("computing filtered global ranking brackets per season")
-	_, err = tx.Exec(`
-		UPDATE run_rankings
-		SET percentile_bracket = (
-			CASE
-				WHEN counts.duration = counts.min_duration THEN 'artifact'
-				ELSE
-					CASE
-						WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_season_dungeon AS REAL) * 100) <= 1.0 THEN 'excellent'
-						WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_season_dungeon AS REAL) * 100) <= 5.0 THEN 'legendary'
-						WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_season_dungeon AS REAL) * 100) <= 20.0 THEN 'epic'
-						WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_season_dungeon AS REAL) * 100) <= 40.0 THEN 'rare'
-						WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_season_dungeon AS REAL) * 100) <= 60.0 THEN 'uncommon'
-						ELSE 'common'
-					END
-			END
-		)
-		FROM (
-			SELECT
-				rr.run_id,
-				rr.dungeon_id,
-				rr.season_id,
-				rr.ranking,
-				cr.duration,
-				MIN(cr.duration) OVER (PARTITION BY rr.dungeon_id, rr.season_id) as min_duration,
-				COUNT(*) OVER (PARTITION BY rr.dungeon_id, rr.season_id) as total_in_season_dungeon
-			FROM run_rankings rr
-			INNER JOIN challenge_runs cr ON rr.run_id = cr.id
-			WHERE rr.ranking_type = 'global' AND rr.ranking_scope = 'filtered'
-		) counts
-		WHERE run_rankings.run_id = counts.run_id
-		AND run_rankings.dungeon_id = counts.dungeon_id
-		AND run_rankings.season_id = counts.season_id
-		AND run_rankings.ranking_type = 'global'
-		AND run_rankings.ranking_scope = 'filtered'
-	`)
+	known, err := knownDungeons(tx)
 	if err != nil {
 		return err
 	}
+	for region, regionParts := range parts {
+		for _, part := range regionParts {
+			if _, err := tx.Exec(`
+				DELETE FROM run_rankings
+				WHERE ranking_type = 'regional' AND ranking_scope IN (?, ?) AND dungeon_id = ? AND season_id = ?
+			`, region, region+"_filtered", part.DungeonID, part.SeasonID); err != nil {
+				return err
+			}
+			if err := insertRegionalRankings(tx, region, part, currentTime); err != nil {
+				return err
+			}
+			if err := updateRunRankingBrackets(tx, "regional", region, part); err != nil {
+				return err
+			}
+			if !known[part.DungeonID] {
+				continue
+			}
+			if err := insertRegionalFilteredRankings(tx, region, part, currentTime); err != nil {
+				return err
+			}
+			if err := updateRunRankingBrackets(tx, "regional", region+"_filtered", part); err != nil {
+				return err
+			}
+		}
+	}
 
-	log.Info("computed global rankings with percentile brackets (all and filtered)")
+	log.Info("computed regional rankings with percentile brackets", "regions", len(parts))
 	return nil
 }
 
-// computeRegionalRankings computes regional rankings for all runs
-func computeRegionalRankings(tx *sql.Tx) error {
-	log.Info("computing regional rankings")
-
-	currentTime := time.Now().UnixMilli()
-
-	// clear existing regional rankings
-	if _, err := tx.Exec("DELETE FROM run_rankings WHERE ranking_type = 'regional'"); err != nil {
-		return err
-	}
-
-	// get all regions
-	regionRows, err := tx.Query("SELECT DISTINCT region FROM realms")
-	if err != nil {
-		return err
-	}
-	defer regionRows.Close()
-
-	var regions []string
-	for regionRows.Next() {
-		var region string
-		if err := regionRows.Scan(&region); err != nil {
-			return err
-		}
-		regions = append(regions, region)
-	}
+// insertRegionalRankings ranks every run of a region in the partition(s) by time
+func insertRegionalRankings(tx *sql.Tx, region string, part seasonDungeon, currentTime int64) error {
+	where, args := part.where("AND", "cr")
+	_, err := tx.Exec(`
+		INSERT INTO run_rankings (run_id, dungeon_id, ranking_type, ranking_scope, ranking, season_id, computed_at)
+		SELECT
+			cr.id as run_id,
+			cr.dungeon_id,
+			'regional' as ranking_type,
+			? as ranking_scope,
+			ROW_NUMBER() OVER (PARTITION BY cr.dungeon_id, cr.season_id ORDER BY cr.duration ASC, cr.completed_timestamp ASC, cr.id ASC) as ranking,
+			cr.season_id as season_id,
+			? as computed_at
+		FROM challenge_runs cr
+		INNER JOIN realms r ON cr.realm_id = r.id
+		WHERE r.region = ?`+where, append([]any{region, currentTime, region}, args...)...)
+	return err
+}
 
-	for _, region := range regions {
-		// unfiltered regional rankings (per season)
-		_, err := tx.Exec(`
-			INSERT INTO run_rankings (run_id, dungeon_id, ranking_type, ranking_scope, ranking, season_id, computed_at)
+// insertRegionalFilteredRankings ranks each team's best run of one region, dungeon and season
+func insertRegionalFilteredRankings(tx *sql.Tx, region string, part seasonDungeon, currentTime int64) error {
+	dungeonID, seasonID := part.DungeonID, part.SeasonID
+	_, err := tx.Exec(`
+		WITH best_team_runs AS (
+			SELECT
+				cr.team_signature,
+				MIN(cr.duration) as best_duration
+			FROM challenge_runs cr
+			INNER JOIN realms r ON cr.realm_id = r.id
+			WHERE cr.dungeon_id = ? AND r.region = ? AND cr.season_id = ?
+			GROUP BY cr.team_signature
+		),
+		team_best_runs AS (
 			SELECT
 				cr.id as run_id,
-				cr.dungeon_id,
-				'regional' as ranking_type,
-				? as ranking_scope,
-				ROW_NUMBER() OVER (PARTITION BY cr.dungeon_id, cr.season_id ORDER BY cr.duration ASC, cr.completed_timestamp ASC) as ranking,
-				cr.season_id as season_id,
-				? as computed_at
+				cr.duration,
+				cr.completed_timestamp,
+				ROW_NUMBER() OVER (PARTITION BY cr.team_signature ORDER BY cr.id ASC) as team_pick
 			FROM challenge_runs cr
 			INNER JOIN realms r ON cr.realm_id = r.id
-			WHERE r.region = ?
-		`, region, currentTime, region)
+			INNER JOIN best_team_runs btr ON cr.team_signature = btr.team_signature
+											AND cr.duration = btr.best_duration
+			WHERE cr.dungeon_id = ? AND r.region = ? AND cr.season_id = ?
+		),
+		filtered_runs AS (
+			SELECT
+				run_id,
+				duration,
+				completed_timestamp,
+				ROW_NUMBER() OVER (ORDER BY duration ASC, completed_timestamp ASC, run_id ASC) as filtered_rank
+			FROM team_best_runs
+			WHERE team_pick = 1
+		)
+		INSERT INTO run_rankings (run_id, dungeon_id, ranking_type, ranking_scope, ranking, season_id, computed_at)
+		SELECT
+			run_id,
+			? as dungeon_id,
+			'regional' as ranking_type,
+			? as ranking_scope,
+			filtered_rank as ranking,
+			? as season_id,
+			? as computed_at
+		FROM filtered_runs
+	`, dungeonID, region, seasonID, dungeonID, region, seasonID, dungeonID, region+"_filtered", seasonID, currentTime)
+	return err
+}
 
-		if err != nil {
-			return err
-		}
+// computeRealmRankings computes realm rankings per realm pool (connected realms grouped together).
+// A run belongs to every pool whose leaderboards it was sighted on. With parts it only
+// rebuilds those partitions, keyed by pool slug; pools of different regions that share
+// a slug share their ranking scope, so they are always rebuilt together.
+func computeRealmRankings(tx *sql.Tx, parts map[string][]seasonDungeon) error {
+	log.Info("computing realm rankings (pool-based for connected realms)")
 
-		// update percentile brackets for unfiltered regional rankings using efficient SQL (per season)
-		log.Info("computing unfiltered regional ranking brackets per season", "region", region)
-		_, err = tx.Exec(`
-			UPDATE run_rankings
-			SET percentile_bracket = (
-				CASE
-					WHEN counts.duration = counts.min_duration THEN 'artifact'
-					ELSE
-						CASE
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_region_season_dungeon AS REAL) * 100) <= 1.0 THEN 'excellent'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_region_season_dungeon AS REAL) * 100) <= 5.0 THEN 'legendary'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_region_season_dungeon AS REAL) * 100) <= 20.0 THEN 'epic'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_region_season_dungeon AS REAL) * 100) <= 40.0 THEN 'rare'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_region_season_dungeon AS REAL) * 100) <= 60.0 THEN 'uncommon'
-							ELSE 'common'
-						END
-				END
-			)
-			FROM (
-				SELECT
-					rr.run_id,
-					rr.dungeon_id,
-					rr.season_id,
-					rr.ranking,
-					cr.duration,
-					MIN(cr.duration) OVER (PARTITION BY rr.dungeon_id, rr.season_id, rr.ranking_scope) as min_duration,
-					COUNT(*) OVER (PARTITION BY rr.dungeon_id, rr.season_id, rr.ranking_scope) as total_in_region_season_dungeon
-				FROM run_rankings rr
-				INNER JOIN challenge_runs cr ON rr.run_id = cr.id
-				WHERE rr.ranking_type = 'regional' AND rr.ranking_scope = ?
-			) counts
-			WHERE run_rankings.run_id = counts.run_id
-			AND run_rankings.dungeon_id = counts.dungeon_id
-			AND run_rankings.season_id = counts.season_id
-			AND run_rankings.ranking_type = 'regional'
-			AND run_rankings.ranking_scope = ?
-		`, region, region)
-		if err != nil {
-			return err
-		}
+	currentTime := time.Now().UnixMilli()
 
-		// get dungeonIDs for filtered rankings
-		dungeonRows, err := tx.Query("SELECT id FROM dungeons")
-		if err != nil {
+	// get all realm pools (parent realms or independent realms that will be used as pool identifiers)
+	pools, err := loadRealmPools(tx)
+	if err != nil {
+		return err
+	}
+
+	if parts == nil {
+		// clear existing realm rankings
+		if _, err := tx.Exec("DELETE FROM run_rankings WHERE ranking_type = 'realm'"); err != nil {
 			return err
 		}
 
-		var dungeonIDs []int
-		for dungeonRows.Next() {
-			var id int
-			if err := dungeonRows.Scan(&id); err != nil {
-				dungeonRows.Close()
+		log.Info("found realm pools to process", "pools", len(pools))
+
+		for _, pool := range pools {
+			// unfiltered realm rankings (per season) using pool-based partitioning
+			if err := insertRealmRankings(tx, pool, seasonDungeon{}, currentTime); err != nil {
+				return err
+			}
+			// update percentile brackets for unfiltered realm rankings
+			if err := updateRunRankingBrackets(tx, "realm", pool.PoolSlug, seasonDungeon{}); err != nil {
 				return err
 			}
-			dungeonIDs = append(dungeonIDs, id)
-		}
-		dungeonRows.Close()
-
-		// Get all seasons for this region
-		seasonRows, err := tx.Query(`
-			SELECT DISTINCT cr.season_id as season_id
-			FROM challenge_runs cr
-			INNER JOIN realms r ON cr.realm_id = r.id
-			WHERE r.region = ?
-		`, region)
-		if err != nil {
-			return err
 		}
 
-		var seasonIDs []int
-		for seasonRows.Next() {
-			var id int
-			if err := seasonRows.Scan(&id); err != nil {
-				seasonRows.Close()
+		// now compute filtered rankings per pool x dungeon x season
+		for _, pool := range pools {
+			all, err := poolPartitions(tx, pool)
+			if err != nil {
 				return err
 			}
-			seasonIDs = append(seasonIDs, id)
-		}
-		seasonRows.Close()
-
-		// filtered regional rankings - per dungeon x season
-		for _, dungeonID := range dungeonIDs {
-			for _, seasonID := range seasonIDs {
-				_, err := tx.Exec(`
-					WITH best_team_runs AS (
-						SELECT
-							cr.team_signature,
-							MIN(cr.duration) as best_duration
-						FROM challenge_runs cr
-						INNER JOIN realms r ON cr.realm_id = r.id
-						WHERE cr.dungeon_id = ? AND r.region = ? AND cr.season_id = ?
-						GROUP BY cr.team_signature
-					),
-					filtered_runs AS (
-						SELECT
-							cr.id as run_id,
-							cr.duration,
-							cr.completed_timestamp,
-							ROW_NUMBER() OVER (ORDER BY cr.duration ASC, cr.completed_timestamp ASC) as filtered_rank
-						FROM challenge_runs cr
-						INNER JOIN realms r ON cr.realm_id = r.id
-						INNER JOIN best_team_runs btr ON cr.team_signature = btr.team_signature
-														AND cr.duration = btr.best_duration
-						WHERE cr.dungeon_id = ? AND r.region = ? AND cr.season_id = ?
-						GROUP BY cr.team_signature
-						HAVING cr.id = MIN(cr.id)
-					)
-					INSERT INTO run_rankings (run_id, dungeon_id, ranking_type, ranking_scope, ranking, season_id, computed_at)
-					SELECT
-						run_id,
-						? as dungeon_id,
-						'regional' as ranking_type,
-						? as ranking_scope,
-						filtered_rank as ranking,
-						? as season_id,
-						? as computed_at
-					FROM filtered_runs
-				`, dungeonID, region, seasonID, dungeonID, region, seasonID, dungeonID, region+"_filtered", seasonID, currentTime)
-
-				if err != nil {
+			// filtered realm rankings - per dungeon x season using pool-based partitioning
+			for _, part := range all {
+				if err := insertRealmFilteredRankings(tx, pool, part, currentTime); err != nil {
 					return err
 				}
 			}
+			// update percentile brackets for filtered realm rankings
+			if err := updateRunRankingBrackets(tx, "realm", pool.PoolSlug+"_filtered", seasonDungeon{}); err != nil {
+				return err
+			}
 		}
 
-		// update percentile brackets for filtered regional rankings using efficient SQL (per season)
-		filteredScope := region + "_filtered"
-		log.Info("computing filtered regional ranking brackets per season", "region", region)
-		_, err = tx.Exec(`
-			UPDATE run_rankings
-			SET percentile_bracket = (
-				CASE
-					WHEN counts.duration = counts.min_duration THEN 'artifact'
-					ELSE
-						CASE
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_region_season_dungeon AS REAL) * 100) <= 1.0 THEN 'excellent'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_region_season_dungeon AS REAL) * 100) <= 5.0 THEN 'legendary'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_region_season_dungeon AS REAL) * 100) <= 20.0 THEN 'epic'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_region_season_dungeon AS REAL) * 100) <= 40.0 THEN 'rare'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_region_season_dungeon AS REAL) * 100) <= 60.0 THEN 'uncommon'
-							ELSE 'common'
-						END
-				END
-			)
-			FROM (
-				SELECT
-					rr.run_id,
-					rr.dungeon_id,
-					rr.season_id,
-					rr.ranking,
-					cr.duration,
-					MIN(cr.duration) OVER (PARTITION BY rr.dungeon_id, rr.season_id, rr.ranking_scope) as min_duration,
-					COUNT(*) OVER (PARTITION BY rr.dungeon_id, rr.season_id, rr.ranking_scope) as total_in_region_season_dungeon
-				FROM run_rankings rr
-				INNER JOIN challenge_runs cr ON rr.run_id = cr.id
-				WHERE rr.ranking_type = 'regional' AND rr.ranking_scope = ?
-			) counts
-			WHERE run_rankings.run_id = counts.run_id
-			AND run_rankings.dungeon_id = counts.dungeon_id
-			AND run_rankings.season_id = counts.season_id
-			AND run_rankings.ranking_type = 'regional'
-			AND run_rankings.ranking_scope = ?
-		`, filteredScope, filteredScope)
-		if err != nil {
-			return err
-		}
+		log.Info("computed realm rankings with percentile brackets", "pools", len(pools))
+		return nil
 	}
 
-	log.Info("computed regional rankings with percentile brackets", "regions", len(regions))
-	return nil
-}
-
-// computeRealmRankings computes realm rankings per realm pool (connected realms grouped together)
-func computeRealmRankings(tx *sql.Tx) error {
-	log.Info("computing realm rankings (pool-based for connected realms)")
-
-	currentTime := time.Now().UnixMilli()
-
-	// clear existing realm rankings
-	if _, err := tx.Exec("DELETE FROM run_rankings WHERE ranking_type = 'realm'"); err != nil {
+	known, err := knownDungeons(tx)
+	if err != nil {
 		return err
 	}
+	poolsBySlug := make(map[string][]realmPool)
+	for _, pool := range pools {
+		poolsBySlug[pool.PoolSlug] = append(poolsBySlug[pool.PoolSlug], pool)
+	}
 
-	// get all realm pools (parent realms or independent realms that will be used as pool identifiers)
-	type RealmPool struct {
-		PoolSlug string
-		Region   string
+	for slug, slugParts := range parts {
+		for _, part := range slugParts {
+			if _, err := tx.Exec(`
+				DELETE FROM run_rankings
+				WHERE ranking_type = 'realm' AND ranking_scope IN (?, ?) AND dungeon_id = ? AND season_id = ?
+			`, slug, slug+"_filtered", part.DungeonID, part.SeasonID); err != nil {
+				return err
+			}
+			for _, pool := range poolsBySlug[slug] {
+				if err := insertRealmRankings(tx, pool, part, currentTime); err != nil {
+					return err
+				}
+			}
+			if err := updateRunRankingBrackets(tx, "realm", slug, part); err != nil {
+				return err
+			}
+			if !known[part.DungeonID] {
+				continue
+			}
+			for _, pool := range poolsBySlug[slug] {
+				if err := insertRealmFilteredRankings(tx, pool, part, currentTime); err != nil {
+					return err
+				}
+			}
+			if err := updateRunRankingBrackets(tx, "realm", slug+"_filtered", part); err != nil {
+				return err
+			}
+		}
 	}
 
-	poolRows, err := tx.Query(`
+	log.Info("computed realm rankings with percentile brackets", "pools", len(parts))
+	return nil
+}
+
+// loadRealmPools lists every realm pool, ordered by region and slug
+func loadRealmPools(q interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}) ([]realmPool, error) {
+	poolRows, err := q.Query(`
 		SELECT DISTINCT
 			COALESCE(parent_r.slug, r.slug) as pool_slug,
 			r.region
@@ -446,225 +446,244 @@ func computeRealmRankings(tx *sql.Tx) error {
 		ORDER BY r.region, pool_slug
 	`)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer poolRows.Close()
 
-	var pools []RealmPool
+	var pools []realmPool
 	for poolRows.Next() {
-		var pool RealmPool
+		var pool realmPool
 		if err := poolRows.Scan(&pool.PoolSlug, &pool.Region); err != nil {
-			return err
+			return nil, err
 		}
 		pools = append(pools, pool)
 	}
+	return pools, poolRows.Err()
+}
 
-	log.Info("found realm pools to process", "pools", len(pools))
+// insertRealmRankings ranks every run sighted in a pool within the partition(s) by time
+func insertRealmRankings(tx *sql.Tx, pool realmPool, part seasonDungeon, currentTime int64) error {
+	where, args := part.where("AND", "cr")
+	_, err := tx.Exec(`
+		INSERT INTO run_rankings (run_id, dungeon_id, ranking_type, ranking_scope, ranking, season_id, computed_at)
+		SELECT
+			cr.id as run_id,
+			cr.dungeon_id,
+			'realm' as ranking_type,
+			? as ranking_scope,
+			ROW_NUMBER() OVER (
+				PARTITION BY cr.dungeon_id, cr.season_id
+				ORDER BY cr.duration ASC, cr.completed_timestamp ASC, cr.id ASC
+			) as ranking,
+			cr.season_id as season_id,
+			? as computed_at
+		FROM challenge_runs cr
+		WHERE `+poolRunsFilter+where, append([]any{pool.PoolSlug, currentTime, pool.Region, pool.PoolSlug}, args...)...)
+	return err
+}
 
-	for _, pool := range pools {
-		// unfiltered realm rankings (per season) using pool-based partitioning
-		_, err := tx.Exec(`
-			INSERT INTO run_rankings (run_id, dungeon_id, ranking_type, ranking_scope, ranking, season_id, computed_at)
+// insertRealmFilteredRankings ranks each team's best run of one pool, dungeon and season
+func insertRealmFilteredRankings(tx *sql.Tx, pool realmPool, part seasonDungeon, currentTime int64) error {
+	dungeonID, seasonID := part.DungeonID, part.SeasonID
+	_, err := tx.Exec(`
+		WITH best_team_runs AS (
+			SELECT
+				cr.team_signature,
+				MIN(cr.duration) as best_duration
+			FROM challenge_runs cr
+			WHERE cr.dungeon_id = ?
+				AND `+poolRunsFilter+`
+				AND cr.season_id = ?
+			GROUP BY cr.team_signature
+		),
+		team_best_runs AS (
 			SELECT
 				cr.id as run_id,
-				cr.dungeon_id,
-				'realm' as ranking_type,
-				? as ranking_scope,
-				ROW_NUMBER() OVER (
-					PARTITION BY cr.dungeon_id, cr.season_id
-					ORDER BY cr.duration ASC, cr.completed_timestamp ASC
-				) as ranking,
-				cr.season_id as season_id,
-				? as computed_at
+				cr.duration,
+				cr.completed_timestamp,
+				ROW_NUMBER() OVER (PARTITION BY cr.team_signature ORDER BY cr.id ASC) as team_pick
 			FROM challenge_runs cr
-			INNER JOIN realms r ON cr.realm_id = r.id
-			LEFT JOIN realms parent_r ON r.parent_realm_slug = parent_r.slug AND r.region = parent_r.region
-			WHERE r.region = ? AND COALESCE(parent_r.slug, r.slug) = ?
-		`, pool.PoolSlug, currentTime, pool.Region, pool.PoolSlug)
+			INNER JOIN best_team_runs btr ON cr.team_signature = btr.team_signature
+											AND cr.duration = btr.best_duration
+			WHERE cr.dungeon_id = ?
+				AND `+poolRunsFilter+`
+				AND cr.season_id = ?
+		),
+		filtered_runs AS (
+			SELECT
+				run_id,
+				duration,
+				completed_timestamp,
+				ROW_NUMBER() OVER (ORDER BY duration ASC, completed_timestamp ASC, run_id ASC) as filtered_rank
+			FROM team_best_runs
+			WHERE team_pick = 1
+		)
+		INSERT INTO run_rankings (run_id, dungeon_id, ranking_type, ranking_scope, ranking, season_id, computed_at)
+		SELECT
+			run_id,
+			? as dungeon_id,
+			'realm' as ranking_type,
+			? as ranking_scope,
+			filtered_rank as ranking,
+			? as season_id,
+			? as computed_at
+		FROM filtered_runs
+	`, dungeonID, pool.Region, pool.PoolSlug, seasonID,
+		dungeonID, pool.Region, pool.PoolSlug, seasonID,
+		dungeonID, pool.PoolSlug+"_filtered", seasonID, currentTime)
+	return err
+}
 
-		if err != nil {
-			return err
-		}
+// updateRunRankingBrackets assigns percentile brackets to one ranking type and scope,
+// within each dungeon and season, for the partition(s) given
+func updateRunRankingBrackets(tx *sql.Tx, rankingType, rankingScope string, part seasonDungeon) error {
+	where, args := part.where("AND", "rr")
+	_, err := tx.Exec(`
+		UPDATE run_rankings
+		SET percentile_bracket = (
+			CASE
+				WHEN counts.duration = counts.min_duration THEN 'artifact'
+				ELSE
+					CASE
+						WHEN (CAST(counts.ranking AS DOUBLE PRECISION) / CAST(counts.total_in_partition AS DOUBLE PRECISION) * 100) <= 1.0 THEN 'excellent'
+						WHEN (CAST(counts.ranking AS DOUBLE PRECISION) / CAST(counts.total_in_partition AS DOUBLE PRECISION) * 100) <= 5.0 THEN 'legendary'
+						WHEN (CAST(counts.ranking AS DOUBLE PRECISION) / CAST(counts.total_in_partition AS DOUBLE PRECISION) * 100) <= 20.0 THEN 'epic'
+						WHEN (CAST(counts.ranking AS DOUBLE PRECISION) / CAST(counts.total_in_partition AS DOUBLE PRECISION) * 100) <= 40.0 THEN 'rare'
+						WHEN (CAST(counts.ranking AS DOUBLE PRECISION) / CAST(counts.total_in_partition AS DOUBLE PRECISION) * 100) <= 60.0 THEN 'uncommon'
+						ELSE 'common'
+					END
+			END
+		)
+		FROM (
+			SELECT
+				rr.run_id,
+				rr.dungeon_id,
+				rr.season_id,
+				rr.ranking,
+				cr.duration,
+				MIN(cr.duration) OVER (PARTITION BY rr.dungeon_id, rr.season_id) as min_duration,
+				COUNT(*) OVER (PARTITION BY rr.dungeon_id, rr.season_id) as total_in_partition
+			FROM run_rankings rr
+			INNER JOIN challenge_runs cr ON rr.run_id = cr.id
+			WHERE rr.ranking_type = ? AND rr.ranking_scope = ?`+where+`
+		) counts
+		WHERE run_rankings.run_id = counts.run_id
+		AND run_rankings.dungeon_id = counts.dungeon_id
+		AND run_rankings.season_id = counts.season_id
+		AND run_rankings.ranking_type = ?
+		AND run_rankings.ranking_scope = ?
+	`, append(append([]any{rankingType, rankingScope}, args...), rankingType, rankingScope)...)
+	return err
+}
 
-		// update percentile brackets for unfiltered realm rankings
-		_, err = tx.Exec(`
-			UPDATE run_rankings
-			SET percentile_bracket = (
-				CASE
-					WHEN counts.duration = counts.min_duration THEN 'artifact'
-					ELSE
-						CASE
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_pool_season_dungeon AS REAL) * 100) <= 1.0 THEN 'excellent'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_pool_season_dungeon AS REAL) * 100) <= 5.0 THEN 'legendary'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_pool_season_dungeon AS REAL) * 100) <= 20.0 THEN 'epic'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_pool_season_dungeon AS REAL) * 100) <= 40.0 THEN 'rare'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_pool_season_dungeon AS REAL) * 100) <= 60.0 THEN 'uncommon'
-							ELSE 'common'
-						END
-				END
-			)
-			FROM (
-				SELECT
-					rr.run_id,
-					rr.dungeon_id,
-					rr.season_id,
-					rr.ranking,
-					cr.duration,
-					MIN(cr.duration) OVER (PARTITION BY rr.dungeon_id, rr.season_id, rr.ranking_scope) as min_duration,
-					COUNT(*) OVER (PARTITION BY rr.dungeon_id, rr.season_id, rr.ranking_scope) as total_in_pool_season_dungeon
-				FROM run_rankings rr
-				INNER JOIN challenge_runs cr ON rr.run_id = cr.id
-				WHERE rr.ranking_type = 'realm' AND rr.ranking_scope = ?
-			) counts
-			WHERE run_rankings.run_id = counts.run_id
-			AND run_rankings.dungeon_id = counts.dungeon_id
-			AND run_rankings.season_id = counts.season_id
-			AND run_rankings.ranking_type = 'realm'
-			AND run_rankings.ranking_scope = ?
-		`, pool.PoolSlug, pool.PoolSlug)
-		if err != nil {
-			return err
-		}
+// rankablePartitions lists the dungeon x season partitions that get filtered
+// rankings: every known dungeon against every season holding runs (of region, if set)
+func rankablePartitions(tx *sql.Tx, region string) ([]seasonDungeon, error) {
+	dungeonIDs, err := queryInts(tx, "SELECT id FROM dungeons")
+	if err != nil {
+		return nil, err
 	}
 
-	// now compute filtered rankings per pool x dungeon x season
-	dungeonRows, err := tx.Query("SELECT id FROM dungeons")
+	// Get all seasons (including fallback season 1 for unmapped periods)
+	var seasonIDs []int
+	if region == "" {
+		seasonIDs, err = queryInts(tx, `
+			SELECT DISTINCT cr.season_id as season_id
+			FROM challenge_runs cr
+		`)
+	} else {
+		seasonIDs, err = queryInts(tx, `
+			SELECT DISTINCT cr.season_id as season_id
+			FROM challenge_runs cr
+			INNER JOIN realms r ON cr.realm_id = r.id
+			WHERE r.region = ?
+		`, region)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return crossPartitions(dungeonIDs, seasonIDs), nil
+}
 
-	var dungeonIDs []int
-	for dungeonRows.Next() {
-		var id int
-		if err := dungeonRows.Scan(&id); err != nil {
-			dungeonRows.Close()
-			return err
-		}
-		dungeonIDs = append(dungeonIDs, id)
+// poolPartitions lists every known dungeon against the seasons sighted in a pool
+func poolPartitions(tx *sql.Tx, pool realmPool) ([]seasonDungeon, error) {
+	dungeonIDs, err := queryInts(tx, "SELECT id FROM dungeons")
+	if err != nil {
+		return nil, err
+	}
+	// Get seasons for this pool
+	seasonIDs, err := queryInts(tx, `
+		SELECT DISTINCT cr.season_id as season_id
+		FROM challenge_runs cr
+		WHERE `+poolRunsFilter, pool.Region, pool.PoolSlug)
+	if err != nil {
+		return nil, err
 	}
-	dungeonRows.Close()
+	return crossPartitions(dungeonIDs, seasonIDs), nil
+}
 
-	for _, pool := range pools {
-		// Get seasons for this pool
-		seasonRows, err := tx.Query(`
-			SELECT DISTINCT cr.season_id as season_id
-			FROM challenge_runs cr
-			INNER JOIN realms r ON cr.realm_id = r.id
-			LEFT JOIN realms parent_r ON r.parent_realm_slug = parent_r.slug AND r.region = parent_r.region
-			WHERE r.region = ? AND COALESCE(parent_r.slug, r.slug) = ?
-		`, pool.Region, pool.PoolSlug)
-		if err != nil {
-			return err
-		}
+// knownDungeons is the set of dungeons that get filtered rankings
+func knownDungeons(tx *sql.Tx) (map[int]bool, error) {
+	ids, err := queryInts(tx, "SELECT id FROM dungeons")
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+	return known, nil
+}
 
-		var seasonIDs []int
-		for seasonRows.Next() {
-			var id int
-			if err := seasonRows.Scan(&id); err != nil {
-				seasonRows.Close()
-				return err
-			}
-			seasonIDs = append(seasonIDs, id)
-		}
-		seasonRows.Close()
-
-		// filtered realm rankings - per dungeon x season using pool-based partitioning
-		for _, dungeonID := range dungeonIDs {
-			for _, seasonID := range seasonIDs {
-				_, err := tx.Exec(`
-					WITH best_team_runs AS (
-						SELECT
-							cr.team_signature,
-							MIN(cr.duration) as best_duration
-						FROM challenge_runs cr
-						INNER JOIN realms r ON cr.realm_id = r.id
-						LEFT JOIN realms parent_r ON r.parent_realm_slug = parent_r.slug AND r.region = parent_r.region
-						WHERE cr.dungeon_id = ?
-							AND r.region = ?
-							AND COALESCE(parent_r.slug, r.slug) = ?
-							AND cr.season_id = ?
-						GROUP BY cr.team_signature
-					),
-					filtered_runs AS (
-						SELECT
-							cr.id as run_id,
-							cr.duration,
-							cr.completed_timestamp,
-							ROW_NUMBER() OVER (ORDER BY cr.duration ASC, cr.completed_timestamp ASC) as filtered_rank
-						FROM challenge_runs cr
-						INNER JOIN realms r ON cr.realm_id = r.id
-						LEFT JOIN realms parent_r ON r.parent_realm_slug = parent_r.slug AND r.region = parent_r.region
-						INNER JOIN best_team_runs btr ON cr.team_signature = btr.team_signature
-														AND cr.duration = btr.best_duration
-						WHERE cr.dungeon_id = ?
-							AND r.region = ?
-							AND COALESCE(parent_r.slug, r.slug) = ?
-							AND cr.season_id = ?
-						GROUP BY cr.team_signature
-						HAVING cr.id = MIN(cr.id)
-					)
-					INSERT INTO run_rankings (run_id, dungeon_id, ranking_type, ranking_scope, ranking, season_id, computed_at)
-					SELECT
-						run_id,
-						? as dungeon_id,
-						'realm' as ranking_type,
-						? as ranking_scope,
-						filtered_rank as ranking,
-						? as season_id,
-						? as computed_at
-					FROM filtered_runs
-				`, dungeonID, pool.Region, pool.PoolSlug, seasonID,
-					dungeonID, pool.Region, pool.PoolSlug, seasonID,
-					dungeonID, pool.PoolSlug+"_filtered", seasonID, currentTime)
-
-				if err != nil {
-					return err
-				}
-			}
+func crossPartitions(dungeonIDs, seasonIDs []int) []seasonDungeon {
+	parts := make([]seasonDungeon, 0, len(dungeonIDs)*len(seasonIDs))
+	for _, dungeonID := range dungeonIDs {
+		for _, seasonID := range seasonIDs {
+			parts = append(parts, seasonDungeon{SeasonID: seasonID, DungeonID: dungeonID})
 		}
+	}
+	return parts
+}
 
-		// update percentile brackets for filtered realm rankings
-		filteredScope := pool.PoolSlug + "_filtered"
-		_, err = tx.Exec(`
-			UPDATE run_rankings
-			SET percentile_bracket = (
-				CASE
-					WHEN counts.duration = counts.min_duration THEN 'artifact'
-					ELSE
-						CASE
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_pool_season_dungeon AS REAL) * 100) <= 1.0 THEN 'excellent'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_pool_season_dungeon AS REAL) * 100) <= 5.0 THEN 'legendary'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_pool_season_dungeon AS REAL) * 100) <= 20.0 THEN 'epic'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_pool_season_dungeon AS REAL) * 100) <= 40.0 THEN 'rare'
-							WHEN (CAST(counts.ranking AS REAL) / CAST(counts.total_in_pool_season_dungeon AS REAL) * 100) <= 60.0 THEN 'uncommon'
-							ELSE 'common'
-						END
-				END
-			)
-			FROM (
-				SELECT
-					rr.run_id,
-					rr.dungeon_id,
-					rr.season_id,
-					rr.ranking,
-					cr.duration,
-					MIN(cr.duration) OVER (PARTITION BY rr.dungeon_id, rr.season_id, rr.ranking_scope) as min_duration,
-					COUNT(*) OVER (PARTITION BY rr.dungeon_id, rr.season_id, rr.ranking_scope) as total_in_pool_season_dungeon
-				FROM run_rankings rr
-				INNER JOIN challenge_runs cr ON rr.run_id = cr.id
-				WHERE rr.ranking_type = 'realm' AND rr.ranking_scope = ?
-			) counts
-			WHERE run_rankings.run_id = counts.run_id
-			AND run_rankings.dungeon_id = counts.dungeon_id
-			AND run_rankings.season_id = counts.season_id
-			AND run_rankings.ranking_type = 'realm'
-			AND run_rankings.ranking_scope = ?
-		`, filteredScope, filteredScope)
-		if err != nil {
-			return err
+func describeParts(parts []seasonDungeon) string {
+	if parts == nil {
+		return "all"
+	}
+	return fmt.Sprint(len(parts))
+}
+
+func queryInts(tx *sql.Tx, query string, args ...any) ([]int, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []int
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
 		}
+		out = append(out, v)
 	}
+	return out, rows.Err()
+}
 
-	log.Info("computed realm rankings with percentile brackets", "pools", len(pools))
-	return nil
+func queryStrings(tx *sql.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
 }
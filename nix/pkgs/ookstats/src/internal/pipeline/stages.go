@@ -0,0 +1,299 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"ookstats/internal/database"
+)
+
+// Stage is one named step of a build. A stage's input hash covers its own Inputs and
+// the input hashes of its dependencies, so changing an option invalidates everything
+// downstream of the stage that reads it.
+type Stage struct {
+	Name string
+	Deps []string
+	// Inputs returns what the stage's result depends on besides its dependencies:
+	// options, and database state written outside the build. Called before the stage
+	// runs to decide whether it is up to date, and again after it ran to record the
+	// state it left; nil means none.
+	Inputs func(ctx context.Context) ([]string, error)
+	Run    func(ctx context.Context) error
+	// MaxAge, when set, is how long a finished run counts as up to date. Stages that
+	// read the API use it since what they fetch changes without any input changing.
+	MaxAge time.Duration
+	// Disabled, when set, is why the stage is left out of this build; it neither runs
+	// nor invalidates its dependents
+	Disabled string
+}
+
+// StageOptions selects which stages RunStages runs
+type StageOptions struct {
+	// Resume skips stages whose recorded input hash matches, that completed after each
+	// of their dependencies and, for stages with a MaxAge, recently enough
+	Resume bool
+	// Only runs just these stages, in graph order, without their dependencies
+	Only []string
+	// From runs this stage and everything that depends on it
+	From string
+}
+
+// StageRunResult lists what RunStages did with each selected stage
+type StageRunResult struct {
+	Ran      []string
+	Skipped  []string
+	Disabled []string
+}
+
+// PlanStages validates the graph and returns the selected stages in dependency order.
+// Stages are listed in declaration order wherever the graph allows it.
+func PlanStages(stages []Stage, opts StageOptions) ([]Stage, error) {
+	if len(opts.Only) > 0 && opts.From != "" {
+		return nil, fmt.Errorf("--only and --from cannot be combined")
+	}
+
+	byName := make(map[string]Stage, len(stages))
+	for _, st := range stages {
+		if _, dup := byName[st.Name]; dup {
+			return nil, fmt.Errorf("duplicate stage %q", st.Name)
+		}
+		byName[st.Name] = st
+	}
+	for _, st := range stages {
+		for _, dep := range st.Deps {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("stage %s depends on unknown stage %q", st.Name, dep)
+			}
+		}
+	}
+
+	order, err := topoSortStages(stages)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool, len(stages))
+	switch {
+	case len(opts.Only) > 0:
+		for _, name := range opts.Only {
+			name = strings.TrimSpace(name)
+			if _, ok := byName[name]; !ok {
+				return nil, fmt.Errorf("unknown stage %q (stages: %s)", name, stageNames(order))
+			}
+			selected[name] = true
+		}
+	case opts.From != "":
+		if _, ok := byName[opts.From]; !ok {
+			return nil, fmt.Errorf("unknown stage %q (stages: %s)", opts.From, stageNames(order))
+		}
+		// order is topological, so one pass picks up every transitive dependent
+		selected[opts.From] = true
+		for _, st := range order {
+			for _, dep := range st.Deps {
+				if selected[dep] {
+					selected[st.Name] = true
+				}
+			}
+		}
+	default:
+		for _, st := range order {
+			selected[st.Name] = true
+		}
+	}
+
+	plan := make([]Stage, 0, len(selected))
+	for _, st := range order {
+		if selected[st.Name] {
+			plan = append(plan, st)
+		}
+	}
+	return plan, nil
+}
+
+// topoSortStages orders stages so each comes after its dependencies, picking the
+// earliest declared ready stage at every step
+func topoSortStages(stages []Stage) ([]Stage, error) {
+	done := make(map[string]bool, len(stages))
+	order := make([]Stage, 0, len(stages))
+	for len(order) < len(stages) {
+		progressed := false
+		for _, st := range stages {
+			if done[st.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range st.Deps {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				done[st.Name] = true
+				order = append(order, st)
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			var cyclic []string
+			for _, st := range stages {
+				if !done[st.Name] {
+					cyclic = append(cyclic, st.Name)
+				}
+			}
+			return nil, fmt.Errorf("build stages have a dependency cycle: %s", strings.Join(cyclic, ", "))
+		}
+	}
+	return order, nil
+}
+
+// RunStages runs the selected stages in dependency order, recording each start and
+// completion with its input hash. The first failing stage stops the build; stages
+// that finished stay recorded so a --resume picks up after them.
+func RunStages(ctx context.Context, store database.BuildStageStore, stages []Stage, opts StageOptions) (*StageRunResult, error) {
+	plan, err := PlanStages(stages, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, err := store.GetBuildStages()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]Stage, len(stages))
+	for _, st := range stages {
+		byName[st.Name] = st
+	}
+
+	// hashes of the stages handled in this build; the rest fall back to their record
+	hashes := make(map[string]string, len(plan))
+	depHash := func(name string) string {
+		if h, ok := hashes[name]; ok {
+			return h
+		}
+		return recorded[name].InputHash
+	}
+
+	res := &StageRunResult{}
+	for i, st := range plan {
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		if st.Disabled != "" {
+			log.Info("stage disabled", "stage", st.Name, "reason", st.Disabled)
+			res.Disabled = append(res.Disabled, st.Name)
+			continue
+		}
+
+		hash, err := stageInputHash(ctx, st, depHash)
+		if err != nil {
+			return res, err
+		}
+		hashes[st.Name] = hash
+
+		if opts.Resume {
+			ok, reason := stageUpToDate(st, hash, recorded, byName, time.Now())
+			if ok {
+				log.Info("stage up to date", "stage", st.Name, "completed", time.UnixMilli(recorded[st.Name].CompletedAt).Format(time.RFC3339))
+				res.Skipped = append(res.Skipped, st.Name)
+				continue
+			}
+			log.Info("stage needs to run", "stage", st.Name, "reason", reason)
+		}
+
+		log.Info("running stage", "stage", st.Name, "step", fmt.Sprintf("%d/%d", i+1, len(plan)))
+		started := time.Now()
+		if err := store.StartBuildStage(st.Name, hash, started.UnixMilli()); err != nil {
+			return res, err
+		}
+		if err := st.Run(ctx); err != nil {
+			return res, err
+		}
+		// record the inputs as the stage left them, so state the stage consumed itself
+		// (dirty marks, missing fingerprints) doesn't count as changed next time
+		if hash, err = stageInputHash(ctx, st, depHash); err != nil {
+			return res, err
+		}
+		hashes[st.Name] = hash
+		completed := time.Now()
+		if err := store.CompleteBuildStage(st.Name, hash, completed.UnixMilli()); err != nil {
+			return res, err
+		}
+		recorded[st.Name] = database.BuildStage{
+			Name:        st.Name,
+			InputHash:   hash,
+			StartedAt:   started.UnixMilli(),
+			CompletedAt: completed.UnixMilli(),
+			DurationMs:  completed.Sub(started).Milliseconds(),
+		}
+		res.Ran = append(res.Ran, st.Name)
+		log.Info("stage complete", "stage", st.Name, "duration", completed.Sub(started).Round(time.Millisecond))
+	}
+	return res, nil
+}
+
+// stageInputHash hashes a stage's name, its current inputs and its dependencies' hashes
+func stageInputHash(ctx context.Context, st Stage, depHash func(string) string) (string, error) {
+	var inputs []string
+	if st.Inputs != nil {
+		var err error
+		if inputs, err = st.Inputs(ctx); err != nil {
+			return "", fmt.Errorf("stage %s inputs: %w", st.Name, err)
+		}
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "stage=%s\n", st.Name)
+	for _, in := range inputs {
+		fmt.Fprintf(h, "input=%s\n", in)
+	}
+	for _, dep := range st.Deps {
+		fmt.Fprintf(h, "dep=%s:%s\n", dep, depHash(dep))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// stageUpToDate reports whether a stage's last run finished against the same inputs,
+// within its MaxAge of now and after every enabled dependency last finished, and why
+// not otherwise
+func stageUpToDate(st Stage, hash string, recorded map[string]database.BuildStage, byName map[string]Stage, now time.Time) (bool, string) {
+	rec, ok := recorded[st.Name]
+	switch {
+	case !ok:
+		return false, "never ran"
+	case rec.CompletedAt == 0:
+		return false, "last run did not finish"
+	case rec.InputHash != hash:
+		return false, "inputs changed"
+	case st.MaxAge > 0 && now.Sub(time.UnixMilli(rec.CompletedAt)) > st.MaxAge:
+		return false, "last run is older than " + st.MaxAge.String()
+	}
+	for _, dep := range st.Deps {
+		if byName[dep].Disabled != "" {
+			continue
+		}
+		d, ok := recorded[dep]
+		switch {
+		case !ok:
+			// never ran through build, e.g. a database restored from a dump
+		case d.CompletedAt == 0:
+			return false, "dependency " + dep + " did not finish"
+		case d.CompletedAt > rec.CompletedAt:
+			return false, "dependency " + dep + " ran since"
+		}
+	}
+	return true, ""
+}
+
+func stageNames(stages []Stage) string {
+	names := make([]string, len(stages))
+	for i, st := range stages {
+		names[i] = st.Name
+	}
+	return strings.Join(names, ", ")
+}
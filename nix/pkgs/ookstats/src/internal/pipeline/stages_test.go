@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"ookstats/internal/database"
+)
+
+// memStageStore keeps build stage records in memory
+type memStageStore struct {
+	stages map[string]database.BuildStage
+}
+
+func (s *memStageStore) GetBuildStages() (map[string]database.BuildStage, error) {
+	out := make(map[string]database.BuildStage, len(s.stages))
+	for k, v := range s.stages {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (s *memStageStore) StartBuildStage(name, inputHash string, startedAt int64) error {
+	s.stages[name] = database.BuildStage{Name: name, InputHash: inputHash, StartedAt: startedAt}
+	return nil
+}
+
+func (s *memStageStore) CompleteBuildStage(name, inputHash string, completedAt int64) error {
+	st := s.stages[name]
+	st.InputHash, st.CompletedAt = inputHash, completedAt
+	s.stages[name] = st
+	return nil
+}
+
+func (s *memStageStore) CountDirtyRankings() (int, int, error) { return 0, 0, nil }
+
+func (s *memStageStore) GenerationInputs() (string, error) { return "", nil }
+
+func TestResumeRerunsStaleAPIStages(t *testing.T) {
+	period := "us:1035"
+	var ran []string
+	stage := func(name string, deps []string, inputs []string, maxAge time.Duration) Stage {
+		return Stage{
+			Name: name,
+			Deps: deps,
+			Inputs: func(context.Context) ([]string, error) {
+				return inputs, nil
+			},
+			Run: func(context.Context) error {
+				ran = append(ran, name)
+				return nil
+			},
+			MaxAge: maxAge,
+		}
+	}
+	stages := func() []Stage {
+		return []Stage{
+			stage("fetch", nil, []string{"current_periods=" + period}, time.Hour),
+			stage("process", []string{"fetch"}, nil, 0),
+		}
+	}
+
+	store := &memStageStore{stages: map[string]database.BuildStage{}}
+	run := func() []string {
+		t.Helper()
+		ran = nil
+		if _, err := RunStages(context.Background(), store, stages(), StageOptions{Resume: true}); err != nil {
+			t.Fatal(err)
+		}
+		return ran
+	}
+
+	if got := run(); !reflect.DeepEqual(got, []string{"fetch", "process"}) {
+		t.Fatalf("first build ran %v", got)
+	}
+	if got := run(); len(got) != 0 {
+		t.Fatalf("resume right after a finished build ran %v", got)
+	}
+
+	// an hour later the fetch is stale, and process follows it
+	for name, st := range store.stages {
+		st.CompletedAt -= (61 * time.Minute).Milliseconds()
+		store.stages[name] = st
+	}
+	if got := run(); !reflect.DeepEqual(got, []string{"fetch", "process"}) {
+		t.Fatalf("resume after max age ran %v", got)
+	}
+
+	period = "us:1036"
+	if got := run(); !reflect.DeepEqual(got, []string{"fetch", "process"}) {
+		t.Fatalf("resume in a new period ran %v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -24,11 +25,13 @@ type StatusResult struct {
 	Invalid   int
 	Errors    int
 	Duration  time.Duration
+	// Interrupted is set when ctx was cancelled before all stale players were checked
+	Interrupted bool
 }
 
 // RefreshPlayerStatuses fetches the status endpoint for players whose cached status is stale.
 
-func RefreshPlayerStatuses(db *database.DatabaseService, client *blizzard.Client, opts StatusOptions) (*StatusResult, error) {
+func RefreshPlayerStatuses(ctx context.Context, db database.Store, client *blizzard.Client, opts StatusOptions) (*StatusResult, error) {
 	start := time.Now()
 	batchSize := opts.BatchSize
 	if batchSize <= 0 {
@@ -57,6 +60,10 @@ func RefreshPlayerStatuses(db *database.DatabaseService, client *blizzard.Client
 
 	batchNumber := 0
 	for {
+		if ctx.Err() != nil {
+			res.Interrupted = true
+			break
+		}
 		if tracker.ShouldStop(res.Processed) {
 			break
 		}
@@ -90,7 +97,11 @@ func RefreshPlayerStatuses(db *database.DatabaseService, client *blizzard.Client
 				defer wg.Done()
 
 				realm := blizzard.NormalizeRealmSlug(cand.Region, cand.RealmSlug)
-				resp, err := client.FetchCharacterStatus(cand.Name, realm, cand.Region)
+				resp, err := client.FetchCharacterStatus(ctx, cand.Name, realm, cand.Region)
+				if err != nil && ctx.Err() != nil {
+					// cancelled before an answer; the next run checks it again
+					return
+				}
 
 				mu.Lock()
 				defer mu.Unlock()
@@ -173,6 +184,7 @@ func RefreshPlayerStatuses(db *database.DatabaseService, client *blizzard.Client
 		"valid", res.Valid,
 		"invalid", res.Invalid,
 		"errors", res.Errors,
+		"interrupted", res.Interrupted,
 		"duration", res.Duration.Truncate(time.Second))
 	return res, nil
 }
@@ -0,0 +1,383 @@
+// Package publish uploads a generated static API directory to S3-compatible storage.
+package publish
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"ookstats/internal/generator"
+)
+
+// Encodings are the precompressed variants publish can upload, by key suffix
+var Encodings = map[string]string{
+	"gzip": ".gz",
+	"br":   ".br",
+}
+
+// Options configures a publish run
+type Options struct {
+	// Dir is the api directory holding manifest.json
+	Dir string
+	// Target is s3://bucket or s3://bucket/prefix
+	Target string
+	// Endpoint is the S3 endpoint URL; empty means AWS for Region
+	Endpoint string
+	Region   string
+	// CacheControl is set on every file but the manifest, which is never cached
+	CacheControl string
+	// Precompress lists encodings (gzip, br) to upload <key>.gz / <key>.br variants for
+	Precompress []string
+	Concurrency int
+	DryRun      bool
+}
+
+// Result summarizes a publish run
+type Result struct {
+	Generation int64
+	Uploaded   int
+	Unchanged  int
+	Variants   int
+	Deleted    int
+}
+
+// upload is one object to put
+type upload struct {
+	file     generator.ManifestFile
+	key      string
+	encoding string
+}
+
+// Run uploads the files of opts.Dir's manifest that differ from the target to the
+// same <prefix><path> keys the site fetches, then the manifest itself, then deletes
+// keys the previously published manifest owned that are no longer part of it. The
+// manifest is the pointer to the published generation and only names one once every
+// file of it is in place. Keys under the target that no manifest listed are never
+// touched.
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	bucket, prefix, err := parseTarget(opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	for _, enc := range opts.Precompress {
+		if _, ok := Encodings[enc]; !ok {
+			return nil, fmt.Errorf("unknown encoding %q (want gzip or br)", enc)
+		}
+	}
+	local, err := generator.LoadManifest(filepath.Join(opts.Dir, generator.ManifestFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no manifest in %s, run 'generate api' first", opts.Dir)
+		}
+		return nil, err
+	}
+	client, err := newClient(opts, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	listed, err := client.list(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	manifestKey := prefix + generator.ManifestFileName
+	remote, err := loadRemoteManifest(ctx, client, manifestKey)
+	if err != nil {
+		return nil, err
+	}
+	published := make(map[string]generator.ManifestFile, len(remote.Files))
+	for _, f := range remote.Files {
+		published[f.Path] = f
+	}
+	fmt.Printf("Publishing manifest generation %d to s3://%s/%s (remote generation %d, %d keys listed)\n",
+		local.Generation, bucket, prefix, remote.Generation, len(listed))
+
+	res := &Result{Generation: local.Generation}
+	var uploads []upload
+	wanted := map[string]bool{manifestKey: true}
+	for _, f := range local.Files {
+		key := prefix + f.Path
+		wanted[key] = true
+		obj, exists := listed[key]
+		current := exists && isCurrent(opts.Dir, f, obj, published[f.Path])
+		if current {
+			res.Unchanged++
+		} else {
+			uploads = append(uploads, upload{file: f, key: key})
+		}
+		for _, enc := range opts.Precompress {
+			variant := key + Encodings[enc]
+			wanted[variant] = true
+			if _, ok := listed[variant]; !ok || !current {
+				uploads = append(uploads, upload{file: f, key: variant, encoding: enc})
+			}
+		}
+	}
+
+	// only keys a published manifest listed, and their variants, belong to us
+	var stale []string
+	for p := range published {
+		key := prefix + p
+		for _, k := range []string{key, key + Encodings["gzip"], key + Encodings["br"]} {
+			if _, ok := listed[k]; ok && !wanted[k] {
+				stale = append(stale, k)
+			}
+		}
+	}
+	sort.Strings(stale)
+
+	for _, u := range uploads {
+		if u.encoding == "" {
+			res.Uploaded++
+		} else {
+			res.Variants++
+		}
+	}
+	if opts.DryRun {
+		for _, u := range uploads {
+			fmt.Printf("  put    %s\n", u.key)
+		}
+		fmt.Printf("  put    %s\n", manifestKey)
+		for _, k := range stale {
+			fmt.Printf("  delete %s\n", k)
+		}
+		res.Deleted = len(stale)
+		return res, nil
+	}
+
+	if err := runUploads(ctx, client, opts, uploads); err != nil {
+		return nil, err
+	}
+
+	// every file is in place, so the manifest can name the new generation
+	data, err := os.ReadFile(filepath.Join(opts.Dir, generator.ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("Cache-Control", "no-cache")
+	if err := client.put(ctx, manifestKey, data, header); err != nil {
+		return nil, fmt.Errorf("failed to switch manifest: %w", err)
+	}
+	fmt.Printf("[OK] Switched %s to generation %d\n", manifestKey, local.Generation)
+
+	if len(stale) > 0 {
+		if err := client.deleteKeys(ctx, stale); err != nil {
+			return nil, err
+		}
+	}
+	res.Deleted = len(stale)
+	fmt.Printf("[OK] Published %d files (%d unchanged, %d precompressed variants), deleted %d stale keys\n",
+		res.Uploaded, res.Unchanged, res.Variants, res.Deleted)
+	return res, nil
+}
+
+// isCurrent reports whether the object at a file's key already holds its content: the
+// published manifest says so and the object is there with the right size, or, for keys
+// published without a manifest, the object's ETag is the file's MD5
+func isCurrent(dir string, f generator.ManifestFile, obj remoteObject, pub generator.ManifestFile) bool {
+	if obj.Size != f.Size {
+		return false
+	}
+	if pub.Path != "" {
+		return pub.SHA256 == f.SHA256 && pub.Size == f.Size
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Path)))
+	if err != nil {
+		return false
+	}
+	sum := md5.Sum(data)
+	return obj.ETag == hex.EncodeToString(sum[:])
+}
+
+// runUploads puts every upload with opts.Concurrency workers, stopping at the first error
+func runUploads(ctx context.Context, client *s3Client, opts Options, uploads []upload) error {
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = 16
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	total := len(uploads)
+	queue := make(chan upload, 100)
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	var completed atomic.Int64
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range queue {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := putFile(ctx, client, opts, u); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+					mu.Unlock()
+					continue
+				}
+				if c := completed.Add(1); c%500 == 0 {
+					fmt.Printf("  ... %d/%d objects uploaded\n", c, total)
+				}
+			}
+		}()
+	}
+	for _, u := range uploads {
+		queue <- u
+	}
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// putFile reads, checks and uploads one file or one of its variants
+func putFile(ctx context.Context, client *s3Client, opts Options, u upload) error {
+	data, err := os.ReadFile(filepath.Join(opts.Dir, filepath.FromSlash(u.file.Path)))
+	if err != nil {
+		return err
+	}
+	if sha256Hex(data) != u.file.SHA256 {
+		return fmt.Errorf("%s changed since the manifest was written, regenerate before publishing", u.file.Path)
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(u.file.Path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	if opts.CacheControl != "" {
+		header.Set("Cache-Control", opts.CacheControl)
+	}
+	header.Set("X-Amz-Meta-Sha256", u.file.SHA256)
+	if u.encoding != "" {
+		if data, err = compress(data, u.encoding); err != nil {
+			return fmt.Errorf("compress %s: %w", u.file.Path, err)
+		}
+		header.Set("Content-Encoding", u.encoding)
+	}
+	return client.put(ctx, u.key, data, header)
+}
+
+// compress encodes data at the best compression level, since it is done once per change
+func compress(data []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	var w interface {
+		Write([]byte) (int, error)
+		Close() error
+	}
+	switch encoding {
+	case "gzip":
+		gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		w = gz
+	case "br":
+		w = brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// loadRemoteManifest returns the published manifest, or an empty one if there is none
+func loadRemoteManifest(ctx context.Context, client *s3Client, key string) (*generator.Manifest, error) {
+	data, err := client.get(ctx, key)
+	if errors.Is(err, errNotFound) {
+		return &generator.Manifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch published manifest: %w", err)
+	}
+	var m generator.Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse published manifest %s: %w", key, err)
+	}
+	return &m, nil
+}
+
+// parseTarget splits s3://bucket/prefix into the bucket and a prefix that is empty or
+// ends in a slash
+func parseTarget(target string) (string, string, error) {
+	rest, ok := strings.CutPrefix(target, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("target %q must look like s3://bucket/prefix", target)
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("target %q has no bucket", target)
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return bucket, prefix, nil
+}
+
+// newClient builds the S3 client from opts and the standard AWS_* environment
+func newClient(opts Options, bucket string) (*s3Client, error) {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	region := opts.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	return &s3Client{
+		httpClient:   &http.Client{Timeout: 5 * time.Minute},
+		endpoint:     u,
+		region:       region,
+		bucket:       bucket,
+		accessKey:    accessKey,
+		secretKey:    secretKey,
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}, nil
+}
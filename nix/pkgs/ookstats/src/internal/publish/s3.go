@@ -0,0 +1,308 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errNotFound is returned for a missing object
+var errNotFound = errors.New("object not found")
+
+// s3Client talks to one bucket of an S3-compatible endpoint with path-style requests
+// signed with AWS Signature Version 4, which AWS, R2 and MinIO all accept
+type s3Client struct {
+	httpClient   *http.Client
+	endpoint     *url.URL
+	region       string
+	bucket       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// remoteObject is a listed object
+type remoteObject struct {
+	Size int64
+	ETag string
+}
+
+// s3Error is an error response from the endpoint
+type s3Error struct {
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: HTTP %d", e.Status)
+	}
+	return fmt.Sprintf("s3: HTTP %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// list returns every object under prefix by key
+func (c *s3Client) list(ctx context.Context, prefix string) (map[string]remoteObject, error) {
+	type listResult struct {
+		IsTruncated           bool   `xml:"IsTruncated"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+		Contents              []struct {
+			Key  string `xml:"Key"`
+			ETag string `xml:"ETag"`
+			Size int64  `xml:"Size"`
+		} `xml:"Contents"`
+	}
+
+	objects := make(map[string]remoteObject)
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		body, err := c.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", prefix, err)
+		}
+		var res listResult
+		if err := xml.Unmarshal(body, &res); err != nil {
+			return nil, fmt.Errorf("parse object listing: %w", err)
+		}
+		for _, o := range res.Contents {
+			objects[o.Key] = remoteObject{Size: o.Size, ETag: strings.Trim(o.ETag, `"`)}
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+// get returns an object's content, or errNotFound
+func (c *s3Client) get(ctx context.Context, key string) ([]byte, error) {
+	body, err := c.do(ctx, http.MethodGet, key, nil, nil, nil)
+	var se *s3Error
+	if errors.As(err, &se) && se.Status == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	return body, err
+}
+
+// put uploads an object with the given headers (Content-Type, Cache-Control, ...)
+func (c *s3Client) put(ctx context.Context, key string, data []byte, header http.Header) error {
+	if _, err := c.do(ctx, http.MethodPut, key, nil, header, data); err != nil {
+		return fmt.Errorf("put %s: %w", key, err)
+	}
+	return nil
+}
+
+// deleteKeys removes objects in batches of the 1000 keys a DeleteObjects request takes
+func (c *s3Client) deleteKeys(ctx context.Context, keys []string) error {
+	type object struct {
+		Key string `xml:"Key"`
+	}
+	type deleteRequest struct {
+		XMLName xml.Name `xml:"Delete"`
+		Quiet   bool     `xml:"Quiet"`
+		Objects []object `xml:"Object"`
+	}
+	type deleteResult struct {
+		Errors []struct {
+			Key     string `xml:"Key"`
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		} `xml:"Error"`
+	}
+
+	for start := 0; start < len(keys); start += 1000 {
+		end := start + 1000
+		if end > len(keys) {
+			end = len(keys)
+		}
+		req := deleteRequest{Quiet: true}
+		for _, k := range keys[start:end] {
+			req.Objects = append(req.Objects, object{Key: k})
+		}
+		payload, err := xml.Marshal(req)
+		if err != nil {
+			return err
+		}
+		sum := md5.Sum(payload)
+		header := http.Header{}
+		header.Set("Content-Type", "application/xml")
+		header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+
+		body, err := c.do(ctx, http.MethodPost, "", url.Values{"delete": {""}}, header, payload)
+		if err != nil {
+			return fmt.Errorf("delete objects: %w", err)
+		}
+		var res deleteResult
+		if err := xml.Unmarshal(body, &res); err != nil {
+			return fmt.Errorf("parse delete result: %w", err)
+		}
+		if len(res.Errors) > 0 {
+			e := res.Errors[0]
+			return fmt.Errorf("delete %s: %s: %s (%d keys failed)", e.Key, e.Code, e.Message, len(res.Errors))
+		}
+	}
+	return nil
+}
+
+// do sends one signed request for key (empty for the bucket itself) and returns the
+// response body, or an *s3Error for a non-2xx status
+func (c *s3Client) do(ctx context.Context, method, key string, query url.Values, header http.Header, payload []byte) ([]byte, error) {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = strings.TrimSuffix(c.endpoint.EscapedPath(), "/") + "/" + uriEncode(c.bucket, false)
+	if key != "" {
+		u.RawPath += "/" + uriEncode(key, false)
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.ContentLength = int64(len(payload))
+	c.sign(req, u.RawPath, payload, time.Now().UTC())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		se := &s3Error{Status: resp.StatusCode}
+		_ = xml.Unmarshal(body, se)
+		return nil, se
+	}
+	return body, nil
+}
+
+// sign adds the Signature Version 4 headers to req. Every header set on the request
+// is signed, which covers the x-amz-* headers S3 requires to be.
+func (c *s3Client) sign(req *http.Request, escapedPath string, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, vs := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(vs, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapedPath,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), day)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by name, as SigV4 expects them
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(query))
+	for k := range query {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, k := range names {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and slashes unless
+// encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
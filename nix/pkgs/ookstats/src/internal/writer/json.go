@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 )
 
 // WriteJSONFile writes a value as JSON to a file atomically.
@@ -101,6 +103,65 @@ func WriteJSONFileCompact(path string, v any) error {
 	return nil
 }
 
+// WriteJSONFileStable writes like WriteJSONFile unless path already holds the same
+// JSON apart from the volatile fields, given as dotted key paths such as
+// "metadata.last_updated". A timestamp field then says when the content last
+// changed, and a regenerated file whose data didn't change keeps its bytes.
+func WriteJSONFileStable(path string, v any, volatile ...string) error {
+	if sameJSONExcept(path, v, volatile) {
+		return nil
+	}
+	return WriteJSONFile(path, v)
+}
+
+// WriteJSONFileCompactStable is WriteJSONFileStable for compact JSON
+func WriteJSONFileCompactStable(path string, v any, volatile ...string) error {
+	if sameJSONExcept(path, v, volatile) {
+		return nil
+	}
+	return WriteJSONFileCompact(path, v)
+}
+
+// sameJSONExcept reports whether the file at path decodes to the same JSON as v once
+// the volatile fields are dropped from both; a missing or unreadable file never is
+func sameJSONExcept(path string, v any, volatile []string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var old any
+	if err := json.Unmarshal(data, &old); err != nil {
+		return false
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	var cur any
+	if err := json.Unmarshal(encoded, &cur); err != nil {
+		return false
+	}
+	for _, field := range volatile {
+		keys := strings.Split(field, ".")
+		dropKey(old, keys)
+		dropKey(cur, keys)
+	}
+	return reflect.DeepEqual(old, cur)
+}
+
+// dropKey deletes the key path from decoded JSON objects
+func dropKey(v any, keys []string) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	if len(keys) == 1 {
+		delete(obj, keys[0])
+		return
+	}
+	dropKey(obj[keys[0]], keys[1:])
+}
+
 // EnsureDir creates a directory if it doesn't exist
 func EnsureDir(path string) error {
 	return os.MkdirAll(path, 0o755)
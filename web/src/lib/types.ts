@@ -235,6 +235,13 @@ export interface DungeonCoverage {
   periods: number[];
   missing_periods: number[];
   error_periods: number[];
+  // periods whose leaderboard hit the entry cap; slower runs may be missing
+  truncated_periods?: TruncatedPeriod[];
+}
+
+export interface TruncatedPeriod {
+  period: number;
+  cutoff_duration: number;
 }
 
 export interface RealmCoverage {
@@ -245,6 +252,7 @@ export interface RealmCoverage {
   total_periods: number;
   missing_periods: number;
   error_periods: number;
+  truncated_periods?: number;
   dungeons: DungeonCoverage[];
 }
 